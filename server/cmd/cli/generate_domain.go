@@ -85,7 +85,7 @@ type %sService struct {
 	repository %sRepository
 }
 
-func New%sService(repo *%sRepository) %sService {
+func New%sService(repo %sRepository) %sService {
 	return &%sService{repository: repo}
 }
 
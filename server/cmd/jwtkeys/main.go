@@ -0,0 +1,134 @@
+// Command jwtkeys manages the key ring access tokens are signed with.
+//
+//	go run ./cmd/jwtkeys rotate [-alg EdDSA|RS256]   generate a key and sign with it from now on
+//	go run ./cmd/jwtkeys generate [-alg EdDSA|RS256] generate a key that is published but not used yet
+//	go run ./cmd/jwtkeys activate <kid>              sign with a generated key from now on
+//	go run ./cmd/jwtkeys list                        list the keys of the ring
+//	go run ./cmd/jwtkeys prune [-grace 1h]           delete keys retired for longer than grace
+//
+// The key directory is JWT_KEYS_DIR, keys/jwt by default. Running servers pick up changes within a minute.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"resq/internal/infra/jwtkeys"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		dir = "keys/jwt"
+	}
+
+	command, args := os.Args[1], os.Args[2:]
+	var err error
+	switch command {
+	case "rotate":
+		err = generate(dir, args, true)
+	case "generate":
+		err = generate(dir, args, false)
+	case "activate":
+		if len(args) != 1 {
+			usage()
+			os.Exit(2)
+		}
+		err = jwtkeys.Activate(dir, args[0])
+		if err == nil {
+			fmt.Printf("Activated %s\n", args[0])
+		}
+	case "list":
+		err = list(dir)
+	case "prune":
+		err = prune(dir, args)
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "jwtkeys %s: %v\n", command, err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: jwtkeys rotate|generate [-alg EdDSA|RS256] | activate <kid> | list | prune [-grace 1h]")
+}
+
+func generate(dir string, args []string, activate bool) error {
+	flags := flag.NewFlagSet("generate", flag.ExitOnError)
+	algorithm := flags.String("alg", jwtkeys.AlgorithmEdDSA, "signing algorithm, EdDSA or RS256")
+	flags.Parse(args)
+
+	kid, err := jwtkeys.GenerateKey(dir, *algorithm)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Generated %s key %s in %s\n", *algorithm, kid, dir)
+
+	if !activate {
+		fmt.Printf("Activate it with `go run ./cmd/jwtkeys activate %s` once verifiers have fetched the new JWKS\n", kid)
+		return nil
+	}
+
+	if err := jwtkeys.Activate(dir, kid); err != nil {
+		return err
+	}
+	fmt.Printf("Activated %s\n", kid)
+	return nil
+}
+
+func list(dir string) error {
+	activeKid, activatedAt, _ := jwtkeys.ActiveKid(dir)
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return err
+	}
+	if len(matches) == 0 {
+		fmt.Printf("No keys in %s\n", dir)
+		return nil
+	}
+
+	for _, match := range matches {
+		kid := strings.TrimSuffix(filepath.Base(match), ".pem")
+		switch {
+		case kid == activeKid:
+			fmt.Printf("%s  active since %s\n", kid, activatedAt.Format(time.RFC3339))
+		case kid > activeKid:
+			fmt.Printf("%s  pending\n", kid)
+		default:
+			fmt.Printf("%s  retired\n", kid)
+		}
+	}
+	return nil
+}
+
+func prune(dir string, args []string) error {
+	flags := flag.NewFlagSet("prune", flag.ExitOnError)
+	grace := flags.Duration("grace", time.Hour, "how long the active key must have been signing before older keys go")
+	flags.Parse(args)
+
+	removed, err := jwtkeys.Prune(dir, *grace)
+	for _, kid := range removed {
+		fmt.Printf("Removed %s\n", kid)
+	}
+	if err == nil && len(removed) == 0 {
+		fmt.Println("Nothing to prune")
+	}
+	return err
+}
@@ -1,10 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
 	"resq/config"
 	"resq/internal/infra/logger"
+	"syscall"
+	"time"
 )
 
+// shutdownTimeout is how long requests in flight get to finish once the process is asked to stop
+const shutdownTimeout = 15 * time.Second
+
 
 func main() {
 	logger.InitLogger("logs/app.log")
@@ -15,5 +26,33 @@ func main() {
 	router := config.Router
 	router.HandleMethodNotAllowed = true
 	port := config.GetEnv("PORT", ":8000")
-	router.Run(port)
+
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	background, cancel := context.WithCancel(context.Background())
+	config.Start(background)
+
+	server := &http.Server{Addr: port, Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("server stopped: %v", err))
+			stop()
+		}
+	}()
+
+	<-signals.Done()
+	logger.GlobalLogger.Log(logger.INFO, "Shutting down")
+
+	shutdown, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancelShutdown()
+	// open streams never finish on their own, whatever is left after the timeout is cut off
+	if err := server.Shutdown(shutdown); err != nil {
+		server.Close()
+	}
+
+	// running jobs and handlers see their context cancelled and hand unfinished work back
+	cancel()
+	config.Wait()
+	logger.GlobalLogger.Log(logger.INFO, "App stopped")
 }
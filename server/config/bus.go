@@ -0,0 +1,54 @@
+package config
+
+import (
+	"context"
+	"resq/internal/infra"
+	"resq/internal/infra/jobs"
+	"resq/internal/infra/logger"
+	"time"
+)
+
+// OutboxRelayInterval is how long a committed domain event waits at most before it is on the bus
+const OutboxRelayInterval = time.Second
+
+// InitBus sets up the event bus before the domains publish on it or subscribe to it.
+// EVENT_BUS picks postgres, which survives restarts and is shared between instances, or memory.
+func InitBus() {
+	driver := GetEnv("EVENT_BUS", "postgres")
+	if driver == "postgres" && DB == nil {
+		logger.GlobalLogger.Log(logger.ERROR, "Postgres event bus needs a database connection, falling back to memory")
+		driver = "memory"
+	}
+
+	switch driver {
+	case "postgres":
+		bus := infra.NewPostgresBus(DB, infra.DefaultRetryPolicy)
+		schedule("event-bus-retention", "@hourly", bus.Prune)
+		infra.GlobalBus = bus
+	default:
+		if driver != "memory" {
+			logger.GlobalLogger.Log(logger.ERROR, "Unknown event bus, falling back to memory", map[string]interface{}{
+				"driver": driver,
+			})
+			driver = "memory"
+		}
+		infra.GlobalBus = infra.NewMemoryBus(infra.DefaultRetryPolicy)
+	}
+
+	logger.GlobalLogger.Log(logger.INFO, "Event bus initialized", map[string]interface{}{
+		"driver": driver,
+	})
+
+	// domain events are written to the outbox with their changes, the relay forwards them to the bus
+	if DB != nil {
+		relay := infra.NewOutboxRelay(DB, infra.GlobalBus)
+		jobs.GlobalRunner.Every("outbox-relay", OutboxRelayInterval, relay.Drain)
+		schedule("outbox-retention", "@hourly", relay.Prune)
+	}
+}
+
+// StartBus starts delivering to the consumers the domains subscribed while their routes were set up
+func StartBus(ctx context.Context) {
+	infra.GlobalBus.Start(ctx)
+	logger.GlobalLogger.Log(logger.INFO, "Event bus started")
+}
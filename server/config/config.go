@@ -1,11 +1,34 @@
 package config
 
-import "os"
+import (
+	"context"
+	"resq/internal/infra"
+	"resq/internal/infra/jobs"
+)
 
 func LoadConfig() {
 	LoadEnv()
+	InitJWTKeys()
 	InitDB()
+	InitStorage()
+	InitMailer()
+	InitSMS()
+	InitPush()
+	InitJobs()
+	InitNotifier()
+	InitBus()
+	InitStream()
 	InitRouter()
 }
 
-var JWT_SECRET = []byte(os.Getenv("JWT_SECRET"))
+// Start runs the background work until ctx is done
+func Start(ctx context.Context) {
+	StartJobs(ctx)
+	StartBus(ctx)
+}
+
+// Wait blocks until the background work started by Start has stopped
+func Wait() {
+	jobs.GlobalRunner.Wait()
+	infra.GlobalBus.Wait()
+}
@@ -4,6 +4,17 @@ import (
 	"log"
 	"resq/internal/infra/logger"
 	"resq/pkg/models"
+	alertModels "resq/pkg/models/alert"
+	busModels "resq/pkg/models/bus"
+	checkinModels "resq/pkg/models/checkin"
+	contactModels "resq/pkg/models/contact"
+	feedModels "resq/pkg/models/feed"
+	jobModels "resq/pkg/models/job"
+	notificationModels "resq/pkg/models/notification"
+	outboxModels "resq/pkg/models/outbox"
+	reportModels "resq/pkg/models/report"
+	sosModels "resq/pkg/models/sos"
+	timerModels "resq/pkg/models/timer"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -28,7 +39,17 @@ func InitDB() {
 }
 
 func RunMigrations() {
-	err := DB.AutoMigrate(models.Models...)
+	migrations := append(append(models.Models, reportModels.Models...), contactModels.Models...)
+	migrations = append(migrations, sosModels.Models...)
+	migrations = append(migrations, checkinModels.Models...)
+	migrations = append(migrations, timerModels.Models...)
+	migrations = append(migrations, alertModels.Models...)
+	migrations = append(migrations, feedModels.Models...)
+	migrations = append(migrations, busModels.Models...)
+	migrations = append(migrations, outboxModels.Models...)
+	migrations = append(migrations, jobModels.Models...)
+	migrations = append(migrations, notificationModels.Models...)
+	err := DB.AutoMigrate(migrations...)
 
 	if err != nil {
 		logger.GlobalLogger.Log(logger.ERROR, "Failed to connect to database", map[string]interface{}{
@@ -37,5 +58,29 @@ func RunMigrations() {
 		return
 	}
 
+	dropStaleIndexes()
+
 	log.Println("Database migrations applied successfully")
+	SeedDatabase()
+}
+
+// staleIndexes were replaced by indexes with other definitions, AutoMigrate never drops an index by itself
+var staleIndexes = map[interface{}][]string{
+	&models.User{}: {"idx_users_email", "idx_users_email_unique", "idx_users_phone_unique"},
+}
+
+func dropStaleIndexes() {
+	for model, names := range staleIndexes {
+		for _, name := range names {
+			if !DB.Migrator().HasIndex(model, name) {
+				continue
+			}
+			if err := DB.Migrator().DropIndex(model, name); err != nil {
+				logger.GlobalLogger.Log(logger.ERROR, "Failed to drop stale index", map[string]interface{}{
+					"index": name,
+					"error": err.Error(),
+				})
+			}
+		}
+	}
 }
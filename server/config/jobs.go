@@ -0,0 +1,32 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"resq/internal/infra/jobs"
+	"resq/internal/infra/logger"
+)
+
+// InitJobs sets up the job runner before the domains register their jobs and schedules on it
+func InitJobs() {
+	jobs.GlobalRunner = jobs.NewRunner(DB)
+	schedule("jobs-retention", "@daily", jobs.GlobalRunner.Prune)
+}
+
+// StartJobs runs the registered jobs and schedules, jobs are stored in the database so nothing runs without one
+func StartJobs(ctx context.Context) {
+	if DB == nil {
+		logger.GlobalLogger.Log(logger.ERROR, "Job runner not started, there is no database connection")
+		return
+	}
+
+	jobs.GlobalRunner.Start(ctx)
+	logger.GlobalLogger.Log(logger.INFO, "Job runner started")
+}
+
+// schedule runs recurring infrastructure work on one instance at a time
+func schedule(name string, spec string, run func(ctx context.Context) error) {
+	if err := jobs.GlobalRunner.Cron(name, spec, run); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to schedule %s: %v", name, err))
+	}
+}
@@ -0,0 +1,29 @@
+package config
+
+import (
+	"log"
+	"resq/internal/infra/jwtkeys"
+	"resq/internal/infra/logger"
+)
+
+// InitJWTKeys loads the signing key ring, the server cannot issue or check tokens without one so it refuses to start
+func InitJWTKeys() {
+	dir := GetEnv("JWT_KEYS_DIR", "keys/jwt")
+
+	ring, err := jwtkeys.LoadKeyRing(dir)
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, "Failed to load jwt signing keys", map[string]interface{}{
+			"dir":   dir,
+			"error": err.Error(),
+		})
+		log.Fatalf("no usable jwt signing key in %s: %v", dir, err)
+	}
+
+	jwtkeys.GlobalKeyRing = ring
+	active, _ := ring.ActiveKey()
+	logger.GlobalLogger.Log(logger.INFO, "JWT signing keys loaded", map[string]interface{}{
+		"dir":        dir,
+		"keys":       len(ring.Keys()),
+		"active_kid": active.ID,
+	})
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/mailer"
+)
+
+func newMailer(driver string) (mailer.Mailer, error) {
+	switch driver {
+	case "console":
+		return mailer.NewConsoleMailer(), nil
+	case "file":
+		return mailer.NewFileMailer(GetEnv("MAIL_FILE", "logs/mail.log"))
+	case "smtp":
+		return mailer.NewSMTPMailer(mailer.SMTPConfig{
+			Host:     GetEnv("SMTP_HOST", ""),
+			Port:     GetEnv("SMTP_PORT", "587"),
+			Username: GetEnv("SMTP_USERNAME", ""),
+			Password: GetEnv("SMTP_PASSWORD", ""),
+			From:     GetEnv("MAIL_FROM", ""),
+		})
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", driver)
+	}
+}
+
+func InitMailer() {
+	driver := GetEnv("MAIL_DRIVER", "console")
+
+	m, err := newMailer(driver)
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, "Failed to initialize mailer, falling back to console", map[string]interface{}{
+			"driver": driver,
+			"error":  err.Error(),
+		})
+		return
+	}
+
+	mailer.GlobalMailer = m
+	logger.GlobalLogger.Log(logger.INFO, "Mailer initialized", map[string]interface{}{
+		"driver": driver,
+	})
+}
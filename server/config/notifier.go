@@ -0,0 +1,17 @@
+package config
+
+import (
+	"resq/internal/infra/jobs"
+	"resq/internal/infra/mailer"
+	"resq/internal/infra/notifier"
+	"resq/internal/infra/push"
+	"resq/internal/infra/sms"
+)
+
+// InitNotifier sets up the notification service on the configured channels, its deliveries run as jobs
+// and the receipts of push notifications are checked on a schedule
+func InitNotifier() {
+	notifier.GlobalNotifier = notifier.NewNotificationService(DB, mailer.GlobalMailer, sms.GlobalProvider, push.GlobalProvider)
+	jobs.GlobalRunner.Register(notifier.DeliveryJob, notifier.GlobalNotifier.Deliver, notifier.DeliveryOptions)
+	schedule("push-receipts", notifier.ReceiptSchedule, notifier.GlobalNotifier.CheckReceipts)
+}
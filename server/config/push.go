@@ -0,0 +1,38 @@
+package config
+
+import (
+	"fmt"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/push"
+)
+
+func newPushProvider(driver string) (push.Provider, error) {
+	switch driver {
+	case "fake":
+		return push.NewFakeProvider(), nil
+	case "expo":
+		return push.NewExpoProvider(push.ExpoConfig{
+			AccessToken: GetEnv("EXPO_ACCESS_TOKEN", ""),
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown push driver %q", driver)
+	}
+}
+
+func InitPush() {
+	driver := GetEnv("PUSH_DRIVER", "fake")
+
+	provider, err := newPushProvider(driver)
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, "Failed to initialize push provider, falling back to fake", map[string]interface{}{
+			"driver": driver,
+			"error":  err.Error(),
+		})
+		return
+	}
+
+	push.GlobalProvider = provider
+	logger.GlobalLogger.Log(logger.INFO, "Push provider initialized", map[string]interface{}{
+		"driver": driver,
+	})
+}
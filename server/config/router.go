@@ -2,7 +2,18 @@ package config
 
 import (
 	"log"
+	"strings"
+	"resq/internal/domain/alert"
+	"resq/internal/domain/checkin"
+	"resq/internal/domain/contact"
+	"resq/internal/domain/feed"
+	"resq/internal/domain/notification"
+	"resq/internal/domain/report"
+	"resq/internal/domain/sos"
+	"resq/internal/domain/timer"
 	"resq/internal/domain/user"
+	"resq/internal/infra/jwtkeys"
+	"resq/internal/infra/middleware"
 	"github.com/gin-gonic/gin"
 )
 
@@ -10,8 +21,32 @@ var Router *gin.Engine
 
 func InitRouter() {
 	Router = gin.Default()
+	// client addresses feed the login throttle, so forwarded headers are only believed from known proxies
+	if err := Router.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
+	middleware.RevocationChecker = user.NewUserRepository(DB)
+	Router.GET("/.well-known/jwks.json", jwtkeys.GlobalKeyRing.ServeJWKS)
 	user.UserRoutes(Router, DB)
+	report.ReportRoutes(Router, DB)
+	contact.ContactRoutes(Router, DB)
+	sos.SOSRoutes(Router, DB)
+	checkin.CheckInRoutes(Router, DB)
+	timer.TimerRoutes(Router, DB)
+	alert.AlertRoutes(Router, DB)
+	feed.FeedRoutes(Router, DB)
+	notification.NotificationRoutes(Router, DB)
 	Router.RedirectTrailingSlash = true
 
 	log.Println("Router initialized")
 }
+
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(GetEnv("TRUSTED_PROXIES", ""), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
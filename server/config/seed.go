@@ -0,0 +1,57 @@
+package config
+
+import (
+	"resq/internal/infra/logger"
+	"resq/pkg/constants"
+	"resq/pkg/models"
+	reportModels "resq/pkg/models/report"
+	"strings"
+)
+
+var defaultReportCategories = []reportModels.ReportCategory{
+	{Title: "Fire", Description: "Building, vehicle or bush fires"},
+	{Title: "Flood", Description: "Flooding and water related emergencies"},
+	{Title: "Medical", Description: "Injuries and medical emergencies"},
+	{Title: "Accident", Description: "Road and other accidents"},
+	{Title: "Crime", Description: "Robbery, assault and other crimes in progress"},
+	{Title: "Other", Description: "Anything that does not fit another category"},
+	{Title: "SOS", Description: "Panic alerts raised from the SOS button", SystemKey: reportModels.SOSCategoryKey, Private: true},
+	{Title: "Need help", Description: "People who answered a check-in saying they need help", SystemKey: reportModels.NeedHelpCategoryKey, Private: true},
+}
+
+// SeedDatabase fills in the report categories on an empty database and promotes the bootstrap admin
+func SeedDatabase() {
+	if DB == nil {
+		return
+	}
+
+	var categoryCount int64
+	DB.Model(&reportModels.ReportCategory{}).Count(&categoryCount)
+	if categoryCount == 0 {
+		categories := append([]reportModels.ReportCategory{}, defaultReportCategories...)
+		if err := DB.Create(&categories).Error; err != nil {
+			logger.GlobalLogger.Log(logger.ERROR, "Failed to seed report categories", map[string]interface{}{
+				"error": err.Error(),
+			})
+		}
+	}
+
+	// the first admin cannot be promoted through the API, so it is named in the environment
+	adminEmail := strings.ToLower(strings.TrimSpace(GetEnv("BOOTSTRAP_ADMIN_EMAIL", "")))
+	if adminEmail == "" {
+		return
+	}
+
+	result := DB.Model(&models.User{}).Where("email = ?", adminEmail).Update("role", constants.RoleAdmin)
+	if result.Error != nil {
+		logger.GlobalLogger.Log(logger.ERROR, "Failed to promote bootstrap admin", map[string]interface{}{
+			"error": result.Error.Error(),
+		})
+		return
+	}
+	if result.RowsAffected > 0 {
+		logger.GlobalLogger.Log(logger.INFO, "Bootstrap admin promoted", map[string]interface{}{
+			"email": adminEmail,
+		})
+	}
+}
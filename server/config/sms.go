@@ -0,0 +1,40 @@
+package config
+
+import (
+	"fmt"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/sms"
+)
+
+func newSMSProvider(driver string) (sms.Provider, error) {
+	switch driver {
+	case "fake":
+		return sms.NewFakeProvider(), nil
+	case "twilio":
+		return sms.NewTwilioProvider(sms.TwilioConfig{
+			AccountSID: GetEnv("TWILIO_ACCOUNT_SID", ""),
+			AuthToken:  GetEnv("TWILIO_AUTH_TOKEN", ""),
+			From:       GetEnv("TWILIO_FROM", ""),
+		})
+	default:
+		return nil, fmt.Errorf("unknown sms driver %q", driver)
+	}
+}
+
+func InitSMS() {
+	driver := GetEnv("SMS_DRIVER", "fake")
+
+	provider, err := newSMSProvider(driver)
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, "Failed to initialize sms provider, falling back to fake", map[string]interface{}{
+			"driver": driver,
+			"error":  err.Error(),
+		})
+		return
+	}
+
+	sms.GlobalProvider = provider
+	logger.GlobalLogger.Log(logger.INFO, "SMS provider initialized", map[string]interface{}{
+		"driver": driver,
+	})
+}
@@ -0,0 +1,44 @@
+package config
+
+import (
+	"fmt"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/storage"
+)
+
+func newStorage(driver string) (storage.Storage, error) {
+	switch driver {
+	case "local":
+		return storage.NewLocalStorage(GetEnv("STORAGE_LOCAL_DIR", "uploads"))
+	case "s3":
+		return storage.NewS3Storage(storage.S3Config{
+			Endpoint:  GetEnv("S3_ENDPOINT", "https://s3.amazonaws.com"),
+			Region:    GetEnv("S3_REGION", "us-east-1"),
+			Bucket:    GetEnv("S3_BUCKET", ""),
+			AccessKey: GetEnv("S3_ACCESS_KEY", ""),
+			SecretKey: GetEnv("S3_SECRET_KEY", ""),
+			PathStyle: GetEnv("S3_PATH_STYLE", "true") == "true",
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", driver)
+	}
+}
+
+func InitStorage() {
+	driver := GetEnv("STORAGE_DRIVER", "local")
+
+	blobStorage, err := newStorage(driver)
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, "Failed to initialize storage", map[string]interface{}{
+			"driver": driver,
+			"error":  err.Error(),
+		})
+		return
+	}
+
+	storage.GlobalStorage = blobStorage
+	storage.UploadStagingDir = GetEnv("UPLOAD_STAGING_DIR", storage.UploadStagingDir)
+	logger.GlobalLogger.Log(logger.INFO, "Storage initialized", map[string]interface{}{
+		"driver": driver,
+	})
+}
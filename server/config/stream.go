@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"resq/internal/infra/jobs"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/stream"
+	"time"
+)
+
+// InitStream shares the events published to the stream hub with the other instances, without a database
+// the subscribers only get the events of their own instance
+func InitStream() {
+	if DB == nil {
+		logger.GlobalLogger.Log(logger.ERROR, "Stream events stay on this instance, there is no database connection")
+		return
+	}
+
+	fanout, err := stream.NewPostgresFanout(DB, stream.GlobalHub)
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to set up stream fanout: %v", err))
+		return
+	}
+	stream.GlobalHub.SetBroadcaster(fanout)
+	jobs.GlobalRunner.Every("stream-fanout-listen", time.Second, fanout.Listen)
+	jobs.GlobalRunner.Every("stream-fanout-send", time.Second, fanout.Send)
+
+	logger.GlobalLogger.Log(logger.INFO, "Stream fanout initialized")
+}
@@ -0,0 +1,91 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	alertModels "resq/pkg/models/alert"
+	"strings"
+	"time"
+)
+
+const atomNamespace = "http://www.w3.org/2005/Atom"
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomEntry struct {
+	ID        string         `xml:"id"`
+	Title     string         `xml:"title"`
+	Updated   string         `xml:"updated"`
+	Published string         `xml:"published"`
+	Author    atomAuthor     `xml:"author"`
+	Category  []atomCategory `xml:"category"`
+	Summary   string         `xml:"summary"`
+	Link      []atomLink     `xml:"link"`
+}
+
+type atomLink struct {
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+	Href string `xml:"href,attr"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+// encodeAtomFeed lists the alerts as an Atom feed whose entries link to their CAP documents under baseURL
+func encodeAtomFeed(alerts []alertModels.Alert, baseURL string, now time.Time) ([]byte, error) {
+	baseURL = strings.TrimRight(baseURL, "/")
+	feed := atomFeed{
+		Xmlns:   atomNamespace,
+		ID:      baseURL + "/alerts/feed",
+		Title:   "ResQ active alerts",
+		Updated: now.UTC().Format(time.RFC3339),
+		Link:    []atomLink{{Rel: "self", Type: "application/atom+xml", Href: baseURL + "/alerts/feed"}},
+		Entries: make([]atomEntry, len(alerts)),
+	}
+
+	for i := range alerts {
+		alert := &alerts[i]
+		author := "ResQ"
+		if alert.CAPSender != "" {
+			author = alert.CAPSender
+		} else if alert.PublishedBy.ID != 0 {
+			author = alert.PublishedBy.FirstName + " " + alert.PublishedBy.LastName
+		}
+
+		capURL := fmt.Sprintf("%s/alerts/%d/cap", baseURL, alert.ID)
+		feed.Entries[i] = atomEntry{
+			ID:        capURL,
+			Title:     alert.Headline,
+			Updated:   alert.UpdatedAt.UTC().Format(time.RFC3339),
+			Published: alert.EffectiveAt.UTC().Format(time.RFC3339),
+			Author:    atomAuthor{Name: author},
+			Category:  []atomCategory{{Term: string(alert.Severity)}},
+			Summary:   alert.Message,
+			Link:      []atomLink{{Rel: "alternate", Type: capContentType, Href: capURL}},
+		}
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buffer)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(feed); err != nil {
+		return nil, fmt.Errorf("unable to encode alert feed %w", err)
+	}
+	return buffer.Bytes(), nil
+}
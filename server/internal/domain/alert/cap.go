@@ -0,0 +1,369 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"resq/pkg/dto"
+	alertModels "resq/pkg/models/alert"
+	"resq/pkg/utils"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	capNamespace   = "urn:oasis:names:tc:emergency:cap:1.2"
+	capContentType = "application/cap+xml"
+	// capTimeLayout is the CAP date format without its offset, CAP does not allow the Z shorthand
+	// and writes UTC as -00:00, which Go never produces for an offset of zero
+	capTimeLayout = "2006-01-02T15:04:05"
+	// maxCAPAreas caps how many alerts a single CAP message turns into
+	maxCAPAreas = 50
+)
+
+var (
+	ErrInvalidCAP = errors.New("invalid CAP message")
+	ErrCAPExists  = errors.New("this CAP message was already imported")
+)
+
+// capAlert is a CAP 1.2 alert message, only the parts ResQ reads or writes are modelled
+type capAlert struct {
+	XMLName    xml.Name  `xml:"alert"`
+	Xmlns      string    `xml:"xmlns,attr,omitempty"`
+	Identifier string    `xml:"identifier"`
+	Sender     string    `xml:"sender"`
+	Sent       string    `xml:"sent"`
+	Status     string    `xml:"status"`
+	MsgType    string    `xml:"msgType"`
+	Source     string    `xml:"source,omitempty"`
+	Scope      string    `xml:"scope"`
+	Note       string    `xml:"note,omitempty"`
+	References string    `xml:"references,omitempty"`
+	Info       []capInfo `xml:"info"`
+}
+
+type capInfo struct {
+	Language    string    `xml:"language,omitempty"`
+	Category    []string  `xml:"category"`
+	Event       string    `xml:"event"`
+	Urgency     string    `xml:"urgency"`
+	Severity    string    `xml:"severity"`
+	Certainty   string    `xml:"certainty"`
+	Effective   string    `xml:"effective,omitempty"`
+	Onset       string    `xml:"onset,omitempty"`
+	Expires     string    `xml:"expires,omitempty"`
+	SenderName  string    `xml:"senderName,omitempty"`
+	Headline    string    `xml:"headline,omitempty"`
+	Description string    `xml:"description,omitempty"`
+	Instruction string    `xml:"instruction,omitempty"`
+	Web         string    `xml:"web,omitempty"`
+	Area        []capArea `xml:"area"`
+}
+
+type capArea struct {
+	AreaDesc string   `xml:"areaDesc"`
+	Polygon  []string `xml:"polygon"`
+	Circle   []string `xml:"circle"`
+}
+
+// capReference is one sender,identifier,sent triple of a references element
+type capReference struct {
+	Sender     string
+	Identifier string
+}
+
+var capSeverities = map[alertModels.AlertSeverity]string{
+	alertModels.AlertMinor:    "Minor",
+	alertModels.AlertModerate: "Moderate",
+	alertModels.AlertSevere:   "Severe",
+	alertModels.AlertExtreme:  "Extreme",
+}
+
+func formatCAPTime(t time.Time) string {
+	return t.UTC().Format(capTimeLayout) + "-00:00"
+}
+
+func parseCAPTime(field, value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%w: %s is not a CAP date", ErrInvalidCAP, field)
+	}
+	return t, nil
+}
+
+func capIdentifier(alertId uint) string {
+	return fmt.Sprintf("resq-alert-%d", alertId)
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+// encodeCAP writes the alert as a CAP 1.2 document sent by sender.
+// A cancelled alert is written as the Cancel message that withdraws the original.
+func encodeCAP(alert *alertModels.Alert, sender string) ([]byte, error) {
+	message := capAlert{
+		Xmlns:      capNamespace,
+		Identifier: capIdentifier(alert.ID),
+		Sender:     sender,
+		Sent:       formatCAPTime(alert.CreatedAt),
+		Status:     "Actual",
+		MsgType:    "Alert",
+		Source:     alert.CAPSender,
+		Scope:      "Public",
+	}
+	if alert.CancelledAt != nil {
+		message.Identifier += "-cancel"
+		message.Sent = formatCAPTime(*alert.CancelledAt)
+		message.MsgType = "Cancel"
+		message.References = strings.Join([]string{sender, capIdentifier(alert.ID), formatCAPTime(alert.CreatedAt)}, ",")
+	}
+
+	senderName := "ResQ"
+	if alert.PublishedBy.ID != 0 && alert.CAPSender == "" {
+		senderName = alert.PublishedBy.FirstName + " " + alert.PublishedBy.LastName
+	}
+
+	info := capInfo{
+		Language:    "en-US",
+		Category:    []string{orDefault(alert.Category, "Safety")},
+		Event:       orDefault(alert.Event, alert.Headline),
+		Urgency:     orDefault(alert.Urgency, "Unknown"),
+		Severity:    capSeverities[alert.Severity],
+		Certainty:   orDefault(alert.Certainty, "Unknown"),
+		Effective:   formatCAPTime(alert.EffectiveAt),
+		Expires:     formatCAPTime(alert.ExpiresAt),
+		SenderName:  senderName,
+		Headline:    alert.Headline,
+		Description: alert.Message,
+		Instruction: alert.Instruction,
+		Area:        []capArea{encodeCAPArea(alert)},
+	}
+	message.Info = []capInfo{info}
+
+	var buffer bytes.Buffer
+	buffer.WriteString(xml.Header)
+	encoder := xml.NewEncoder(&buffer)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(message); err != nil {
+		return nil, fmt.Errorf("unable to encode CAP message %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+func encodeCAPArea(alert *alertModels.Alert) capArea {
+	area := capArea{AreaDesc: alert.AreaDescription}
+
+	if alert.AreaType == alertModels.AlertAreaCircle {
+		if area.AreaDesc == "" {
+			area.AreaDesc = fmt.Sprintf("Within %s km of %s", formatCoordinate(*alert.RadiusKm), formatPoint(*alert.Latitude, *alert.Longitude))
+		}
+		area.Circle = []string{formatPoint(*alert.Latitude, *alert.Longitude) + " " + formatCoordinate(*alert.RadiusKm)}
+		return area
+	}
+
+	if area.AreaDesc == "" {
+		area.AreaDesc = "Alert area"
+	}
+	// CAP polygons are closed, the first point is repeated at the end
+	points := make([]string, 0, len(alert.Polygon)+1)
+	for _, point := range alert.Polygon {
+		points = append(points, formatPoint(point.Latitude, point.Longitude))
+	}
+	if first, last := alert.Polygon[0], alert.Polygon[len(alert.Polygon)-1]; first != last {
+		points = append(points, points[0])
+	}
+	area.Polygon = []string{strings.Join(points, " ")}
+	return area
+}
+
+func formatCoordinate(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatPoint(lat, lng float64) string {
+	return formatCoordinate(lat) + "," + formatCoordinate(lng)
+}
+
+// decodeCAP reads a CAP 1.2 message and checks the fields CAP requires
+func decodeCAP(data []byte) (*capAlert, error) {
+	var message capAlert
+	if err := xml.Unmarshal(data, &message); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCAP, err)
+	}
+	if message.XMLName.Space != capNamespace {
+		return nil, fmt.Errorf("%w: only CAP 1.2 (%s) is supported", ErrInvalidCAP, capNamespace)
+	}
+
+	var missing []string
+	for field, value := range map[string]string{
+		"identifier": message.Identifier,
+		"sender":     message.Sender,
+		"sent":       message.Sent,
+		"status":     message.Status,
+		"msgType":    message.MsgType,
+		"scope":      message.Scope,
+	} {
+		if strings.TrimSpace(value) == "" {
+			missing = append(missing, field)
+		}
+	}
+	for i, info := range message.Info {
+		for field, value := range map[string]string{
+			"category":  strings.Join(info.Category, ""),
+			"event":     info.Event,
+			"urgency":   info.Urgency,
+			"severity":  info.Severity,
+			"certainty": info.Certainty,
+		} {
+			if strings.TrimSpace(value) == "" {
+				missing = append(missing, fmt.Sprintf("info[%d].%s", i, field))
+			}
+		}
+		for j, area := range info.Area {
+			if strings.TrimSpace(area.AreaDesc) == "" {
+				missing = append(missing, fmt.Sprintf("info[%d].area[%d].areaDesc", i, j))
+			}
+		}
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("%w: missing %s", ErrInvalidCAP, strings.Join(missing, ", "))
+	}
+
+	if _, err := parseCAPTime("sent", message.Sent); err != nil {
+		return nil, err
+	}
+	return &message, nil
+}
+
+// references lists the messages an Update or Cancel refers to
+func (c *capAlert) references() []capReference {
+	var references []capReference
+	for _, field := range strings.Fields(c.References) {
+		parts := strings.Split(field, ",")
+		if len(parts) >= 2 {
+			references = append(references, capReference{Sender: parts[0], Identifier: parts[1]})
+		}
+	}
+	return references
+}
+
+// englishInfo picks the info block to import, the first English one or else the first one
+func (c *capAlert) englishInfo() *capInfo {
+	for i := range c.Info {
+		if language := strings.ToLower(c.Info[i].Language); language == "" || strings.HasPrefix(language, "en") {
+			return &c.Info[i]
+		}
+	}
+	return &c.Info[0]
+}
+
+// capSeverity maps a CAP severity to ours, Unknown is taken as minor
+func capSeverity(value string) (alertModels.AlertSeverity, error) {
+	switch value {
+	case "Extreme":
+		return alertModels.AlertExtreme, nil
+	case "Severe":
+		return alertModels.AlertSevere, nil
+	case "Moderate":
+		return alertModels.AlertModerate, nil
+	case "Minor", "Unknown":
+		return alertModels.AlertMinor, nil
+	}
+	return "", fmt.Errorf("%w: unknown severity %q", ErrInvalidCAP, value)
+}
+
+// capAreaRequests turns every circle and polygon of the info block into the area of an alert.
+// CAP areas given only as geocodes can not be geofenced and are rejected.
+func capAreaRequests(info *capInfo) ([]dto.AlertAreaRequestDTO, error) {
+	var areas []dto.AlertAreaRequestDTO
+	for _, area := range info.Area {
+		description := utils.Truncate(strings.TrimSpace(area.AreaDesc), 255)
+		for _, polygon := range area.Polygon {
+			points, err := parseCAPPolygon(polygon)
+			if err != nil {
+				return nil, err
+			}
+			areas = append(areas, dto.AlertAreaRequestDTO{Type: string(alertModels.AlertAreaPolygon), Description: description, Polygon: points})
+		}
+		for _, circle := range area.Circle {
+			lat, lng, radiusKm, err := parseCAPCircle(circle)
+			if err != nil {
+				return nil, err
+			}
+			areas = append(areas, dto.AlertAreaRequestDTO{
+				Type:        string(alertModels.AlertAreaCircle),
+				Description: description,
+				Latitude:    &lat,
+				Longitude:   &lng,
+				RadiusKm:    &radiusKm,
+			})
+		}
+	}
+
+	if len(areas) == 0 {
+		return nil, fmt.Errorf("%w: no area has a polygon or circle, geocode only areas are not supported", ErrInvalidCAP)
+	}
+	if len(areas) > maxCAPAreas {
+		return nil, fmt.Errorf("%w: more than %d polygons and circles", ErrInvalidCAP, maxCAPAreas)
+	}
+	return areas, nil
+}
+
+func parseCAPPoint(value string) (dto.AlertPointDTO, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return dto.AlertPointDTO{}, fmt.Errorf("%w: %q is not a latitude,longitude pair", ErrInvalidCAP, value)
+	}
+	lat, latErr := strconv.ParseFloat(parts[0], 64)
+	lng, lngErr := strconv.ParseFloat(parts[1], 64)
+	if latErr != nil || lngErr != nil || lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return dto.AlertPointDTO{}, fmt.Errorf("%w: %q is not a valid coordinate", ErrInvalidCAP, value)
+	}
+	return dto.AlertPointDTO{Latitude: lat, Longitude: lng}, nil
+}
+
+// parseCAPPolygon reads a closed CAP polygon and drops the repeated closing point
+func parseCAPPolygon(value string) ([]dto.AlertPointDTO, error) {
+	fields := strings.Fields(value)
+	if len(fields) < 4 {
+		return nil, fmt.Errorf("%w: a polygon needs at least four points", ErrInvalidCAP)
+	}
+
+	points := make([]dto.AlertPointDTO, len(fields))
+	for i, field := range fields {
+		point, err := parseCAPPoint(field)
+		if err != nil {
+			return nil, err
+		}
+		points[i] = point
+	}
+	if points[0] != points[len(points)-1] {
+		return nil, fmt.Errorf("%w: a polygon has to end on its first point", ErrInvalidCAP)
+	}
+	return points[:len(points)-1], nil
+}
+
+// parseCAPCircle reads a CAP circle, a centre point followed by a radius in kilometres
+func parseCAPCircle(value string) (float64, float64, float64, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 2 {
+		return 0, 0, 0, fmt.Errorf("%w: %q is not a CAP circle", ErrInvalidCAP, value)
+	}
+	centre, err := parseCAPPoint(fields[0])
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	radiusKm, err := strconv.ParseFloat(fields[1], 64)
+	if err != nil || radiusKm <= 0 || radiusKm > 500 {
+		return 0, 0, 0, fmt.Errorf("%w: circle radius has to be between 0 and 500 km", ErrInvalidCAP)
+	}
+	return centre.Latitude, centre.Longitude, radiusKm, nil
+}
@@ -0,0 +1,249 @@
+package alert
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"resq/pkg/constants"
+	"resq/pkg/dto"
+	"resq/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AlertController interface {
+	PublishAlert(ctx *gin.Context)
+	GetAlerts(ctx *gin.Context)
+	GetAlert(ctx *gin.Context)
+	GetActiveAlerts(ctx *gin.Context)
+	GetReceivedAlerts(ctx *gin.Context)
+	MarkAlertRead(ctx *gin.Context)
+	CancelAlert(ctx *gin.Context)
+	GetDeliveries(ctx *gin.Context)
+	GetAlertCAP(ctx *gin.Context)
+	GetAlertFeed(ctx *gin.Context)
+	ImportCAP(ctx *gin.Context)
+}
+
+// maxCAPSize bounds an uploaded CAP message, real ones are a few kilobytes
+const maxCAPSize = 1 << 20
+
+type alertController struct {
+	service AlertService
+}
+
+func NewAlertController(service AlertService) AlertController {
+	return &alertController{service: service}
+}
+
+func alertErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrAlertNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrAlertCancelled), errors.Is(err, ErrCAPExists):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func (a *alertController) PublishAlert(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var request dto.PublishAlertRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	result, err := a.service.PublishAlert(userId, &request)
+	if err != nil {
+		ctx.JSON(alertErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{constants.RequestData: result})
+}
+
+func (a *alertController) GetAlerts(ctx *gin.Context) {
+	result, err := a.service.GetAlerts()
+	if err != nil {
+		ctx.JSON(alertErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (a *alertController) GetAlert(ctx *gin.Context) {
+	alertId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid alert id"})
+		return
+	}
+
+	result, err := a.service.GetAlert(alertId)
+	if err != nil {
+		ctx.JSON(alertErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (a *alertController) GetActiveAlerts(ctx *gin.Context) {
+	var query dto.ActiveAlertsQueryDTO
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, query)})
+		return
+	}
+
+	result, err := a.service.GetActiveAlerts(*query.Latitude, *query.Longitude)
+	if err != nil {
+		ctx.JSON(alertErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (a *alertController) GetReceivedAlerts(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	result, err := a.service.GetReceivedAlerts(userId)
+	if err != nil {
+		ctx.JSON(alertErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (a *alertController) MarkAlertRead(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	alertId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid alert id"})
+		return
+	}
+
+	if err := a.service.MarkAlertRead(userId, alertId); err != nil {
+		ctx.JSON(alertErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: "alert marked as read"})
+}
+
+func (a *alertController) CancelAlert(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	alertId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid alert id"})
+		return
+	}
+
+	result, err := a.service.CancelAlert(userId, alertId)
+	if err != nil {
+		ctx.JSON(alertErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (a *alertController) GetDeliveries(ctx *gin.Context) {
+	alertId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid alert id"})
+		return
+	}
+
+	result, err := a.service.GetDeliveries(alertId)
+	if err != nil {
+		ctx.JSON(alertErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (a *alertController) GetAlertCAP(ctx *gin.Context) {
+	alertId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid alert id"})
+		return
+	}
+
+	result, err := a.service.GetAlertCAP(alertId)
+	if err != nil {
+		ctx.JSON(alertErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Data(http.StatusOK, capContentType+"; charset=utf-8", result)
+}
+
+func (a *alertController) GetAlertFeed(ctx *gin.Context) {
+	result, err := a.service.GetAlertFeed(baseURL(ctx))
+	if err != nil {
+		ctx.JSON(alertErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Header("Cache-Control", "public, max-age=60")
+	ctx.Data(http.StatusOK, "application/atom+xml; charset=utf-8", result)
+}
+
+// baseURL is the configured public address of the API, or the one the request came in on
+func baseURL(ctx *gin.Context) string {
+	if url := constants.APIBaseURL(); url != "" {
+		return url
+	}
+	scheme := "http"
+	if ctx.Request.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + ctx.Request.Host
+}
+
+// ImportCAP takes a raw CAP 1.2 XML document as the request body
+func (a *alertController) ImportCAP(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(ctx.Writer, ctx.Request.Body, maxCAPSize))
+	if err != nil {
+		ctx.JSON(http.StatusRequestEntityTooLarge, gin.H{constants.RequestError: "CAP message is too large"})
+		return
+	}
+
+	result, err := a.service.ImportCAP(userId, data)
+	if err != nil {
+		ctx.JSON(alertErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{constants.RequestData: result})
+}
@@ -0,0 +1,344 @@
+package alert
+
+import (
+	"fmt"
+	"resq/internal/infra/jobs"
+	"resq/pkg/models"
+	alertModels "resq/pkg/models/alert"
+	feedModels "resq/pkg/models/feed"
+	"resq/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// deliveryBatchSize keeps the insert of a large audience under the Postgres parameter limit
+const deliveryBatchSize = 1000
+
+type AlertRepository interface {
+	CreateAlert(alert *alertModels.Alert) error
+	FindAlertById(alertId uint) (*alertModels.Alert, error)
+	FindAlerts(limit int) ([]alertModels.Alert, error)
+	FindActiveAlertsAround(lat, lng float64, now time.Time) ([]alertModels.Alert, error)
+	FindActiveAlerts(now time.Time, limit int) ([]alertModels.Alert, error)
+	FindCAPAlerts(sender string, identifier string) ([]alertModels.Alert, error)
+	ImportAlerts(alerts []*alertModels.Alert, supersededIds []uint, actorId uint) error
+	CancelAlert(alertId uint, actorId uint) error
+	FindUndispatchedAlerts(effectiveBefore time.Time, now time.Time, limit int) ([]alertModels.Alert, error)
+	FindUserLocationsInBox(box utils.BoundingBox, locatedSince time.Time) ([]models.UserLocation, error)
+	FindSavedPlacesInBox(box utils.BoundingBox) ([]models.SavedPlace, error)
+	DispatchAlert(alert *alertModels.Alert, deliveries []alertModels.AlertDelivery) (bool, []feedModels.FeedEvent, error)
+	FindPendingDeliveries(alertId uint) ([]alertModels.AlertDelivery, error)
+	SendDelivery(delivery *alertModels.AlertDelivery, send func(tx *gorm.DB) error) error
+	FindDeliveries(alertId uint, limit int) ([]alertModels.AlertDelivery, error)
+	CountDeliveries(alertId uint) (map[alertModels.AlertDeliveryStatus]int64, int64, error)
+	FindUserDeliveries(userId uint, limit int) ([]alertModels.AlertDelivery, error)
+	MarkDeliveryRead(alertId uint, userId uint) error
+}
+
+type alertRepository struct {
+	db *gorm.DB
+}
+
+func NewAlertRepository(db *gorm.DB) AlertRepository {
+	return &alertRepository{db: db}
+}
+
+// CreateAlert stores the alert and queues its dispatch for when it comes into force
+func (a *alertRepository) CreateAlert(alert *alertModels.Alert) error {
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("PublishedBy").Create(alert).Error; err != nil {
+			return err
+		}
+		return jobs.EnqueueTx(tx, DispatchJob, &alertPayload{AlertID: alert.ID}, alert.EffectiveAt)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to create alert %w", err)
+	}
+	return nil
+}
+
+func (a *alertRepository) FindAlertById(alertId uint) (*alertModels.Alert, error) {
+	var alert alertModels.Alert
+	result := a.db.Preload("PublishedBy").Where("id = ?", alertId).First(&alert)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find alert: %w", result.Error)
+	}
+	return &alert, nil
+}
+
+func (a *alertRepository) FindAlerts(limit int) ([]alertModels.Alert, error) {
+	var alerts []alertModels.Alert
+	result := a.db.Preload("PublishedBy").Order("created_at desc").Limit(limit).Find(&alerts)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find alerts: %w", result.Error)
+	}
+	return alerts, nil
+}
+
+// FindActiveAlertsAround returns the alerts in force whose bounding box holds the point,
+// the caller still checks the exact shape
+func (a *alertRepository) FindActiveAlertsAround(lat, lng float64, now time.Time) ([]alertModels.Alert, error) {
+	var alerts []alertModels.Alert
+	result := a.db.Preload("PublishedBy").
+		Where("cancelled_at IS NULL AND effective_at <= ? AND expires_at > ?", now, now).
+		Where("? BETWEEN min_latitude AND max_latitude", lat).
+		Where("(min_longitude <= max_longitude AND ? BETWEEN min_longitude AND max_longitude) OR "+
+			"(min_longitude > max_longitude AND (? >= min_longitude OR ? <= max_longitude))", lng, lng, lng).
+		Order("created_at desc").
+		Find(&alerts)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find active alerts: %w", result.Error)
+	}
+	return alerts, nil
+}
+
+func (a *alertRepository) FindActiveAlerts(now time.Time, limit int) ([]alertModels.Alert, error) {
+	var alerts []alertModels.Alert
+	result := a.db.Preload("PublishedBy").
+		Where("cancelled_at IS NULL AND effective_at <= ? AND expires_at > ?", now, now).
+		Order("created_at desc").
+		Limit(limit).
+		Find(&alerts)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find active alerts: %w", result.Error)
+	}
+	return alerts, nil
+}
+
+// FindCAPAlerts returns the alerts imported from the CAP message identifier of sender
+func (a *alertRepository) FindCAPAlerts(sender string, identifier string) ([]alertModels.Alert, error) {
+	var alerts []alertModels.Alert
+	result := a.db.Where("cap_sender = ? AND cap_identifier = ?", sender, identifier).Order("id asc").Find(&alerts)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find imported alerts: %w", result.Error)
+	}
+	return alerts, nil
+}
+
+// ImportAlerts stores the alerts of a CAP message and cancels the ones it updates or cancels, all or nothing.
+// The dispatch of each stored alert is queued for when it comes into force.
+func (a *alertRepository) ImportAlerts(alerts []*alertModels.Alert, supersededIds []uint, actorId uint) error {
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		if len(supersededIds) > 0 {
+			if err := tx.Model(&alertModels.Alert{}).
+				Where("id IN ? AND cancelled_at IS NULL", supersededIds).
+				Updates(map[string]interface{}{"cancelled_at": time.Now(), "cancelled_by_id": actorId}).Error; err != nil {
+				return err
+			}
+		}
+		for _, alert := range alerts {
+			if err := tx.Omit("PublishedBy").Create(alert).Error; err != nil {
+				return err
+			}
+			if err := jobs.EnqueueTx(tx, DispatchJob, &alertPayload{AlertID: alert.ID}, alert.EffectiveAt); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to import alerts %w", err)
+	}
+	return nil
+}
+
+func (a *alertRepository) CancelAlert(alertId uint, actorId uint) error {
+	result := a.db.Model(&alertModels.Alert{}).
+		Where("id = ? AND cancelled_at IS NULL", alertId).
+		Updates(map[string]interface{}{"cancelled_at": time.Now(), "cancelled_by_id": actorId})
+	if result.Error != nil {
+		return fmt.Errorf("unable to cancel alert %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("unable to cancel alert: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// FindUndispatchedAlerts returns the alerts in force since effectiveBefore whose audience has not been worked out yet
+func (a *alertRepository) FindUndispatchedAlerts(effectiveBefore time.Time, now time.Time, limit int) ([]alertModels.Alert, error) {
+	var alerts []alertModels.Alert
+	result := a.db.Where("dispatched_at IS NULL AND cancelled_at IS NULL AND effective_at <= ? AND expires_at > ?", effectiveBefore, now).
+		Order("effective_at asc").
+		Limit(limit).
+		Find(&alerts)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find undispatched alerts: %w", result.Error)
+	}
+	return alerts, nil
+}
+
+// inBox narrows query to the rows of table whose coordinates fall inside box
+func inBox(query *gorm.DB, table string, box utils.BoundingBox) *gorm.DB {
+	query = query.Where(table+".latitude BETWEEN ? AND ?", box.MinLatitude, box.MaxLatitude)
+	if box.CrossesAntimeridian() {
+		return query.Where("("+table+".longitude >= ? OR "+table+".longitude <= ?)", box.MinLongitude, box.MaxLongitude)
+	}
+	return query.Where(table+".longitude BETWEEN ? AND ?", box.MinLongitude, box.MaxLongitude)
+}
+
+func (a *alertRepository) FindUserLocationsInBox(box utils.BoundingBox, locatedSince time.Time) ([]models.UserLocation, error) {
+	var locations []models.UserLocation
+	query := a.db.Model(&models.UserLocation{}).
+		Joins("JOIN users ON users.id = user_locations.user_id AND users.deleted_at IS NULL").
+		Where("user_locations.recorded_at >= ?", locatedSince)
+	result := inBox(query, "user_locations", box).Find(&locations)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find user locations: %w", result.Error)
+	}
+	return locations, nil
+}
+
+func (a *alertRepository) FindSavedPlacesInBox(box utils.BoundingBox) ([]models.SavedPlace, error) {
+	var places []models.SavedPlace
+	result := inBox(a.db.Model(&models.SavedPlace{}), "saved_places", box).Find(&places)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find saved places: %w", result.Error)
+	}
+	return places, nil
+}
+
+// DispatchAlert marks the alert as dispatched and records who it is for, false means someone else already did.
+// The alert goes into the feed of every recipient and the job sending it is queued in the same transaction,
+// the stored events are returned for publishing.
+func (a *alertRepository) DispatchAlert(alert *alertModels.Alert, deliveries []alertModels.AlertDelivery) (bool, []feedModels.FeedEvent, error) {
+	claimed := false
+	var events []feedModels.FeedEvent
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&alertModels.Alert{}).
+			Where("id = ? AND dispatched_at IS NULL", alert.ID).
+			Update("dispatched_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 || len(deliveries) == 0 {
+			claimed = result.RowsAffected > 0
+			return nil
+		}
+		claimed = true
+
+		// a user already recorded for the alert is left as is
+		if err := tx.Omit("Alert", "User").
+			Clauses(clause.OnConflict{DoNothing: true}).
+			CreateInBatches(&deliveries, deliveryBatchSize).Error; err != nil {
+			return err
+		}
+
+		userIds := make([]uint, len(deliveries))
+		for i := range deliveries {
+			userIds[i] = deliveries[i].UserID
+		}
+		events = feedModels.NewFeedEvents(userIds, feedModels.FeedAlert, nil, &alert.ID, alert.ToDTO())
+		if err := tx.CreateInBatches(&events, deliveryBatchSize).Error; err != nil {
+			return err
+		}
+		return jobs.EnqueueTx(tx, SendJob, &alertPayload{AlertID: alert.ID}, time.Now())
+	})
+	if err != nil {
+		return false, nil, fmt.Errorf("unable to dispatch alert %w", err)
+	}
+	return claimed, events, nil
+}
+
+func (a *alertRepository) FindPendingDeliveries(alertId uint) ([]alertModels.AlertDelivery, error) {
+	var deliveries []alertModels.AlertDelivery
+	result := a.db.Preload("User").
+		Where("alert_id = ? AND status = ?", alertId, alertModels.AlertDeliveryPending).
+		Order("id asc").
+		Find(&deliveries)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find alert deliveries: %w", result.Error)
+	}
+	return deliveries, nil
+}
+
+// SendDelivery runs send for a pending delivery and records the outcome send left on it in the same transaction,
+// a delivery another run already handled is left alone
+func (a *alertRepository) SendDelivery(delivery *alertModels.AlertDelivery, send func(tx *gorm.DB) error) error {
+	err := a.db.Transaction(func(tx *gorm.DB) error {
+		var current alertModels.AlertDelivery
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("id = ? AND status = ?", delivery.ID, alertModels.AlertDeliveryPending).
+			Limit(1).
+			Find(&current)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		if err := send(tx); err != nil {
+			return err
+		}
+		return tx.Model(delivery).Updates(map[string]interface{}{
+			"channel": delivery.Channel,
+			"status":  delivery.Status,
+			"error":   delivery.Error,
+			"sent_at": delivery.SentAt,
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("unable to send alert delivery %w", err)
+	}
+	return nil
+}
+
+func (a *alertRepository) FindDeliveries(alertId uint, limit int) ([]alertModels.AlertDelivery, error) {
+	var deliveries []alertModels.AlertDelivery
+	result := a.db.Where("alert_id = ?", alertId).Order("id asc").Limit(limit).Find(&deliveries)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find alert deliveries: %w", result.Error)
+	}
+	return deliveries, nil
+}
+
+// CountDeliveries returns how many deliveries of the alert are in each status and how many were read
+func (a *alertRepository) CountDeliveries(alertId uint) (map[alertModels.AlertDeliveryStatus]int64, int64, error) {
+	var rows []struct {
+		Status alertModels.AlertDeliveryStatus
+		Count  int64
+		Read   int64
+	}
+	result := a.db.Model(&alertModels.AlertDelivery{}).
+		Select("status, count(*) AS count, count(read_at) AS read").
+		Where("alert_id = ?", alertId).
+		Group("status").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, 0, fmt.Errorf("unable to count alert deliveries: %w", result.Error)
+	}
+
+	counts := map[alertModels.AlertDeliveryStatus]int64{}
+	var read int64
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+		read += row.Read
+	}
+	return counts, read, nil
+}
+
+func (a *alertRepository) FindUserDeliveries(userId uint, limit int) ([]alertModels.AlertDelivery, error) {
+	var deliveries []alertModels.AlertDelivery
+	result := a.db.Preload("Alert").Preload("Alert.PublishedBy").
+		Where("user_id = ?", userId).
+		Order("created_at desc").
+		Limit(limit).
+		Find(&deliveries)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find alert deliveries: %w", result.Error)
+	}
+	return deliveries, nil
+}
+
+func (a *alertRepository) MarkDeliveryRead(alertId uint, userId uint) error {
+	result := a.db.Model(&alertModels.AlertDelivery{}).
+		Where("alert_id = ? AND user_id = ?", alertId, userId).
+		Update("read_at", gorm.Expr("COALESCE(read_at, ?)", time.Now()))
+	if result.Error != nil {
+		return fmt.Errorf("unable to mark alert read %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("unable to mark alert read: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
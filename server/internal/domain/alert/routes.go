@@ -0,0 +1,46 @@
+package alert
+
+import (
+	"fmt"
+	"resq/internal/infra/jobs"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/middleware"
+	"resq/internal/infra/notifier"
+	"resq/internal/infra/stream"
+	"resq/pkg/constants"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func AlertRoutes(router *gin.Engine, db *gorm.DB) {
+	alertRepository := NewAlertRepository(db)
+	alertService := NewAlertService(alertRepository, stream.GlobalHub, notifier.GlobalNotifier)
+	alertController := NewAlertController(alertService)
+
+	jobs.GlobalRunner.Register(DispatchJob, alertService.DispatchAlert, DispatchOptions)
+	jobs.GlobalRunner.Register(SendJob, alertService.SendAlert, SendOptions)
+	if err := jobs.GlobalRunner.Cron("alert-dispatch", DispatchSchedule, alertService.DispatchDueAlerts); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to schedule alert dispatch: %v", err))
+	}
+
+	alerts := router.Group("alerts")
+
+	{
+		alerts.GET("/active", alertController.GetActiveAlerts)
+		alerts.GET("/feed", alertController.GetAlertFeed)
+		alerts.GET("/:id/cap", alertController.GetAlertCAP)
+
+		alerts.Use(middleware.AuthMiddleware())
+		{
+			alerts.GET("/received", alertController.GetReceivedAlerts)
+			alerts.POST("/:id/read", alertController.MarkAlertRead)
+			alerts.GET("/:id", alertController.GetAlert)
+			alerts.POST("", middleware.RequirePermission(constants.PermissionAlertsPublish), alertController.PublishAlert)
+			alerts.POST("/cap", middleware.RequirePermission(constants.PermissionAlertsPublish), alertController.ImportCAP)
+			alerts.GET("", middleware.RequirePermission(constants.PermissionAlertsPublish), alertController.GetAlerts)
+			alerts.POST("/:id/cancel", middleware.RequirePermission(constants.PermissionAlertsPublish), alertController.CancelAlert)
+			alerts.GET("/:id/deliveries", middleware.RequirePermission(constants.PermissionAlertsPublish), alertController.GetDeliveries)
+		}
+	}
+}
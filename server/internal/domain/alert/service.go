@@ -0,0 +1,662 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"resq/internal/infra"
+	"resq/internal/infra/jobs"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/notifier"
+	"resq/internal/infra/stream"
+	"resq/pkg/constants"
+	"resq/pkg/dto"
+	alertModels "resq/pkg/models/alert"
+	"resq/pkg/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxAlertDuration caps how long an alert stays in force, a longer emergency is published again
+	MaxAlertDuration = 30 * 24 * time.Hour
+	// LocationMaxAge leaves users out when their phone has not reported for this long, their saved places still count
+	LocationMaxAge = 24 * time.Hour
+	// DispatchSchedule is when the job runner looks for alerts in force that were never dispatched
+	DispatchSchedule = "* * * * *"
+	// DispatchJob works out the audience of one alert, SendJob sends an alert to its pending deliveries
+	DispatchJob = "alert-audience"
+	SendJob     = "alert-send"
+	// dispatchGrace leaves an alert that just came into force to its own DispatchJob
+	dispatchGrace = 5 * time.Minute
+	listLimit     = 100
+	feedLimit     = 200
+	deliveryLimit = 1000
+	dispatchBatch = 20
+)
+
+var (
+	ErrAlertNotFound  = errors.New("alert not found")
+	ErrAlertCancelled = errors.New("alert is already cancelled")
+	ErrInvalidArea    = errors.New("a circle needs latitude, longitude and radius_km, a polygon at least three points")
+	ErrInvalidExpiry  = errors.New("expires_at must be after effective_at, in the future and at most 30 days away")
+)
+
+// DispatchOptions retries working out an audience for a few minutes, the dispatch schedule picks up an alert after that
+var DispatchOptions = jobs.Options{
+	Concurrency: 2,
+	Timeout:     5 * time.Minute,
+	Retry: infra.RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 10 * time.Second,
+		MaxBackoff:     time.Minute,
+	},
+}
+
+// SendOptions retries the deliveries of an alert for about half an hour before the ones left are marked failed
+var SendOptions = jobs.Options{
+	Concurrency: 2,
+	Timeout:     10 * time.Minute,
+	Retry: infra.RetryPolicy{
+		MaxAttempts:    6,
+		InitialBackoff: 30 * time.Second,
+		MaxBackoff:     10 * time.Minute,
+	},
+}
+
+type alertPayload struct {
+	AlertID uint `json:"alert_id"`
+}
+
+type AlertService interface {
+	PublishAlert(actorId uint, request *dto.PublishAlertRequestDTO) (*dto.AlertDTO, error)
+	GetAlerts() ([]dto.AlertDTO, error)
+	GetAlert(alertId uint) (*dto.AlertDTO, error)
+	GetActiveAlerts(lat, lng float64) ([]dto.AlertDTO, error)
+	GetReceivedAlerts(userId uint) ([]dto.AlertDTO, error)
+	MarkAlertRead(userId uint, alertId uint) error
+	CancelAlert(actorId uint, alertId uint) (*dto.AlertDTO, error)
+	GetDeliveries(alertId uint) (*dto.AlertDeliveriesDTO, error)
+	GetAlertCAP(alertId uint) ([]byte, error)
+	GetAlertFeed(baseURL string) ([]byte, error)
+	ImportCAP(actorId uint, data []byte) ([]dto.AlertDTO, error)
+	DispatchDueAlerts(ctx context.Context) error
+	// DispatchAlert is the DispatchJob handler
+	DispatchAlert(ctx context.Context, job jobs.Job) error
+	// SendAlert is the SendJob handler
+	SendAlert(ctx context.Context, job jobs.Job) error
+}
+
+type alertService struct {
+	repository AlertRepository
+	hub        *stream.Hub
+	notifier   notifier.NotificationService
+}
+
+func NewAlertService(repo AlertRepository, hub *stream.Hub, n notifier.NotificationService) AlertService {
+	return &alertService{repository: repo, hub: hub, notifier: n}
+}
+
+// PublishAlert stores the alert and queues its dispatch, an alert already in force goes out right away
+// and one that starts later once it comes into force
+func (a *alertService) PublishAlert(actorId uint, request *dto.PublishAlertRequestDTO) (*dto.AlertDTO, error) {
+	alert, err := newAlert(actorId, request, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.repository.CreateAlert(alert); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to create alert: %v", err))
+		return nil, errors.New("unable to publish alert")
+	}
+	a.published(alert)
+
+	if published, err := a.repository.FindAlertById(alert.ID); err == nil {
+		alert = published
+	}
+	return alert.ToDTO(), nil
+}
+
+// newAlert validates a publish request and turns it into an alert of actorId, it is not stored yet
+func newAlert(actorId uint, request *dto.PublishAlertRequestDTO, now time.Time) (*alertModels.Alert, error) {
+	severity := alertModels.AlertSeverity(request.Severity)
+	if !severity.IsValid() {
+		return nil, errors.New("invalid severity")
+	}
+
+	effectiveAt := now
+	if request.EffectiveAt != nil && request.EffectiveAt.After(now) {
+		effectiveAt = *request.EffectiveAt
+	}
+	if !request.ExpiresAt.After(effectiveAt) || request.ExpiresAt.After(effectiveAt.Add(MaxAlertDuration)) {
+		return nil, ErrInvalidExpiry
+	}
+
+	alert := &alertModels.Alert{
+		PublishedByID: actorId,
+		Severity:      severity,
+		Headline:      request.Headline,
+		Message:       request.Message,
+		Instruction:   request.Instruction,
+		EffectiveAt:   effectiveAt,
+		ExpiresAt:     request.ExpiresAt,
+	}
+	if err := setArea(alert, &request.Area); err != nil {
+		return nil, err
+	}
+	return alert, nil
+}
+
+// published logs a stored alert, its dispatch was queued along with it
+func (a *alertService) published(alert *alertModels.Alert) {
+	logger.GlobalLogger.Log(logger.INFO, "Alert published", map[string]interface{}{
+		"alert_id":     alert.ID,
+		"published_by": alert.PublishedByID,
+		"severity":     alert.Severity,
+		"effective_at": alert.EffectiveAt,
+		"cap_sender":   alert.CAPSender,
+	})
+}
+
+func setArea(alert *alertModels.Alert, area *dto.AlertAreaRequestDTO) error {
+	alert.AreaDescription = area.Description
+	switch alertModels.AlertAreaType(area.Type) {
+	case alertModels.AlertAreaCircle:
+		if area.Latitude == nil || area.Longitude == nil || area.RadiusKm == nil {
+			return ErrInvalidArea
+		}
+		alert.SetArea(alertModels.AlertAreaCircle, area.Latitude, area.Longitude, area.RadiusKm, nil)
+	case alertModels.AlertAreaPolygon:
+		if len(area.Polygon) < 3 {
+			return ErrInvalidArea
+		}
+		polygon := make([]utils.GeoPoint, len(area.Polygon))
+		for i, point := range area.Polygon {
+			polygon[i] = utils.GeoPoint{Latitude: point.Latitude, Longitude: point.Longitude}
+		}
+		// the box of a polygon spanning more than half the globe is taken as crossing the antimeridian, which is not supported
+		if box := utils.PolygonBoundingBox(polygon); box.MaxLongitude-box.MinLongitude > 180 {
+			return errors.New("polygons crossing the antimeridian are not supported, split the area in two alerts")
+		}
+		alert.SetArea(alertModels.AlertAreaPolygon, nil, nil, nil, polygon)
+	default:
+		return ErrInvalidArea
+	}
+	return nil
+}
+
+func (a *alertService) GetAlerts() ([]dto.AlertDTO, error) {
+	alerts, err := a.repository.FindAlerts(listLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.AlertDTO, len(alerts))
+	for i := range alerts {
+		result[i] = *alerts[i].ToDTO()
+	}
+	return result, nil
+}
+
+func (a *alertService) findAlert(alertId uint) (*alertModels.Alert, error) {
+	alert, err := a.repository.FindAlertById(alertId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAlertNotFound
+		}
+		return nil, err
+	}
+	return alert, nil
+}
+
+func (a *alertService) GetAlert(alertId uint) (*dto.AlertDTO, error) {
+	alert, err := a.findAlert(alertId)
+	if err != nil {
+		return nil, err
+	}
+	return alert.ToDTO(), nil
+}
+
+// GetActiveAlerts returns the alerts in force whose area contains the point, most recent first
+func (a *alertService) GetActiveAlerts(lat, lng float64) ([]dto.AlertDTO, error) {
+	candidates, err := a.repository.FindActiveAlertsAround(lat, lng, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	result := []dto.AlertDTO{}
+	for i := range candidates {
+		if candidates[i].Covers(lat, lng) {
+			result = append(result, *candidates[i].ToDTO())
+		}
+	}
+	return result, nil
+}
+
+func (a *alertService) GetReceivedAlerts(userId uint) ([]dto.AlertDTO, error) {
+	deliveries, err := a.repository.FindUserDeliveries(userId, listLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.AlertDTO, len(deliveries))
+	for i := range deliveries {
+		result[i] = *deliveries[i].Alert.ToDTO()
+		result[i].Delivery = deliveries[i].ToDTO()
+	}
+	return result, nil
+}
+
+func (a *alertService) MarkAlertRead(userId uint, alertId uint) error {
+	if err := a.repository.MarkDeliveryRead(alertId, userId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrAlertNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// CancelAlert takes the alert out of force, recipients not reached yet are not sent it any more
+func (a *alertService) CancelAlert(actorId uint, alertId uint) (*dto.AlertDTO, error) {
+	alert, err := a.findAlert(alertId)
+	if err != nil {
+		return nil, err
+	}
+	if alert.CancelledAt != nil {
+		return nil, ErrAlertCancelled
+	}
+
+	if err := a.repository.CancelAlert(alertId, actorId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAlertCancelled
+		}
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to cancel alert: %v", err))
+		return nil, errors.New("unable to cancel alert")
+	}
+
+	logger.GlobalLogger.Log(logger.INFO, "Alert cancelled", map[string]interface{}{
+		"alert_id":     alertId,
+		"cancelled_by": actorId,
+	})
+
+	cancelledAt := time.Now()
+	alert.CancelledAt = &cancelledAt
+	alert.CancelledByID = &actorId
+	return alert.ToDTO(), nil
+}
+
+func (a *alertService) GetDeliveries(alertId uint) (*dto.AlertDeliveriesDTO, error) {
+	if _, err := a.findAlert(alertId); err != nil {
+		return nil, err
+	}
+
+	counts, read, err := a.repository.CountDeliveries(alertId)
+	if err != nil {
+		return nil, err
+	}
+	deliveries, err := a.repository.FindDeliveries(alertId, deliveryLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &dto.AlertDeliveriesDTO{
+		Summary: dto.AlertDeliverySummaryDTO{
+			Pending: counts[alertModels.AlertDeliveryPending],
+			Sent:    counts[alertModels.AlertDeliverySent],
+			Failed:  counts[alertModels.AlertDeliveryFailed],
+			Skipped: counts[alertModels.AlertDeliverySkipped],
+			Read:    read,
+		},
+		Deliveries: make([]dto.AlertDeliveryDTO, len(deliveries)),
+	}
+	result.Summary.Total = result.Summary.Pending + result.Summary.Sent + result.Summary.Failed + result.Summary.Skipped
+	for i := range deliveries {
+		result.Deliveries[i] = *deliveries[i].ToDTO()
+	}
+	return result, nil
+}
+
+// GetAlertCAP returns the alert as a CAP 1.2 document
+func (a *alertService) GetAlertCAP(alertId uint) ([]byte, error) {
+	alert, err := a.findAlert(alertId)
+	if err != nil {
+		return nil, err
+	}
+	return encodeCAP(alert, constants.CAPSender())
+}
+
+// GetAlertFeed returns the alerts in force as an Atom feed, baseURL is where this API is reached
+func (a *alertService) GetAlertFeed(baseURL string) ([]byte, error) {
+	now := time.Now()
+	alerts, err := a.repository.FindActiveAlerts(now, feedLimit)
+	if err != nil {
+		return nil, err
+	}
+	return encodeAtomFeed(alerts, baseURL, now)
+}
+
+// ImportCAP turns an external CAP 1.2 message into broadcasts of actorId, one alert per polygon or circle.
+// An Update replaces the alerts imported from the messages it references and a Cancel withdraws them.
+// Only actual public messages are imported, exercises and tests never reach the public.
+func (a *alertService) ImportCAP(actorId uint, data []byte) ([]dto.AlertDTO, error) {
+	message, err := decodeCAP(data)
+	if err != nil {
+		return nil, err
+	}
+	if message.Status != "Actual" {
+		return nil, fmt.Errorf("%w: only Actual messages are imported, this one is %s", ErrInvalidCAP, message.Status)
+	}
+	if message.Scope != "Public" {
+		return nil, fmt.Errorf("%w: only Public messages are imported, this one is %s", ErrInvalidCAP, message.Scope)
+	}
+	if message.MsgType != "Alert" && message.MsgType != "Update" && message.MsgType != "Cancel" {
+		return nil, fmt.Errorf("%w: %s messages are not imported", ErrInvalidCAP, message.MsgType)
+	}
+
+	imported, err := a.repository.FindCAPAlerts(message.Sender, message.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	if len(imported) > 0 {
+		return nil, ErrCAPExists
+	}
+
+	var superseded []alertModels.Alert
+	var supersededIds []uint
+	if message.MsgType != "Alert" {
+		for _, reference := range message.references() {
+			referenced, err := a.repository.FindCAPAlerts(reference.Sender, reference.Identifier)
+			if err != nil {
+				return nil, err
+			}
+			for _, alert := range referenced {
+				if alert.CancelledAt == nil {
+					superseded = append(superseded, alert)
+					supersededIds = append(supersededIds, alert.ID)
+				}
+			}
+		}
+	}
+
+	var alerts []*alertModels.Alert
+	if message.MsgType == "Cancel" {
+		if len(superseded) == 0 {
+			return nil, ErrAlertNotFound
+		}
+	} else if alerts, err = capToAlerts(actorId, message, time.Now()); err != nil {
+		return nil, err
+	}
+
+	if err := a.repository.ImportAlerts(alerts, supersededIds, actorId); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to import CAP message: %v", err))
+		return nil, errors.New("unable to import CAP message")
+	}
+
+	logger.GlobalLogger.Log(logger.INFO, "CAP message imported", map[string]interface{}{
+		"cap_sender":     message.Sender,
+		"cap_identifier": message.Identifier,
+		"msg_type":       message.MsgType,
+		"alerts":         len(alerts),
+		"cancelled":      len(supersededIds),
+		"imported_by":    actorId,
+	})
+
+	result := []dto.AlertDTO{}
+	for _, alert := range alerts {
+		a.published(alert)
+		result = append(result, *alert.ToDTO())
+	}
+	if message.MsgType == "Cancel" {
+		cancelledAt := time.Now()
+		for i := range superseded {
+			superseded[i].CancelledAt = &cancelledAt
+			superseded[i].CancelledByID = &actorId
+			result = append(result, *superseded[i].ToDTO())
+		}
+	}
+	return result, nil
+}
+
+// capToAlerts builds the alerts of an Alert or Update message from its English info block
+func capToAlerts(actorId uint, message *capAlert, now time.Time) ([]*alertModels.Alert, error) {
+	if len(message.Info) == 0 {
+		return nil, fmt.Errorf("%w: the message has no info block", ErrInvalidCAP)
+	}
+	info := message.englishInfo()
+
+	severity, err := capSeverity(info.Severity)
+	if err != nil {
+		return nil, err
+	}
+	if info.Expires == "" {
+		return nil, fmt.Errorf("%w: info.expires is required, broadcasts need an end", ErrInvalidCAP)
+	}
+	expiresAt, err := parseCAPTime("expires", info.Expires)
+	if err != nil {
+		return nil, err
+	}
+	if !expiresAt.After(now) {
+		return nil, fmt.Errorf("%w: the message expired at %s", ErrInvalidCAP, expiresAt.Format(time.RFC3339))
+	}
+	effectiveAt, err := parseCAPTime("sent", message.Sent)
+	if err != nil {
+		return nil, err
+	}
+	if info.Effective != "" {
+		if effectiveAt, err = parseCAPTime("effective", info.Effective); err != nil {
+			return nil, err
+		}
+	}
+
+	areas, err := capAreaRequests(info)
+	if err != nil {
+		return nil, err
+	}
+
+	headline := utils.Truncate(orDefault(strings.TrimSpace(info.Headline), info.Event), 160)
+	alerts := make([]*alertModels.Alert, len(areas))
+	for i := range areas {
+		alert, err := newAlert(actorId, &dto.PublishAlertRequestDTO{
+			Severity:    string(severity),
+			Headline:    headline,
+			Message:     utils.Truncate(orDefault(strings.TrimSpace(info.Description), headline), 2000),
+			Instruction: utils.Truncate(strings.TrimSpace(info.Instruction), 1000),
+			Area:        areas[i],
+			EffectiveAt: &effectiveAt,
+			ExpiresAt:   expiresAt,
+		}, now)
+		if err != nil {
+			return nil, err
+		}
+
+		alert.CAPSender = message.Sender
+		alert.CAPIdentifier = message.Identifier
+		alert.Event = info.Event
+		alert.Category = info.Category[0]
+		alert.Urgency = info.Urgency
+		alert.Certainty = info.Certainty
+		alerts[i] = alert
+	}
+	return alerts, nil
+}
+
+// DispatchDueAlerts is the scheduled job, it dispatches the alerts in force whose DispatchJob gave up or was lost
+func (a *alertService) DispatchDueAlerts(ctx context.Context) error {
+	now := time.Now()
+	alerts, err := a.repository.FindUndispatchedAlerts(now.Add(-dispatchGrace), now, dispatchBatch)
+	if err != nil {
+		return err
+	}
+	for i := range alerts {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := a.dispatch(&alerts[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *alertService) DispatchAlert(ctx context.Context, job jobs.Job) error {
+	var payload alertPayload
+	if err := job.Decode(&payload); err != nil {
+		return err
+	}
+
+	alert, err := a.repository.FindAlertById(payload.AlertID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if alert.DispatchedAt != nil || !alert.IsActive(time.Now()) {
+		return nil
+	}
+	return a.dispatch(alert)
+}
+
+// dispatch works out who the alert is for and records a delivery for each of them, the SendJob queued with them sends it.
+// Claiming the alert in the same transaction makes sure two servers never send it twice.
+func (a *alertService) dispatch(alert *alertModels.Alert) error {
+	deliveries, err := a.audience(alert)
+	if err != nil {
+		return fmt.Errorf("unable to work out alert audience: %w", err)
+	}
+
+	claimed, feedEvents, err := a.repository.DispatchAlert(alert, deliveries)
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		return nil
+	}
+	stream.PublishFeed(a.hub, feedEvents)
+
+	logger.GlobalLogger.Log(logger.INFO, "Alert dispatched", map[string]interface{}{
+		"alert_id":   alert.ID,
+		"recipients": len(deliveries),
+	})
+	return nil
+}
+
+// SendAlert sends the alert to each of its pending deliveries, a cancelled alert is not sent any more.
+// A delivery that fails stays pending for the next attempt of the job.
+func (a *alertService) SendAlert(ctx context.Context, job jobs.Job) error {
+	var payload alertPayload
+	if err := job.Decode(&payload); err != nil {
+		return err
+	}
+
+	alert, err := a.repository.FindAlertById(payload.AlertID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	if alert.CancelledAt != nil {
+		return nil
+	}
+
+	pending, err := a.repository.FindPendingDeliveries(alert.ID)
+	if err != nil {
+		return err
+	}
+
+	final := job.Attempt >= SendOptions.Retry.MaxAttempts
+	var sendErr error
+	for i := range pending {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		delivery := &pending[i]
+		err := a.repository.SendDelivery(delivery, func(tx *gorm.DB) error {
+			return a.send(tx, alert, delivery, final)
+		})
+		if err != nil {
+			logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to send alert: %v", err), map[string]interface{}{
+				"alert_id": alert.ID,
+				"user_id":  delivery.UserID,
+			})
+			sendErr = err
+		}
+	}
+	return sendErr
+}
+
+// audience matches users by their recent last known location first and by their saved places second,
+// each user gets one delivery however many of their places are inside the area
+func (a *alertService) audience(alert *alertModels.Alert) ([]alertModels.AlertDelivery, error) {
+	box := alert.BoundingBox()
+	seen := map[uint]bool{}
+	var deliveries []alertModels.AlertDelivery
+
+	locations, err := a.repository.FindUserLocationsInBox(box, time.Now().Add(-LocationMaxAge))
+	if err != nil {
+		return nil, err
+	}
+	for _, location := range locations {
+		if seen[location.UserID] || !alert.Covers(location.Latitude, location.Longitude) {
+			continue
+		}
+		seen[location.UserID] = true
+		deliveries = append(deliveries, alertModels.AlertDelivery{
+			AlertID:   alert.ID,
+			UserID:    location.UserID,
+			MatchedBy: alertModels.AlertMatchLocation,
+			Status:    alertModels.AlertDeliveryPending,
+		})
+	}
+
+	places, err := a.repository.FindSavedPlacesInBox(box)
+	if err != nil {
+		return nil, err
+	}
+	for _, place := range places {
+		if seen[place.UserID] || !alert.Covers(place.Latitude, place.Longitude) {
+			continue
+		}
+		seen[place.UserID] = true
+		placeId := place.ID
+		deliveries = append(deliveries, alertModels.AlertDelivery{
+			AlertID:      alert.ID,
+			UserID:       place.UserID,
+			MatchedBy:    alertModels.AlertMatchSavedPlace,
+			SavedPlaceID: &placeId,
+			Status:       alertModels.AlertDeliveryPending,
+		})
+	}
+	return deliveries, nil
+}
+
+// send hands the alert to the notifier, which reaches the user on every channel they allow, and notes how that went
+// on the delivery. An error leaves the delivery pending, on the final attempt it is marked failed instead.
+func (a *alertService) send(tx *gorm.DB, alert *alertModels.Alert, delivery *alertModels.AlertDelivery, final bool) error {
+	_, err := a.notifier.NotifyTx(tx, notifier.Request{
+		UserID:   delivery.UserID,
+		Template: alertTemplate,
+		Data:     alertData(alert),
+		Link:     map[string]string{"alert_id": strconv.FormatUint(uint64(alert.ID), 10)},
+		Critical: alert.Severity.IsCritical(),
+	})
+	switch {
+	case errors.Is(err, notifier.ErrRecipientNotFound):
+		delivery.Status = alertModels.AlertDeliverySkipped
+	case err != nil && !final:
+		return err
+	case err != nil:
+		delivery.Status = alertModels.AlertDeliveryFailed
+		delivery.Error = utils.Truncate(err.Error(), 255)
+	default:
+		sentAt := time.Now()
+		delivery.Status = alertModels.AlertDeliverySent
+		delivery.SentAt = &sentAt
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package alert
+
+import (
+	"resq/internal/infra/notifier"
+	alertModels "resq/pkg/models/alert"
+	"strings"
+	"time"
+)
+
+var alertTemplate = notifier.NewTemplate("alert",
+	"[{{.Severity}}] {{.Headline}}",
+	`{{.Message}}{{if .Instruction}}
+
+What to do: {{.Instruction}}{{end}}
+
+In force until {{.ExpiresAt}}. You are receiving this because your location or one of your saved places is inside the alert area.`)
+
+func alertData(alert *alertModels.Alert) map[string]interface{} {
+	return map[string]interface{}{
+		"Severity":    strings.ToUpper(string(alert.Severity)),
+		"Headline":    alert.Headline,
+		"Message":     alert.Message,
+		"Instruction": alert.Instruction,
+		"ExpiresAt":   alert.ExpiresAt.UTC().Format(time.RFC1123),
+	}
+}
@@ -0,0 +1,258 @@
+package checkin
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"resq/pkg/constants"
+	"resq/pkg/dto"
+	"resq/pkg/utils"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// streamKeepAlive keeps proxies from closing the tally stream while nobody answers
+const streamKeepAlive = 15 * time.Second
+
+type CheckInController interface {
+	StartCheckIn(ctx *gin.Context)
+	GetCreatedCheckIns(ctx *gin.Context)
+	GetAskedCheckIns(ctx *gin.Context)
+	GetCheckIn(ctx *gin.Context)
+	GetRecipients(ctx *gin.Context)
+	StreamCheckIn(ctx *gin.Context)
+	Respond(ctx *gin.Context)
+	CloseCheckIn(ctx *gin.Context)
+}
+
+type checkInController struct {
+	service CheckInService
+}
+
+func NewCheckInController(service CheckInService) CheckInController {
+	return &checkInController{service: service}
+}
+
+func checkInErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrCheckInNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrCheckInClosed):
+		return http.StatusConflict
+	case errors.Is(err, ErrAreaNotAllowed):
+		return http.StatusForbidden
+	case errors.Is(err, ErrNoRecipients):
+		return http.StatusUnprocessableEntity
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func (c *checkInController) StartCheckIn(ctx *gin.Context) {
+	requester, err := utils.GetAuthenticatedUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var request dto.StartCheckInRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	result, err := c.service.StartCheckIn(requester, &request)
+	if err != nil {
+		ctx.JSON(checkInErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{constants.RequestData: result})
+}
+
+func (c *checkInController) GetCreatedCheckIns(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	result, err := c.service.GetCreatedCheckIns(userId)
+	if err != nil {
+		ctx.JSON(checkInErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (c *checkInController) GetAskedCheckIns(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	result, err := c.service.GetAskedCheckIns(userId)
+	if err != nil {
+		ctx.JSON(checkInErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (c *checkInController) GetCheckIn(ctx *gin.Context) {
+	requester, err := utils.GetAuthenticatedUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	checkInId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid check-in id"})
+		return
+	}
+
+	result, err := c.service.GetCheckIn(checkInId, requester)
+	if err != nil {
+		ctx.JSON(checkInErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (c *checkInController) GetRecipients(ctx *gin.Context) {
+	requester, err := utils.GetAuthenticatedUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	checkInId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid check-in id"})
+		return
+	}
+
+	result, err := c.service.GetRecipients(checkInId, requester, ctx.Query("answer"))
+	if err != nil {
+		ctx.JSON(checkInErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+// StreamCheckIn sends the requester the tally as server-sent events, every answer is followed by the new tally
+func (c *checkInController) StreamCheckIn(ctx *gin.Context) {
+	requester, err := utils.GetAuthenticatedUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	checkInId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid check-in id"})
+		return
+	}
+
+	follow, err := c.service.FollowCheckIn(checkInId, requester)
+	if err != nil {
+		ctx.JSON(checkInErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+	defer follow.Stop()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+
+	ctx.Render(-1, sse.Event{Event: EventTally, Data: follow.CheckIn.Tally})
+	if !follow.CheckIn.Open {
+		ctx.Render(-1, sse.Event{Event: EventClosed, Data: follow.CheckIn})
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	closes := time.NewTimer(time.Until(follow.CheckIn.ClosesAt))
+	defer closes.Stop()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case <-closes.C:
+			follow.CheckIn.Open = false
+			ctx.Render(-1, sse.Event{Event: EventClosed, Data: follow.CheckIn})
+			return false
+		case event, ok := <-follow.Events:
+			if !ok {
+				// fell too far behind, the client reconnects and gets a fresh tally
+				return false
+			}
+			ctx.Render(-1, sse.Event{Event: event.Name, Data: event.Data})
+			return event.Name != EventClosed
+		}
+	})
+}
+
+func (c *checkInController) Respond(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	checkInId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid check-in id"})
+		return
+	}
+
+	var request dto.RespondCheckInRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	result, err := c.service.Respond(userId, checkInId, &request)
+	if err != nil {
+		ctx.JSON(checkInErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (c *checkInController) CloseCheckIn(ctx *gin.Context) {
+	requester, err := utils.GetAuthenticatedUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	checkInId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid check-in id"})
+		return
+	}
+
+	result, err := c.service.CloseCheckIn(checkInId, requester)
+	if err != nil {
+		ctx.JSON(checkInErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
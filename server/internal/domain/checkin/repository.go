@@ -0,0 +1,338 @@
+package checkin
+
+import (
+	"fmt"
+	"resq/pkg/constants"
+	"resq/pkg/models"
+	checkinModels "resq/pkg/models/checkin"
+	contactModels "resq/pkg/models/contact"
+	outboxModels "resq/pkg/models/outbox"
+	reportModels "resq/pkg/models/report"
+	"resq/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// haversineSQL is utils.HaversineKm over user_locations, its placeholders are the centre latitude, latitude and longitude
+const haversineSQL = `2 * 6371 * asin(least(1, sqrt(
+	power(sin(radians(user_locations.latitude - ?) / 2), 2) +
+	cos(radians(?)) * cos(radians(user_locations.latitude)) *
+	power(sin(radians(user_locations.longitude - ?) / 2), 2)
+)))`
+
+// recipientBatchSize keeps the insert of a large area check-in under the Postgres parameter limit
+const recipientBatchSize = 1000
+
+type CheckInRepository interface {
+	FindUserById(userId uint) (*models.User, error)
+	FindUserLocation(userId uint) (*models.UserLocation, error)
+	FindContactUserIds(userId uint) ([]uint, error)
+	FindUserIdsInArea(lat, lng, radiusKm float64, locatedSince time.Time) ([]uint, error)
+	FindOrCreateNeedHelpCategory() (*reportModels.ReportCategory, error)
+	CreateCheckIn(checkIn *checkinModels.CheckIn, userIds []uint, queue func(tx *gorm.DB) error) error
+	AskRecipients(checkInId uint, limit int, ask func(tx *gorm.DB, recipient *checkinModels.CheckInRecipient) error) (int, error)
+	FindCheckInById(checkInId uint) (*checkinModels.CheckIn, error)
+	FindCheckInsCreatedBy(userId uint, limit int) ([]checkinModels.CheckIn, error)
+	FindCheckInsAskingUser(userId uint, limit int) ([]checkinModels.CheckIn, error)
+	FindRecipient(checkInId uint, userId uint) (*checkinModels.CheckInRecipient, error)
+	FindRecipients(checkInId uint, answer checkinModels.CheckInAnswer) ([]checkinModels.CheckInRecipient, error)
+	CountAnswers(checkInId uint) (map[checkinModels.CheckInAnswer]int64, error)
+	RecordAnswer(recipient *checkinModels.CheckInRecipient, report *reportModels.Report) (bool, error)
+	CloseCheckIn(checkInId uint) error
+}
+
+type checkInRepository struct {
+	db *gorm.DB
+}
+
+func NewCheckInRepository(db *gorm.DB) CheckInRepository {
+	return &checkInRepository{db: db}
+}
+
+func (c *checkInRepository) FindUserById(userId uint) (*models.User, error) {
+	var user models.User
+	result := c.db.Where("id = ?", userId).First(&user)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find user: %w", result.Error)
+	}
+	return &user, nil
+}
+
+func (c *checkInRepository) FindUserLocation(userId uint) (*models.UserLocation, error) {
+	var location models.UserLocation
+	result := c.db.Where("user_id = ?", userId).First(&location)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find user location: %w", result.Error)
+	}
+	return &location, nil
+}
+
+// FindContactUserIds returns the other side of every accepted contact of userId
+func (c *checkInRepository) FindContactUserIds(userId uint) ([]uint, error) {
+	var userIds []uint
+	result := contactModels.AcceptedContactIDs(c.db, userId).Scan(&userIds)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find contacts: %w", result.Error)
+	}
+	return userIds, nil
+}
+
+// FindUserIdsInArea returns the users whose last known location, no older than locatedSince, is inside the circle
+func (c *checkInRepository) FindUserIdsInArea(lat, lng, radiusKm float64, locatedSince time.Time) ([]uint, error) {
+	box := utils.RadiusBoundingBox(lat, lng, radiusKm)
+
+	query := c.db.Model(&models.UserLocation{}).
+		Joins("JOIN users ON users.id = user_locations.user_id AND users.deleted_at IS NULL").
+		Where("user_locations.recorded_at >= ?", locatedSince).
+		Where("user_locations.latitude BETWEEN ? AND ?", box.MinLatitude, box.MaxLatitude)
+	if box.CrossesAntimeridian() {
+		query = query.Where("(user_locations.longitude >= ? OR user_locations.longitude <= ?)", box.MinLongitude, box.MaxLongitude)
+	} else {
+		query = query.Where("user_locations.longitude BETWEEN ? AND ?", box.MinLongitude, box.MaxLongitude)
+	}
+
+	var userIds []uint
+	result := query.
+		Where(clause.Expr{SQL: "(" + haversineSQL + ") <= ?", Vars: []interface{}{lat, lat, lng, radiusKm}}).
+		Pluck("user_locations.user_id", &userIds)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find users in area: %w", result.Error)
+	}
+	return userIds, nil
+}
+
+func (c *checkInRepository) FindOrCreateNeedHelpCategory() (*reportModels.ReportCategory, error) {
+	var category reportModels.ReportCategory
+	result := c.db.Where(reportModels.ReportCategory{SystemKey: reportModels.NeedHelpCategoryKey}).
+		Attrs(reportModels.ReportCategory{Title: "Need help", Description: "People who answered a check-in saying they need help", Private: true}).
+		FirstOrCreate(&category)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find need help category: %w", result.Error)
+	}
+	return &category, nil
+}
+
+// CreateCheckIn stores the check-in and asks every one of userIds, nobody has answered yet.
+// queue runs in the same transaction once the check-in has its ID.
+func (c *checkInRepository) CreateCheckIn(checkIn *checkinModels.CheckIn, userIds []uint, queue func(tx *gorm.DB) error) error {
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("CreatedBy").Create(checkIn).Error; err != nil {
+			return err
+		}
+
+		recipients := make([]checkinModels.CheckInRecipient, len(userIds))
+		for i, userId := range userIds {
+			recipients[i] = checkinModels.CheckInRecipient{
+				CheckInID: checkIn.ID,
+				UserID:    userId,
+				Answer:    checkinModels.CheckInNoResponse,
+			}
+		}
+		if err := tx.Omit("User").CreateInBatches(&recipients, recipientBatchSize).Error; err != nil {
+			return err
+		}
+		return queue(tx)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to create check-in %w", err)
+	}
+	return nil
+}
+
+// AskRecipients runs ask for up to limit recipients not asked yet and marks them asked in the same transaction,
+// it returns how many were asked. The rows stay locked meanwhile, so a second run waits and skips them.
+func (c *checkInRepository) AskRecipients(checkInId uint, limit int, ask func(tx *gorm.DB, recipient *checkinModels.CheckInRecipient) error) (int, error) {
+	var recipients []checkinModels.CheckInRecipient
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("check_in_id = ? AND asked_at IS NULL", checkInId).
+			Order("id asc").
+			Limit(limit).
+			Find(&recipients).Error; err != nil {
+			return err
+		}
+		if len(recipients) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(recipients))
+		for i := range recipients {
+			if err := ask(tx, &recipients[i]); err != nil {
+				return err
+			}
+			ids[i] = recipients[i].ID
+		}
+		return tx.Model(&checkinModels.CheckInRecipient{}).Where("id IN ?", ids).Update("asked_at", time.Now()).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to ask check-in recipients %w", err)
+	}
+	return len(recipients), nil
+}
+
+func (c *checkInRepository) FindCheckInById(checkInId uint) (*checkinModels.CheckIn, error) {
+	var checkIn checkinModels.CheckIn
+	result := c.db.Preload("CreatedBy").Where("id = ?", checkInId).First(&checkIn)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find check-in: %w", result.Error)
+	}
+	return &checkIn, nil
+}
+
+func (c *checkInRepository) FindCheckInsCreatedBy(userId uint, limit int) ([]checkinModels.CheckIn, error) {
+	var checkIns []checkinModels.CheckIn
+	result := c.db.Preload("CreatedBy").
+		Where("created_by_id = ?", userId).
+		Order("created_at desc").
+		Limit(limit).
+		Find(&checkIns)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find check-ins: %w", result.Error)
+	}
+	return checkIns, nil
+}
+
+func (c *checkInRepository) FindCheckInsAskingUser(userId uint, limit int) ([]checkinModels.CheckIn, error) {
+	asked := c.db.Model(&checkinModels.CheckInRecipient{}).Select("check_in_id").Where("user_id = ?", userId)
+
+	var checkIns []checkinModels.CheckIn
+	result := c.db.Preload("CreatedBy").
+		Where("id IN (?)", asked).
+		Order("created_at desc").
+		Limit(limit).
+		Find(&checkIns)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find check-ins: %w", result.Error)
+	}
+	return checkIns, nil
+}
+
+func (c *checkInRepository) FindRecipient(checkInId uint, userId uint) (*checkinModels.CheckInRecipient, error) {
+	var recipient checkinModels.CheckInRecipient
+	result := c.db.Where("check_in_id = ? AND user_id = ?", checkInId, userId).First(&recipient)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find check-in recipient: %w", result.Error)
+	}
+	return &recipient, nil
+}
+
+// FindRecipients lists who was asked, narrowed to one answer unless answer is empty.
+// People who need help come first, then the most recent answers.
+func (c *checkInRepository) FindRecipients(checkInId uint, answer checkinModels.CheckInAnswer) ([]checkinModels.CheckInRecipient, error) {
+	query := c.db.Preload("User").Where("check_in_id = ?", checkInId)
+	if answer != "" {
+		query = query.Where("answer = ?", answer)
+	}
+
+	var recipients []checkinModels.CheckInRecipient
+	result := query.
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:  "answer = ? DESC, responded_at DESC NULLS LAST, id ASC",
+			Vars: []interface{}{checkinModels.CheckInNeedHelp},
+		}}).
+		Find(&recipients)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find check-in recipients: %w", result.Error)
+	}
+	return recipients, nil
+}
+
+func (c *checkInRepository) CountAnswers(checkInId uint) (map[checkinModels.CheckInAnswer]int64, error) {
+	var rows []struct {
+		Answer checkinModels.CheckInAnswer
+		Count  int64
+	}
+	result := c.db.Model(&checkinModels.CheckInRecipient{}).
+		Select("answer, count(*) AS count").
+		Where("check_in_id = ?", checkInId).
+		Group("answer").
+		Scan(&rows)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to count check-in answers: %w", result.Error)
+	}
+
+	counts := map[checkinModels.CheckInAnswer]int64{}
+	for _, row := range rows {
+		counts[row.Answer] = row.Count
+	}
+	return counts, nil
+}
+
+// RecordAnswer saves the recipient's answer if the check-in is still open, it finds nothing to answer once it closed.
+// The recipient's row stays locked meanwhile so answers sent at the same time take turns, report is filed and linked
+// only when no earlier answer filed one, filed says whether it was. Coordinates sent with the answer also become
+// the recipient's last known location. A filed report's report.created event goes to the outbox in the same transaction.
+func (c *checkInRepository) RecordAnswer(recipient *checkinModels.CheckInRecipient, report *reportModels.Report) (bool, error) {
+	filed := false
+	err := c.db.Transaction(func(tx *gorm.DB) error {
+		var current checkinModels.CheckInRecipient
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", recipient.ID).First(&current).Error; err != nil {
+			return err
+		}
+
+		// closing the check-in waits for the answers being recorded, and the other way round
+		var checkIn checkinModels.CheckIn
+		result := tx.Clauses(clause.Locking{Strength: "SHARE"}).Select("id").
+			Where("id = ? AND closed_at IS NULL AND closes_at > ?", recipient.CheckInID, *recipient.RespondedAt).
+			Limit(1).
+			Find(&checkIn)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		recipient.ReportID = current.ReportID
+		if report != nil && recipient.ReportID == nil {
+			if err := tx.Omit("Category", "Reporter").Create(report).Error; err != nil {
+				return err
+			}
+			if err := outboxModels.Record(tx, constants.TopicReportCreated, constants.AggregateReport, report.ID, report.CreatedEvent()); err != nil {
+				return err
+			}
+			recipient.ReportID = &report.ID
+			filed = true
+		}
+
+		if err := tx.Model(recipient).Updates(map[string]interface{}{
+			"answer":       recipient.Answer,
+			"note":         recipient.Note,
+			"latitude":     recipient.Latitude,
+			"longitude":    recipient.Longitude,
+			"report_id":    recipient.ReportID,
+			"responded_at": recipient.RespondedAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		if recipient.Latitude == nil || recipient.Longitude == nil {
+			return nil
+		}
+		return tx.Clauses(models.UpsertUserLocation).Create(&models.UserLocation{
+			UserID:     recipient.UserID,
+			Latitude:   *recipient.Latitude,
+			Longitude:  *recipient.Longitude,
+			RecordedAt: *recipient.RespondedAt,
+		}).Error
+	})
+	if err != nil {
+		return false, fmt.Errorf("unable to record check-in answer %w", err)
+	}
+	return filed, nil
+}
+
+func (c *checkInRepository) CloseCheckIn(checkInId uint) error {
+	result := c.db.Model(&checkinModels.CheckIn{}).
+		Where("id = ? AND closed_at IS NULL", checkInId).
+		Update("closed_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("unable to close check-in %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("unable to close check-in: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package checkin
+
+import (
+	"resq/internal/infra/jobs"
+	"resq/internal/infra/middleware"
+	"resq/internal/infra/notifier"
+	"resq/internal/infra/stream"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func CheckInRoutes(router *gin.Engine, db *gorm.DB) {
+	checkInRepository := NewCheckInRepository(db)
+	checkInService := NewCheckInService(checkInRepository, stream.GlobalHub, notifier.GlobalNotifier)
+	checkInController := NewCheckInController(checkInService)
+
+	jobs.GlobalRunner.Register(AskJob, checkInService.AskRecipients, AskOptions)
+
+	checkIns := router.Group("checkins")
+
+	checkIns.Use(middleware.AuthMiddleware())
+	{
+		checkIns.POST("", checkInController.StartCheckIn)
+		checkIns.GET("", checkInController.GetCreatedCheckIns)
+		checkIns.GET("/asked", checkInController.GetAskedCheckIns)
+		checkIns.GET("/:id", checkInController.GetCheckIn)
+		checkIns.GET("/:id/recipients", checkInController.GetRecipients)
+		checkIns.GET("/:id/stream", checkInController.StreamCheckIn)
+		checkIns.POST("/:id/respond", checkInController.Respond)
+		checkIns.POST("/:id/close", checkInController.CloseCheckIn)
+	}
+}
@@ -0,0 +1,529 @@
+package checkin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"resq/internal/infra"
+	"resq/internal/infra/jobs"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/notifier"
+	"resq/internal/infra/stream"
+	"resq/pkg/constants"
+	"resq/pkg/dto"
+	"resq/pkg/models"
+	checkinModels "resq/pkg/models/checkin"
+	reportModels "resq/pkg/models/report"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultCheckInDuration is how long a check-in takes answers when the requester does not say
+	DefaultCheckInDuration = 24 * time.Hour
+	// LocationMaxAge leaves people out of area check-ins when their phone has not reported for this long
+	LocationMaxAge = 24 * time.Hour
+	// listLimit caps the check-in lists, older ones drop off the end
+	listLimit = 50
+	// AskJob sends a new check-in to everyone it asks
+	AskJob = "check-in-ask"
+	// askBatch is how many recipients are asked per transaction
+	askBatch = 100
+
+	EventTally  = "tally"
+	EventAnswer = "answer"
+	EventClosed = "closed"
+)
+
+var (
+	ErrCheckInNotFound = errors.New("check-in not found")
+	ErrCheckInClosed   = errors.New("check-in is closed")
+	ErrAreaRequired    = errors.New("an area check-in needs latitude, longitude and radius_km")
+	ErrAreaNotAllowed  = errors.New("you are not allowed to start area check-ins")
+	ErrNoRecipients    = errors.New("nobody to ask, there are no contacts or located users in scope")
+)
+
+// AskOptions keep asking for about half an hour, a large area check-in can take a while to queue
+var AskOptions = jobs.Options{
+	Concurrency: 2,
+	Timeout:     10 * time.Minute,
+	Retry: infra.RetryPolicy{
+		MaxAttempts:    6,
+		InitialBackoff: 30 * time.Second,
+		MaxBackoff:     10 * time.Minute,
+	},
+}
+
+type checkInPayload struct {
+	CheckInID uint `json:"check_in_id"`
+}
+
+// CheckInFollow is the requester's live view of a check-in, the current tally followed by changes to it
+type CheckInFollow struct {
+	CheckIn *dto.CheckInDTO
+	Events  <-chan stream.Event
+	Stop    func()
+}
+
+type CheckInService interface {
+	StartCheckIn(requester *dto.AuthenticatedUserDTO, request *dto.StartCheckInRequestDTO) (*dto.CheckInDTO, error)
+	GetCreatedCheckIns(userId uint) ([]dto.CheckInDTO, error)
+	GetAskedCheckIns(userId uint) ([]dto.CheckInDTO, error)
+	GetCheckIn(checkInId uint, viewer *dto.AuthenticatedUserDTO) (*dto.CheckInDTO, error)
+	GetRecipients(checkInId uint, viewer *dto.AuthenticatedUserDTO, answer string) ([]dto.CheckInRecipientDTO, error)
+	FollowCheckIn(checkInId uint, viewer *dto.AuthenticatedUserDTO) (*CheckInFollow, error)
+	Respond(userId uint, checkInId uint, request *dto.RespondCheckInRequestDTO) (*dto.CheckInRecipientDTO, error)
+	CloseCheckIn(checkInId uint, viewer *dto.AuthenticatedUserDTO) (*dto.CheckInDTO, error)
+	// AskRecipients is the AskJob handler
+	AskRecipients(ctx context.Context, job jobs.Job) error
+}
+
+type checkInService struct {
+	repository CheckInRepository
+	hub        *stream.Hub
+	notifier   notifier.NotificationService
+}
+
+func NewCheckInService(repo CheckInRepository, hub *stream.Hub, n notifier.NotificationService) CheckInService {
+	return &checkInService{repository: repo, hub: hub, notifier: n}
+}
+
+func checkInTopic(checkInId uint) string {
+	return "checkin:" + strconv.FormatUint(uint64(checkInId), 10)
+}
+
+// StartCheckIn asks everyone in scope whether they are safe. Anyone may ask their accepted contacts,
+// asking everyone located in an area needs PermissionCheckInsArea.
+func (c *checkInService) StartCheckIn(requester *dto.AuthenticatedUserDTO, request *dto.StartCheckInRequestDTO) (*dto.CheckInDTO, error) {
+	scope := checkinModels.CheckInScope(request.Scope)
+	if !scope.IsValid() {
+		return nil, errors.New("invalid check-in scope")
+	}
+
+	now := time.Now()
+	duration := DefaultCheckInDuration
+	if request.DurationMinutes > 0 {
+		duration = time.Duration(request.DurationMinutes) * time.Minute
+	}
+
+	checkIn := &checkinModels.CheckIn{
+		CreatedByID: requester.ID,
+		Scope:       scope,
+		Title:       request.Title,
+		Message:     request.Message,
+		ClosesAt:    now.Add(duration),
+	}
+
+	var userIds []uint
+	var err error
+	switch scope {
+	case checkinModels.CheckInArea:
+		if !requester.Can(constants.PermissionCheckInsArea) {
+			return nil, ErrAreaNotAllowed
+		}
+		if request.Latitude == nil || request.Longitude == nil || request.RadiusKm == nil {
+			return nil, ErrAreaRequired
+		}
+		checkIn.Latitude = request.Latitude
+		checkIn.Longitude = request.Longitude
+		checkIn.RadiusKm = request.RadiusKm
+		userIds, err = c.repository.FindUserIdsInArea(*request.Latitude, *request.Longitude, *request.RadiusKm, now.Add(-LocationMaxAge))
+	case checkinModels.CheckInContacts:
+		userIds, err = c.repository.FindContactUserIds(requester.ID)
+	}
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to find check-in recipients: %v", err))
+		return nil, errors.New("unable to start check-in")
+	}
+
+	userIds = withoutUser(userIds, requester.ID)
+	if len(userIds) == 0 {
+		return nil, ErrNoRecipients
+	}
+
+	if creator, err := c.repository.FindUserById(requester.ID); err == nil {
+		checkIn.CreatedBy = *creator
+	}
+
+	// an area check-in can ask thousands of people, the AskJob queued with it sends it to them
+	queueAsk := func(tx *gorm.DB) error {
+		return jobs.EnqueueTx(tx, AskJob, checkInPayload{CheckInID: checkIn.ID}, time.Time{})
+	}
+
+	if err := c.repository.CreateCheckIn(checkIn, userIds, queueAsk); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to create check-in: %v", err))
+		return nil, errors.New("unable to start check-in")
+	}
+
+	logger.GlobalLogger.Log(logger.INFO, "Check-in started", map[string]interface{}{
+		"check_in_id": checkIn.ID,
+		"created_by":  requester.ID,
+		"scope":       scope,
+		"recipients":  len(userIds),
+	})
+
+	return checkIn.ToDTO(&dto.CheckInTallyDTO{Total: int64(len(userIds)), NoResponse: int64(len(userIds))}), nil
+}
+
+// AskRecipients sends the check-in to everyone it asks who was not sent it yet, a closed check-in is not sent any more.
+// Each recipient is marked asked in the transaction that queues their notification, a retried job carries on where it stopped.
+func (c *checkInService) AskRecipients(ctx context.Context, job jobs.Job) error {
+	var payload checkInPayload
+	if err := job.Decode(&payload); err != nil {
+		return err
+	}
+
+	checkIn, err := c.repository.FindCheckInById(payload.CheckInID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	ask := func(tx *gorm.DB, recipient *checkinModels.CheckInRecipient) error {
+		_, err := c.notifier.NotifyTx(tx, notifier.Request{
+			UserID:   recipient.UserID,
+			Template: checkInTemplate,
+			Data:     checkInData(checkIn),
+			Link:     map[string]string{"check_in_id": strconv.FormatUint(uint64(checkIn.ID), 10)},
+		})
+		if errors.Is(err, notifier.ErrRecipientNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	for checkIn.IsOpen(time.Now()) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		asked, err := c.repository.AskRecipients(checkIn.ID, askBatch, ask)
+		if err != nil {
+			return err
+		}
+		if asked < askBatch {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (c *checkInService) tally(checkInId uint) (*dto.CheckInTallyDTO, error) {
+	counts, err := c.repository.CountAnswers(checkInId)
+	if err != nil {
+		return nil, err
+	}
+
+	tally := &dto.CheckInTallyDTO{
+		Safe:       counts[checkinModels.CheckInSafe],
+		NeedHelp:   counts[checkinModels.CheckInNeedHelp],
+		NoResponse: counts[checkinModels.CheckInNoResponse],
+	}
+	tally.Total = tally.Safe + tally.NeedHelp + tally.NoResponse
+	return tally, nil
+}
+
+func (c *checkInService) GetCreatedCheckIns(userId uint) ([]dto.CheckInDTO, error) {
+	checkIns, err := c.repository.FindCheckInsCreatedBy(userId, listLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.CheckInDTO, len(checkIns))
+	for i := range checkIns {
+		tally, err := c.tally(checkIns[i].ID)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = *checkIns[i].ToDTO(tally)
+	}
+	return result, nil
+}
+
+func (c *checkInService) GetAskedCheckIns(userId uint) ([]dto.CheckInDTO, error) {
+	checkIns, err := c.repository.FindCheckInsAskingUser(userId, listLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.CheckInDTO, len(checkIns))
+	for i := range checkIns {
+		result[i] = *checkIns[i].ToDTO(nil)
+		if recipient, err := c.repository.FindRecipient(checkIns[i].ID, userId); err == nil {
+			result[i].MyAnswer = recipient.ToDTO()
+		}
+	}
+	return result, nil
+}
+
+func (c *checkInService) findCheckIn(checkInId uint) (*checkinModels.CheckIn, error) {
+	checkIn, err := c.repository.FindCheckInById(checkInId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCheckInNotFound
+		}
+		return nil, err
+	}
+	return checkIn, nil
+}
+
+// canManage is true for whoever started the check-in and staff who may read every report
+func canManage(checkIn *checkinModels.CheckIn, viewer *dto.AuthenticatedUserDTO) bool {
+	return checkIn.CreatedByID == viewer.ID || viewer.Can(constants.PermissionReportsReadAny)
+}
+
+// GetCheckIn shows the tally to whoever may manage the check-in, the people asked only see their own answer
+func (c *checkInService) GetCheckIn(checkInId uint, viewer *dto.AuthenticatedUserDTO) (*dto.CheckInDTO, error) {
+	checkIn, err := c.findCheckIn(checkInId)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient, err := c.repository.FindRecipient(checkInId, viewer.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var result *dto.CheckInDTO
+	switch {
+	case canManage(checkIn, viewer):
+		tally, err := c.tally(checkInId)
+		if err != nil {
+			return nil, err
+		}
+		result = checkIn.ToDTO(tally)
+	case recipient != nil:
+		result = checkIn.ToDTO(nil)
+	default:
+		return nil, ErrCheckInNotFound
+	}
+
+	if recipient != nil {
+		result.MyAnswer = recipient.ToDTO()
+	}
+	return result, nil
+}
+
+func (c *checkInService) GetRecipients(checkInId uint, viewer *dto.AuthenticatedUserDTO, answer string) ([]dto.CheckInRecipientDTO, error) {
+	checkIn, err := c.findCheckIn(checkInId)
+	if err != nil {
+		return nil, err
+	}
+	if !canManage(checkIn, viewer) {
+		return nil, ErrCheckInNotFound
+	}
+
+	filter := checkinModels.CheckInAnswer(answer)
+	if answer != "" && !filter.IsValid() {
+		return nil, errors.New("answer must be one of safe, need_help or no_response")
+	}
+
+	recipients, err := c.repository.FindRecipients(checkInId, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.CheckInRecipientDTO, len(recipients))
+	for i := range recipients {
+		result[i] = *recipients[i].ToDTO()
+	}
+	return result, nil
+}
+
+// FollowCheckIn subscribes before the tally is read so no answer given in between is missed
+func (c *checkInService) FollowCheckIn(checkInId uint, viewer *dto.AuthenticatedUserDTO) (*CheckInFollow, error) {
+	checkIn, err := c.findCheckIn(checkInId)
+	if err != nil {
+		return nil, err
+	}
+	if !canManage(checkIn, viewer) {
+		return nil, ErrCheckInNotFound
+	}
+
+	events, stop := c.hub.Subscribe(checkInTopic(checkInId))
+
+	tally, err := c.tally(checkInId)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+
+	return &CheckInFollow{CheckIn: checkIn.ToDTO(tally), Events: events, Stop: stop}, nil
+}
+
+// Respond records the recipient's answer, they may change it while the check-in is open.
+// The first "need help" answer files a high priority report at the best location known for them,
+// the repository checks again that the check-in is open and no report was filed yet when it records the answer.
+func (c *checkInService) Respond(userId uint, checkInId uint, request *dto.RespondCheckInRequestDTO) (*dto.CheckInRecipientDTO, error) {
+	checkIn, err := c.findCheckIn(checkInId)
+	if err != nil {
+		return nil, err
+	}
+
+	recipient, err := c.repository.FindRecipient(checkInId, userId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCheckInNotFound
+		}
+		return nil, err
+	}
+
+	now := time.Now()
+	if !checkIn.IsOpen(now) {
+		return nil, ErrCheckInClosed
+	}
+
+	recipient.Answer = checkinModels.CheckInAnswer(request.Answer)
+	recipient.Note = request.Note
+	recipient.Latitude = request.Latitude
+	recipient.Longitude = request.Longitude
+	recipient.RespondedAt = &now
+
+	var report *reportModels.Report
+	if recipient.Answer == checkinModels.CheckInNeedHelp && recipient.ReportID == nil {
+		report, err = c.needHelpReport(checkIn, recipient)
+		if err != nil {
+			logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to prepare need help report: %v", err))
+		}
+	}
+
+	filed, err := c.repository.RecordAnswer(recipient, report)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCheckInClosed
+		}
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to record check-in answer: %v", err))
+		return nil, errors.New("unable to record answer")
+	}
+
+	if filed {
+		logger.GlobalLogger.Log(logger.INFO, "Check-in answer opened a report", map[string]interface{}{
+			"check_in_id": checkInId,
+			"user_id":     userId,
+			"report_id":   report.ID,
+		})
+	}
+
+	if user, err := c.repository.FindUserById(userId); err == nil {
+		recipient.User = *user
+	}
+	result := recipient.ToDTO()
+
+	c.hub.Publish(checkInTopic(checkInId), stream.Event{Name: EventAnswer, Data: result})
+	if tally, err := c.tally(checkInId); err == nil {
+		c.hub.Publish(checkInTopic(checkInId), stream.Event{Name: EventTally, Data: tally})
+	}
+	return result, nil
+}
+
+// needHelpReport builds the report for a "need help" answer. It is filed where the answer says the person is,
+// else at their last known location, else at the centre of an area check-in. Without any of those no report is filed,
+// the answer still shows up in the tally.
+func (c *checkInService) needHelpReport(checkIn *checkinModels.CheckIn, recipient *checkinModels.CheckInRecipient) (*reportModels.Report, error) {
+	var location *reportModels.ReportLocation
+	switch {
+	case recipient.Latitude != nil && recipient.Longitude != nil:
+		location = &reportModels.ReportLocation{Latitude: *recipient.Latitude, Longitude: *recipient.Longitude}
+	default:
+		known, err := c.repository.FindUserLocation(recipient.UserID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+		if known != nil {
+			location = &reportModels.ReportLocation{
+				Latitude:       known.Latitude,
+				Longitude:      known.Longitude,
+				AccuracyRadius: known.AccuracyRadius,
+			}
+		} else if checkIn.Scope == checkinModels.CheckInArea && checkIn.Latitude != nil && checkIn.Longitude != nil && checkIn.RadiusKm != nil {
+			location = &reportModels.ReportLocation{
+				Latitude:       *checkIn.Latitude,
+				Longitude:      *checkIn.Longitude,
+				AccuracyRadius: *checkIn.RadiusKm * 1000,
+			}
+		}
+	}
+	if location == nil {
+		logger.GlobalLogger.Log(logger.INFO, "Need help answer without a known location, no report filed", map[string]interface{}{
+			"check_in_id": checkIn.ID,
+			"user_id":     recipient.UserID,
+		})
+		return nil, nil
+	}
+
+	category, err := c.repository.FindOrCreateNeedHelpCategory()
+	if err != nil {
+		return nil, err
+	}
+
+	reporterId := recipient.UserID
+	return &reportModels.Report{
+		Title:      "Need help: " + checkIn.Title,
+		Summary:    recipient.Note,
+		CategoryID: category.ID,
+		ReporterID: &reporterId,
+		Status:     reportModels.ReportStatusPending,
+		Priority:   reportModels.ReportPriorityHigh,
+		Location:   *location,
+	}, nil
+}
+
+func (c *checkInService) CloseCheckIn(checkInId uint, viewer *dto.AuthenticatedUserDTO) (*dto.CheckInDTO, error) {
+	checkIn, err := c.findCheckIn(checkInId)
+	if err != nil {
+		return nil, err
+	}
+	if !canManage(checkIn, viewer) {
+		return nil, ErrCheckInNotFound
+	}
+	if checkIn.ClosedAt != nil {
+		return nil, ErrCheckInClosed
+	}
+
+	if err := c.repository.CloseCheckIn(checkInId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCheckInClosed
+		}
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to close check-in: %v", err))
+		return nil, errors.New("unable to close check-in")
+	}
+
+	closedAt := time.Now()
+	checkIn.ClosedAt = &closedAt
+
+	tally, err := c.tally(checkInId)
+	if err != nil {
+		return nil, err
+	}
+	result := checkIn.ToDTO(tally)
+
+	c.hub.Publish(checkInTopic(checkInId), stream.Event{Name: EventClosed, Data: result})
+	return result, nil
+}
+
+func withoutUser(userIds []uint, userId uint) []uint {
+	result := userIds[:0]
+	seen := map[uint]bool{}
+	for _, id := range userIds {
+		if id == userId || seen[id] {
+			continue
+		}
+		seen[id] = true
+		result = append(result, id)
+	}
+	return result
+}
+
+func displayName(user *models.User) string {
+	name := user.FirstName
+	if user.LastName != "" {
+		name += " " + user.LastName
+	}
+	if name == "" {
+		return "ResQ"
+	}
+	return name
+}
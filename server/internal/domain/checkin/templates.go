@@ -0,0 +1,35 @@
+package checkin
+
+import (
+	"fmt"
+	"resq/internal/infra/notifier"
+	"resq/pkg/constants"
+	checkinModels "resq/pkg/models/checkin"
+	"strings"
+)
+
+var checkInTemplate = notifier.NewTemplate("check_in",
+	"Are you safe? {{.Title}}",
+	`{{.Name}} wants to know whether you are safe.{{if .Message}}
+
+{{.Message}}{{end}}
+
+{{if .AnswerLink}}Let them know you are safe, or that you need help: {{.AnswerLink}}{{else}}Open ResQ to let them know you are safe, or that you need help.{{end}}`)
+
+// answerLink opens the check-in in the app so the recipient can answer, empty without APP_BASE_URL
+func answerLink(checkIn *checkinModels.CheckIn) string {
+	baseURL := strings.TrimRight(constants.AppBaseURL(), "/")
+	if baseURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/checkins/%d", baseURL, checkIn.ID)
+}
+
+func checkInData(checkIn *checkinModels.CheckIn) map[string]interface{} {
+	return map[string]interface{}{
+		"Name":       displayName(&checkIn.CreatedBy),
+		"Title":      checkIn.Title,
+		"Message":    checkIn.Message,
+		"AnswerLink": answerLink(checkIn),
+	}
+}
@@ -0,0 +1,190 @@
+package contact
+
+import (
+	"errors"
+	"net/http"
+	"resq/pkg/constants"
+	"resq/pkg/dto"
+	"resq/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ContactController interface {
+	InviteContact(ctx *gin.Context)
+	GetInvitations(ctx *gin.Context)
+	AcceptInvitation(ctx *gin.Context)
+	DeclineInvitation(ctx *gin.Context)
+	GetContacts(ctx *gin.Context)
+	UpdateContact(ctx *gin.Context)
+	RemoveContact(ctx *gin.Context)
+}
+
+type contactController struct {
+	service ContactService
+}
+
+func NewContactController(service ContactService) ContactController {
+	return &contactController{service: service}
+}
+
+func contactErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrContactNotFound), errors.Is(err, ErrInvitationNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrContactExists):
+		return http.StatusConflict
+	case errors.Is(err, ErrTooManyInvitations), errors.Is(err, ErrInvitationRateLimit), errors.Is(err, ErrRecentlyDeclined):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrUnverifiedInviter):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func (c *contactController) InviteContact(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var request dto.InviteContactRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	result, err := c.service.InviteContact(userId, &request)
+	if err != nil {
+		ctx.JSON(contactErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{constants.RequestData: result})
+}
+
+func (c *contactController) GetInvitations(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	result, err := c.service.GetInvitations(userId)
+	if err != nil {
+		ctx.JSON(contactErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (c *contactController) AcceptInvitation(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	contactId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid invitation id"})
+		return
+	}
+
+	result, err := c.service.AcceptInvitation(userId, contactId)
+	if err != nil {
+		ctx.JSON(contactErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (c *contactController) DeclineInvitation(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	contactId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid invitation id"})
+		return
+	}
+
+	if err := c.service.DeclineInvitation(userId, contactId); err != nil {
+		ctx.JSON(contactErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (c *contactController) GetContacts(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	result, err := c.service.GetContacts(userId)
+	if err != nil {
+		ctx.JSON(contactErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (c *contactController) UpdateContact(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	contactId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid contact id"})
+		return
+	}
+
+	var request dto.UpdateContactRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	result, err := c.service.UpdateContact(userId, contactId, &request)
+	if err != nil {
+		ctx.JSON(contactErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (c *contactController) RemoveContact(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	contactId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid contact id"})
+		return
+	}
+
+	if err := c.service.RemoveContact(userId, contactId); err != nil {
+		ctx.JSON(contactErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,242 @@
+package contact
+
+import (
+	"fmt"
+	"resq/pkg/models"
+	contactModels "resq/pkg/models/contact"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ContactRepository interface {
+	FindUserById(userId uint) (*models.User, error)
+	FindVerifiedUserByEmail(email string) (*models.User, error)
+	FindVerifiedUserByPhone(phone string) (*models.User, error)
+	CreateContact(contact *contactModels.Contact) error
+	FindContactById(contactId uint) (*contactModels.Contact, error)
+	FindOpenContact(requesterId uint, addresseeId *uint, email string, phone string) (*contactModels.Contact, error)
+	HasAcceptedContact(userId uint, otherUserId uint) (bool, error)
+	CountPendingInvitations(requesterId uint) (int64, error)
+	CountInvitationsSince(requesterId uint, since time.Time) (int64, error)
+	FindDeclinedInvitation(requesterId uint, addresseeId *uint, email string, phone string, since time.Time) (*contactModels.Contact, error)
+	FindIncomingInvitations(user *models.User) ([]contactModels.Contact, error)
+	FindOutgoingInvitations(userId uint) ([]contactModels.Contact, error)
+	FindAcceptedContacts(userId uint) ([]contactModels.Contact, error)
+	RespondToInvitation(contactId uint, addresseeId uint, status contactModels.ContactStatus) error
+	UpdateContact(contactId uint, changes map[string]interface{}) error
+	DeleteContact(contactId uint) error
+}
+
+type contactRepository struct {
+	db *gorm.DB
+}
+
+func NewContactRepository(db *gorm.DB) ContactRepository {
+	return &contactRepository{db: db}
+}
+
+func (c *contactRepository) FindUserById(userId uint) (*models.User, error) {
+	var user models.User
+	result := c.db.Where("id = ?", userId).First(&user)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find user: %w", result.Error)
+	}
+	return &user, nil
+}
+
+func (c *contactRepository) FindVerifiedUserByEmail(email string) (*models.User, error) {
+	var user models.User
+	result := c.db.Where("email = ? AND email_verified_at IS NOT NULL", email).First(&user)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find user: %w", result.Error)
+	}
+	return &user, nil
+}
+
+func (c *contactRepository) FindVerifiedUserByPhone(phone string) (*models.User, error) {
+	var user models.User
+	result := c.db.Where("phone = ? AND phone_verified_at IS NOT NULL", phone).First(&user)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find user: %w", result.Error)
+	}
+	return &user, nil
+}
+
+func (c *contactRepository) CreateContact(contact *contactModels.Contact) error {
+	if err := c.db.Omit("Requester", "Addressee").Create(contact).Error; err != nil {
+		return fmt.Errorf("unable to create contact %w", err)
+	}
+	return nil
+}
+
+func (c *contactRepository) FindContactById(contactId uint) (*contactModels.Contact, error) {
+	var contact contactModels.Contact
+	result := c.db.Preload("Requester").Preload("Addressee").Where("id = ?", contactId).First(&contact)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find contact: %w", result.Error)
+	}
+	return &contact, nil
+}
+
+// invitee matches contacts addressed to the account or to either address
+func (c *contactRepository) invitee(addresseeId *uint, email string, phone string) *gorm.DB {
+	invitee := c.db.Where("1 = 0")
+	if addresseeId != nil {
+		invitee = invitee.Or("addressee_id = ?", *addresseeId)
+	}
+	if email != "" {
+		invitee = invitee.Or("invite_email = ?", email)
+	}
+	if phone != "" {
+		invitee = invitee.Or("invite_phone = ?", phone)
+	}
+	return invitee
+}
+
+// FindOpenContact looks for a pending or accepted contact between the requester and the invitee, in either direction
+func (c *contactRepository) FindOpenContact(requesterId uint, addresseeId *uint, email string, phone string) (*contactModels.Contact, error) {
+	invitee := c.invitee(addresseeId, email, phone)
+
+	direction := c.db.Where(c.db.Where("requester_id = ?", requesterId).Where(invitee))
+	if addresseeId != nil {
+		direction = direction.Or("requester_id = ? AND addressee_id = ?", *addresseeId, requesterId)
+	}
+
+	var contact contactModels.Contact
+	result := c.db.Where("status IN ?", []contactModels.ContactStatus{contactModels.ContactPending, contactModels.ContactAccepted}).
+		Where(direction).
+		First(&contact)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find contact: %w", result.Error)
+	}
+	return &contact, nil
+}
+
+func (c *contactRepository) HasAcceptedContact(userId uint, otherUserId uint) (bool, error) {
+	var count int64
+	result := c.db.Model(&contactModels.Contact{}).
+		Where("status = ?", contactModels.ContactAccepted).
+		Where("(requester_id = ? AND addressee_id = ?) OR (requester_id = ? AND addressee_id = ?)", userId, otherUserId, otherUserId, userId).
+		Count(&count)
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to find contact %w", result.Error)
+	}
+	return count > 0, nil
+}
+
+func (c *contactRepository) CountPendingInvitations(requesterId uint) (int64, error) {
+	var count int64
+	result := c.db.Model(&contactModels.Contact{}).
+		Where("requester_id = ? AND status = ?", requesterId, contactModels.ContactPending).
+		Count(&count)
+	if result.Error != nil {
+		return 0, fmt.Errorf("unable to count invitations %w", result.Error)
+	}
+	return count, nil
+}
+
+// CountInvitationsSince counts every invitation the requester sent since then, withdrawn ones are only soft deleted and count too
+func (c *contactRepository) CountInvitationsSince(requesterId uint, since time.Time) (int64, error) {
+	var count int64
+	result := c.db.Unscoped().Model(&contactModels.Contact{}).
+		Where("requester_id = ? AND created_at >= ?", requesterId, since).
+		Count(&count)
+	if result.Error != nil {
+		return 0, fmt.Errorf("unable to count invitations %w", result.Error)
+	}
+	return count, nil
+}
+
+// FindDeclinedInvitation looks for an invitation from the requester the invitee declined since then, removed afterwards or not
+func (c *contactRepository) FindDeclinedInvitation(requesterId uint, addresseeId *uint, email string, phone string, since time.Time) (*contactModels.Contact, error) {
+	var contact contactModels.Contact
+	result := c.db.Unscoped().
+		Where("requester_id = ? AND status = ? AND responded_at >= ?", requesterId, contactModels.ContactDeclined, since).
+		Where(c.invitee(addresseeId, email, phone)).
+		First(&contact)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find invitation: %w", result.Error)
+	}
+	return &contact, nil
+}
+
+// FindIncomingInvitations matches invitations addressed to the user and ones sent to their verified email or phone
+func (c *contactRepository) FindIncomingInvitations(user *models.User) ([]contactModels.Contact, error) {
+	addressed := c.db.Where("addressee_id = ?", user.ID)
+	if user.Email != "" && user.EmailVerifiedAt != nil {
+		addressed = addressed.Or("addressee_id IS NULL AND invite_email = ?", user.Email)
+	}
+	if user.Phone != "" && user.PhoneVerifiedAt != nil {
+		addressed = addressed.Or("addressee_id IS NULL AND invite_phone = ?", user.Phone)
+	}
+
+	var contacts []contactModels.Contact
+	result := c.db.Preload("Requester").Preload("Addressee").
+		Where("status = ? AND requester_id <> ?", contactModels.ContactPending, user.ID).
+		Where(addressed).
+		Order("created_at desc").
+		Find(&contacts)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find invitations: %w", result.Error)
+	}
+	return contacts, nil
+}
+
+func (c *contactRepository) FindOutgoingInvitations(userId uint) ([]contactModels.Contact, error) {
+	var contacts []contactModels.Contact
+	result := c.db.Preload("Requester").Preload("Addressee").
+		Where("requester_id = ? AND status = ?", userId, contactModels.ContactPending).
+		Order("created_at desc").
+		Find(&contacts)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find invitations: %w", result.Error)
+	}
+	return contacts, nil
+}
+
+func (c *contactRepository) FindAcceptedContacts(userId uint) ([]contactModels.Contact, error) {
+	var contacts []contactModels.Contact
+	result := c.db.Preload("Requester").Preload("Addressee").
+		Where("status = ? AND (requester_id = ? OR addressee_id = ?)", contactModels.ContactAccepted, userId, userId).
+		Order("responded_at desc").
+		Find(&contacts)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find contacts: %w", result.Error)
+	}
+	return contacts, nil
+}
+
+// RespondToInvitation settles a pending invitation once, a second answer finds nothing to update
+func (c *contactRepository) RespondToInvitation(contactId uint, addresseeId uint, status contactModels.ContactStatus) error {
+	result := c.db.Model(&contactModels.Contact{}).
+		Where("id = ? AND status = ?", contactId, contactModels.ContactPending).
+		Updates(map[string]interface{}{
+			"addressee_id": addresseeId,
+			"status":       status,
+			"responded_at": time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("unable to respond to invitation %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("unable to respond to invitation: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+func (c *contactRepository) UpdateContact(contactId uint, changes map[string]interface{}) error {
+	result := c.db.Model(&contactModels.Contact{}).Where("id = ?", contactId).Updates(changes)
+	if result.Error != nil {
+		return fmt.Errorf("unable to update contact %w", result.Error)
+	}
+	return nil
+}
+
+func (c *contactRepository) DeleteContact(contactId uint) error {
+	result := c.db.Delete(&contactModels.Contact{}, contactId)
+	if result.Error != nil {
+		return fmt.Errorf("unable to delete contact %w", result.Error)
+	}
+	return nil
+}
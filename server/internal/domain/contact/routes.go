@@ -0,0 +1,28 @@
+package contact
+
+import (
+	"resq/internal/infra/middleware"
+	"resq/internal/infra/notifier"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func ContactRoutes(router *gin.Engine, db *gorm.DB) {
+	contactRepository := NewContactRepository(db)
+	contactService := NewContactService(contactRepository, notifier.GlobalNotifier)
+	contactController := NewContactController(contactService)
+
+	contacts := router.Group("contacts")
+
+	contacts.Use(middleware.AuthMiddleware())
+	{
+		contacts.GET("", contactController.GetContacts)
+		contacts.POST("/invitations", contactController.InviteContact)
+		contacts.GET("/invitations", contactController.GetInvitations)
+		contacts.POST("/invitations/:id/accept", contactController.AcceptInvitation)
+		contacts.POST("/invitations/:id/decline", contactController.DeclineInvitation)
+		contacts.PATCH("/:id", contactController.UpdateContact)
+		contacts.DELETE("/:id", contactController.RemoveContact)
+	}
+}
@@ -0,0 +1,360 @@
+package contact
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/notifier"
+	"resq/pkg/dto"
+	"resq/pkg/models"
+	contactModels "resq/pkg/models/contact"
+	"resq/pkg/utils"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxPendingInvitations caps unanswered invitations per user, every invitation sends an email or a text
+	MaxPendingInvitations = 20
+	// MaxInvitationsPerWindow caps the invitations a user sends within InvitationWindow,
+	// withdrawn and declined ones count too so sending and withdrawing cannot get around it
+	MaxInvitationsPerWindow = 30
+	InvitationWindow        = 24 * time.Hour
+	// DeclineCooldown is how long someone who declined is left alone before the same user may invite them again
+	DeclineCooldown = 30 * 24 * time.Hour
+)
+
+var (
+	ErrContactNotFound      = errors.New("contact not found")
+	ErrInvitationNotFound   = errors.New("invitation not found")
+	ErrContactExists        = errors.New("an invitation or contact with this person already exists")
+	ErrInviteTargetRequired = errors.New("invite by either email or phone")
+	ErrCannotInviteSelf     = errors.New("you cannot invite yourself")
+	ErrTooManyInvitations   = errors.New("too many pending invitations")
+	ErrInvitationRateLimit  = errors.New("too many invitations sent recently, try again later")
+	ErrRecentlyDeclined     = errors.New("this person declined your invitation recently")
+	ErrUnverifiedInviter    = errors.New("verify your account before inviting contacts")
+)
+
+type ContactService interface {
+	InviteContact(userId uint, request *dto.InviteContactRequestDTO) (*dto.ContactDTO, error)
+	GetInvitations(userId uint) (*dto.ContactInvitationsDTO, error)
+	AcceptInvitation(userId uint, contactId uint) (*dto.ContactDTO, error)
+	DeclineInvitation(userId uint, contactId uint) error
+	GetContacts(userId uint) ([]dto.ContactDTO, error)
+	UpdateContact(userId uint, contactId uint, request *dto.UpdateContactRequestDTO) (*dto.ContactDTO, error)
+	RemoveContact(userId uint, contactId uint) error
+}
+
+type contactService struct {
+	repository ContactRepository
+	notifier   notifier.NotificationService
+}
+
+func NewContactService(repo ContactRepository, n notifier.NotificationService) ContactService {
+	return &contactService{repository: repo, notifier: n}
+}
+
+// InviteContact records the invitation and tells the invitee, who does not need an account yet
+func (c *contactService) InviteContact(userId uint, request *dto.InviteContactRequestDTO) (*dto.ContactDTO, error) {
+	email, phone, err := inviteTarget(request)
+	if err != nil {
+		return nil, err
+	}
+
+	requester, err := c.repository.FindUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	// every invitation sends an email or a text to an address the requester picks
+	if !requester.IsVerified() {
+		return nil, ErrUnverifiedInviter
+	}
+
+	if (email != "" && email == requester.Email) || (phone != "" && phone == requester.Phone) {
+		return nil, ErrCannotInviteSelf
+	}
+
+	// only a verified account is linked right away, anyone else has to prove they own the address first
+	var addressee *models.User
+	if email != "" {
+		addressee, _ = c.repository.FindVerifiedUserByEmail(email)
+	} else {
+		addressee, _ = c.repository.FindVerifiedUserByPhone(phone)
+	}
+
+	var addresseeId *uint
+	if addressee != nil {
+		if addressee.ID == userId {
+			return nil, ErrCannotInviteSelf
+		}
+		addresseeId = &addressee.ID
+	}
+
+	if _, err := c.repository.FindOpenContact(userId, addresseeId, email, phone); err == nil {
+		return nil, ErrContactExists
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	now := time.Now()
+	if _, err := c.repository.FindDeclinedInvitation(userId, addresseeId, email, phone, now.Add(-DeclineCooldown)); err == nil {
+		return nil, ErrRecentlyDeclined
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	pending, err := c.repository.CountPendingInvitations(userId)
+	if err != nil {
+		return nil, err
+	}
+	if pending >= MaxPendingInvitations {
+		return nil, ErrTooManyInvitations
+	}
+
+	sent, err := c.repository.CountInvitationsSince(userId, now.Add(-InvitationWindow))
+	if err != nil {
+		return nil, err
+	}
+	if sent >= MaxInvitationsPerWindow {
+		return nil, ErrInvitationRateLimit
+	}
+
+	contact := &contactModels.Contact{
+		RequesterID:          userId,
+		AddresseeID:          addresseeId,
+		InviteEmail:          email,
+		InvitePhone:          phone,
+		Status:               contactModels.ContactPending,
+		RequesterNotifyOnSOS: true,
+		AddresseeNotifyOnSOS: true,
+	}
+	if err := c.repository.CreateContact(contact); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to create contact invitation: %v", err))
+		return nil, errors.New("unable to send invitation")
+	}
+
+	// the invitation stands even when it could not be delivered, the invitee sees it once they sign in
+	c.notifyInvitee(contact, requester, addressee)
+
+	contact.Requester = *requester
+	contact.Addressee = addressee
+	return contact.ToDTO(userId), nil
+}
+
+func inviteTarget(request *dto.InviteContactRequestDTO) (string, string, error) {
+	email := strings.TrimSpace(request.Email)
+	phone := strings.TrimSpace(request.Phone)
+
+	if (email == "") == (phone == "") {
+		return "", "", ErrInviteTargetRequired
+	}
+
+	if email != "" {
+		sanitizedEmail, err := utils.SanitizeEmail(email)
+		return sanitizedEmail, "", err
+	}
+
+	normalizedPhone, err := utils.NormalizePhone(phone)
+	return "", normalizedPhone, err
+}
+
+// notifyInvitee reaches an invitee with an account like any other notification, anyone else at the address they were invited by
+func (c *contactService) notifyInvitee(contact *contactModels.Contact, requester *models.User, addressee *models.User) {
+	name := strings.TrimSpace(requester.FirstName + " " + requester.LastName)
+	if name == "" {
+		name = "Someone"
+	}
+
+	request := notifier.Request{
+		Template: invitationTemplate,
+		Data:     map[string]interface{}{"Name": name, "SignInWith": ""},
+		Link:     map[string]string{"contact_id": strconv.FormatUint(uint64(contact.ID), 10)},
+	}
+	switch {
+	case addressee != nil:
+		request.UserID = addressee.ID
+	case contact.InviteEmail != "":
+		request.Email = contact.InviteEmail
+		request.Data = map[string]interface{}{"Name": name, "SignInWith": "this email address"}
+	default:
+		request.Phone = contact.InvitePhone
+		request.Data = map[string]interface{}{"Name": name, "SignInWith": "this number"}
+	}
+
+	if _, err := c.notifier.Notify(context.Background(), request); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to deliver contact invitation: %v", err))
+	}
+}
+
+func (c *contactService) GetInvitations(userId uint) (*dto.ContactInvitationsDTO, error) {
+	user, err := c.repository.FindUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	incoming, err := c.repository.FindIncomingInvitations(user)
+	if err != nil {
+		return nil, err
+	}
+
+	outgoing, err := c.repository.FindOutgoingInvitations(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ContactInvitationsDTO{
+		Incoming: contactsToDTO(incoming, userId),
+		Outgoing: contactsToDTO(outgoing, userId),
+	}, nil
+}
+
+// findIncomingInvitation hands out a pending invitation only to the user it was sent to
+func (c *contactService) findIncomingInvitation(userId uint, contactId uint) (*contactModels.Contact, error) {
+	contact, err := c.repository.FindContactById(contactId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationNotFound
+		}
+		return nil, err
+	}
+
+	if contact.Status != contactModels.ContactPending || contact.IsRequester(userId) {
+		return nil, ErrInvitationNotFound
+	}
+
+	if contact.AddresseeID != nil {
+		if !contact.IsAddressee(userId) {
+			return nil, ErrInvitationNotFound
+		}
+		return contact, nil
+	}
+
+	user, err := c.repository.FindUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	emailMatches := contact.InviteEmail != "" && contact.InviteEmail == user.Email && user.EmailVerifiedAt != nil
+	phoneMatches := contact.InvitePhone != "" && contact.InvitePhone == user.Phone && user.PhoneVerifiedAt != nil
+	if !emailMatches && !phoneMatches {
+		return nil, ErrInvitationNotFound
+	}
+	return contact, nil
+}
+
+func (c *contactService) respond(userId uint, contactId uint, status contactModels.ContactStatus) (*contactModels.Contact, error) {
+	contact, err := c.findIncomingInvitation(userId, contactId)
+	if err != nil {
+		return nil, err
+	}
+
+	if status == contactModels.ContactAccepted {
+		exists, err := c.repository.HasAcceptedContact(userId, contact.RequesterID)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrContactExists
+		}
+	}
+
+	if err := c.repository.RespondToInvitation(contact.ID, userId, status); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvitationNotFound
+		}
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to respond to invitation: %v", err))
+		return nil, errors.New("unable to respond to invitation")
+	}
+
+	return c.repository.FindContactById(contact.ID)
+}
+
+func (c *contactService) AcceptInvitation(userId uint, contactId uint) (*dto.ContactDTO, error) {
+	contact, err := c.respond(userId, contactId, contactModels.ContactAccepted)
+	if err != nil {
+		return nil, err
+	}
+	return contact.ToDTO(userId), nil
+}
+
+func (c *contactService) DeclineInvitation(userId uint, contactId uint) error {
+	_, err := c.respond(userId, contactId, contactModels.ContactDeclined)
+	return err
+}
+
+func (c *contactService) GetContacts(userId uint) ([]dto.ContactDTO, error) {
+	contacts, err := c.repository.FindAcceptedContacts(userId)
+	if err != nil {
+		return nil, err
+	}
+	return contactsToDTO(contacts, userId), nil
+}
+
+// findOwnContact returns a contact the user is a party of, pending invitations they sent included
+func (c *contactService) findOwnContact(userId uint, contactId uint) (*contactModels.Contact, error) {
+	contact, err := c.repository.FindContactById(contactId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrContactNotFound
+		}
+		return nil, err
+	}
+
+	if !contact.IsRequester(userId) && !contact.IsAddressee(userId) {
+		return nil, ErrContactNotFound
+	}
+	return contact, nil
+}
+
+// UpdateContact changes the user's own side of a contact, whether their SOS alerts the other person
+func (c *contactService) UpdateContact(userId uint, contactId uint, request *dto.UpdateContactRequestDTO) (*dto.ContactDTO, error) {
+	contact, err := c.findOwnContact(userId, contactId)
+	if err != nil {
+		return nil, err
+	}
+
+	column := "addressee_notify_on_sos"
+	if contact.IsRequester(userId) {
+		column = "requester_notify_on_sos"
+	}
+
+	if err := c.repository.UpdateContact(contact.ID, map[string]interface{}{column: *request.NotifyOnSOS}); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to update contact: %v", err))
+		return nil, errors.New("unable to update contact")
+	}
+
+	if contact.IsRequester(userId) {
+		contact.RequesterNotifyOnSOS = *request.NotifyOnSOS
+	} else {
+		contact.AddresseeNotifyOnSOS = *request.NotifyOnSOS
+	}
+	return contact.ToDTO(userId), nil
+}
+
+// RemoveContact ends a contact for both sides, or withdraws an invitation the user sent
+func (c *contactService) RemoveContact(userId uint, contactId uint) error {
+	contact, err := c.findOwnContact(userId, contactId)
+	if err != nil {
+		return err
+	}
+
+	if err := c.repository.DeleteContact(contact.ID); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to remove contact: %v", err))
+		return errors.New("unable to remove contact")
+	}
+	return nil
+}
+
+func contactsToDTO(contacts []contactModels.Contact, viewerId uint) []dto.ContactDTO {
+	result := make([]dto.ContactDTO, len(contacts))
+	for i := range contacts {
+		result[i] = *contacts[i].ToDTO(viewerId)
+	}
+	return result
+}
@@ -0,0 +1,9 @@
+package contact
+
+import "resq/internal/infra/notifier"
+
+var invitationTemplate = notifier.NewTemplate("contact_invitation",
+	"{{.Name}} added you as an emergency contact",
+	`{{.Name}} added you as an emergency contact on ResQ. If you accept, you will be alerted when they raise an SOS.
+
+{{if .SignInWith}}Sign in to ResQ with {{.SignInWith}} to accept or decline the invitation.{{else}}Open ResQ to accept or decline the invitation.{{end}}`)
@@ -0,0 +1,119 @@
+package feed
+
+import (
+	"io"
+	"net/http"
+	"resq/pkg/constants"
+	"resq/pkg/dto"
+	"resq/pkg/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// streamKeepAlive keeps proxies from closing a quiet stream
+	streamKeepAlive = 15 * time.Second
+	// streamRetry is how long a disconnected EventSource waits before reconnecting, in milliseconds
+	streamRetry = 3000
+	// EventReset tells the client it missed more than the replay holds and should reload the feed
+	EventReset = "reset"
+)
+
+type FeedController interface {
+	GetEvents(ctx *gin.Context)
+	StreamFeed(ctx *gin.Context)
+}
+
+type feedController struct {
+	service FeedService
+}
+
+func NewFeedController(service FeedService) FeedController {
+	return &feedController{service: service}
+}
+
+func (f *feedController) GetEvents(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var query dto.FeedEventsQueryDTO
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, query)})
+		return
+	}
+
+	result, err := f.service.GetEvents(userId, &query)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+// StreamFeed sends report status changes, responder comments and broadcast alerts meant for the user
+// as server-sent events, a reconnecting client resumes after the Last-Event-ID it already has
+func (f *feedController) StreamFeed(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var lastEventId uint
+	if value := ctx.GetHeader("Last-Event-ID"); value != "" {
+		lastEventId, _ = utils.ParseId(value)
+	}
+
+	follow, err := f.service.FollowFeed(userId, lastEventId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{constants.RequestError: err.Error()})
+		return
+	}
+	defer follow.Stop()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+
+	ctx.Render(-1, sse.Event{Event: "ready", Retry: streamRetry, Data: gin.H{"last_event_id": lastEventId}})
+	if follow.Truncated {
+		ctx.Render(-1, sse.Event{Event: EventReset, Data: gin.H{"last_event_id": lastEventId}})
+	}
+	lastSent := lastEventId
+	for _, event := range follow.Replay {
+		ctx.Render(-1, sse.Event{Id: strconv.FormatUint(uint64(event.ID), 10), Event: event.Kind, Data: event})
+		lastSent = event.ID
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case event, ok := <-follow.Events:
+			if !ok {
+				// fell too far behind, the client reconnects and catches up from Last-Event-ID
+				return false
+			}
+			// already sent as part of the replay
+			if id, err := utils.ParseId(event.ID); err == nil && id <= lastSent {
+				return true
+			}
+			ctx.Render(-1, sse.Event{Id: event.ID, Event: event.Name, Data: event.Data})
+			return true
+		}
+	})
+}
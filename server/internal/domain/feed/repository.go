@@ -0,0 +1,60 @@
+package feed
+
+import (
+	"fmt"
+	feedModels "resq/pkg/models/feed"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type FeedRepository interface {
+	FindEventsAfter(userId uint, afterId uint, limit int) ([]feedModels.FeedEvent, error)
+	FindLatestEvents(userId uint, limit int) ([]feedModels.FeedEvent, error)
+	DeleteEventsBefore(cutoff time.Time) (int64, error)
+}
+
+type feedRepository struct {
+	db *gorm.DB
+}
+
+func NewFeedRepository(db *gorm.DB) FeedRepository {
+	return &feedRepository{db: db}
+}
+
+func (f *feedRepository) FindEventsAfter(userId uint, afterId uint, limit int) ([]feedModels.FeedEvent, error) {
+	var events []feedModels.FeedEvent
+	result := f.db.Where("user_id = ? AND id > ?", userId, afterId).
+		Order("id asc").
+		Limit(limit).
+		Find(&events)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find feed events: %w", result.Error)
+	}
+	return events, nil
+}
+
+// FindLatestEvents returns the user's newest events, oldest first
+func (f *feedRepository) FindLatestEvents(userId uint, limit int) ([]feedModels.FeedEvent, error) {
+	var events []feedModels.FeedEvent
+	result := f.db.Where("user_id = ?", userId).
+		Order("id desc").
+		Limit(limit).
+		Find(&events)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find feed events: %w", result.Error)
+	}
+
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
+
+func (f *feedRepository) DeleteEventsBefore(cutoff time.Time) (int64, error) {
+	result := f.db.Where("created_at < ?", cutoff).Delete(&feedModels.FeedEvent{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("unable to delete feed events %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
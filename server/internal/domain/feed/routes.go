@@ -0,0 +1,30 @@
+package feed
+
+import (
+	"fmt"
+	"resq/internal/infra/jobs"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/middleware"
+	"resq/internal/infra/stream"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func FeedRoutes(router *gin.Engine, db *gorm.DB) {
+	feedRepository := NewFeedRepository(db)
+	feedService := NewFeedService(feedRepository, stream.GlobalHub)
+	feedController := NewFeedController(feedService)
+
+	if err := jobs.GlobalRunner.Cron("feed-retention", PruneSchedule, feedService.PruneEvents); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to schedule feed retention: %v", err))
+	}
+
+	feed := router.Group("feed")
+
+	feed.Use(middleware.AuthMiddleware())
+	{
+		feed.GET("", feedController.GetEvents)
+		feed.GET("/stream", feedController.StreamFeed)
+	}
+}
@@ -0,0 +1,106 @@
+package feed
+
+import (
+	"context"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/stream"
+	"resq/pkg/dto"
+	feedModels "resq/pkg/models/feed"
+	"time"
+)
+
+const (
+	// replayLimit caps how many stored events a reconnecting client is sent before live ones
+	replayLimit = 500
+	// defaultPageSize is how many events a client polling without a cursor gets
+	defaultPageSize = 50
+	// Retention is how long events stay available for catching up
+	Retention = 7 * 24 * time.Hour
+	// PruneSchedule is when events past Retention are deleted
+	PruneSchedule = "@hourly"
+)
+
+// FeedFollow is a user's live feed, the events missed since the last one they saw followed by new ones.
+// Truncated means more was missed than fits in the replay, the client should reload the feed first.
+type FeedFollow struct {
+	Replay    []dto.FeedEventDTO
+	Truncated bool
+	Events    <-chan stream.Event
+	Stop      func()
+}
+
+type FeedService interface {
+	GetEvents(userId uint, query *dto.FeedEventsQueryDTO) ([]dto.FeedEventDTO, error)
+	FollowFeed(userId uint, lastEventId uint) (*FeedFollow, error)
+	PruneEvents(ctx context.Context) error
+}
+
+type feedService struct {
+	repository FeedRepository
+	hub        *stream.Hub
+}
+
+func NewFeedService(repo FeedRepository, hub *stream.Hub) FeedService {
+	return &feedService{repository: repo, hub: hub}
+}
+
+// GetEvents pages forward from query.After, or returns the newest events when no cursor is given
+func (f *feedService) GetEvents(userId uint, query *dto.FeedEventsQueryDTO) ([]dto.FeedEventDTO, error) {
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+
+	var events []feedModels.FeedEvent
+	var err error
+	if query.After > 0 {
+		events, err = f.repository.FindEventsAfter(userId, query.After, limit)
+	} else {
+		events, err = f.repository.FindLatestEvents(userId, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return eventsToDTO(events), nil
+}
+
+// FollowFeed subscribes before reading the stored events so nothing published in between is lost,
+// the caller skips live events it already got from the replay. Without a lastEventId only new events are sent.
+func (f *feedService) FollowFeed(userId uint, lastEventId uint) (*FeedFollow, error) {
+	events, stop := f.hub.Subscribe(feedModels.Topic(userId))
+	follow := &FeedFollow{Replay: []dto.FeedEventDTO{}, Events: events, Stop: stop}
+	if lastEventId == 0 {
+		return follow, nil
+	}
+
+	missed, err := f.repository.FindEventsAfter(userId, lastEventId, replayLimit)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+	follow.Replay = eventsToDTO(missed)
+	follow.Truncated = len(missed) == replayLimit
+	return follow, nil
+}
+
+// PruneEvents is the scheduled job that drops events older than Retention
+func (f *feedService) PruneEvents(ctx context.Context) error {
+	deleted, err := f.repository.DeleteEventsBefore(time.Now().Add(-Retention))
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		logger.GlobalLogger.Log(logger.INFO, "Feed events pruned", map[string]interface{}{
+			"deleted": deleted,
+		})
+	}
+	return nil
+}
+
+func eventsToDTO(events []feedModels.FeedEvent) []dto.FeedEventDTO {
+	result := make([]dto.FeedEventDTO, len(events))
+	for i := range events {
+		result[i] = *events[i].ToDTO()
+	}
+	return result
+}
@@ -0,0 +1,227 @@
+package notification
+
+import (
+	"errors"
+	"net/http"
+	"resq/pkg/constants"
+	"resq/pkg/dto"
+	"resq/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationController interface {
+	GetNotifications(ctx *gin.Context)
+	MarkNotificationRead(ctx *gin.Context)
+	MarkAllNotificationsRead(ctx *gin.Context)
+	GetPreferences(ctx *gin.Context)
+	UpdatePreferences(ctx *gin.Context)
+	RegisterDevice(ctx *gin.Context)
+	RefreshDevice(ctx *gin.Context)
+	GetDevices(ctx *gin.Context)
+	RemoveDevice(ctx *gin.Context)
+}
+
+type notificationController struct {
+	service NotificationService
+}
+
+func NewNotificationController(service NotificationService) NotificationController {
+	return &notificationController{service: service}
+}
+
+func notificationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrNotificationNotFound), errors.Is(err, ErrDeviceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrTooManyDevices):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrInvalidQuietHours), errors.Is(err, ErrInvalidTimeZone):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (n *notificationController) GetNotifications(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var query dto.NotificationsQueryDTO
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, query)})
+		return
+	}
+
+	result, err := n.service.GetNotifications(userId, &query)
+	if err != nil {
+		ctx.JSON(notificationErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (n *notificationController) MarkNotificationRead(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	notificationId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid notification id"})
+		return
+	}
+
+	if err := n.service.MarkNotificationRead(userId, notificationId); err != nil {
+		ctx.JSON(notificationErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (n *notificationController) MarkAllNotificationsRead(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	if err := n.service.MarkAllNotificationsRead(userId); err != nil {
+		ctx.JSON(notificationErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (n *notificationController) GetPreferences(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	result, err := n.service.GetPreferences(userId)
+	if err != nil {
+		ctx.JSON(notificationErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (n *notificationController) UpdatePreferences(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var request dto.NotificationPreferenceRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	result, err := n.service.UpdatePreferences(userId, &request)
+	if err != nil {
+		ctx.JSON(notificationErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (n *notificationController) RegisterDevice(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var request dto.RegisterDeviceRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	result, err := n.service.RegisterDevice(userId, &request)
+	if err != nil {
+		ctx.JSON(notificationErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{constants.RequestData: result})
+}
+
+func (n *notificationController) RefreshDevice(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	deviceId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid device id"})
+		return
+	}
+
+	var request dto.RefreshDeviceRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	result, err := n.service.RefreshDevice(userId, deviceId, &request)
+	if err != nil {
+		ctx.JSON(notificationErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (n *notificationController) GetDevices(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	result, err := n.service.GetDevices(userId)
+	if err != nil {
+		ctx.JSON(notificationErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (n *notificationController) RemoveDevice(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	deviceId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid device id"})
+		return
+	}
+
+	if err := n.service.RemoveDevice(userId, deviceId); err != nil {
+		ctx.JSON(notificationErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
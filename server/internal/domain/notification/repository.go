@@ -0,0 +1,179 @@
+package notification
+
+import (
+	"fmt"
+	notificationModels "resq/pkg/models/notification"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type NotificationRepository interface {
+	FindNotifications(userId uint, beforeId uint, limit int, unreadOnly bool) ([]notificationModels.Notification, error)
+	MarkNotificationRead(userId uint, notificationId uint) error
+	MarkAllNotificationsRead(userId uint) error
+	FindPreference(userId uint) (*notificationModels.NotificationPreference, error)
+	SavePreference(preference *notificationModels.NotificationPreference) error
+	FindDevices(userId uint) ([]notificationModels.Device, error)
+	FindDevice(userId uint, deviceId uint) (*notificationModels.Device, error)
+	FindDeviceByToken(token string) (*notificationModels.Device, error)
+	CountActiveDevices(userId uint) (int64, error)
+	RegisterDevice(device *notificationModels.Device) error
+	RefreshDevice(device *notificationModels.Device) error
+	DeleteDevice(userId uint, deviceId uint) error
+	DeleteStaleDevices(disabledBefore time.Time, seenBefore time.Time) (int64, error)
+}
+
+type notificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) NotificationRepository {
+	return &notificationRepository{db: db}
+}
+
+// FindNotifications returns the user's notifications newest first, older than beforeId when it is set
+func (n *notificationRepository) FindNotifications(userId uint, beforeId uint, limit int, unreadOnly bool) ([]notificationModels.Notification, error) {
+	query := n.db.Where("user_id = ?", userId)
+	if beforeId > 0 {
+		query = query.Where("id < ?", beforeId)
+	}
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var notifications []notificationModels.Notification
+	if err := query.Order("id desc").Limit(limit).Find(&notifications).Error; err != nil {
+		return nil, fmt.Errorf("unable to find notifications: %w", err)
+	}
+	return notifications, nil
+}
+
+// MarkNotificationRead keeps the first read time, a notification of someone else is reported as not found
+func (n *notificationRepository) MarkNotificationRead(userId uint, notificationId uint) error {
+	var notification notificationModels.Notification
+	result := n.db.Select("id").Where("id = ? AND user_id = ?", notificationId, userId).First(&notification)
+	if result.Error != nil {
+		return fmt.Errorf("unable to find notification: %w", result.Error)
+	}
+
+	if err := n.db.Model(&notificationModels.Notification{}).
+		Where("id = ? AND read_at IS NULL", notificationId).
+		Update("read_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("unable to mark notification read %w", err)
+	}
+	return nil
+}
+
+func (n *notificationRepository) MarkAllNotificationsRead(userId uint) error {
+	if err := n.db.Model(&notificationModels.Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userId).
+		Update("read_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("unable to mark notifications read %w", err)
+	}
+	return nil
+}
+
+// FindPreference returns the defaults for a user who never saved any
+func (n *notificationRepository) FindPreference(userId uint) (*notificationModels.NotificationPreference, error) {
+	preference := notificationModels.DefaultPreference(userId)
+	if err := n.db.Where("user_id = ?", userId).Limit(1).Find(preference).Error; err != nil {
+		return nil, fmt.Errorf("unable to find notification preferences: %w", err)
+	}
+	return preference, nil
+}
+
+func (n *notificationRepository) SavePreference(preference *notificationModels.NotificationPreference) error {
+	if err := n.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(preference).Error; err != nil {
+		return fmt.Errorf("unable to save notification preferences %w", err)
+	}
+	return nil
+}
+
+// FindDevices returns the user's devices, the most recently seen first
+func (n *notificationRepository) FindDevices(userId uint) ([]notificationModels.Device, error) {
+	var devices []notificationModels.Device
+	if err := n.db.Where("user_id = ?", userId).Order("last_seen_at desc").Find(&devices).Error; err != nil {
+		return nil, fmt.Errorf("unable to find devices: %w", err)
+	}
+	return devices, nil
+}
+
+func (n *notificationRepository) FindDevice(userId uint, deviceId uint) (*notificationModels.Device, error) {
+	var device notificationModels.Device
+	if err := n.db.Where("id = ? AND user_id = ?", deviceId, userId).First(&device).Error; err != nil {
+		return nil, fmt.Errorf("unable to find device: %w", err)
+	}
+	return &device, nil
+}
+
+func (n *notificationRepository) FindDeviceByToken(token string) (*notificationModels.Device, error) {
+	var device notificationModels.Device
+	if err := n.db.Where("token = ?", token).First(&device).Error; err != nil {
+		return nil, fmt.Errorf("unable to find device: %w", err)
+	}
+	return &device, nil
+}
+
+func (n *notificationRepository) CountActiveDevices(userId uint) (int64, error) {
+	var count int64
+	if err := n.db.Model(&notificationModels.Device{}).
+		Where("user_id = ? AND disabled_at IS NULL", userId).
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("unable to count devices %w", err)
+	}
+	return count, nil
+}
+
+// RegisterDevice stores the token, a token that is already known moves to the device's user and becomes active again
+func (n *notificationRepository) RegisterDevice(device *notificationModels.Device) error {
+	result := n.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "platform", "app_version", "name", "disabled_at", "last_seen_at", "updated_at"}),
+	}).Create(device)
+	if result.Error != nil {
+		return fmt.Errorf("unable to register device %w", result.Error)
+	}
+	// the conflicting row keeps its ID, read it back so the caller sees the stored device
+	if err := n.db.Where("token = ?", device.Token).First(device).Error; err != nil {
+		return fmt.Errorf("unable to find device %w", err)
+	}
+	return nil
+}
+
+// RefreshDevice saves a device whose token may have rotated, another row still holding the new token is stale and goes
+func (n *notificationRepository) RefreshDevice(device *notificationModels.Device) error {
+	return n.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("token = ? AND id <> ?", device.Token, device.ID).
+			Delete(&notificationModels.Device{}).Error; err != nil {
+			return fmt.Errorf("unable to remove stale device %w", err)
+		}
+
+		if err := tx.Model(device).Select("token", "app_version", "disabled_at", "last_seen_at", "updated_at").
+			Updates(device).Error; err != nil {
+			return fmt.Errorf("unable to refresh device %w", err)
+		}
+		return nil
+	})
+}
+
+func (n *notificationRepository) DeleteDevice(userId uint, deviceId uint) error {
+	result := n.db.Where("id = ? AND user_id = ?", deviceId, userId).Delete(&notificationModels.Device{})
+	if result.Error != nil {
+		return fmt.Errorf("unable to delete device %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("unable to delete device: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// DeleteStaleDevices drops devices the push provider gave up on before disabledBefore and ones not seen since seenBefore
+func (n *notificationRepository) DeleteStaleDevices(disabledBefore time.Time, seenBefore time.Time) (int64, error) {
+	result := n.db.Where("disabled_at < ? OR last_seen_at < ?", disabledBefore, seenBefore).Delete(&notificationModels.Device{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("unable to delete stale devices %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
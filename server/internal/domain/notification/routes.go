@@ -0,0 +1,50 @@
+package notification
+
+import (
+	"fmt"
+	"resq/internal/infra"
+	"resq/internal/infra/jobs"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/middleware"
+	"resq/internal/infra/notifier"
+	"resq/pkg/constants"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func NotificationRoutes(router *gin.Engine, db *gorm.DB) {
+	notificationRepository := NewNotificationRepository(db)
+	notificationService := NewNotificationService(notificationRepository, notifier.GlobalNotifier)
+	notificationController := NewNotificationController(notificationService)
+
+	if err := infra.GlobalBus.Subscribe(constants.TopicReportStatusChanged, ConsumerGroup,
+		infra.Idempotent(db, ConsumerGroup, notificationService.HandleReportStatusChanged)); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to subscribe to report status changes: %v", err))
+	}
+
+	if err := jobs.GlobalRunner.Cron("device-retention", DevicePruneSchedule, notificationService.PruneDevices); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to schedule device retention: %v", err))
+	}
+
+	notifications := router.Group("notifications")
+
+	notifications.Use(middleware.AuthMiddleware())
+	{
+		notifications.GET("", notificationController.GetNotifications)
+		notifications.POST("/read", notificationController.MarkAllNotificationsRead)
+		notifications.POST("/:id/read", notificationController.MarkNotificationRead)
+		notifications.GET("/preferences", notificationController.GetPreferences)
+		notifications.PUT("/preferences", notificationController.UpdatePreferences)
+	}
+
+	devices := router.Group("devices")
+
+	devices.Use(middleware.AuthMiddleware())
+	{
+		devices.GET("", notificationController.GetDevices)
+		devices.POST("", notificationController.RegisterDevice)
+		devices.PUT("/:id", notificationController.RefreshDevice)
+		devices.DELETE("/:id", notificationController.RemoveDevice)
+	}
+}
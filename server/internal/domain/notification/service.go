@@ -0,0 +1,274 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"resq/internal/infra"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/notifier"
+	"resq/pkg/dto"
+	notificationModels "resq/pkg/models/notification"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 30
+	// MaxDevices caps the active devices of one user, every one of them gets each push notification
+	MaxDevices = 20
+	// DisabledDeviceRetention is how long a device the push provider refused stays listed for its user
+	DisabledDeviceRetention = 30 * 24 * time.Hour
+	// DeviceMaxIdle is how long an app may go without refreshing its token before its device is dropped
+	DeviceMaxIdle = 180 * 24 * time.Hour
+	// DevicePruneSchedule is when stale devices are dropped
+	DevicePruneSchedule = "@daily"
+	// ConsumerGroup is the event bus group the notification consumers subscribe under
+	ConsumerGroup = "notifications"
+)
+
+var (
+	ErrNotificationNotFound = errors.New("notification not found")
+	ErrInvalidQuietHours    = errors.New("quiet hours need a start and an end as HH:MM")
+	ErrInvalidTimeZone      = errors.New("unknown time zone")
+	ErrDeviceNotFound       = errors.New("device not found")
+	ErrTooManyDevices       = errors.New("too many devices registered")
+)
+
+type NotificationService interface {
+	GetNotifications(userId uint, query *dto.NotificationsQueryDTO) ([]dto.NotificationDTO, error)
+	MarkNotificationRead(userId uint, notificationId uint) error
+	MarkAllNotificationsRead(userId uint) error
+	GetPreferences(userId uint) (*dto.NotificationPreferenceDTO, error)
+	UpdatePreferences(userId uint, request *dto.NotificationPreferenceRequestDTO) (*dto.NotificationPreferenceDTO, error)
+	RegisterDevice(userId uint, request *dto.RegisterDeviceRequestDTO) (*dto.DeviceDTO, error)
+	RefreshDevice(userId uint, deviceId uint, request *dto.RefreshDeviceRequestDTO) (*dto.DeviceDTO, error)
+	GetDevices(userId uint) ([]dto.DeviceDTO, error)
+	RemoveDevice(userId uint, deviceId uint) error
+	PruneDevices(ctx context.Context) error
+	// HandleReportStatusChanged tells the reporter their report moved on
+	HandleReportStatusChanged(ctx context.Context, tx *gorm.DB, message infra.Message) error
+}
+
+type notificationService struct {
+	repository NotificationRepository
+	notifier   notifier.NotificationService
+}
+
+func NewNotificationService(repo NotificationRepository, n notifier.NotificationService) NotificationService {
+	return &notificationService{repository: repo, notifier: n}
+}
+
+func (n *notificationService) GetNotifications(userId uint, query *dto.NotificationsQueryDTO) ([]dto.NotificationDTO, error) {
+	limit := query.Limit
+	if limit == 0 {
+		limit = defaultPageSize
+	}
+
+	notifications, err := n.repository.FindNotifications(userId, query.Before, limit, query.UnreadOnly)
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to find notifications: %v", err))
+		return nil, errors.New("unable to find notifications")
+	}
+
+	result := make([]dto.NotificationDTO, len(notifications))
+	for i := range notifications {
+		result[i] = *notifications[i].ToDTO()
+	}
+	return result, nil
+}
+
+func (n *notificationService) MarkNotificationRead(userId uint, notificationId uint) error {
+	if err := n.repository.MarkNotificationRead(userId, notificationId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrNotificationNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (n *notificationService) MarkAllNotificationsRead(userId uint) error {
+	return n.repository.MarkAllNotificationsRead(userId)
+}
+
+func (n *notificationService) GetPreferences(userId uint) (*dto.NotificationPreferenceDTO, error) {
+	preference, err := n.repository.FindPreference(userId)
+	if err != nil {
+		return nil, err
+	}
+	return preference.ToDTO(), nil
+}
+
+func (n *notificationService) UpdatePreferences(userId uint, request *dto.NotificationPreferenceRequestDTO) (*dto.NotificationPreferenceDTO, error) {
+	preference := &notificationModels.NotificationPreference{
+		UserID:   userId,
+		Push:     *request.Push,
+		SMS:      *request.SMS,
+		Email:    *request.Email,
+		TimeZone: strings.TrimSpace(request.TimeZone),
+	}
+
+	if preference.TimeZone == "" {
+		preference.TimeZone = "UTC"
+	}
+	if _, err := time.LoadLocation(preference.TimeZone); err != nil {
+		return nil, ErrInvalidTimeZone
+	}
+
+	if request.QuietHoursStart != "" || request.QuietHoursEnd != "" {
+		start, startErr := parseClock(request.QuietHoursStart)
+		end, endErr := parseClock(request.QuietHoursEnd)
+		if startErr != nil || endErr != nil || start == end {
+			return nil, ErrInvalidQuietHours
+		}
+		preference.QuietHoursStart = &start
+		preference.QuietHoursEnd = &end
+	}
+
+	if err := n.repository.SavePreference(preference); err != nil {
+		return nil, err
+	}
+	return preference.ToDTO(), nil
+}
+
+// parseClock reads "HH:MM" as minutes after midnight
+func parseClock(clock string) (int, error) {
+	parsed, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, err
+	}
+	return parsed.Hour()*60 + parsed.Minute(), nil
+}
+
+func (n *notificationService) RegisterDevice(userId uint, request *dto.RegisterDeviceRequestDTO) (*dto.DeviceDTO, error) {
+	token := strings.TrimSpace(request.Token)
+
+	// a token the user already holds is a refresh, it does not count against the limit
+	existing, err := n.repository.FindDeviceByToken(token)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if existing == nil || existing.UserID != userId || existing.DisabledAt != nil {
+		active, err := n.repository.CountActiveDevices(userId)
+		if err != nil {
+			return nil, err
+		}
+		if active >= MaxDevices {
+			return nil, ErrTooManyDevices
+		}
+	}
+
+	now := time.Now()
+	device := &notificationModels.Device{
+		UserID:     userId,
+		Token:      token,
+		Platform:   notificationModels.DevicePlatform(request.Platform),
+		AppVersion: strings.TrimSpace(request.AppVersion),
+		Name:       strings.TrimSpace(request.Name),
+		LastSeenAt: now,
+		UpdatedAt:  now,
+	}
+	if err := n.repository.RegisterDevice(device); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to register device: %v", err))
+		return nil, errors.New("unable to register device")
+	}
+	return device.ToDTO(), nil
+}
+
+func (n *notificationService) RefreshDevice(userId uint, deviceId uint, request *dto.RefreshDeviceRequestDTO) (*dto.DeviceDTO, error) {
+	device, err := n.repository.FindDevice(userId, deviceId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeviceNotFound
+		}
+		return nil, err
+	}
+
+	// a new token is worth trying again even when the old one was refused
+	if token := strings.TrimSpace(request.Token); token != "" && token != device.Token {
+		device.Token = token
+		device.DisabledAt = nil
+	}
+	if appVersion := strings.TrimSpace(request.AppVersion); appVersion != "" {
+		device.AppVersion = appVersion
+	}
+	device.LastSeenAt = time.Now()
+	device.UpdatedAt = device.LastSeenAt
+
+	if err := n.repository.RefreshDevice(device); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to refresh device: %v", err))
+		return nil, errors.New("unable to refresh device")
+	}
+	return device.ToDTO(), nil
+}
+
+func (n *notificationService) GetDevices(userId uint) ([]dto.DeviceDTO, error) {
+	devices, err := n.repository.FindDevices(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.DeviceDTO, len(devices))
+	for i := range devices {
+		result[i] = *devices[i].ToDTO()
+	}
+	return result, nil
+}
+
+func (n *notificationService) RemoveDevice(userId uint, deviceId uint) error {
+	if err := n.repository.DeleteDevice(userId, deviceId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrDeviceNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// PruneDevices is the scheduled job that drops devices the push provider refused and ones the app stopped refreshing
+func (n *notificationService) PruneDevices(ctx context.Context) error {
+	now := time.Now()
+	deleted, err := n.repository.DeleteStaleDevices(now.Add(-DisabledDeviceRetention), now.Add(-DeviceMaxIdle))
+	if err != nil {
+		return err
+	}
+	if deleted > 0 {
+		logger.GlobalLogger.Log(logger.INFO, "Stale devices pruned", map[string]interface{}{
+			"deleted": deleted,
+		})
+	}
+	return nil
+}
+
+func (n *notificationService) HandleReportStatusChanged(ctx context.Context, tx *gorm.DB, message infra.Message) error {
+	var event dto.ReportStatusChangedEvent
+	if err := message.Decode(&event); err != nil {
+		// a payload that cannot be read never will be, retrying does not help
+		logger.GlobalLogger.Log(logger.ERROR, err.Error())
+		return nil
+	}
+	// the reporter deleted their account, or moved the report themselves
+	if event.ReporterID == nil || *event.ReporterID == event.ActorID {
+		return nil
+	}
+
+	_, err := n.notifier.NotifyTx(tx, notifier.Request{
+		UserID:   *event.ReporterID,
+		Template: reportStatusTemplate,
+		Data: map[string]interface{}{
+			"ReportID":   event.ReportID,
+			"FromStatus": event.FromStatus,
+			"Status":     event.ToStatus,
+			"Reason":     event.Reason,
+		},
+		Link: map[string]string{"report_id": strconv.FormatUint(uint64(event.ReportID), 10)},
+	})
+	if errors.Is(err, notifier.ErrRecipientNotFound) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,9 @@
+package notification
+
+import "resq/internal/infra/notifier"
+
+var reportStatusTemplate = notifier.NewTemplate("report_status",
+	"Your report is now {{.Status}}",
+	`Report #{{.ReportID}} moved from {{.FromStatus}} to {{.Status}}.{{if .Reason}}
+
+{{.Reason}}{{end}}`)
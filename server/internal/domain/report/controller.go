@@ -1,10 +1,32 @@
 package report
 
 import (
+	"errors"
+	"net/http"
+	"resq/pkg/constants"
+	"resq/pkg/dto"
+	"resq/pkg/utils"
+
 	"github.com/gin-gonic/gin"
 )
 
 type ReportController interface {
+	CreateReport(ctx *gin.Context)
+	GetReport(ctx *gin.Context)
+	GetUserReports(ctx *gin.Context)
+	GetReportStatus(ctx *gin.Context)
+	GetReportCategories(ctx *gin.Context)
+	CreateReportCategory(ctx *gin.Context)
+	UpdateReportCategory(ctx *gin.Context)
+	DeleteReportCategory(ctx *gin.Context)
+	UpdateReportStatus(ctx *gin.Context)
+	GetReportStatusHistory(ctx *gin.Context)
+	GetNearbyReports(ctx *gin.Context)
+	GetReportsInBoundingBox(ctx *gin.Context)
+	FollowReport(ctx *gin.Context)
+	UnfollowReport(ctx *gin.Context)
+	GetReportComments(ctx *gin.Context)
+	AddReportComment(ctx *gin.Context)
 }
 
 type reportController struct {
@@ -14,3 +36,353 @@ type reportController struct {
 func NewReportController(service ReportService) ReportController {
 	return &reportController{service: service}
 }
+
+func reportErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrReportNotFound), errors.Is(err, ErrCategoryNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrInvalidStatusTransition):
+		return http.StatusConflict
+	case errors.Is(err, ErrUnverifiedReporter), errors.Is(err, ErrSystemCategory):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func (r *reportController) CreateReport(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var request dto.CreateReportRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	report, err := r.service.CreateReport(userId, &request)
+	if err != nil {
+		ctx.JSON(reportErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{constants.RequestData: report})
+}
+
+func (r *reportController) GetReport(ctx *gin.Context) {
+	requester, err := utils.GetAuthenticatedUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	reportId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid report id"})
+		return
+	}
+
+	report, err := r.service.GetReport(reportId, requester)
+	if err != nil {
+		ctx.JSON(reportErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: report})
+}
+
+func (r *reportController) GetUserReports(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	reports, err := r.service.GetUserReports(userId)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{constants.RequestError: "unable to fetch reports"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: reports})
+}
+
+func (r *reportController) GetReportStatus(ctx *gin.Context) {
+	requester, err := utils.GetAuthenticatedUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	reportId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid report id"})
+		return
+	}
+
+	status, err := r.service.GetReportStatus(reportId, requester)
+	if err != nil {
+		ctx.JSON(reportErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: status})
+}
+
+func (r *reportController) GetReportCategories(ctx *gin.Context) {
+	categories, err := r.service.GetReportCategories()
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{constants.RequestError: "unable to fetch report categories"})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: categories})
+}
+
+func (r *reportController) CreateReportCategory(ctx *gin.Context) {
+	var request dto.ReportCategoryRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	category, err := r.service.CreateReportCategory(&request)
+	if err != nil {
+		ctx.JSON(reportErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{constants.RequestData: category})
+}
+
+func (r *reportController) UpdateReportCategory(ctx *gin.Context) {
+	categoryId, err := utils.ParseId(ctx.Param("categoryId"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid category id"})
+		return
+	}
+
+	var request dto.ReportCategoryRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	category, err := r.service.UpdateReportCategory(categoryId, &request)
+	if err != nil {
+		ctx.JSON(reportErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: category})
+}
+
+func (r *reportController) DeleteReportCategory(ctx *gin.Context) {
+	categoryId, err := utils.ParseId(ctx.Param("categoryId"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid category id"})
+		return
+	}
+
+	if err := r.service.DeleteReportCategory(categoryId); err != nil {
+		ctx.JSON(reportErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (r *reportController) UpdateReportStatus(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	reportId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid report id"})
+		return
+	}
+
+	var request dto.UpdateReportStatusRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	history, err := r.service.UpdateReportStatus(reportId, userId, &request)
+	if err != nil {
+		ctx.JSON(reportErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: history})
+}
+
+func (r *reportController) GetReportStatusHistory(ctx *gin.Context) {
+	requester, err := utils.GetAuthenticatedUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	reportId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid report id"})
+		return
+	}
+
+	history, err := r.service.GetReportStatusHistory(reportId, requester)
+	if err != nil {
+		ctx.JSON(reportErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: history})
+}
+
+func (r *reportController) GetNearbyReports(ctx *gin.Context) {
+	requester, err := utils.GetAuthenticatedUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var query dto.NearbyReportsQueryDTO
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, query)})
+		return
+	}
+
+	reports, err := r.service.GetNearbyReports(&query, requester)
+	if err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: reports})
+}
+
+func (r *reportController) GetReportsInBoundingBox(ctx *gin.Context) {
+	requester, err := utils.GetAuthenticatedUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var query dto.BoundingBoxReportsQueryDTO
+	if err := ctx.ShouldBindQuery(&query); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, query)})
+		return
+	}
+
+	reports, err := r.service.GetReportsInBoundingBox(&query, requester)
+	if err != nil {
+		if errors.Is(err, ErrInvalidBoundingBox) {
+			ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: err.Error()})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: reports})
+}
+
+func (r *reportController) FollowReport(ctx *gin.Context) {
+	requester, err := utils.GetAuthenticatedUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	reportId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid report id"})
+		return
+	}
+
+	result, err := r.service.FollowReport(reportId, requester)
+	if err != nil {
+		ctx.JSON(reportErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (r *reportController) UnfollowReport(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	reportId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid report id"})
+		return
+	}
+
+	result, err := r.service.UnfollowReport(reportId, userId)
+	if err != nil {
+		ctx.JSON(reportErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (r *reportController) GetReportComments(ctx *gin.Context) {
+	requester, err := utils.GetAuthenticatedUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	reportId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid report id"})
+		return
+	}
+
+	comments, err := r.service.GetReportComments(reportId, requester)
+	if err != nil {
+		ctx.JSON(reportErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: comments})
+}
+
+func (r *reportController) AddReportComment(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	reportId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid report id"})
+		return
+	}
+
+	var request dto.ReportCommentRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	comment, err := r.service.AddReportComment(reportId, userId, &request)
+	if err != nil {
+		ctx.JSON(reportErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{constants.RequestData: comment})
+}
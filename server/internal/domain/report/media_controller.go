@@ -0,0 +1,229 @@
+package report
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"resq/pkg/constants"
+	"resq/pkg/dto"
+	reportModels "resq/pkg/models/report"
+	"resq/pkg/utils"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// multipartOverhead leaves room for boundaries and part headers on top of the largest file
+const multipartOverhead = 1 << 20
+
+type ReportMediaController interface {
+	UploadReportFile(ctx *gin.Context)
+	GetReportFiles(ctx *gin.Context)
+	CreateReportUpload(ctx *gin.Context)
+	GetReportUpload(ctx *gin.Context)
+	AppendReportUploadChunk(ctx *gin.Context)
+	DownloadReportFile(ctx *gin.Context)
+}
+
+type reportMediaController struct {
+	service ReportMediaService
+}
+
+func NewReportMediaController(service ReportMediaService) ReportMediaController {
+	return &reportMediaController{service: service}
+}
+
+func mediaErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrReportNotFound), errors.Is(err, ErrUploadNotFound), errors.Is(err, ErrReportFileNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrUploadOffsetMismatch), errors.Is(err, ErrUploadClosed):
+		return http.StatusConflict
+	case errors.Is(err, ErrFileTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, ErrUnsupportedMediaType):
+		return http.StatusUnsupportedMediaType
+	case errors.Is(err, utils.ErrInvalidSignature):
+		return http.StatusForbidden
+	case errors.Is(err, ErrStorageUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func (r *reportMediaController) UploadReportFile(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	reportId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid report id"})
+		return
+	}
+
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, reportModels.MaxReportFileSize+multipartOverhead)
+	reader, err := ctx.Request.MultipartReader()
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "expected a multipart upload"})
+		return
+	}
+
+	// stream the "file" part straight to staging instead of buffering the form
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid multipart upload"})
+			return
+		}
+
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		file, err := r.service.UploadReportFile(reportId, userId, part.FileName(), part)
+		part.Close()
+		if err != nil {
+			ctx.JSON(mediaErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+			return
+		}
+
+		ctx.JSON(http.StatusCreated, gin.H{constants.RequestData: file})
+		return
+	}
+
+	ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "file is required"})
+}
+
+func (r *reportMediaController) GetReportFiles(ctx *gin.Context) {
+	requester, err := utils.GetAuthenticatedUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	reportId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid report id"})
+		return
+	}
+
+	files, err := r.service.GetReportFiles(reportId, requester)
+	if err != nil {
+		ctx.JSON(mediaErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: files})
+}
+
+func (r *reportMediaController) CreateReportUpload(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	reportId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid report id"})
+		return
+	}
+
+	var request dto.CreateReportUploadRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	upload, err := r.service.CreateReportUpload(reportId, userId, &request)
+	if err != nil {
+		ctx.JSON(mediaErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{constants.RequestData: upload})
+}
+
+func (r *reportMediaController) GetReportUpload(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	reportId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid report id"})
+		return
+	}
+
+	upload, err := r.service.GetReportUpload(reportId, userId, ctx.Param("uploadId"))
+	if err != nil {
+		ctx.JSON(mediaErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: upload})
+}
+
+// AppendReportUploadChunk takes the raw chunk as the body and its position in the Upload-Offset header
+func (r *reportMediaController) AppendReportUploadChunk(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	reportId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid report id"})
+		return
+	}
+
+	offset, err := strconv.ParseInt(ctx.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid Upload-Offset header"})
+		return
+	}
+
+	upload, err := r.service.AppendReportUploadChunk(reportId, userId, ctx.Param("uploadId"), offset, ctx.Request.Body)
+	if err != nil {
+		// the current upload state tells the client where to resume from
+		ctx.JSON(mediaErrorStatus(err), gin.H{constants.RequestError: err.Error(), constants.RequestData: upload})
+		return
+	}
+
+	ctx.Header("Upload-Offset", strconv.FormatInt(upload.ReceivedSize, 10))
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: upload})
+}
+
+func (r *reportMediaController) DownloadReportFile(ctx *gin.Context) {
+	fileId, err := utils.ParseId(ctx.Param("fileId"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid file id"})
+		return
+	}
+
+	file, content, err := r.service.OpenSignedReportFile(fileId, ctx.Query("expires"), ctx.Query("signature"))
+	if err != nil {
+		ctx.JSON(mediaErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+	defer content.Close()
+
+	fileName := strings.ReplaceAll(file.OriginalName, `"`, "")
+	ctx.DataFromReader(http.StatusOK, file.FileSize, file.MimeType, content, map[string]string{
+		"Content-Disposition":    `inline; filename="` + fileName + `"`,
+		"Cache-Control":          "private, max-age=300",
+		"X-Content-Type-Options": "nosniff",
+	})
+}
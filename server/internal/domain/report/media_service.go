@@ -0,0 +1,458 @@
+package report
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/storage"
+	"resq/pkg/constants"
+	"resq/pkg/dto"
+	reportModels "resq/pkg/models/report"
+	"resq/pkg/utils"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUnsupportedMediaType = errors.New("only image, video and audio files are accepted")
+	ErrFileTooLarge         = errors.New("file exceeds the size limit for its type")
+	ErrUploadNotFound       = errors.New("upload not found")
+	ErrUploadOffsetMismatch = errors.New("upload offset does not match the received size")
+	ErrUploadClosed         = errors.New("upload is no longer accepting chunks")
+	ErrStorageUnavailable   = errors.New("media storage is unavailable")
+	ErrReportFileNotFound   = errors.New("report file not found")
+)
+
+const (
+	reportUploadLifetime = 24 * time.Hour
+	// downloadURLLifetime is how long a signed media link handed to a client keeps working
+	downloadURLLifetime = 15 * time.Minute
+)
+
+// uploadLocks serialises chunks for the same upload so two appends never interleave in the staging file,
+// an entry only lives while a chunk holds or waits for it so abandoned and expired uploads leave nothing behind
+var (
+	uploadLocksMu sync.Mutex
+	uploadLocks   = map[string]*uploadLock{}
+)
+
+type uploadLock struct {
+	sync.Mutex
+	holders int
+}
+
+// lockUpload blocks until the caller is the only one working on uploadId, the returned func lets go again
+func lockUpload(uploadId string) func() {
+	uploadLocksMu.Lock()
+	lock, ok := uploadLocks[uploadId]
+	if !ok {
+		lock = &uploadLock{}
+		uploadLocks[uploadId] = lock
+	}
+	lock.holders++
+	uploadLocksMu.Unlock()
+
+	lock.Lock()
+	return func() {
+		lock.Unlock()
+
+		uploadLocksMu.Lock()
+		lock.holders--
+		if lock.holders == 0 {
+			delete(uploadLocks, uploadId)
+		}
+		uploadLocksMu.Unlock()
+	}
+}
+
+type ReportMediaService interface {
+	UploadReportFile(reportId uint, uploaderId uint, fileName string, content io.Reader) (*dto.ReportFileDTO, error)
+	GetReportFiles(reportId uint, requester *dto.AuthenticatedUserDTO) ([]dto.ReportFileDTO, error)
+	CreateReportUpload(reportId uint, uploaderId uint, request *dto.CreateReportUploadRequestDTO) (*dto.ReportUploadDTO, error)
+	GetReportUpload(reportId uint, uploaderId uint, uploadId string) (*dto.ReportUploadDTO, error)
+	AppendReportUploadChunk(reportId uint, uploaderId uint, uploadId string, offset int64, chunk io.Reader) (*dto.ReportUploadDTO, error)
+	OpenSignedReportFile(fileId uint, expires string, signature string) (*reportModels.ReportFile, io.ReadCloser, error)
+}
+
+type reportMediaService struct {
+	repository ReportRepository
+	storage    storage.Storage
+}
+
+func NewReportMediaService(repo ReportRepository, blobStorage storage.Storage) ReportMediaService {
+	return &reportMediaService{repository: repo, storage: blobStorage}
+}
+
+func reportFileDownloadPath(fileId uint) string {
+	return fmt.Sprintf("/media/report-files/%d", fileId)
+}
+
+// reportFileToDTO attaches a short lived signed link so clients never see the storage key
+func reportFileToDTO(file *reportModels.ReportFile) *dto.ReportFileDTO {
+	result := file.ToDTO()
+
+	downloadURL, expiresAt, err := utils.SignURL(reportFileDownloadPath(file.ID), downloadURLLifetime, constants.MediaSigningKey())
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to sign report file url: %v", err))
+		return result
+	}
+
+	result.DownloadURL = downloadURL
+	result.URLExpiresAt = expiresAt
+	return result
+}
+
+func classifyMimeType(mimeType string) (reportModels.ReportFileType, bool) {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return reportModels.ReportFileTypeImage, true
+	case strings.HasPrefix(mimeType, "video/"):
+		return reportModels.ReportFileTypeVideo, true
+	case strings.HasPrefix(mimeType, "audio/"):
+		return reportModels.ReportFileTypeAudio, true
+	default:
+		return "", false
+	}
+}
+
+func stagingPath(name string) string {
+	return filepath.Join(storage.UploadStagingDir, name)
+}
+
+// stageContent copies the whole body to the staging directory, refusing anything over MaxReportFileSize
+func stageContent(content io.Reader) (string, int64, error) {
+	if err := os.MkdirAll(storage.UploadStagingDir, 0750); err != nil {
+		return "", 0, fmt.Errorf("unable to create staging directory: %w", err)
+	}
+
+	staged, err := os.CreateTemp(storage.UploadStagingDir, "multipart-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("unable to stage upload: %w", err)
+	}
+
+	written, err := io.Copy(staged, io.LimitReader(content, reportModels.MaxReportFileSize+1))
+	if closeErr := staged.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(staged.Name())
+		return "", 0, fmt.Errorf("unable to stage upload: %w", err)
+	}
+
+	if written > reportModels.MaxReportFileSize {
+		os.Remove(staged.Name())
+		return "", 0, ErrFileTooLarge
+	}
+
+	return staged.Name(), written, nil
+}
+
+func hashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// storeStagedFile sniffs, checks and hashes a complete staged upload, then moves it into blob storage
+func (m *reportMediaService) storeStagedFile(reportId uint, uploaderId uint, fileName string, path string, size int64) (*reportModels.ReportFile, error) {
+	mime, err := mimetype.DetectFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to detect file type: %w", err)
+	}
+
+	fileType, ok := classifyMimeType(mime.String())
+	if !ok {
+		return nil, ErrUnsupportedMediaType
+	}
+
+	if size > reportModels.ReportFileSizeLimits[fileType] {
+		return nil, ErrFileTooLarge
+	}
+
+	contentHash, err := hashFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to hash file: %w", err)
+	}
+
+	// the same evidence uploaded twice is only stored once
+	existing, err := m.repository.FindReportFileByHash(reportId, contentHash)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	staged, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open staged file: %w", err)
+	}
+	defer staged.Close()
+
+	key := fmt.Sprintf("reports/%d/%s%s", reportId, contentHash, mime.Extension())
+	if _, err := m.storage.Save(key, staged); err != nil {
+		return nil, err
+	}
+
+	file := &reportModels.ReportFile{
+		ReportID:     reportId,
+		UploaderID:   uploaderId,
+		FileType:     fileType,
+		MimeType:     mime.String(),
+		FileSize:     size,
+		Sha256:       contentHash,
+		StorageKey:   key,
+		OriginalName: filepath.Base(fileName),
+	}
+
+	if err := m.repository.CreateReportFile(file); err != nil {
+		m.storage.Delete(key)
+		return nil, err
+	}
+
+	return file, nil
+}
+
+func (m *reportMediaService) UploadReportFile(reportId uint, uploaderId uint, fileName string, content io.Reader) (*dto.ReportFileDTO, error) {
+	if m.storage == nil {
+		return nil, ErrStorageUnavailable
+	}
+
+	if _, err := findOwnReport(m.repository, reportId, uploaderId); err != nil {
+		return nil, err
+	}
+
+	path, size, err := stageContent(content)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+
+	file, err := m.storeStagedFile(reportId, uploaderId, fileName, path, size)
+	if err != nil {
+		if errors.Is(err, ErrUnsupportedMediaType) || errors.Is(err, ErrFileTooLarge) {
+			return nil, err
+		}
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to store report file: %v", err))
+		return nil, errors.New("unable to store report file")
+	}
+
+	return reportFileToDTO(file), nil
+}
+
+func (m *reportMediaService) GetReportFiles(reportId uint, requester *dto.AuthenticatedUserDTO) ([]dto.ReportFileDTO, error) {
+	if _, err := findVisibleReport(m.repository, reportId, requester); err != nil {
+		return nil, err
+	}
+
+	files, err := m.repository.FindReportFiles(reportId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.ReportFileDTO, len(files))
+	for i := range files {
+		result[i] = *reportFileToDTO(&files[i])
+	}
+	return result, nil
+}
+
+func (m *reportMediaService) CreateReportUpload(reportId uint, uploaderId uint, request *dto.CreateReportUploadRequestDTO) (*dto.ReportUploadDTO, error) {
+	if _, err := findOwnReport(m.repository, reportId, uploaderId); err != nil {
+		return nil, err
+	}
+
+	if request.TotalSize > reportModels.MaxReportFileSize {
+		return nil, ErrFileTooLarge
+	}
+
+	uploadId, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, errors.New("unable to create upload")
+	}
+
+	upload := &reportModels.ReportUpload{
+		UploadID:   uploadId,
+		ReportID:   reportId,
+		UploaderID: uploaderId,
+		FileName:   filepath.Base(request.FileName),
+		TotalSize:  request.TotalSize,
+		Status:     reportModels.ReportUploadStatusUploading,
+		ExpiresAt:  time.Now().Add(reportUploadLifetime),
+	}
+
+	if err := m.repository.CreateReportUpload(upload); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to create report upload: %v", err))
+		return nil, errors.New("unable to create upload")
+	}
+
+	return upload.ToDTO(), nil
+}
+
+func (m *reportMediaService) findOwnUpload(reportId uint, uploaderId uint, uploadId string) (*reportModels.ReportUpload, error) {
+	upload, err := m.repository.FindReportUpload(uploadId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+
+	if upload.ReportID != reportId || upload.UploaderID != uploaderId {
+		return nil, ErrUploadNotFound
+	}
+
+	return upload, nil
+}
+
+func (m *reportMediaService) GetReportUpload(reportId uint, uploaderId uint, uploadId string) (*dto.ReportUploadDTO, error) {
+	upload, err := m.findOwnUpload(reportId, uploaderId, uploadId)
+	if err != nil {
+		return nil, err
+	}
+	return upload.ToDTO(), nil
+}
+
+// AppendReportUploadChunk writes the chunk at offset, the offset has to match what the server already holds
+// so a client that lost its connection asks for the upload first and resumes from received_size
+func (m *reportMediaService) AppendReportUploadChunk(reportId uint, uploaderId uint, uploadId string, offset int64, chunk io.Reader) (*dto.ReportUploadDTO, error) {
+	if m.storage == nil {
+		return nil, ErrStorageUnavailable
+	}
+
+	unlock := lockUpload(uploadId)
+	defer unlock()
+
+	upload, err := m.findOwnUpload(reportId, uploaderId, uploadId)
+	if err != nil {
+		return nil, err
+	}
+
+	if upload.Status != reportModels.ReportUploadStatusUploading || time.Now().After(upload.ExpiresAt) {
+		return upload.ToDTO(), ErrUploadClosed
+	}
+
+	if offset != upload.ReceivedSize {
+		return upload.ToDTO(), ErrUploadOffsetMismatch
+	}
+
+	if err := os.MkdirAll(storage.UploadStagingDir, 0750); err != nil {
+		return nil, fmt.Errorf("unable to create staging directory: %w", err)
+	}
+
+	path := stagingPath(upload.UploadID + ".part")
+	received, err := appendChunk(path, offset, upload.TotalSize-offset, chunk)
+	if err != nil {
+		if errors.Is(err, ErrFileTooLarge) {
+			return upload.ToDTO(), err
+		}
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to write upload chunk: %v", err))
+		return nil, errors.New("unable to write upload chunk")
+	}
+
+	previousSize := upload.ReceivedSize
+	upload.ReceivedSize += received
+
+	if upload.ReceivedSize == upload.TotalSize {
+		file, err := m.storeStagedFile(upload.ReportID, uploaderId, upload.FileName, path, upload.TotalSize)
+		os.Remove(path)
+
+		if err != nil {
+			upload.Status = reportModels.ReportUploadStatusFailed
+			m.repository.UpdateReportUploadProgress(upload, previousSize)
+			if errors.Is(err, ErrUnsupportedMediaType) || errors.Is(err, ErrFileTooLarge) {
+				return upload.ToDTO(), err
+			}
+			logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to store report file: %v", err))
+			return nil, errors.New("unable to store report file")
+		}
+
+		upload.Status = reportModels.ReportUploadStatusCompleted
+		upload.ReportFileID = &file.ID
+		upload.ReportFile = file
+	}
+
+	if err := m.repository.UpdateReportUploadProgress(upload, previousSize); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to update report upload: %v", err))
+		return nil, errors.New("unable to update upload")
+	}
+
+	return upload.ToDTO(), nil
+}
+
+// appendChunk writes at most remaining bytes at offset, anything beyond that rolls the file back
+func appendChunk(path string, offset int64, remaining int64, chunk io.Reader) (int64, error) {
+	staged, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0640)
+	if err != nil {
+		return 0, err
+	}
+	defer staged.Close()
+
+	// drop whatever a previously interrupted chunk left behind
+	if err := staged.Truncate(offset); err != nil {
+		return 0, err
+	}
+	if _, err := staged.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	written, err := io.Copy(staged, io.LimitReader(chunk, remaining+1))
+	if err != nil {
+		staged.Truncate(offset)
+		return 0, err
+	}
+
+	if written > remaining {
+		staged.Truncate(offset)
+		return 0, ErrFileTooLarge
+	}
+
+	return written, nil
+}
+
+// OpenSignedReportFile serves a file to whoever holds a valid signed link, the signature is the authorisation
+func (m *reportMediaService) OpenSignedReportFile(fileId uint, expires string, signature string) (*reportModels.ReportFile, io.ReadCloser, error) {
+	if err := utils.VerifySignedURL(reportFileDownloadPath(fileId), expires, signature, constants.MediaSigningKey()); err != nil {
+		return nil, nil, err
+	}
+
+	if m.storage == nil {
+		return nil, nil, ErrStorageUnavailable
+	}
+
+	file, err := m.repository.FindReportFileById(fileId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrReportFileNotFound
+		}
+		return nil, nil, err
+	}
+
+	content, err := m.storage.Open(file.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrObjectNotFound) {
+			return nil, nil, ErrReportFileNotFound
+		}
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to open report file: %v", err))
+		return nil, nil, errors.New("unable to open report file")
+	}
+
+	return file, content, nil
+}
@@ -1,10 +1,53 @@
 package report
 
 import (
+	"fmt"
+	"resq/pkg/constants"
+	"resq/pkg/dto"
+	"resq/pkg/models"
+	feedModels "resq/pkg/models/feed"
+	outboxModels "resq/pkg/models/outbox"
+	reportModels "resq/pkg/models/report"
+	"resq/pkg/utils"
+
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// haversineSQL is utils.HaversineKm in SQL so plain Postgres can filter and sort by distance without PostGIS,
+// its placeholders are the centre latitude, latitude and longitude
+const haversineSQL = `2 * 6371 * asin(least(1, sqrt(
+	power(sin(radians(report_locations.latitude - ?) / 2), 2) +
+	cos(radians(?)) * cos(radians(report_locations.latitude)) *
+	power(sin(radians(report_locations.longitude - ?) / 2), 2)
+)))`
+
 type ReportRepository interface {
+	CreateReport(report *reportModels.Report) (*reportModels.Report, error)
+	FindUserById(userId uint) (*models.User, error)
+	FindReportById(reportId uint) (*reportModels.Report, error)
+	FindReportsByReporter(reporterId uint) ([]reportModels.Report, error)
+	FindCategoryById(categoryId uint) (*reportModels.ReportCategory, error)
+	GetReportCategories() ([]reportModels.ReportCategory, error)
+	CreateReportCategory(category *reportModels.ReportCategory) error
+	UpdateReportCategory(category *reportModels.ReportCategory) error
+	DeleteReportCategory(categoryId uint) error
+	UpdateReportStatus(history *reportModels.ReportStatusHistory) ([]feedModels.FeedEvent, error)
+	FindReportStatusHistory(reportId uint) ([]reportModels.ReportStatusHistory, error)
+	FindReportsWithinRadius(lat, lng, radiusKm float64, limit int) ([]reportModels.Report, error)
+	FindReportsInBoundingBox(box utils.BoundingBox, limit int) ([]reportModels.Report, error)
+	CreateReportFile(file *reportModels.ReportFile) error
+	FindReportFiles(reportId uint) ([]reportModels.ReportFile, error)
+	FindReportFileById(fileId uint) (*reportModels.ReportFile, error)
+	FindReportFileByHash(reportId uint, sha256 string) (*reportModels.ReportFile, error)
+	CreateReportUpload(upload *reportModels.ReportUpload) error
+	FindReportUpload(uploadId string) (*reportModels.ReportUpload, error)
+	UpdateReportUploadProgress(upload *reportModels.ReportUpload, previousSize int64) error
+	FollowReport(reportId uint, userId uint) error
+	UnfollowReport(reportId uint, userId uint) error
+	IsFollowing(reportId uint, userId uint) (bool, error)
+	CreateComment(comment *reportModels.ReportComment) ([]feedModels.FeedEvent, error)
+	FindComments(reportId uint) ([]reportModels.ReportComment, error)
 }
 
 type reportRepository struct {
@@ -14,3 +57,310 @@ type reportRepository struct {
 func NewReportRepository(db *gorm.DB) ReportRepository {
 	return &reportRepository{db: db}
 }
+
+// CreateReport stores the report and its report.created event in one transaction
+func (r *reportRepository) CreateReport(report *reportModels.Report) (*reportModels.Report, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// the category and reporter already exist, only the location is created alongside the report
+		if err := tx.Omit("Category", "Reporter").Create(report).Error; err != nil {
+			return fmt.Errorf("unable to create report %w", err)
+		}
+		return outboxModels.Record(tx, constants.TopicReportCreated, constants.AggregateReport, report.ID, report.CreatedEvent())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r.FindReportById(report.ID)
+}
+
+func (r *reportRepository) FindUserById(userId uint) (*models.User, error) {
+	var user models.User
+	result := r.db.Where("id = ?", userId).First(&user)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find user: %w", result.Error)
+	}
+	return &user, nil
+}
+
+func (r *reportRepository) FindReportById(reportId uint) (*reportModels.Report, error) {
+	var report reportModels.Report
+	result := r.db.Preload("Category").Preload("Location").Where("id = ?", reportId).First(&report)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find report: %w", result.Error)
+	}
+	return &report, nil
+}
+
+func (r *reportRepository) FindReportsByReporter(reporterId uint) ([]reportModels.Report, error) {
+	var reports []reportModels.Report
+	result := r.db.Preload("Category").Preload("Location").
+		Where("reporter_id = ?", reporterId).
+		Order("created_at desc").
+		Find(&reports)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find reports: %w", result.Error)
+	}
+	return reports, nil
+}
+
+func (r *reportRepository) FindCategoryById(categoryId uint) (*reportModels.ReportCategory, error) {
+	var category reportModels.ReportCategory
+	result := r.db.Where("id = ?", categoryId).First(&category)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find report category: %w", result.Error)
+	}
+	return &category, nil
+}
+
+func (r *reportRepository) GetReportCategories() ([]reportModels.ReportCategory, error) {
+	var categories []reportModels.ReportCategory
+	result := r.db.Order("title asc").Find(&categories)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find report categories: %w", result.Error)
+	}
+	return categories, nil
+}
+
+func (r *reportRepository) CreateReportCategory(category *reportModels.ReportCategory) error {
+	if err := r.db.Create(category).Error; err != nil {
+		return fmt.Errorf("unable to create report category %w", err)
+	}
+	return nil
+}
+
+func (r *reportRepository) UpdateReportCategory(category *reportModels.ReportCategory) error {
+	if err := r.db.Save(category).Error; err != nil {
+		return fmt.Errorf("unable to update report category %w", err)
+	}
+	return nil
+}
+
+func (r *reportRepository) DeleteReportCategory(categoryId uint) error {
+	if err := r.db.Delete(&reportModels.ReportCategory{}, categoryId).Error; err != nil {
+		return fmt.Errorf("unable to delete report category %w", err)
+	}
+	return nil
+}
+
+// UpdateReportStatus moves the report and records the transition in one transaction,
+// the update only applies while the report is still in history.FromStatus.
+// The reporter and followers get the transition in their feed, the stored events are returned for publishing.
+// The report.status_changed event goes to the outbox in the same transaction.
+func (r *reportRepository) UpdateReportStatus(history *reportModels.ReportStatusHistory) ([]feedModels.FeedEvent, error) {
+	var events []feedModels.FeedEvent
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&reportModels.Report{}).
+			Where("id = ? AND status = ?", history.ReportID, history.FromStatus).
+			Update("status", history.ToStatus)
+		if result.Error != nil {
+			return fmt.Errorf("unable to update report status %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("unable to update report status: %w", gorm.ErrRecordNotFound)
+		}
+
+		if err := tx.Omit("Actor").Create(history).Error; err != nil {
+			return fmt.Errorf("unable to record report status history %w", err)
+		}
+
+		var report reportModels.Report
+		if err := tx.Select("id", "reporter_id").Where("id = ?", history.ReportID).First(&report).Error; err != nil {
+			return fmt.Errorf("unable to find report %w", err)
+		}
+		if err := outboxModels.Record(tx, constants.TopicReportStatusChanged, constants.AggregateReport, history.ReportID, history.ChangedEvent(report.ReporterID)); err != nil {
+			return err
+		}
+
+		var err error
+		events, err = reportModels.CreateFeedEvents(tx, history.ReportID, feedModels.FeedReportStatus, &dto.ReportStatusEventDTO{
+			ReportID:   history.ReportID,
+			FromStatus: string(history.FromStatus),
+			ToStatus:   string(history.ToStatus),
+			Reason:     history.Reason,
+			ChangedAt:  history.CreatedAt,
+		})
+		return err
+	})
+	return events, err
+}
+
+func (r *reportRepository) FindReportStatusHistory(reportId uint) ([]reportModels.ReportStatusHistory, error) {
+	var history []reportModels.ReportStatusHistory
+	result := r.db.Where("report_id = ?", reportId).Order("created_at asc").Find(&history)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find report status history: %w", result.Error)
+	}
+	return history, nil
+}
+
+// onPublicMap keeps rejected reports and private categories such as SOS off the map
+func onPublicMap(query *gorm.DB) *gorm.DB {
+	return query.
+		Joins("JOIN report_categories ON report_categories.id = reports.category_id").
+		Where("reports.status <> ?", reportModels.ReportStatusRejected).
+		Where("report_categories.private = ?", false)
+}
+
+// withinBoundingBox narrows the query with the coordinates index before any distance maths runs
+func withinBoundingBox(query *gorm.DB, box utils.BoundingBox) *gorm.DB {
+	query = query.
+		Joins("JOIN report_locations ON report_locations.id = reports.location_id").
+		Where("report_locations.latitude BETWEEN ? AND ?", box.MinLatitude, box.MaxLatitude)
+
+	if box.CrossesAntimeridian() {
+		return query.Where("(report_locations.longitude >= ? OR report_locations.longitude <= ?)", box.MinLongitude, box.MaxLongitude)
+	}
+	return query.Where("report_locations.longitude BETWEEN ? AND ?", box.MinLongitude, box.MaxLongitude)
+}
+
+func (r *reportRepository) FindReportsWithinRadius(lat, lng, radiusKm float64, limit int) ([]reportModels.Report, error) {
+	var reports []reportModels.Report
+
+	distance := clause.Expr{SQL: haversineSQL, Vars: []interface{}{lat, lat, lng}}
+	query := withinBoundingBox(onPublicMap(r.db.Model(&reportModels.Report{})), utils.RadiusBoundingBox(lat, lng, radiusKm))
+
+	result := query.
+		Preload("Category").Preload("Location").
+		Where(clause.Expr{SQL: "(" + haversineSQL + ") <= ?", Vars: []interface{}{lat, lat, lng, radiusKm}}).
+		Order(clause.OrderBy{Expression: distance}).
+		Limit(limit).
+		Find(&reports)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find nearby reports: %w", result.Error)
+	}
+	return reports, nil
+}
+
+func (r *reportRepository) FindReportsInBoundingBox(box utils.BoundingBox, limit int) ([]reportModels.Report, error) {
+	var reports []reportModels.Report
+
+	result := withinBoundingBox(onPublicMap(r.db.Model(&reportModels.Report{})), box).
+		Preload("Category").Preload("Location").
+		Order("reports.created_at desc").
+		Limit(limit).
+		Find(&reports)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find reports in bounding box: %w", result.Error)
+	}
+	return reports, nil
+}
+
+func (r *reportRepository) CreateReportFile(file *reportModels.ReportFile) error {
+	if err := r.db.Create(file).Error; err != nil {
+		return fmt.Errorf("unable to create report file %w", err)
+	}
+	return nil
+}
+
+func (r *reportRepository) FindReportFiles(reportId uint) ([]reportModels.ReportFile, error) {
+	var files []reportModels.ReportFile
+	result := r.db.Where("report_id = ?", reportId).Order("created_at asc").Find(&files)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find report files: %w", result.Error)
+	}
+	return files, nil
+}
+
+func (r *reportRepository) FindReportFileById(fileId uint) (*reportModels.ReportFile, error) {
+	var file reportModels.ReportFile
+	result := r.db.Where("id = ?", fileId).First(&file)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find report file: %w", result.Error)
+	}
+	return &file, nil
+}
+
+func (r *reportRepository) FindReportFileByHash(reportId uint, sha256 string) (*reportModels.ReportFile, error) {
+	var file reportModels.ReportFile
+	result := r.db.Where("report_id = ? AND sha256 = ?", reportId, sha256).First(&file)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find report file: %w", result.Error)
+	}
+	return &file, nil
+}
+
+func (r *reportRepository) CreateReportUpload(upload *reportModels.ReportUpload) error {
+	if err := r.db.Create(upload).Error; err != nil {
+		return fmt.Errorf("unable to create report upload %w", err)
+	}
+	return nil
+}
+
+func (r *reportRepository) FindReportUpload(uploadId string) (*reportModels.ReportUpload, error) {
+	var upload reportModels.ReportUpload
+	result := r.db.Preload("ReportFile").Where("upload_id = ?", uploadId).First(&upload)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find report upload: %w", result.Error)
+	}
+	return &upload, nil
+}
+
+// UpdateReportUploadProgress only applies when nobody else has moved the upload past previousSize
+func (r *reportRepository) UpdateReportUploadProgress(upload *reportModels.ReportUpload, previousSize int64) error {
+	result := r.db.Model(&reportModels.ReportUpload{}).
+		Where("id = ? AND received_size = ?", upload.ID, previousSize).
+		Updates(map[string]interface{}{
+			"received_size":  upload.ReceivedSize,
+			"status":         upload.Status,
+			"report_file_id": upload.ReportFileID,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("unable to update report upload %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("unable to update report upload: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+func (r *reportRepository) FollowReport(reportId uint, userId uint) error {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&reportModels.ReportFollower{ReportID: reportId, UserID: userId})
+	if result.Error != nil {
+		return fmt.Errorf("unable to follow report %w", result.Error)
+	}
+	return nil
+}
+
+func (r *reportRepository) UnfollowReport(reportId uint, userId uint) error {
+	result := r.db.Where("report_id = ? AND user_id = ?", reportId, userId).Delete(&reportModels.ReportFollower{})
+	if result.Error != nil {
+		return fmt.Errorf("unable to unfollow report %w", result.Error)
+	}
+	return nil
+}
+
+func (r *reportRepository) IsFollowing(reportId uint, userId uint) (bool, error) {
+	var count int64
+	result := r.db.Model(&reportModels.ReportFollower{}).
+		Where("report_id = ? AND user_id = ?", reportId, userId).
+		Count(&count)
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to find report follower %w", result.Error)
+	}
+	return count > 0, nil
+}
+
+// CreateComment stores the comment and puts it in the feed of the reporter and followers
+func (r *reportRepository) CreateComment(comment *reportModels.ReportComment) ([]feedModels.FeedEvent, error) {
+	var events []feedModels.FeedEvent
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Author").Create(comment).Error; err != nil {
+			return fmt.Errorf("unable to create report comment %w", err)
+		}
+
+		var err error
+		events, err = reportModels.CreateFeedEvents(tx, comment.ReportID, feedModels.FeedReportComment, comment.ToDTO())
+		return err
+	})
+	return events, err
+}
+
+func (r *reportRepository) FindComments(reportId uint) ([]reportModels.ReportComment, error) {
+	var comments []reportModels.ReportComment
+	result := r.db.Preload("Author").Where("report_id = ?", reportId).Order("created_at asc").Find(&comments)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find report comments: %w", result.Error)
+	}
+	return comments, nil
+}
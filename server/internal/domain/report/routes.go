@@ -0,0 +1,49 @@
+package report
+
+import (
+	"resq/internal/infra/middleware"
+	"resq/internal/infra/storage"
+	"resq/internal/infra/stream"
+	"resq/pkg/constants"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func ReportRoutes(router *gin.Engine, db *gorm.DB) {
+	reportRepository := NewReportRepository(db)
+	reportService := NewReportService(reportRepository, stream.GlobalHub)
+	reportController := NewReportController(reportService)
+	reportMediaService := NewReportMediaService(reportRepository, storage.GlobalStorage)
+	reportMediaController := NewReportMediaController(reportMediaService)
+
+	// signed links are their own authorisation so responders can open evidence without a bearer token
+	router.GET("/media/report-files/:fileId", reportMediaController.DownloadReportFile)
+
+	reports := router.Group("reports")
+
+	reports.Use(middleware.AuthMiddleware())
+	{
+		reports.POST("", reportController.CreateReport)
+		reports.GET("/mine", reportController.GetUserReports)
+		reports.GET("/categories", reportController.GetReportCategories)
+		reports.POST("/categories", middleware.RequirePermission(constants.PermissionCategoriesManage), reportController.CreateReportCategory)
+		reports.PATCH("/categories/:categoryId", middleware.RequirePermission(constants.PermissionCategoriesManage), reportController.UpdateReportCategory)
+		reports.DELETE("/categories/:categoryId", middleware.RequirePermission(constants.PermissionCategoriesManage), reportController.DeleteReportCategory)
+		reports.GET("/nearby", reportController.GetNearbyReports)
+		reports.GET("/within", reportController.GetReportsInBoundingBox)
+		reports.GET("/:id", reportController.GetReport)
+		reports.GET("/:id/status", reportController.GetReportStatus)
+		reports.PATCH("/:id/status", middleware.RequirePermission(constants.PermissionReportsUpdateStatus), reportController.UpdateReportStatus)
+		reports.GET("/:id/history", reportController.GetReportStatusHistory)
+		reports.POST("/:id/follow", reportController.FollowReport)
+		reports.DELETE("/:id/follow", reportController.UnfollowReport)
+		reports.GET("/:id/comments", reportController.GetReportComments)
+		reports.POST("/:id/comments", middleware.RequirePermission(constants.PermissionReportsUpdateStatus), reportController.AddReportComment)
+		reports.POST("/:id/files", reportMediaController.UploadReportFile)
+		reports.GET("/:id/files", reportMediaController.GetReportFiles)
+		reports.POST("/:id/uploads", reportMediaController.CreateReportUpload)
+		reports.GET("/:id/uploads/:uploadId", reportMediaController.GetReportUpload)
+		reports.PATCH("/:id/uploads/:uploadId", reportMediaController.AppendReportUploadChunk)
+	}
+}
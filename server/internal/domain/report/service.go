@@ -1,15 +1,459 @@
 package report
 
+import (
+	"errors"
+	"fmt"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/stream"
+	"resq/pkg/constants"
+	"resq/pkg/dto"
+	reportModels "resq/pkg/models/report"
+	"resq/pkg/utils"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrReportNotFound          = errors.New("report not found")
+	ErrCategoryNotFound        = errors.New("report category not found")
+	ErrSystemCategory          = errors.New("reports are filed under this category by the server, it cannot be changed")
+	ErrInvalidStatus           = errors.New("invalid report status")
+	ErrInvalidStatusTransition = errors.New("report status transition not allowed")
+	ErrInvalidBoundingBox      = errors.New("min_lat must not be greater than max_lat")
+	ErrUnverifiedReporter      = errors.New("verify your account before filing a report under your name, or file it anonymously")
+)
+
+const defaultMapReportsLimit = 100
+
 type ReportService interface {
+	CreateReport(reporterId uint, request *dto.CreateReportRequestDTO) (*dto.ReportDTO, error)
+	GetReport(reportId uint, requester *dto.AuthenticatedUserDTO) (*dto.ReportDTO, error)
+	GetUserReports(userId uint) ([]dto.ReportDTO, error)
+	GetReportStatus(reportId uint, requester *dto.AuthenticatedUserDTO) (*dto.ReportStatusDTO, error)
+	GetReportCategories() ([]dto.ReportCategoryDTO, error)
+	CreateReportCategory(request *dto.ReportCategoryRequestDTO) (*dto.ReportCategoryDTO, error)
+	UpdateReportCategory(categoryId uint, request *dto.ReportCategoryRequestDTO) (*dto.ReportCategoryDTO, error)
+	DeleteReportCategory(categoryId uint) error
+	UpdateReportStatus(reportId uint, actorId uint, request *dto.UpdateReportStatusRequestDTO) (*dto.ReportStatusHistoryDTO, error)
+	GetReportStatusHistory(reportId uint, requester *dto.AuthenticatedUserDTO) ([]dto.ReportStatusHistoryDTO, error)
+	GetNearbyReports(query *dto.NearbyReportsQueryDTO, requester *dto.AuthenticatedUserDTO) ([]dto.ReportDTO, error)
+	GetReportsInBoundingBox(query *dto.BoundingBoxReportsQueryDTO, requester *dto.AuthenticatedUserDTO) ([]dto.ReportDTO, error)
+	FollowReport(reportId uint, requester *dto.AuthenticatedUserDTO) (*dto.ReportFollowDTO, error)
+	UnfollowReport(reportId uint, userId uint) (*dto.ReportFollowDTO, error)
+	GetReportComments(reportId uint, requester *dto.AuthenticatedUserDTO) ([]dto.ReportCommentDTO, error)
+	AddReportComment(reportId uint, actorId uint, request *dto.ReportCommentRequestDTO) (*dto.ReportCommentDTO, error)
 }
 
 type reportService struct {
 	repository ReportRepository
+	hub        *stream.Hub
+}
+
+func NewReportService(repo ReportRepository, hub *stream.Hub) ReportService {
+	return &reportService{repository: repo, hub: hub}
+}
+
+func (r *reportService) CreateReport(reporterId uint, request *dto.CreateReportRequestDTO) (*dto.ReportDTO, error) {
+	summary := strings.TrimSpace(request.Summary)
+	if summary == "" {
+		return nil, errors.New("summary is required")
+	}
+
+	// anonymous reports stay open to everyone, named ones need an account that proved its contact details
+	if !request.IsAnonymous {
+		reporter, err := r.repository.FindUserById(reporterId)
+		if err != nil {
+			return nil, err
+		}
+		if !reporter.IsVerified() {
+			return nil, ErrUnverifiedReporter
+		}
+	}
+
+	if _, err := r.repository.FindCategoryById(request.CategoryID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, err
+	}
+
+	report := &reportModels.Report{
+		Summary:     summary,
+		IsAnonymous: request.IsAnonymous,
+		CategoryID:  request.CategoryID,
+		ReporterID:  &reporterId,
+		Priority:    reportModels.ReportPriorityNormal,
+		Location: reportModels.ReportLocation{
+			Latitude:       *request.Location.Latitude,
+			Longitude:      *request.Location.Longitude,
+			AccuracyRadius: request.Location.AccuracyRadius,
+			Altitude:       request.Location.Altitude,
+			Address:        request.Location.Address,
+		},
+	}
+
+	result, err := r.repository.CreateReport(report)
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to create report: %v", err))
+		return nil, errors.New("unable to create report")
+	}
+
+	return result.ToDTO(), nil
+}
+
+// findOwnReport only hands out reports filed by the requester, anything else looks like it does not exist
+func findOwnReport(repository ReportRepository, reportId uint, requesterId uint) (*reportModels.Report, error) {
+	report, err := repository.FindReportById(reportId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReportNotFound
+		}
+		return nil, err
+	}
+
+	if !report.IsFiledBy(requesterId) {
+		return nil, ErrReportNotFound
+	}
+
+	return report, nil
+}
+
+// findVisibleReport hands a report to its reporter, to staff who may read every report and to
+// its followers while it stays on the public map, anything else looks like it does not exist
+func findVisibleReport(repository ReportRepository, reportId uint, requester *dto.AuthenticatedUserDTO) (*reportModels.Report, error) {
+	report, err := repository.FindReportById(reportId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReportNotFound
+		}
+		return nil, err
+	}
+
+	if report.IsFiledBy(requester.ID) || requester.Can(constants.PermissionReportsReadAny) {
+		return report, nil
+	}
+	if !report.IsOnPublicMap() {
+		return nil, ErrReportNotFound
+	}
+
+	following, err := repository.IsFollowing(reportId, requester.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !following {
+		return nil, ErrReportNotFound
+	}
+	return report, nil
+}
+
+func (r *reportService) GetReport(reportId uint, requester *dto.AuthenticatedUserDTO) (*dto.ReportDTO, error) {
+	report, err := findVisibleReport(r.repository, reportId, requester)
+	if err != nil {
+		return nil, err
+	}
+
+	result := report.ToDTO()
+	// the reporter can always see that the report is theirs
+	if report.IsFiledBy(requester.ID) {
+		result.ReporterID = report.ReporterID
+	}
+	return result, nil
+}
+
+func (r *reportService) GetUserReports(userId uint) ([]dto.ReportDTO, error) {
+	reports, err := r.repository.FindReportsByReporter(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.ReportDTO, len(reports))
+	for i := range reports {
+		result[i] = *reports[i].ToDTO()
+		result[i].ReporterID = reports[i].ReporterID
+	}
+	return result, nil
+}
+
+func (r *reportService) GetReportStatus(reportId uint, requester *dto.AuthenticatedUserDTO) (*dto.ReportStatusDTO, error) {
+	report, err := findVisibleReport(r.repository, reportId, requester)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.ReportStatusDTO{
+		ID:        report.ID,
+		Status:    string(report.Status),
+		UpdatedAt: report.UpdatedAt,
+	}, nil
+}
+
+func (r *reportService) GetReportCategories() ([]dto.ReportCategoryDTO, error) {
+	categories, err := r.repository.GetReportCategories()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.ReportCategoryDTO, len(categories))
+	for i := range categories {
+		result[i] = *categories[i].ToDTO()
+	}
+	return result, nil
+}
+
+func (r *reportService) UpdateReportStatus(reportId uint, actorId uint, request *dto.UpdateReportStatusRequestDTO) (*dto.ReportStatusHistoryDTO, error) {
+	nextStatus := reportModels.ReportStatus(strings.TrimSpace(request.Status))
+	if !nextStatus.IsValid() {
+		return nil, ErrInvalidStatus
+	}
+
+	report, err := r.repository.FindReportById(reportId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReportNotFound
+		}
+		return nil, err
+	}
+
+	if !report.Status.CanTransitionTo(nextStatus) {
+		return nil, ErrInvalidStatusTransition
+	}
+
+	history := &reportModels.ReportStatusHistory{
+		ReportID:   report.ID,
+		FromStatus: report.Status,
+		ToStatus:   nextStatus,
+		ActorID:    actorId,
+		Reason:     strings.TrimSpace(request.Reason),
+	}
+
+	events, err := r.repository.UpdateReportStatus(history)
+	if err != nil {
+		// somebody else moved the report between the read and the update
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidStatusTransition
+		}
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to update report status: %v", err))
+		return nil, errors.New("unable to update report status")
+	}
+
+	logger.GlobalLogger.Log(logger.INFO, "Report status changed", map[string]interface{}{
+		"report_id": report.ID,
+		"from":      history.FromStatus,
+		"to":        history.ToStatus,
+		"actor_id":  actorId,
+	})
+	stream.PublishFeed(r.hub, events)
+
+	return history.ToDTO(), nil
 }
 
-func NewReportService(repo *ReportRepository) ReportService {
-	return &reportService{repository: repo}
+func (r *reportService) GetReportStatusHistory(reportId uint, requester *dto.AuthenticatedUserDTO) ([]dto.ReportStatusHistoryDTO, error) {
+	report, err := findVisibleReport(r.repository, reportId, requester)
+	if err != nil {
+		return nil, err
+	}
+
+	history, err := r.repository.FindReportStatusHistory(report.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.ReportStatusHistoryDTO, len(history))
+	for i := range history {
+		result[i] = *history[i].ToDTO()
+	}
+	return result, nil
+}
+
+func (r *reportService) CreateReportCategory(request *dto.ReportCategoryRequestDTO) (*dto.ReportCategoryDTO, error) {
+	category := &reportModels.ReportCategory{
+		Title:       strings.TrimSpace(request.Title),
+		Description: strings.TrimSpace(request.Description),
+	}
+
+	if err := r.repository.CreateReportCategory(category); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to create report category: %v", err))
+		return nil, errors.New("unable to create report category")
+	}
+
+	return category.ToDTO(), nil
+}
+
+func (r *reportService) UpdateReportCategory(categoryId uint, request *dto.ReportCategoryRequestDTO) (*dto.ReportCategoryDTO, error) {
+	category, err := r.repository.FindCategoryById(categoryId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCategoryNotFound
+		}
+		return nil, err
+	}
+	if category.IsSystem() {
+		return nil, ErrSystemCategory
+	}
+
+	category.Title = strings.TrimSpace(request.Title)
+	category.Description = strings.TrimSpace(request.Description)
+
+	if err := r.repository.UpdateReportCategory(category); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to update report category: %v", err))
+		return nil, errors.New("unable to update report category")
+	}
+
+	return category.ToDTO(), nil
 }
 
+func (r *reportService) DeleteReportCategory(categoryId uint) error {
+	category, err := r.repository.FindCategoryById(categoryId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrCategoryNotFound
+		}
+		return err
+	}
+	if category.IsSystem() {
+		return ErrSystemCategory
+	}
+
+	// existing reports keep pointing at the soft deleted category
+	return r.repository.DeleteReportCategory(categoryId)
+}
+
+func mapReportsLimit(limit int) int {
+	if limit <= 0 {
+		return defaultMapReportsLimit
+	}
+	return limit
+}
+
+// mapReportDTO is the report as the map shows it, only staff who read every report learn who filed it
+func mapReportDTO(report *reportModels.Report, requester *dto.AuthenticatedUserDTO) dto.ReportDTO {
+	result := *report.ToDTO()
+	if !requester.Can(constants.PermissionReportsReadAny) {
+		result.ReporterID = nil
+	}
+	return result
+}
+
+func (r *reportService) GetNearbyReports(query *dto.NearbyReportsQueryDTO, requester *dto.AuthenticatedUserDTO) ([]dto.ReportDTO, error) {
+	lat, lng := *query.Latitude, *query.Longitude
+
+	reports, err := r.repository.FindReportsWithinRadius(lat, lng, query.RadiusKm, mapReportsLimit(query.Limit))
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to find nearby reports: %v", err))
+		return nil, errors.New("unable to find nearby reports")
+	}
+
+	result := make([]dto.ReportDTO, len(reports))
+	for i := range reports {
+		result[i] = mapReportDTO(&reports[i], requester)
+		distance := utils.HaversineKm(lat, lng, reports[i].Location.Latitude, reports[i].Location.Longitude)
+		result[i].DistanceKm = &distance
+	}
+	return result, nil
+}
 
-	
\ No newline at end of file
+func (r *reportService) GetReportsInBoundingBox(query *dto.BoundingBoxReportsQueryDTO, requester *dto.AuthenticatedUserDTO) ([]dto.ReportDTO, error) {
+	if *query.MinLatitude > *query.MaxLatitude {
+		return nil, ErrInvalidBoundingBox
+	}
+
+	box := utils.BoundingBox{
+		MinLatitude:  *query.MinLatitude,
+		MinLongitude: *query.MinLongitude,
+		MaxLatitude:  *query.MaxLatitude,
+		MaxLongitude: *query.MaxLongitude,
+	}
+
+	reports, err := r.repository.FindReportsInBoundingBox(box, mapReportsLimit(query.Limit))
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to find reports in bounding box: %v", err))
+		return nil, errors.New("unable to find reports in bounding box")
+	}
+
+	result := make([]dto.ReportDTO, len(reports))
+	for i := range reports {
+		result[i] = mapReportDTO(&reports[i], requester)
+	}
+	return result, nil
+}
+
+// FollowReport puts the report's status changes and comments in the user's feed,
+// any report a user can find on the map may be followed, as may staff who read every report
+func (r *reportService) FollowReport(reportId uint, requester *dto.AuthenticatedUserDTO) (*dto.ReportFollowDTO, error) {
+	report, err := r.repository.FindReportById(reportId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReportNotFound
+		}
+		return nil, err
+	}
+
+	// reporters hear about their own reports anyway
+	if report.IsFiledBy(requester.ID) {
+		return &dto.ReportFollowDTO{ReportID: reportId, Following: true}, nil
+	}
+
+	if !report.IsOnPublicMap() && !requester.Can(constants.PermissionReportsReadAny) {
+		return nil, ErrReportNotFound
+	}
+
+	if err := r.repository.FollowReport(reportId, requester.ID); err != nil {
+		return nil, err
+	}
+	return &dto.ReportFollowDTO{ReportID: reportId, Following: true}, nil
+}
+
+func (r *reportService) UnfollowReport(reportId uint, userId uint) (*dto.ReportFollowDTO, error) {
+	if err := r.repository.UnfollowReport(reportId, userId); err != nil {
+		return nil, err
+	}
+	return &dto.ReportFollowDTO{ReportID: reportId, Following: false}, nil
+}
+
+// GetReportComments is open to whoever may see the report
+func (r *reportService) GetReportComments(reportId uint, requester *dto.AuthenticatedUserDTO) ([]dto.ReportCommentDTO, error) {
+	if _, err := findVisibleReport(r.repository, reportId, requester); err != nil {
+		return nil, err
+	}
+
+	comments, err := r.repository.FindComments(reportId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.ReportCommentDTO, len(comments))
+	for i := range comments {
+		result[i] = *comments[i].ToDTO()
+	}
+	return result, nil
+}
+
+// AddReportComment lets a responder tell the reporter and followers what is happening
+func (r *reportService) AddReportComment(reportId uint, actorId uint, request *dto.ReportCommentRequestDTO) (*dto.ReportCommentDTO, error) {
+	body := strings.TrimSpace(request.Body)
+	if body == "" {
+		return nil, errors.New("body is required")
+	}
+
+	if _, err := r.repository.FindReportById(reportId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrReportNotFound
+		}
+		return nil, err
+	}
+	author, err := r.repository.FindUserById(actorId)
+	if err != nil {
+		return nil, err
+	}
+
+	comment := &reportModels.ReportComment{ReportID: reportId, AuthorID: actorId, Author: *author, Body: body}
+	events, err := r.repository.CreateComment(comment)
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to create report comment: %v", err))
+		return nil, errors.New("unable to add comment")
+	}
+	stream.PublishFeed(r.hub, events)
+
+	return comment.ToDTO(), nil
+}
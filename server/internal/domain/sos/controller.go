@@ -0,0 +1,318 @@
+package sos
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"resq/pkg/constants"
+	"resq/pkg/dto"
+	sosModels "resq/pkg/models/sos"
+	"resq/pkg/utils"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// streamKeepAlive keeps proxies from closing a quiet stream while the person stays put
+const streamKeepAlive = 15 * time.Second
+
+type SOSController interface {
+	StartSOS(ctx *gin.Context)
+	GetActiveSession(ctx *gin.Context)
+	GetFollowedSessions(ctx *gin.Context)
+	GetSession(ctx *gin.Context)
+	AddPings(ctx *gin.Context)
+	GetPings(ctx *gin.Context)
+	StreamSession(ctx *gin.Context)
+	CancelSOS(ctx *gin.Context)
+	ResolveSOS(ctx *gin.Context)
+}
+
+type sosController struct {
+	service SOSService
+}
+
+func NewSOSController(service SOSService) SOSController {
+	return &sosController{service: service}
+}
+
+func sosErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrSessionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrSessionEnded):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidPin):
+		return http.StatusForbidden
+	case errors.Is(err, ErrCancelLocked):
+		return http.StatusTooManyRequests
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func (c *sosController) StartSOS(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var request dto.StartSOSRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	result, created, err := c.service.StartSOS(userId, &request)
+	if err != nil {
+		ctx.JSON(sosErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	ctx.JSON(status, gin.H{constants.RequestData: result})
+}
+
+func (c *sosController) GetActiveSession(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	result, err := c.service.GetActiveSession(userId)
+	if err != nil {
+		ctx.JSON(sosErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (c *sosController) GetFollowedSessions(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	result, err := c.service.GetFollowedSessions(userId)
+	if err != nil {
+		ctx.JSON(sosErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (c *sosController) GetSession(ctx *gin.Context) {
+	requester, err := utils.GetAuthenticatedUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	sessionId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid sos id"})
+		return
+	}
+
+	result, err := c.service.GetSession(sessionId, requester)
+	if err != nil {
+		ctx.JSON(sosErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (c *sosController) AddPings(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	sessionId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid sos id"})
+		return
+	}
+
+	var request dto.SOSPingsRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	result, err := c.service.AddPings(userId, sessionId, &request)
+	if err != nil {
+		ctx.JSON(sosErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{constants.RequestData: result})
+}
+
+func (c *sosController) GetPings(ctx *gin.Context) {
+	requester, err := utils.GetAuthenticatedUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	sessionId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid sos id"})
+		return
+	}
+
+	var afterId uint
+	if value := ctx.Query("after_id"); value != "" {
+		if afterId, err = utils.ParseId(value); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid after_id"})
+			return
+		}
+	}
+
+	result, err := c.service.GetPings(sessionId, requester, afterId)
+	if err != nil {
+		ctx.JSON(sosErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+// StreamSession sends the session's pings as server-sent events, a reconnecting EventSource
+// resumes after the Last-Event-ID it already has
+func (c *sosController) StreamSession(ctx *gin.Context) {
+	requester, err := utils.GetAuthenticatedUser(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	sessionId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid sos id"})
+		return
+	}
+
+	var lastEventId uint
+	if value := ctx.GetHeader("Last-Event-ID"); value != "" {
+		lastEventId, _ = utils.ParseId(value)
+	}
+
+	follow, err := c.service.FollowSession(sessionId, requester, lastEventId)
+	if err != nil {
+		ctx.JSON(sosErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+	defer follow.Stop()
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+
+	ctx.Render(-1, sse.Event{Event: "session", Data: follow.Session})
+	lastSent := lastEventId
+	for _, ping := range follow.Replay {
+		ctx.Render(-1, sse.Event{Id: strconv.FormatUint(uint64(ping.ID), 10), Event: EventPing, Data: ping})
+		lastSent = ping.ID
+	}
+	if follow.Session.Status != string(sosModels.SOSActive) {
+		ctx.Render(-1, sse.Event{Event: EventEnded, Data: follow.Session})
+		return
+	}
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	ctx.Stream(func(w io.Writer) bool {
+		select {
+		case <-ctx.Request.Context().Done():
+			return false
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case event, ok := <-follow.Events:
+			if !ok {
+				// fell too far behind, the client reconnects and catches up from Last-Event-ID
+				return false
+			}
+			if event.ID != "" {
+				// already sent as part of the replay
+				if id, err := utils.ParseId(event.ID); err == nil && id <= lastSent {
+					return true
+				}
+			}
+			ctx.Render(-1, sse.Event{Id: event.ID, Event: event.Name, Data: event.Data})
+			return event.Name != EventEnded
+		}
+	})
+}
+
+func (c *sosController) CancelSOS(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	sessionId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid sos id"})
+		return
+	}
+
+	var request dto.CancelSOSRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	result, err := c.service.CancelSOS(userId, sessionId, request.Pin)
+	if err != nil {
+		ctx.JSON(sosErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (c *sosController) ResolveSOS(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	sessionId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid sos id"})
+		return
+	}
+
+	var request dto.ResolveSOSRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil && !errors.Is(err, io.EOF) {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	result, err := c.service.ResolveSOS(userId, sessionId, request.Reason)
+	if err != nil {
+		ctx.JSON(sosErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
@@ -0,0 +1,293 @@
+package sos
+
+import (
+	"fmt"
+	"resq/pkg/constants"
+	"resq/pkg/dto"
+	"resq/pkg/models"
+	contactModels "resq/pkg/models/contact"
+	feedModels "resq/pkg/models/feed"
+	outboxModels "resq/pkg/models/outbox"
+	reportModels "resq/pkg/models/report"
+	sosModels "resq/pkg/models/sos"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SOSRepository interface {
+	FindUserById(userId uint) (*models.User, error)
+	FindOrCreateSOSCategory() (*reportModels.ReportCategory, error)
+	CreateSession(session *sosModels.SOSSession, ping *sosModels.SOSPing, notify func(tx *gorm.DB) error) error
+	FindSessionById(sessionId uint) (*sosModels.SOSSession, error)
+	FindActiveSessionByUser(userId uint) (*sosModels.SOSSession, error)
+	FindFollowedSessions(userId uint) ([]sosModels.SOSSession, error)
+	FindSOSRecipients(userId uint) ([]models.User, error)
+	IsSOSRecipient(ownerId uint, userId uint) (bool, error)
+	CreatePings(sessionId uint, pings []sosModels.SOSPing) error
+	FindPings(sessionId uint, afterId uint, limit int) ([]sosModels.SOSPing, error)
+	RecordCancelAttempt(sessionId uint, maxAttempts int) (bool, error)
+	EndSession(session *sosModels.SOSSession, status sosModels.SOSStatus, actorId uint, reason string, reportStatus reportModels.ReportStatus) ([]feedModels.FeedEvent, error)
+}
+
+type sosRepository struct {
+	db *gorm.DB
+}
+
+func NewSOSRepository(db *gorm.DB) SOSRepository {
+	return &sosRepository{db: db}
+}
+
+func (s *sosRepository) FindUserById(userId uint) (*models.User, error) {
+	var user models.User
+	result := s.db.Where("id = ?", userId).First(&user)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find user: %w", result.Error)
+	}
+	return &user, nil
+}
+
+func (s *sosRepository) FindOrCreateSOSCategory() (*reportModels.ReportCategory, error) {
+	var category reportModels.ReportCategory
+	result := s.db.Where(reportModels.ReportCategory{SystemKey: reportModels.SOSCategoryKey}).
+		Attrs(reportModels.ReportCategory{Title: "SOS", Description: "Panic alerts raised from the SOS button", Private: true}).
+		FirstOrCreate(&category)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find sos category: %w", result.Error)
+	}
+	return &category, nil
+}
+
+// CreateSession files the session's report together with the session and its first ping,
+// the report.created event goes to the outbox and notify alerts the contacts in the same transaction
+func (s *sosRepository) CreateSession(session *sosModels.SOSSession, ping *sosModels.SOSPing, notify func(tx *gorm.DB) error) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Category", "Reporter").Create(&session.Report).Error; err != nil {
+			return err
+		}
+		if err := outboxModels.Record(tx, constants.TopicReportCreated, constants.AggregateReport, session.Report.ID, session.Report.CreatedEvent()); err != nil {
+			return err
+		}
+
+		session.ReportID = session.Report.ID
+		if err := tx.Omit("User", "Report").Create(session).Error; err != nil {
+			return err
+		}
+
+		ping.SessionID = session.ID
+		if err := tx.Create(ping).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Clauses(models.UpsertUserLocation).Create(&models.UserLocation{
+			UserID:         session.UserID,
+			Latitude:       ping.Latitude,
+			Longitude:      ping.Longitude,
+			AccuracyRadius: ping.AccuracyRadius,
+			RecordedAt:     ping.RecordedAt,
+		}).Error; err != nil {
+			return err
+		}
+		return notify(tx)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to create sos session %w", err)
+	}
+	return nil
+}
+
+func (s *sosRepository) FindSessionById(sessionId uint) (*sosModels.SOSSession, error) {
+	var session sosModels.SOSSession
+	result := s.db.Preload("User").Where("id = ?", sessionId).First(&session)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find sos session: %w", result.Error)
+	}
+	return &session, nil
+}
+
+func (s *sosRepository) FindActiveSessionByUser(userId uint) (*sosModels.SOSSession, error) {
+	var session sosModels.SOSSession
+	result := s.db.Preload("User").Where("user_id = ? AND status = ?", userId, sosModels.SOSActive).First(&session)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find sos session: %w", result.Error)
+	}
+	return &session, nil
+}
+
+// FindFollowedSessions returns the active sessions of everyone whose SOS alerts userId
+func (s *sosRepository) FindFollowedSessions(userId uint) ([]sosModels.SOSSession, error) {
+	var sessions []sosModels.SOSSession
+	result := s.db.Preload("User").
+		Where("status = ? AND user_id IN (?)", sosModels.SOSActive, contactModels.SOSOwnerIDs(s.db, userId)).
+		Order("created_at desc").
+		Find(&sessions)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find sos sessions: %w", result.Error)
+	}
+	return sessions, nil
+}
+
+func (s *sosRepository) FindSOSRecipients(userId uint) ([]models.User, error) {
+	var users []models.User
+	result := s.db.Where("id IN (?)", contactModels.SOSRecipientIDs(s.db, userId)).Find(&users)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find sos recipients: %w", result.Error)
+	}
+	return users, nil
+}
+
+func (s *sosRepository) IsSOSRecipient(ownerId uint, userId uint) (bool, error) {
+	var count int64
+	result := s.db.Model(&models.User{}).
+		Where("id = ? AND id IN (?)", userId, contactModels.SOSRecipientIDs(s.db, ownerId)).
+		Count(&count)
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to find sos recipient %w", result.Error)
+	}
+	return count > 0, nil
+}
+
+// CreatePings stores the pings of an active session and moves its last known location to the newest one
+func (s *sosRepository) CreatePings(sessionId uint, pings []sosModels.SOSPing) error {
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var session sosModels.SOSSession
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND status = ?", sessionId, sosModels.SOSActive).
+			First(&session).Error; err != nil {
+			return err
+		}
+
+		for i := range pings {
+			pings[i].SessionID = sessionId
+		}
+		if err := tx.Create(&pings).Error; err != nil {
+			return err
+		}
+
+		latest := pings[0]
+		for _, ping := range pings[1:] {
+			if ping.RecordedAt.After(latest.RecordedAt) {
+				latest = ping
+			}
+		}
+		if session.LastPingAt != nil && session.LastPingAt.After(latest.RecordedAt) {
+			return nil
+		}
+
+		if err := tx.Model(&session).Updates(map[string]interface{}{
+			"last_latitude":  latest.Latitude,
+			"last_longitude": latest.Longitude,
+			"last_ping_at":   latest.RecordedAt,
+		}).Error; err != nil {
+			return err
+		}
+
+		return tx.Clauses(models.UpsertUserLocation).Create(&models.UserLocation{
+			UserID:         session.UserID,
+			Latitude:       latest.Latitude,
+			Longitude:      latest.Longitude,
+			AccuracyRadius: latest.AccuracyRadius,
+			RecordedAt:     latest.RecordedAt,
+		}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("unable to store sos pings: %w", err)
+	}
+	return nil
+}
+
+func (s *sosRepository) FindPings(sessionId uint, afterId uint, limit int) ([]sosModels.SOSPing, error) {
+	var pings []sosModels.SOSPing
+	result := s.db.Where("session_id = ? AND id > ?", sessionId, afterId).
+		Order("id asc").
+		Limit(limit).
+		Find(&pings)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find sos pings: %w", result.Error)
+	}
+	return pings, nil
+}
+
+// RecordCancelAttempt counts a PIN guess, false means the session has no guesses left
+func (s *sosRepository) RecordCancelAttempt(sessionId uint, maxAttempts int) (bool, error) {
+	result := s.db.Model(&sosModels.SOSSession{}).
+		Where("id = ? AND cancel_attempts < ?", sessionId, maxAttempts).
+		Update("cancel_attempts", gorm.Expr("cancel_attempts + 1"))
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to record cancel attempt %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// EndSession closes an active session and moves its report to reportStatus, recording who did it in the report history.
+// The report follows its lifecycle on the way, stepping through in_progress when needed, and a report staff already
+// closed is left as it is. The report's audience gets each transition in their feed, the stored events are returned for publishing.
+// Every transition also goes to the outbox as a report.status_changed event.
+func (s *sosRepository) EndSession(session *sosModels.SOSSession, status sosModels.SOSStatus, actorId uint, reason string, reportStatus reportModels.ReportStatus) ([]feedModels.FeedEvent, error) {
+	endedAt := time.Now()
+	var events []feedModels.FeedEvent
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&sosModels.SOSSession{}).
+			Where("id = ? AND status = ?", session.ID, sosModels.SOSActive).
+			Updates(map[string]interface{}{
+				"status":      status,
+				"ended_at":    endedAt,
+				"ended_by_id": actorId,
+				"end_reason":  reason,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		var report reportModels.Report
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", session.ReportID).First(&report).Error; err != nil {
+			return err
+		}
+
+		for _, next := range report.Status.PathTo(reportStatus) {
+			if err := tx.Model(&report).Update("status", next).Error; err != nil {
+				return err
+			}
+			history := &reportModels.ReportStatusHistory{
+				ReportID:   report.ID,
+				FromStatus: report.Status,
+				ToStatus:   next,
+				ActorID:    actorId,
+				Reason:     reason,
+			}
+			if err := tx.Omit("Actor").Create(history).Error; err != nil {
+				return err
+			}
+			report.Status = next
+			if err := outboxModels.Record(tx, constants.TopicReportStatusChanged, constants.AggregateReport, report.ID, history.ChangedEvent(report.ReporterID)); err != nil {
+				return err
+			}
+
+			stepEvents, err := reportModels.CreateFeedEvents(tx, report.ID, feedModels.FeedReportStatus, &dto.ReportStatusEventDTO{
+				ReportID:   report.ID,
+				FromStatus: string(history.FromStatus),
+				ToStatus:   string(history.ToStatus),
+				Reason:     history.Reason,
+				ChangedAt:  history.CreatedAt,
+			})
+			if err != nil {
+				return err
+			}
+			events = append(events, stepEvents...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to end sos session: %w", err)
+	}
+
+	session.Status = status
+	session.EndedAt = &endedAt
+	session.EndedByID = &actorId
+	session.EndReason = reason
+	return events, nil
+}
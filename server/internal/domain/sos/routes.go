@@ -0,0 +1,32 @@
+package sos
+
+import (
+	"resq/internal/infra/middleware"
+	"resq/internal/infra/notifier"
+	"resq/internal/infra/stream"
+	"resq/pkg/constants"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func SOSRoutes(router *gin.Engine, db *gorm.DB) {
+	sosRepository := NewSOSRepository(db)
+	sosService := NewSOSService(sosRepository, stream.GlobalHub, notifier.GlobalNotifier)
+	sosController := NewSOSController(sosService)
+
+	sos := router.Group("sos")
+
+	sos.Use(middleware.AuthMiddleware())
+	{
+		sos.POST("", sosController.StartSOS)
+		sos.GET("/active", sosController.GetActiveSession)
+		sos.GET("/following", sosController.GetFollowedSessions)
+		sos.GET("/:id", sosController.GetSession)
+		sos.POST("/:id/pings", sosController.AddPings)
+		sos.GET("/:id/pings", sosController.GetPings)
+		sos.GET("/:id/stream", sosController.StreamSession)
+		sos.POST("/:id/cancel", sosController.CancelSOS)
+		sos.POST("/:id/resolve", middleware.RequirePermission(constants.PermissionReportsUpdateStatus), sosController.ResolveSOS)
+	}
+}
@@ -0,0 +1,403 @@
+package sos
+
+import (
+	"errors"
+	"fmt"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/notifier"
+	"resq/internal/infra/stream"
+	"resq/pkg/constants"
+	"resq/pkg/dto"
+	"resq/pkg/models"
+	reportModels "resq/pkg/models/report"
+	sosModels "resq/pkg/models/sos"
+	"resq/pkg/utils"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// pingReplayLimit caps how many stored pings a reconnecting follower is sent before live ones
+	pingReplayLimit = 500
+
+	EventPing  = "ping"
+	EventEnded = "ended"
+)
+
+var (
+	ErrSessionNotFound = errors.New("sos session not found")
+	ErrSessionEnded    = errors.New("sos session has ended")
+	ErrInvalidPin      = errors.New("invalid pin")
+	ErrCancelLocked    = errors.New("too many wrong pins, a responder has to close this sos")
+)
+
+// SOSFollow is a follower's view of a session, the stored pings it missed followed by live events
+type SOSFollow struct {
+	Session *dto.SOSSessionDTO
+	Replay  []dto.SOSPingDTO
+	Events  <-chan stream.Event
+	Stop    func()
+}
+
+type SOSService interface {
+	StartSOS(userId uint, request *dto.StartSOSRequestDTO) (*dto.SOSSessionDTO, bool, error)
+	GetActiveSession(userId uint) (*dto.SOSSessionDTO, error)
+	GetFollowedSessions(userId uint) ([]dto.SOSSessionDTO, error)
+	GetSession(sessionId uint, viewer *dto.AuthenticatedUserDTO) (*dto.SOSSessionDTO, error)
+	AddPings(userId uint, sessionId uint, request *dto.SOSPingsRequestDTO) ([]dto.SOSPingDTO, error)
+	GetPings(sessionId uint, viewer *dto.AuthenticatedUserDTO, afterId uint) ([]dto.SOSPingDTO, error)
+	FollowSession(sessionId uint, viewer *dto.AuthenticatedUserDTO, lastEventId uint) (*SOSFollow, error)
+	CancelSOS(userId uint, sessionId uint, pin string) (*dto.SOSSessionDTO, error)
+	ResolveSOS(actorId uint, sessionId uint, reason string) (*dto.SOSSessionDTO, error)
+}
+
+type sosService struct {
+	repository SOSRepository
+	hub        *stream.Hub
+	notifier   notifier.NotificationService
+}
+
+func NewSOSService(repo SOSRepository, hub *stream.Hub, n notifier.NotificationService) SOSService {
+	return &sosService{repository: repo, hub: hub, notifier: n}
+}
+
+func sessionTopic(sessionId uint) string {
+	return "sos:" + strconv.FormatUint(uint64(sessionId), 10)
+}
+
+// StartSOS opens a high priority report and a live session, pressing SOS again while one is active returns it unchanged
+func (s *sosService) StartSOS(userId uint, request *dto.StartSOSRequestDTO) (*dto.SOSSessionDTO, bool, error) {
+	if active, err := s.repository.FindActiveSessionByUser(userId); err == nil {
+		return active.ToDTO(), false, nil
+	}
+
+	user, err := s.repository.FindUserById(userId)
+	if err != nil {
+		return nil, false, err
+	}
+
+	pinHash, err := utils.HashPIN(request.Pin)
+	if err != nil {
+		return nil, false, err
+	}
+
+	category, err := s.repository.FindOrCreateSOSCategory()
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to find sos category: %v", err))
+		return nil, false, errors.New("unable to raise sos")
+	}
+
+	now := time.Now()
+	session := &sosModels.SOSSession{
+		UserID: userId,
+		Report: reportModels.Report{
+			Title:      "SOS",
+			Summary:    request.Message,
+			CategoryID: category.ID,
+			ReporterID: &userId,
+			Status:     reportModels.ReportStatusPending,
+			Priority:   reportModels.ReportPriorityHigh,
+			Location: reportModels.ReportLocation{
+				Latitude:       *request.Latitude,
+				Longitude:      *request.Longitude,
+				AccuracyRadius: request.AccuracyRadius,
+			},
+		},
+		Status:        sosModels.SOSActive,
+		Message:       request.Message,
+		PinHash:       pinHash,
+		LastLatitude:  *request.Latitude,
+		LastLongitude: *request.Longitude,
+		LastPingAt:    &now,
+	}
+	ping := &sosModels.SOSPing{
+		Latitude:       *request.Latitude,
+		Longitude:      *request.Longitude,
+		AccuracyRadius: request.AccuracyRadius,
+		RecordedAt:     now,
+	}
+
+	recipients, err := s.repository.FindSOSRecipients(userId)
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to find sos recipients: %v", err))
+		return nil, false, errors.New("unable to raise sos")
+	}
+
+	name := displayName(user)
+	alertContacts := func(tx *gorm.DB) error {
+		for _, recipient := range recipients {
+			_, err := s.notifier.NotifyTx(tx, notifier.Request{
+				UserID:   recipient.ID,
+				Template: sosTemplate,
+				Data:     sosData(name, session),
+				Link:     map[string]string{"sos_session_id": strconv.FormatUint(uint64(session.ID), 10)},
+				Critical: true,
+			})
+			if err != nil && !errors.Is(err, notifier.ErrRecipientNotFound) {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := s.repository.CreateSession(session, ping, alertContacts); err != nil {
+		// a second tap racing the first one loses on the active session index
+		if active, findErr := s.repository.FindActiveSessionByUser(userId); findErr == nil {
+			return active.ToDTO(), false, nil
+		}
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to create sos session: %v", err))
+		return nil, false, errors.New("unable to raise sos")
+	}
+	session.User = *user
+
+	logger.GlobalLogger.Log(logger.INFO, "SOS raised", map[string]interface{}{
+		"user_id":    userId,
+		"session_id": session.ID,
+		"report_id":  session.ReportID,
+		"recipients": len(recipients),
+	})
+
+	return session.ToDTO(), true, nil
+}
+
+func (s *sosService) GetActiveSession(userId uint) (*dto.SOSSessionDTO, error) {
+	session, err := s.repository.FindActiveSessionByUser(userId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	return session.ToDTO(), nil
+}
+
+func (s *sosService) GetFollowedSessions(userId uint) ([]dto.SOSSessionDTO, error) {
+	sessions, err := s.repository.FindFollowedSessions(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.SOSSessionDTO, len(sessions))
+	for i := range sessions {
+		result[i] = *sessions[i].ToDTO()
+	}
+	return result, nil
+}
+
+// findFollowableSession hands a session to its owner, the contacts it alerts and staff who may read every report
+func (s *sosService) findFollowableSession(sessionId uint, viewer *dto.AuthenticatedUserDTO) (*sosModels.SOSSession, error) {
+	session, err := s.repository.FindSessionById(sessionId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+
+	if session.UserID == viewer.ID || viewer.Can(constants.PermissionReportsReadAny) {
+		return session, nil
+	}
+
+	recipient, err := s.repository.IsSOSRecipient(session.UserID, viewer.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !recipient {
+		return nil, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+func (s *sosService) GetSession(sessionId uint, viewer *dto.AuthenticatedUserDTO) (*dto.SOSSessionDTO, error) {
+	session, err := s.findFollowableSession(sessionId, viewer)
+	if err != nil {
+		return nil, err
+	}
+	return session.ToDTO(), nil
+}
+
+func (s *sosService) findOwnActiveSession(userId uint, sessionId uint) (*sosModels.SOSSession, error) {
+	session, err := s.repository.FindSessionById(sessionId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	if session.UserID != userId {
+		return nil, ErrSessionNotFound
+	}
+	if !session.IsActive() {
+		return nil, ErrSessionEnded
+	}
+	return session, nil
+}
+
+// AddPings stores the phone's location fixes and pushes them to everyone following the session
+func (s *sosService) AddPings(userId uint, sessionId uint, request *dto.SOSPingsRequestDTO) ([]dto.SOSPingDTO, error) {
+	if _, err := s.findOwnActiveSession(userId, sessionId); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	pings := make([]sosModels.SOSPing, len(request.Pings))
+	for i, fix := range request.Pings {
+		recordedAt := now
+		// the phone clock is trusted for buffered fixes, but not into the future
+		if fix.RecordedAt != nil && fix.RecordedAt.Before(now) {
+			recordedAt = *fix.RecordedAt
+		}
+		pings[i] = sosModels.SOSPing{
+			Latitude:       *fix.Latitude,
+			Longitude:      *fix.Longitude,
+			AccuracyRadius: fix.AccuracyRadius,
+			Altitude:       fix.Altitude,
+			Speed:          fix.Speed,
+			Heading:        fix.Heading,
+			BatteryLevel:   fix.BatteryLevel,
+			RecordedAt:     recordedAt,
+		}
+	}
+
+	if err := s.repository.CreatePings(sessionId, pings); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionEnded
+		}
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to store sos pings: %v", err))
+		return nil, errors.New("unable to store location")
+	}
+
+	result := make([]dto.SOSPingDTO, len(pings))
+	for i := range pings {
+		result[i] = *pings[i].ToDTO()
+		s.hub.Publish(sessionTopic(sessionId), stream.Event{
+			ID:   strconv.FormatUint(uint64(pings[i].ID), 10),
+			Name: EventPing,
+			Data: result[i],
+		})
+	}
+	return result, nil
+}
+
+func (s *sosService) GetPings(sessionId uint, viewer *dto.AuthenticatedUserDTO, afterId uint) ([]dto.SOSPingDTO, error) {
+	if _, err := s.findFollowableSession(sessionId, viewer); err != nil {
+		return nil, err
+	}
+
+	pings, err := s.repository.FindPings(sessionId, afterId, pingReplayLimit)
+	if err != nil {
+		return nil, err
+	}
+	return pingsToDTO(pings), nil
+}
+
+// FollowSession subscribes before reading the stored pings so nothing published in between is lost,
+// the caller skips live pings it already got from the replay
+func (s *sosService) FollowSession(sessionId uint, viewer *dto.AuthenticatedUserDTO, lastEventId uint) (*SOSFollow, error) {
+	session, err := s.findFollowableSession(sessionId, viewer)
+	if err != nil {
+		return nil, err
+	}
+
+	events, stop := s.hub.Subscribe(sessionTopic(sessionId))
+
+	pings, err := s.repository.FindPings(sessionId, lastEventId, pingReplayLimit)
+	if err != nil {
+		stop()
+		return nil, err
+	}
+
+	return &SOSFollow{
+		Session: session.ToDTO(),
+		Replay:  pingsToDTO(pings),
+		Events:  events,
+		Stop:    stop,
+	}, nil
+}
+
+// CancelSOS ends the session when the owner gives the PIN they chose, the report is closed as a false alarm
+func (s *sosService) CancelSOS(userId uint, sessionId uint, pin string) (*dto.SOSSessionDTO, error) {
+	session, err := s.findOwnActiveSession(userId, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := s.repository.RecordCancelAttempt(session.ID, sosModels.SOSMaxCancelAttempts)
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to record sos cancel attempt: %v", err))
+		return nil, errors.New("unable to cancel sos")
+	}
+	if !allowed {
+		return nil, ErrCancelLocked
+	}
+
+	if !utils.VerifyPassword(pin, session.PinHash) {
+		logger.GlobalLogger.Log(logger.INFO, "Wrong pin given to cancel SOS", map[string]interface{}{
+			"session_id": session.ID,
+		})
+		return nil, ErrInvalidPin
+	}
+
+	return s.endSession(session, sosModels.SOSCancelled, userId, "cancelled by the user", reportModels.ReportStatusRejected)
+}
+
+func (s *sosService) ResolveSOS(actorId uint, sessionId uint, reason string) (*dto.SOSSessionDTO, error) {
+	session, err := s.repository.FindSessionById(sessionId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	if !session.IsActive() {
+		return nil, ErrSessionEnded
+	}
+
+	if reason == "" {
+		reason = "resolved by a responder"
+	}
+	return s.endSession(session, sosModels.SOSResolved, actorId, reason, reportModels.ReportStatusResolved)
+}
+
+func (s *sosService) endSession(session *sosModels.SOSSession, status sosModels.SOSStatus, actorId uint, reason string, reportStatus reportModels.ReportStatus) (*dto.SOSSessionDTO, error) {
+	feedEvents, err := s.repository.EndSession(session, status, actorId, reason, reportStatus)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrSessionEnded
+		}
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to end sos session: %v", err))
+		return nil, errors.New("unable to end sos")
+	}
+
+	logger.GlobalLogger.Log(logger.INFO, "SOS ended", map[string]interface{}{
+		"session_id": session.ID,
+		"status":     status,
+		"actor_id":   actorId,
+	})
+
+	result := session.ToDTO()
+	s.hub.Publish(sessionTopic(session.ID), stream.Event{Name: EventEnded, Data: result})
+	stream.PublishFeed(s.hub, feedEvents)
+	return result, nil
+}
+
+func pingsToDTO(pings []sosModels.SOSPing) []dto.SOSPingDTO {
+	result := make([]dto.SOSPingDTO, len(pings))
+	for i := range pings {
+		result[i] = *pings[i].ToDTO()
+	}
+	return result
+}
+
+func displayName(user *models.User) string {
+	name := user.FirstName
+	if user.LastName != "" {
+		name += " " + user.LastName
+	}
+	if name == "" {
+		return "Someone"
+	}
+	return name
+}
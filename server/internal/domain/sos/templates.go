@@ -0,0 +1,37 @@
+package sos
+
+import (
+	"fmt"
+	"resq/internal/infra/notifier"
+	"resq/pkg/constants"
+	sosModels "resq/pkg/models/sos"
+	"strings"
+)
+
+var sosTemplate = notifier.NewTemplate("sos",
+	"SOS: {{.Name}} needs help",
+	`{{.Name}} raised an SOS on ResQ and listed you as an emergency contact.{{if .Message}}
+
+Their message: {{.Message}}{{end}}
+
+Last known location: {{.Location}}
+
+{{if .FollowLink}}Follow their location live: {{.FollowLink}}{{else}}Open ResQ to follow their location live.{{end}}`)
+
+// followLink opens the session in the app, without APP_BASE_URL the contact is pointed at the app itself
+func followLink(session *sosModels.SOSSession) string {
+	baseURL := strings.TrimRight(constants.AppBaseURL(), "/")
+	if baseURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/sos/%d", baseURL, session.ID)
+}
+
+func sosData(name string, session *sosModels.SOSSession) map[string]interface{} {
+	return map[string]interface{}{
+		"Name":       name,
+		"Message":    session.Message,
+		"Location":   fmt.Sprintf("%.5f,%.5f", session.LastLatitude, session.LastLongitude),
+		"FollowLink": followLink(session),
+	}
+}
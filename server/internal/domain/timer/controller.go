@@ -0,0 +1,125 @@
+package timer
+
+import (
+	"errors"
+	"net/http"
+	"resq/pkg/constants"
+	"resq/pkg/dto"
+	"resq/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TimerController interface {
+	ArmTimer(ctx *gin.Context)
+	GetActiveTimer(ctx *gin.Context)
+	ExtendTimer(ctx *gin.Context)
+	DisarmTimer(ctx *gin.Context)
+}
+
+type timerController struct {
+	service TimerService
+}
+
+func NewTimerController(service TimerService) TimerController {
+	return &timerController{service: service}
+}
+
+func timerErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrTimerNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrTimerArmed), errors.Is(err, ErrTimerNotArmed):
+		return http.StatusConflict
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func (t *timerController) ArmTimer(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var request dto.ArmSafetyTimerRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	result, err := t.service.ArmTimer(userId, &request)
+	if err != nil {
+		ctx.JSON(timerErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{constants.RequestData: result})
+}
+
+func (t *timerController) GetActiveTimer(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	result, err := t.service.GetActiveTimer(userId)
+	if err != nil {
+		ctx.JSON(timerErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (t *timerController) ExtendTimer(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	timerId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid timer id"})
+		return
+	}
+
+	var request dto.ExtendSafetyTimerRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	result, err := t.service.ExtendTimer(userId, timerId, &request)
+	if err != nil {
+		ctx.JSON(timerErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (t *timerController) DisarmTimer(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	timerId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid timer id"})
+		return
+	}
+
+	result, err := t.service.DisarmTimer(userId, timerId)
+	if err != nil {
+		ctx.JSON(timerErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
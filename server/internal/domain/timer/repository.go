@@ -0,0 +1,250 @@
+package timer
+
+import (
+	"errors"
+	"fmt"
+	"resq/pkg/models"
+	contactModels "resq/pkg/models/contact"
+	timerModels "resq/pkg/models/timer"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TimerRepository interface {
+	FindUserById(userId uint) (*models.User, error)
+	SaveUserLocation(location *models.UserLocation) error
+	FindEmergencyContacts(userId uint) ([]models.User, error)
+	CreateTimer(timer *timerModels.SafetyTimer) error
+	FindTimerById(timerId uint) (*timerModels.SafetyTimer, error)
+	FindArmedTimerByUser(userId uint) (*timerModels.SafetyTimer, error)
+	ExtendTimer(timerId uint, dueAt time.Time) error
+	DisarmTimer(timerId uint) error
+	ClaimReminders(now time.Time, lead time.Duration, limit int, notify func(tx *gorm.DB, timer *timerModels.SafetyTimer) error) ([]timerModels.SafetyTimer, error)
+	ClaimLapsedTimers(now time.Time, limit int, notify func(tx *gorm.DB, timer *timerModels.SafetyTimer, contacts []models.User) error) ([]timerModels.SafetyTimer, error)
+}
+
+type timerRepository struct {
+	db *gorm.DB
+}
+
+func NewTimerRepository(db *gorm.DB) TimerRepository {
+	return &timerRepository{db: db}
+}
+
+func (t *timerRepository) FindUserById(userId uint) (*models.User, error) {
+	var user models.User
+	result := t.db.Where("id = ?", userId).First(&user)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find user: %w", result.Error)
+	}
+	return &user, nil
+}
+
+func (t *timerRepository) SaveUserLocation(location *models.UserLocation) error {
+	if err := t.db.Clauses(models.UpsertUserLocation).Create(location).Error; err != nil {
+		return fmt.Errorf("unable to save user location %w", err)
+	}
+	return nil
+}
+
+// FindEmergencyContacts returns the accepted contacts userId chose to alert on SOS, a lapsed timer alerts the same people
+func (t *timerRepository) FindEmergencyContacts(userId uint) ([]models.User, error) {
+	return emergencyContacts(t.db, userId)
+}
+
+func emergencyContacts(db *gorm.DB, userId uint) ([]models.User, error) {
+	var users []models.User
+	result := db.Where("id IN (?)", contactModels.SOSRecipientIDs(db, userId)).Find(&users)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find emergency contacts: %w", result.Error)
+	}
+	return users, nil
+}
+
+func (t *timerRepository) CreateTimer(timer *timerModels.SafetyTimer) error {
+	if err := t.db.Omit("User").Create(timer).Error; err != nil {
+		return fmt.Errorf("unable to create safety timer %w", err)
+	}
+	return nil
+}
+
+func (t *timerRepository) FindTimerById(timerId uint) (*timerModels.SafetyTimer, error) {
+	var timer timerModels.SafetyTimer
+	result := t.db.Where("id = ?", timerId).First(&timer)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find safety timer: %w", result.Error)
+	}
+	return &timer, nil
+}
+
+func (t *timerRepository) FindArmedTimerByUser(userId uint) (*timerModels.SafetyTimer, error) {
+	var timer timerModels.SafetyTimer
+	result := t.db.Where("user_id = ? AND status = ?", userId, timerModels.SafetyTimerArmed).First(&timer)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find safety timer: %w", result.Error)
+	}
+	return &timer, nil
+}
+
+// ExtendTimer only moves a timer that is armed and has not lapsed yet, the reminder is sent again for the new deadline
+func (t *timerRepository) ExtendTimer(timerId uint, dueAt time.Time) error {
+	result := t.db.Model(&timerModels.SafetyTimer{}).
+		Where("id = ? AND status = ? AND due_at > ?", timerId, timerModels.SafetyTimerArmed, time.Now()).
+		Updates(map[string]interface{}{
+			"due_at":           dueAt,
+			"extensions":       gorm.Expr("extensions + 1"),
+			"reminder_sent_at": nil,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("unable to extend safety timer %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("unable to extend safety timer: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// DisarmTimer stops an armed timer, or marks an escalated one as checked in after the fact
+func (t *timerRepository) DisarmTimer(timerId uint) error {
+	result := t.db.Model(&timerModels.SafetyTimer{}).
+		Where("id = ? AND status IN ?", timerId, []timerModels.SafetyTimerStatus{timerModels.SafetyTimerArmed, timerModels.SafetyTimerEscalated}).
+		Updates(map[string]interface{}{
+			"status":      timerModels.SafetyTimerDisarmed,
+			"disarmed_at": time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("unable to disarm safety timer %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("unable to disarm safety timer: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// ClaimReminders marks the armed timers falling due within lead as reminded and returns them.
+// Rows another server is claiming are skipped, so every reminder goes out once. notify queues the reminder of
+// each timer in the same transaction, a timer is only marked reminded together with its reminder.
+func (t *timerRepository) ClaimReminders(now time.Time, lead time.Duration, limit int, notify func(tx *gorm.DB, timer *timerModels.SafetyTimer) error) ([]timerModels.SafetyTimer, error) {
+	var timers []timerModels.SafetyTimer
+	err := t.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND reminder_sent_at IS NULL AND due_at > ? AND due_at <= ?", timerModels.SafetyTimerArmed, now, now.Add(lead)).
+			Order("due_at asc").
+			Limit(limit).
+			Find(&timers).Error; err != nil {
+			return err
+		}
+		if len(timers) == 0 {
+			return nil
+		}
+
+		ids := make([]uint, len(timers))
+		for i := range timers {
+			ids[i] = timers[i].ID
+			timers[i].ReminderSentAt = &now
+		}
+		if err := tx.Model(&timerModels.SafetyTimer{}).Where("id IN ?", ids).Update("reminder_sent_at", now).Error; err != nil {
+			return err
+		}
+
+		if err := withUsers(tx, timers); err != nil {
+			return err
+		}
+		for i := range timers {
+			if err := notify(tx, &timers[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to claim safety timer reminders: %w", err)
+	}
+	return timers, nil
+}
+
+// ClaimLapsedTimers escalates the armed timers that are past due and returns them,
+// each one records the user's last known location at that moment. notify queues the alerts to the owner's
+// emergency contacts in the same transaction, when it fails nothing is escalated and the next run tries again.
+func (t *timerRepository) ClaimLapsedTimers(now time.Time, limit int, notify func(tx *gorm.DB, timer *timerModels.SafetyTimer, contacts []models.User) error) ([]timerModels.SafetyTimer, error) {
+	var timers []timerModels.SafetyTimer
+	err := t.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND due_at <= ?", timerModels.SafetyTimerArmed, now).
+			Order("due_at asc").
+			Limit(limit).
+			Find(&timers).Error; err != nil {
+			return err
+		}
+
+		for i := range timers {
+			timer := &timers[i]
+			timer.Status = timerModels.SafetyTimerEscalated
+			timer.EscalatedAt = &now
+
+			var location models.UserLocation
+			err := tx.Where("user_id = ?", timer.UserID).First(&location).Error
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return err
+			}
+			if err == nil {
+				timer.EscalatedLatitude = &location.Latitude
+				timer.EscalatedLongitude = &location.Longitude
+			}
+
+			if err := tx.Model(timer).Updates(map[string]interface{}{
+				"status":              timer.Status,
+				"escalated_at":        now,
+				"escalated_latitude":  timer.EscalatedLatitude,
+				"escalated_longitude": timer.EscalatedLongitude,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := withUsers(tx, timers); err != nil {
+			return err
+		}
+		for i := range timers {
+			contacts, err := emergencyContacts(tx, timers[i].UserID)
+			if err != nil {
+				return err
+			}
+			if err := notify(tx, &timers[i], contacts); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to claim lapsed safety timers: %w", err)
+	}
+	return timers, nil
+}
+
+func withUsers(db *gorm.DB, timers []timerModels.SafetyTimer) error {
+	if len(timers) == 0 {
+		return nil
+	}
+
+	userIds := make([]uint, len(timers))
+	for i := range timers {
+		userIds[i] = timers[i].UserID
+	}
+
+	var users []models.User
+	if err := db.Where("id IN ?", userIds).Find(&users).Error; err != nil {
+		return fmt.Errorf("unable to find safety timer users: %w", err)
+	}
+
+	byId := make(map[uint]models.User, len(users))
+	for _, user := range users {
+		byId[user.ID] = user
+	}
+	for i := range timers {
+		timers[i].User = byId[timers[i].UserID]
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package timer
+
+import (
+	"fmt"
+	"resq/internal/infra/jobs"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/middleware"
+	"resq/internal/infra/notifier"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TimerRoutes(router *gin.Engine, db *gorm.DB) {
+	timerRepository := NewTimerRepository(db)
+	timerService := NewTimerService(timerRepository, notifier.GlobalNotifier)
+	timerController := NewTimerController(timerService)
+
+	if err := jobs.GlobalRunner.Cron("safety-timers", CheckSchedule, timerService.RunDueTimers); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to schedule safety timers: %v", err))
+	}
+
+	timers := router.Group("timers")
+
+	timers.Use(middleware.AuthMiddleware())
+	{
+		timers.POST("", timerController.ArmTimer)
+		timers.GET("/active", timerController.GetActiveTimer)
+		timers.POST("/:id/extend", timerController.ExtendTimer)
+		timers.POST("/:id/disarm", timerController.DisarmTimer)
+	}
+}
@@ -0,0 +1,313 @@
+package timer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/notifier"
+	"resq/pkg/dto"
+	"resq/pkg/models"
+	timerModels "resq/pkg/models/timer"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	MinTimerDuration = time.Minute
+	MaxTimerDuration = 7 * 24 * time.Hour
+	// ReminderLead is how long before the deadline the user is reminded to check in
+	ReminderLead = 10 * time.Minute
+	// CheckSchedule is when the job runner looks for timers to remind or escalate
+	CheckSchedule = "* * * * *"
+	// claimBatch caps the timers one run handles, the rest wait for the next run
+	claimBatch = 100
+)
+
+var (
+	ErrTimerNotFound   = errors.New("safety timer not found")
+	ErrTimerArmed      = errors.New("you already have an armed safety timer, extend or disarm it")
+	ErrTimerNotArmed   = errors.New("safety timer is no longer armed")
+	ErrInvalidDeadline = errors.New("the deadline must be between a minute and seven days from now")
+)
+
+type TimerService interface {
+	ArmTimer(userId uint, request *dto.ArmSafetyTimerRequestDTO) (*dto.SafetyTimerDTO, error)
+	GetActiveTimer(userId uint) (*dto.SafetyTimerDTO, error)
+	ExtendTimer(userId uint, timerId uint, request *dto.ExtendSafetyTimerRequestDTO) (*dto.SafetyTimerDTO, error)
+	DisarmTimer(userId uint, timerId uint) (*dto.SafetyTimerDTO, error)
+	RunDueTimers(ctx context.Context) error
+}
+
+type timerService struct {
+	repository TimerRepository
+	notifier   notifier.NotificationService
+}
+
+func NewTimerService(repo TimerRepository, n notifier.NotificationService) TimerService {
+	return &timerService{repository: repo, notifier: n}
+}
+
+// deadline resolves an absolute or relative deadline, from is where a relative one counts from
+func deadline(dueAt *time.Time, durationMinutes int, from time.Time) (time.Time, error) {
+	var due time.Time
+	switch {
+	case dueAt != nil:
+		due = *dueAt
+	case durationMinutes > 0:
+		due = from.Add(time.Duration(durationMinutes) * time.Minute)
+	default:
+		return time.Time{}, errors.New("either due_at or duration_minutes is required")
+	}
+
+	now := time.Now()
+	if due.Before(now.Add(MinTimerDuration)) || due.After(now.Add(MaxTimerDuration)) {
+		return time.Time{}, ErrInvalidDeadline
+	}
+	return due, nil
+}
+
+func (t *timerService) saveLocation(userId uint, latitude, longitude *float64) {
+	if latitude == nil || longitude == nil {
+		return
+	}
+	err := t.repository.SaveUserLocation(&models.UserLocation{
+		UserID:     userId,
+		Latitude:   *latitude,
+		Longitude:  *longitude,
+		RecordedAt: time.Now(),
+	})
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to save user location: %v", err))
+	}
+}
+
+func (t *timerService) ArmTimer(userId uint, request *dto.ArmSafetyTimerRequestDTO) (*dto.SafetyTimerDTO, error) {
+	dueAt, err := deadline(request.DueAt, request.DurationMinutes, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := t.repository.FindArmedTimerByUser(userId); err == nil {
+		return nil, ErrTimerArmed
+	}
+
+	timer := &timerModels.SafetyTimer{
+		UserID: userId,
+		Status: timerModels.SafetyTimerArmed,
+		DueAt:  dueAt,
+		Note:   request.Note,
+	}
+	if err := t.repository.CreateTimer(timer); err != nil {
+		// a second request racing the first one loses on the armed timer index
+		if _, findErr := t.repository.FindArmedTimerByUser(userId); findErr == nil {
+			return nil, ErrTimerArmed
+		}
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to arm safety timer: %v", err))
+		return nil, errors.New("unable to arm safety timer")
+	}
+
+	t.saveLocation(userId, request.Latitude, request.Longitude)
+
+	logger.GlobalLogger.Log(logger.INFO, "Safety timer armed", map[string]interface{}{
+		"user_id":  userId,
+		"timer_id": timer.ID,
+		"due_at":   dueAt,
+	})
+	return timer.ToDTO(), nil
+}
+
+func (t *timerService) GetActiveTimer(userId uint) (*dto.SafetyTimerDTO, error) {
+	timer, err := t.repository.FindArmedTimerByUser(userId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTimerNotFound
+		}
+		return nil, err
+	}
+	return timer.ToDTO(), nil
+}
+
+func (t *timerService) findOwnTimer(userId uint, timerId uint) (*timerModels.SafetyTimer, error) {
+	timer, err := t.repository.FindTimerById(timerId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTimerNotFound
+		}
+		return nil, err
+	}
+	if timer.UserID != userId {
+		return nil, ErrTimerNotFound
+	}
+	return timer, nil
+}
+
+// ExtendTimer moves the deadline of a timer that has not lapsed, a relative extension counts from the current deadline
+func (t *timerService) ExtendTimer(userId uint, timerId uint, request *dto.ExtendSafetyTimerRequestDTO) (*dto.SafetyTimerDTO, error) {
+	timer, err := t.findOwnTimer(userId, timerId)
+	if err != nil {
+		return nil, err
+	}
+	if !timer.IsArmed() {
+		return nil, ErrTimerNotArmed
+	}
+
+	dueAt, err := deadline(request.DueAt, request.DurationMinutes, timer.DueAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := t.repository.ExtendTimer(timer.ID, dueAt); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTimerNotArmed
+		}
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to extend safety timer: %v", err))
+		return nil, errors.New("unable to extend safety timer")
+	}
+
+	t.saveLocation(userId, request.Latitude, request.Longitude)
+
+	timer.DueAt = dueAt
+	timer.Extensions++
+	timer.ReminderSentAt = nil
+	return timer.ToDTO(), nil
+}
+
+// DisarmTimer is the user checking in. When the timer already lapsed their contacts are told they are safe.
+func (t *timerService) DisarmTimer(userId uint, timerId uint) (*dto.SafetyTimerDTO, error) {
+	timer, err := t.findOwnTimer(userId, timerId)
+	if err != nil {
+		return nil, err
+	}
+	if timer.Status == timerModels.SafetyTimerDisarmed {
+		return nil, ErrTimerNotArmed
+	}
+
+	if err := t.repository.DisarmTimer(timer.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTimerNotArmed
+		}
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to disarm safety timer: %v", err))
+		return nil, errors.New("unable to disarm safety timer")
+	}
+
+	// the safety-timers job may have escalated the timer since it was read
+	escalated := timer.Status == timerModels.SafetyTimerEscalated
+	if !escalated {
+		if current, err := t.repository.FindTimerById(timer.ID); err == nil && current.EscalatedAt != nil {
+			escalated = true
+		}
+	}
+
+	disarmedAt := time.Now()
+	timer.Status = timerModels.SafetyTimerDisarmed
+	timer.DisarmedAt = &disarmedAt
+
+	if escalated {
+		if user, err := t.repository.FindUserById(userId); err == nil {
+			timer.User = *user
+			t.allClear(timer)
+		}
+	}
+
+	logger.GlobalLogger.Log(logger.INFO, "Safety timer disarmed", map[string]interface{}{
+		"user_id":   userId,
+		"timer_id":  timer.ID,
+		"escalated": escalated,
+	})
+	return timer.ToDTO(), nil
+}
+
+// RunDueTimers is the scheduled job, it reminds users whose deadline is close and escalates lapsed timers.
+// The messages are queued in the transaction that claims the timers and the notifier retries sending them.
+func (t *timerService) RunDueTimers(ctx context.Context) error {
+	now := time.Now()
+
+	if _, err := t.repository.ClaimReminders(now, ReminderLead, claimBatch, t.remind); err != nil {
+		return err
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	lapsed, err := t.repository.ClaimLapsedTimers(now, claimBatch, t.escalate)
+	if err != nil {
+		return err
+	}
+	for _, timer := range lapsed {
+		logger.GlobalLogger.Log(logger.INFO, "Safety timer lapsed, emergency contacts alerted", map[string]interface{}{
+			"user_id":  timer.UserID,
+			"timer_id": timer.ID,
+			"due_at":   timer.DueAt,
+		})
+	}
+	return nil
+}
+
+func (t *timerService) remind(tx *gorm.DB, timer *timerModels.SafetyTimer) error {
+	_, err := t.notifier.NotifyTx(tx, notifier.Request{
+		UserID:   timer.UserID,
+		Template: reminderTemplate,
+		Data:     timerData(timer),
+		Link:     timerLink(timer),
+		// a reminder held back until quiet hours end would come after the contacts were alerted
+		Critical: true,
+	})
+	if err != nil && !errors.Is(err, notifier.ErrRecipientNotFound) {
+		return err
+	}
+	return nil
+}
+
+// escalate alerts the emergency contacts of a lapsed timer's owner
+func (t *timerService) escalate(tx *gorm.DB, timer *timerModels.SafetyTimer, contacts []models.User) error {
+	if len(contacts) == 0 {
+		logger.GlobalLogger.Log(logger.INFO, "Safety timer owner has no emergency contacts to alert", map[string]interface{}{
+			"timer_id": timer.ID,
+		})
+		return nil
+	}
+
+	for _, contact := range contacts {
+		_, err := t.notifier.NotifyTx(tx, notifier.Request{
+			UserID:   contact.ID,
+			Template: lapsedTemplate,
+			Data:     timerData(timer),
+			Critical: true,
+		})
+		if err != nil && !errors.Is(err, notifier.ErrRecipientNotFound) {
+			return err
+		}
+	}
+	return nil
+}
+
+// allClear tells the emergency contacts that the owner of an escalated timer checked in
+func (t *timerService) allClear(timer *timerModels.SafetyTimer) {
+	contacts, err := t.repository.FindEmergencyContacts(timer.UserID)
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to find emergency contacts: %v", err))
+		return
+	}
+
+	for _, contact := range contacts {
+		_, err := t.notifier.Notify(context.Background(), notifier.Request{
+			UserID:   contact.ID,
+			Template: allClearTemplate,
+			Data:     timerData(timer),
+		})
+		if err != nil && !errors.Is(err, notifier.ErrRecipientNotFound) {
+			logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to tell emergency contact: %v", err), map[string]interface{}{
+				"timer_id":   timer.ID,
+				"contact_id": contact.ID,
+			})
+		}
+	}
+}
+
+// timerLink opens the timer in its owner's app, contacts have nothing to open
+func timerLink(timer *timerModels.SafetyTimer) map[string]string {
+	return map[string]string{"safety_timer_id": strconv.FormatUint(uint64(timer.ID), 10)}
+}
@@ -0,0 +1,51 @@
+package timer
+
+import (
+	"fmt"
+	"resq/internal/infra/notifier"
+	timerModels "resq/pkg/models/timer"
+	"time"
+)
+
+var reminderTemplate = notifier.NewTemplate("safety_timer_reminder",
+	"Your safety timer is about to run out",
+	`Your safety timer runs out at {{.DueAt}}. Check in or extend it, otherwise your emergency contacts are alerted.`)
+
+var lapsedTemplate = notifier.NewTemplate("safety_timer_lapsed",
+	"{{.Name}} has not checked in",
+	`{{.Name}} set a safety timer on ResQ and listed you as an emergency contact. They said they would check in by {{.DueAt}} and have not.{{if .Note}}
+
+Their note: {{.Note}}{{end}}
+
+{{.Location}} Please try to reach them.`)
+
+var allClearTemplate = notifier.NewTemplate("safety_timer_all_clear",
+	"{{.Name}} has checked in",
+	`{{.Name}} has checked in on ResQ after their safety timer ran out. They are safe.`)
+
+func displayName(timer *timerModels.SafetyTimer) string {
+	name := timer.User.FirstName
+	if timer.User.LastName != "" {
+		name += " " + timer.User.LastName
+	}
+	if name == "" {
+		return "Someone"
+	}
+	return name
+}
+
+func lastKnownLocation(timer *timerModels.SafetyTimer) string {
+	if timer.EscalatedLatitude == nil || timer.EscalatedLongitude == nil {
+		return "Their location is not known."
+	}
+	return fmt.Sprintf("Last known location %.5f,%.5f.", *timer.EscalatedLatitude, *timer.EscalatedLongitude)
+}
+
+func timerData(timer *timerModels.SafetyTimer) map[string]interface{} {
+	return map[string]interface{}{
+		"Name":     displayName(timer),
+		"DueAt":    timer.DueAt.UTC().Format(time.RFC1123),
+		"Note":     timer.Note,
+		"Location": lastKnownLocation(timer),
+	}
+}
@@ -1,18 +1,43 @@
 package user
 
 import (
+	"errors"
 	"github.com/gin-gonic/gin"
+	"math"
 	"net/http"
 	"resq/pkg/constants"
 	"resq/pkg/dto"
 	"resq/pkg/models"
 	"resq/pkg/utils"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
 )
 
 type UserController interface {
 	CreateUser(ctx *gin.Context)
 	AuthorizeUser(ctx *gin.Context)
 	GetUserProfileInformation(ctx *gin.Context)
+	UpdateUserRole(ctx *gin.Context)
+	RefreshSession(ctx *gin.Context)
+	Logout(ctx *gin.Context)
+	LogoutAllDevices(ctx *gin.Context)
+	VerifyEmail(ctx *gin.Context)
+	ResendEmailVerification(ctx *gin.Context)
+	ForgotPassword(ctx *gin.Context)
+	ResetPassword(ctx *gin.Context)
+	SendPhoneOTP(ctx *gin.Context)
+	VerifyPhoneOTP(ctx *gin.Context)
+	UnlockUser(ctx *gin.Context)
+	UpdateProfile(ctx *gin.Context)
+	ChangePassword(ctx *gin.Context)
+	DeleteAccount(ctx *gin.Context)
+	UpdateLocation(ctx *gin.Context)
+	ClearLocation(ctx *gin.Context)
+	GetSavedPlaces(ctx *gin.Context)
+	AddSavedPlace(ctx *gin.Context)
+	RemoveSavedPlace(ctx *gin.Context)
 }
 
 type userController struct {
@@ -47,14 +72,21 @@ func (u *userController) AuthorizeUser(ctx *gin.Context) {
 		return
 	}
 
-	token, err := u.service.AuthorizeUser(&login)
+	token, err := u.service.AuthorizeUser(&login, ctx.ClientIP())
+
+	var locked *LoginLockedError
+	if errors.As(err, &locked) {
+		ctx.Header("Retry-After", strconv.Itoa(int(math.Ceil(locked.RetryAfter.Seconds()))))
+		ctx.JSON(http.StatusTooManyRequests, gin.H{constants.RequestError: locked.Error()})
+		return
+	}
 
 	if err != nil {
 		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "authorization error"})
 		return
 	}
 
-	if token == "" {
+	if token == nil {
 		ctx.JSON(http.StatusInternalServerError, gin.H{constants.RequestError: "invalid token generated"})
 		return
 	}
@@ -96,3 +128,390 @@ func (u *userController) GetUserProfileInformation(ctx *gin.Context) {
 
 	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
 }
+
+func (u *userController) UpdateUserRole(ctx *gin.Context) {
+	userId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid user id"})
+		return
+	}
+
+	var request dto.UpdateUserRoleRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	result, err := u.service.UpdateUserRole(userId, request.Role)
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (u *userController) RefreshSession(ctx *gin.Context) {
+	var request dto.RefreshTokenRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	token, err := u.service.RefreshSession(request.RefreshToken)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: token})
+}
+
+func (u *userController) Logout(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	// the refresh token is optional, without it only the current access token is revoked
+	var request dto.LogoutRequestDTO
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid request body"})
+			return
+		}
+	}
+
+	expiresAt, _ := ctx.Get("token_expires_at")
+	accessExpiresAt, ok := expiresAt.(time.Time)
+	if !ok {
+		accessExpiresAt = time.Now().Add(utils.AccessTokenLifetime)
+	}
+
+	if err := u.service.Logout(userId, ctx.GetString("jti"), accessExpiresAt, request.RefreshToken); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (u *userController) LogoutAllDevices(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	if err := u.service.LogoutAllDevices(userId); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (u *userController) VerifyEmail(ctx *gin.Context) {
+	var request dto.VerifyEmailRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	if err := u.service.VerifyEmail(request.Token); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (u *userController) ResendEmailVerification(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	if err := u.service.ResendEmailVerification(userId); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusAccepted)
+}
+
+func (u *userController) ForgotPassword(ctx *gin.Context) {
+	var request dto.ForgotPasswordRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	if err := u.service.ForgotPassword(request.Email); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusAccepted)
+}
+
+func (u *userController) ResetPassword(ctx *gin.Context) {
+	var request dto.ResetPasswordRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	if err := u.service.ResetPassword(request.Token, request.Password); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func phoneOTPErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrTooManyOTPRequests), errors.Is(err, ErrTooManyOTPAttempts):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrInvalidPhoneOTP):
+		return http.StatusUnauthorized
+	case errors.Is(err, ErrSMSUnavailable):
+		return http.StatusServiceUnavailable
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func (u *userController) SendPhoneOTP(ctx *gin.Context) {
+	var request dto.SendPhoneOTPRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	if err := u.service.SendPhoneOTP(request.Phone); err != nil {
+		ctx.JSON(phoneOTPErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusAccepted)
+}
+
+func (u *userController) VerifyPhoneOTP(ctx *gin.Context) {
+	var request dto.VerifyPhoneOTPRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	token, err := u.service.VerifyPhoneOTP(&request)
+	if err != nil {
+		ctx.JSON(phoneOTPErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: token})
+}
+
+func (u *userController) UnlockUser(ctx *gin.Context) {
+	userId, err := utils.ParseId(ctx.Param("id"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid user id"})
+		return
+	}
+
+	actorId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	if err := u.service.UnlockUser(userId, actorId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			ctx.JSON(http.StatusNotFound, gin.H{constants.RequestError: "user not found"})
+			return
+		}
+		ctx.JSON(http.StatusInternalServerError, gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func profileErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound), errors.Is(err, ErrSavedPlaceNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrEmailTaken), errors.Is(err, ErrPhoneTaken):
+		return http.StatusConflict
+	case errors.Is(err, ErrInvalidCredentials):
+		return http.StatusForbidden
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+func (u *userController) UpdateProfile(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var request dto.UpdateProfileRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	result, err := u.service.UpdateProfile(userId, &request)
+	if err != nil {
+		ctx.JSON(profileErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (u *userController) ChangePassword(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var request dto.ChangePasswordRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	token, err := u.service.ChangePassword(userId, &request)
+	if err != nil {
+		ctx.JSON(profileErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: token})
+}
+
+func (u *userController) DeleteAccount(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var request dto.DeleteAccountRequestDTO
+	if ctx.Request.ContentLength > 0 {
+		if err := ctx.ShouldBindJSON(&request); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid request body"})
+			return
+		}
+	}
+
+	if err := u.service.DeleteAccount(userId, request.Password); err != nil {
+		ctx.JSON(profileErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (u *userController) UpdateLocation(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var request dto.UpdateLocationRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	if err := u.service.UpdateLocation(userId, &request); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (u *userController) ClearLocation(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	if err := u.service.ClearLocation(userId); err != nil {
+		ctx.JSON(http.StatusInternalServerError, gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
+
+func (u *userController) GetSavedPlaces(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	result, err := u.service.GetSavedPlaces(userId)
+	if err != nil {
+		ctx.JSON(profileErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{constants.RequestData: result})
+}
+
+func (u *userController) AddSavedPlace(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	var request dto.SavedPlaceRequestDTO
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: utils.FormatValidationErrors(err, request)})
+		return
+	}
+
+	result, err := u.service.AddSavedPlace(userId, &request)
+	if err != nil {
+		ctx.JSON(profileErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{constants.RequestData: result})
+}
+
+func (u *userController) RemoveSavedPlace(ctx *gin.Context) {
+	userId, err := utils.GetAuthenticatedUserId(ctx)
+	if err != nil {
+		ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "unauthorized"})
+		return
+	}
+
+	placeId, err := utils.ParseId(ctx.Param("placeId"))
+	if err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{constants.RequestError: "invalid place id"})
+		return
+	}
+
+	if err := u.service.RemoveSavedPlace(userId, placeId); err != nil {
+		ctx.JSON(profileErrorStatus(err), gin.H{constants.RequestError: err.Error()})
+		return
+	}
+
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,39 @@
+package user
+
+import (
+	"fmt"
+	"net/url"
+	"resq/internal/infra/mailer"
+	"resq/pkg/constants"
+	"strings"
+	"time"
+)
+
+// actionLink points at the app screen for a token, without APP_BASE_URL the raw token is all the user gets
+func actionLink(path string, token string) string {
+	baseURL := strings.TrimRight(constants.AppBaseURL(), "/")
+	if baseURL == "" {
+		return ""
+	}
+	return baseURL + path + "?token=" + url.QueryEscape(token)
+}
+
+func verificationEmail(to string, firstName string, token string, lifetime time.Duration) mailer.Message {
+	body := fmt.Sprintf("Hi %s,\n\nPlease confirm your email address for ResQ with this code:\n\n%s\n", firstName, token)
+	if link := actionLink("/verify-email", token); link != "" {
+		body += fmt.Sprintf("\nOr open %s\n", link)
+	}
+	body += fmt.Sprintf("\nThe code expires in %d hours.\n", int(lifetime.Hours()))
+
+	return mailer.Message{To: to, Subject: "Confirm your ResQ email address", Body: body}
+}
+
+func passwordResetEmail(to string, firstName string, token string, lifetime time.Duration) mailer.Message {
+	body := fmt.Sprintf("Hi %s,\n\nSomeone asked to reset the password of your ResQ account. Use this code to choose a new one:\n\n%s\n", firstName, token)
+	if link := actionLink("/reset-password", token); link != "" {
+		body += fmt.Sprintf("\nOr open %s\n", link)
+	}
+	body += fmt.Sprintf("\nThe code expires in %d minutes. If this was not you, you can ignore this email.\n", int(lifetime.Minutes()))
+
+	return mailer.Message{To: to, Subject: "Reset your ResQ password", Body: body}
+}
@@ -1,10 +1,22 @@
 package user
 
 import (
+	"errors"
 	"fmt"
+	"resq/internal/infra/logger"
+	"resq/pkg/constants"
 	"resq/pkg/dto"
 	"resq/pkg/models"
+	contactModels "resq/pkg/models/contact"
+	feedModels "resq/pkg/models/feed"
+	notificationModels "resq/pkg/models/notification"
+	outboxModels "resq/pkg/models/outbox"
+	reportModels "resq/pkg/models/report"
+	timerModels "resq/pkg/models/timer"
+	"resq/pkg/utils"
+	"time"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 
@@ -14,6 +26,40 @@ type UserRepository interface {
 	CreateUser (*models.User) (*dto.UserDTO, error)
 	FindUserByEmail (email string) (*models.User, error)
 	GetUserProfileInformation (userId uint) (*dto.UserDTO, error)
+	UpdateUserRole (userId uint, role constants.Role) (*dto.UserDTO, error)
+	FindUserById (userId uint) (*models.User, error)
+	CreateRefreshToken (token *models.RefreshToken) error
+	FindRefreshTokenByHash (tokenHash string) (*models.RefreshToken, error)
+	RotateRefreshToken (current *models.RefreshToken, next *models.RefreshToken) error
+	RevokeRefreshToken (tokenId uint) error
+	RevokeRefreshTokenFamily (familyId string) error
+	RevokeUserRefreshTokens (userId uint) error
+	CreateRevokedToken (token *models.RevokedToken) error
+	IsTokenRevoked (jti string, userId string, issuedAt time.Time) bool
+	CreateUserToken (token *models.UserToken) error
+	ConsumeUserToken (tokenHash string, purpose models.UserTokenPurpose) (*models.UserToken, error)
+	InvalidateUserTokens (userId uint, purpose models.UserTokenPurpose) error
+	MarkEmailVerified (userId uint) error
+	UpdatePassword (userId uint, hashedPassword string) error
+	FindUserByPhone (phone string) (*models.User, error)
+	MarkPhoneVerified (userId uint) error
+	CreatePhoneOTP (otp *models.PhoneOTP) error
+	FindLatestPhoneOTP (phone string) (*models.PhoneOTP, error)
+	CountPhoneOTPsSince (phone string, since time.Time) (int64, error)
+	RecordPhoneOTPAttempt (otpId uint, maxAttempts int) (bool, error)
+	ConsumePhoneOTP (otpId uint) error
+	FindLoginThrottle (scope models.LoginThrottleScope, key string) (*models.LoginThrottle, error)
+	RecordLoginFailure (scope models.LoginThrottleScope, key string, resetBefore time.Time) (*models.LoginThrottle, error)
+	LockLogin (throttleId uint, until time.Time) error
+	ClearLoginThrottles (scope models.LoginThrottleScope, keys []string) error
+	UpdateUserProfile (userId uint, changes map[string]interface{}) (*models.User, error)
+	DeleteUser (userId uint) error
+	SaveUserLocation (location *models.UserLocation) error
+	DeleteUserLocation (userId uint) error
+	CountSavedPlaces (userId uint) (int64, error)
+	CreateSavedPlace (place *models.SavedPlace) error
+	FindSavedPlaces (userId uint) ([]models.SavedPlace, error)
+	DeleteSavedPlace (userId uint, placeId uint) error
 }
 
 
@@ -27,10 +73,16 @@ func NewUserRepository (db *gorm.DB) UserRepository {
 
 
 
+// CreateUser stores the user and its user.registered event in one transaction
 func (u *userRepository ) CreateUser (user *models.User) (*dto.UserDTO, error) {
-	result := u.db.Create(user);
-	if result.Error != nil {
-		return nil, fmt.Errorf("unable to create user %w", result.Error)
+	err := u.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return fmt.Errorf("unable to create user %w", err)
+		}
+		return outboxModels.Record(tx, constants.TopicUserRegistered, constants.AggregateUser, user.ID, user.RegisteredEvent())
+	})
+	if err != nil {
+		return nil, err
 	}
 	return user.ToDTO(), nil
 }
@@ -55,3 +107,507 @@ func (u *userRepository) GetUserProfileInformation (userId uint) (*dto.UserDTO,
 	}
 	return user.ToDTO(), nil
 }
+
+
+func (u *userRepository) UpdateUserRole (userId uint, role constants.Role) (*dto.UserDTO, error) {
+	var user models.User
+	if err := u.db.Where("id = ?", userId).First(&user).Error; err != nil {
+		return nil, fmt.Errorf("unable to find user: %w", err)
+	}
+
+	if err := u.db.Model(&user).Update("role", role).Error; err != nil {
+		return nil, fmt.Errorf("unable to update user role %w", err)
+	}
+	return user.ToDTO(), nil
+}
+
+
+func (u *userRepository) FindUserById (userId uint) (*models.User, error) {
+	var user models.User
+	result := u.db.Where("id = ?", userId).First(&user)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find user: %w", result.Error)
+	}
+	return &user, nil
+}
+
+
+func (u *userRepository) CreateRefreshToken (token *models.RefreshToken) error {
+	if err := u.db.Create(token).Error; err != nil {
+		return fmt.Errorf("unable to create refresh token %w", err)
+	}
+	return nil
+}
+
+
+func (u *userRepository) FindRefreshTokenByHash (tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	result := u.db.Where("token_hash = ?", tokenHash).First(&token)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find refresh token: %w", result.Error)
+	}
+	return &token, nil
+}
+
+
+// RotateRefreshToken revokes current and stores next in one transaction, it fails with
+// gorm.ErrRecordNotFound when current was already used so a replayed token never rotates twice
+func (u *userRepository) RotateRefreshToken (current *models.RefreshToken, next *models.RefreshToken) error {
+	return u.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND revoked_at IS NULL", current.ID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			return fmt.Errorf("unable to revoke refresh token %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("unable to rotate refresh token: %w", gorm.ErrRecordNotFound)
+		}
+
+		if err := tx.Create(next).Error; err != nil {
+			return fmt.Errorf("unable to create refresh token %w", err)
+		}
+		return nil
+	})
+}
+
+
+func (u *userRepository) RevokeRefreshToken (tokenId uint) error {
+	result := u.db.Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", tokenId).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("unable to revoke refresh token %w", result.Error)
+	}
+	return nil
+}
+
+
+func (u *userRepository) RevokeRefreshTokenFamily (familyId string) error {
+	result := u.db.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyId).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("unable to revoke refresh token family %w", result.Error)
+	}
+	return nil
+}
+
+
+func (u *userRepository) RevokeUserRefreshTokens (userId uint) error {
+	result := u.db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userId).
+		Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("unable to revoke refresh tokens %w", result.Error)
+	}
+	return nil
+}
+
+
+func (u *userRepository) CreateRevokedToken (token *models.RevokedToken) error {
+	if err := u.db.Create(token).Error; err != nil {
+		return fmt.Errorf("unable to revoke token %w", err)
+	}
+	return nil
+}
+
+
+// IsTokenRevoked fails closed, a token is refused when the denylist cannot be read
+func (u *userRepository) IsTokenRevoked (jti string, userId string, issuedAt time.Time) bool {
+	parsedUserId, err := utils.ParseUserId(userId)
+	if err != nil {
+		return true
+	}
+
+	var count int64
+	result := u.db.Model(&models.RevokedToken{}).
+		Where("expires_at > ?", time.Now()).
+		Where(u.db.Where("jti = ?", jti).Or("user_id = ? AND issued_before > ?", parsedUserId, issuedAt)).
+		Count(&count)
+
+	if result.Error != nil {
+		logger.GlobalLogger.Log(logger.ERROR, "unable to check token revocation", map[string]interface{}{
+			"error": result.Error.Error(),
+		})
+		return true
+	}
+	return count > 0
+}
+
+
+func (u *userRepository) CreateUserToken (token *models.UserToken) error {
+	if err := u.db.Create(token).Error; err != nil {
+		return fmt.Errorf("unable to create user token %w", err)
+	}
+	return nil
+}
+
+
+// ConsumeUserToken marks a live token used and returns it, a used or expired token is reported as not found
+func (u *userRepository) ConsumeUserToken (tokenHash string, purpose models.UserTokenPurpose) (*models.UserToken, error) {
+	var token models.UserToken
+	err := u.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("token_hash = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?", tokenHash, purpose, time.Now()).
+			First(&token)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		result = tx.Model(&models.UserToken{}).
+			Where("id = ? AND used_at IS NULL", token.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to consume user token: %w", err)
+	}
+	return &token, nil
+}
+
+
+func (u *userRepository) InvalidateUserTokens (userId uint, purpose models.UserTokenPurpose) error {
+	result := u.db.Model(&models.UserToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userId, purpose).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("unable to invalidate user tokens %w", result.Error)
+	}
+	return nil
+}
+
+
+func (u *userRepository) MarkEmailVerified (userId uint) error {
+	result := u.db.Model(&models.User{}).Where("id = ?", userId).Update("email_verified_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("unable to verify email %w", result.Error)
+	}
+	return nil
+}
+
+
+func (u *userRepository) UpdatePassword (userId uint, hashedPassword string) error {
+	result := u.db.Model(&models.User{}).Where("id = ?", userId).Update("password", hashedPassword)
+	if result.Error != nil {
+		return fmt.Errorf("unable to update password %w", result.Error)
+	}
+	return nil
+}
+
+
+func (u *userRepository) FindUserByPhone (phone string) (*models.User, error) {
+	var user models.User
+	result := u.db.Where("phone = ?", phone).First(&user)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find user: %w", result.Error)
+	}
+	return &user, nil
+}
+
+
+func (u *userRepository) MarkPhoneVerified (userId uint) error {
+	result := u.db.Model(&models.User{}).Where("id = ?", userId).Update("phone_verified_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("unable to verify phone %w", result.Error)
+	}
+	return nil
+}
+
+
+// CreatePhoneOTP stores a new code and retires every code still live for the same number
+func (u *userRepository) CreatePhoneOTP (otp *models.PhoneOTP) error {
+	err := u.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PhoneOTP{}).
+			Where("phone = ? AND consumed_at IS NULL", otp.Phone).
+			Update("consumed_at", time.Now()).Error; err != nil {
+			return err
+		}
+		return tx.Create(otp).Error
+	})
+	if err != nil {
+		return fmt.Errorf("unable to create phone otp %w", err)
+	}
+	return nil
+}
+
+
+func (u *userRepository) FindLatestPhoneOTP (phone string) (*models.PhoneOTP, error) {
+	var otp models.PhoneOTP
+	result := u.db.Where("phone = ?", phone).Order("created_at DESC").First(&otp)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find phone otp: %w", result.Error)
+	}
+	return &otp, nil
+}
+
+
+func (u *userRepository) CountPhoneOTPsSince (phone string, since time.Time) (int64, error) {
+	var count int64
+	result := u.db.Model(&models.PhoneOTP{}).Where("phone = ? AND created_at > ?", phone, since).Count(&count)
+	if result.Error != nil {
+		return 0, fmt.Errorf("unable to count phone otps %w", result.Error)
+	}
+	return count, nil
+}
+
+
+// RecordPhoneOTPAttempt counts a guess against the code, false means the code has no guesses left
+func (u *userRepository) RecordPhoneOTPAttempt (otpId uint, maxAttempts int) (bool, error) {
+	result := u.db.Model(&models.PhoneOTP{}).
+		Where("id = ? AND attempts < ?", otpId, maxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		return false, fmt.Errorf("unable to record phone otp attempt %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+
+func (u *userRepository) ConsumePhoneOTP (otpId uint) error {
+	result := u.db.Model(&models.PhoneOTP{}).
+		Where("id = ? AND consumed_at IS NULL", otpId).
+		Update("consumed_at", time.Now())
+	if result.Error != nil {
+		return fmt.Errorf("unable to consume phone otp %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("unable to consume phone otp: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+
+func (u *userRepository) FindLoginThrottle (scope models.LoginThrottleScope, key string) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	result := u.db.Where("scope = ? AND key = ?", scope, key).First(&throttle)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find login throttle: %w", result.Error)
+	}
+	return &throttle, nil
+}
+
+
+// RecordLoginFailure adds a failure to the counter, a counter whose last failure is older than resetBefore starts over
+func (u *userRepository) RecordLoginFailure (scope models.LoginThrottleScope, key string, resetBefore time.Time) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	err := u.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("scope = ? AND key = ?", scope, key).
+			First(&throttle)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			throttle = models.LoginThrottle{Scope: scope, Key: key, Failures: 1, LastFailureAt: now}
+			return tx.Create(&throttle).Error
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if throttle.LastFailureAt.Before(resetBefore) && !throttle.IsLocked(now) {
+			throttle.Failures = 0
+		}
+		throttle.Failures++
+		throttle.LastFailureAt = now
+		return tx.Model(&throttle).Updates(map[string]interface{}{
+			"failures":        throttle.Failures,
+			"last_failure_at": throttle.LastFailureAt,
+		}).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to record login failure %w", err)
+	}
+	return &throttle, nil
+}
+
+
+func (u *userRepository) LockLogin (throttleId uint, until time.Time) error {
+	result := u.db.Model(&models.LoginThrottle{}).Where("id = ?", throttleId).Update("locked_until", until)
+	if result.Error != nil {
+		return fmt.Errorf("unable to lock login %w", result.Error)
+	}
+	return nil
+}
+
+
+// ClearLoginThrottles removes the counters for good, a soft deleted row would still hold the unique key
+func (u *userRepository) ClearLoginThrottles (scope models.LoginThrottleScope, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	result := u.db.Unscoped().Where("scope = ? AND key IN ?", scope, keys).Delete(&models.LoginThrottle{})
+	if result.Error != nil {
+		return fmt.Errorf("unable to clear login throttles %w", result.Error)
+	}
+	return nil
+}
+
+
+func (u *userRepository) UpdateUserProfile (userId uint, changes map[string]interface{}) (*models.User, error) {
+	var user models.User
+	err := u.db.Transaction(func(tx *gorm.DB) error {
+		if len(changes) > 0 {
+			if err := tx.Model(&models.User{}).Where("id = ?", userId).Updates(changes).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("id = ?", userId).First(&user).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to update user profile %w", err)
+	}
+	return &user, nil
+}
+
+
+// DeleteUser anonymises the user's reports, drops their contacts, sessions and pending tokens,
+// then scrubs and soft deletes the account row
+func (u *userRepository) DeleteUser (userId uint) error {
+	err := u.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&reportModels.Report{}).
+			Where("reporter_id = ?", userId).
+			Updates(map[string]interface{}{"reporter_id": nil, "is_anonymous": true}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("requester_id = ? OR addressee_id = ?", userId, userId).
+			Delete(&contactModels.Contact{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", userId).Delete(&models.UserLocation{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", userId).Delete(&models.SavedPlace{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", userId).Delete(&reportModels.ReportFollower{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", userId).Delete(&feedModels.FeedEvent{}).Error; err != nil {
+			return err
+		}
+
+		// pending deliveries go with their notifications, nothing more reaches the account
+		if err := tx.Where("notification_id IN (?)", tx.Model(&notificationModels.Notification{}).Select("id").Where("user_id = ?", userId)).
+			Delete(&notificationModels.NotificationDelivery{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", userId).Delete(&notificationModels.Notification{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", userId).Delete(&notificationModels.NotificationPreference{}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("user_id = ?", userId).Delete(&notificationModels.Device{}).Error; err != nil {
+			return err
+		}
+
+		// a deleted account cannot check in, its armed timer must not alert anyone
+		if err := tx.Model(&timerModels.SafetyTimer{}).
+			Where("user_id = ? AND status = ?", userId, timerModels.SafetyTimerArmed).
+			Updates(map[string]interface{}{"status": timerModels.SafetyTimerDisarmed, "disarmed_at": time.Now()}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND revoked_at IS NULL", userId).
+			Update("revoked_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND used_at IS NULL", userId).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+			"email":             "",
+			"phone":             "",
+			"first_name":        "",
+			"last_name":         "",
+			"password":          "",
+			"email_verified_at": nil,
+			"phone_verified_at": nil,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		return tx.Delete(&models.User{}, userId).Error
+	})
+	if err != nil {
+		return fmt.Errorf("unable to delete user: %w", err)
+	}
+	return nil
+}
+
+
+func (u *userRepository) SaveUserLocation (location *models.UserLocation) error {
+	if err := u.db.Clauses(models.UpsertUserLocation).Create(location).Error; err != nil {
+		return fmt.Errorf("unable to save user location %w", err)
+	}
+	return nil
+}
+
+
+func (u *userRepository) DeleteUserLocation (userId uint) error {
+	if err := u.db.Where("user_id = ?", userId).Delete(&models.UserLocation{}).Error; err != nil {
+		return fmt.Errorf("unable to delete user location %w", err)
+	}
+	return nil
+}
+
+
+func (u *userRepository) CountSavedPlaces (userId uint) (int64, error) {
+	var count int64
+	if err := u.db.Model(&models.SavedPlace{}).Where("user_id = ?", userId).Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("unable to count saved places %w", err)
+	}
+	return count, nil
+}
+
+
+func (u *userRepository) CreateSavedPlace (place *models.SavedPlace) error {
+	if err := u.db.Create(place).Error; err != nil {
+		return fmt.Errorf("unable to create saved place %w", err)
+	}
+	return nil
+}
+
+
+func (u *userRepository) FindSavedPlaces (userId uint) ([]models.SavedPlace, error) {
+	var places []models.SavedPlace
+	result := u.db.Where("user_id = ?", userId).Order("created_at asc").Find(&places)
+	if result.Error != nil {
+		return nil, fmt.Errorf("unable to find saved places: %w", result.Error)
+	}
+	return places, nil
+}
+
+
+func (u *userRepository) DeleteSavedPlace (userId uint, placeId uint) error {
+	result := u.db.Where("id = ? AND user_id = ?", placeId, userId).Delete(&models.SavedPlace{})
+	if result.Error != nil {
+		return fmt.Errorf("unable to delete saved place %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("unable to delete saved place: %w", gorm.ErrRecordNotFound)
+	}
+	return nil
+}
@@ -1,14 +1,18 @@
 package user
 
 import (
+	"resq/internal/infra/jwtkeys"
+	"resq/internal/infra/mailer"
 	"resq/internal/infra/middleware"
+	"resq/internal/infra/sms"
+	"resq/pkg/constants"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 func UserRoutes(router *gin.Engine, db *gorm.DB) {
 	userRepository := NewUserRepository(db)
-	userService := NewUserService(userRepository)
+	userService := NewUserService(userRepository, mailer.GlobalMailer, sms.GlobalProvider, jwtkeys.GlobalKeyRing)
 	userController := NewUserController(userService)
 
 	users := router.Group("users")
@@ -16,10 +20,29 @@ func UserRoutes(router *gin.Engine, db *gorm.DB) {
 	{
 		users.POST("/create", userController.CreateUser)
 		users.POST("/login", userController.AuthorizeUser)
+		users.POST("/otp/send", userController.SendPhoneOTP)
+		users.POST("/otp/verify", userController.VerifyPhoneOTP)
+		users.POST("/token/refresh", userController.RefreshSession)
+		users.POST("/verify-email", userController.VerifyEmail)
+		users.POST("/password/forgot", userController.ForgotPassword)
+		users.POST("/password/reset", userController.ResetPassword)
 
 		users.Use(middleware.AuthMiddleware())
 		{
 			users.GET("/profile", userController.GetUserProfileInformation)
+			users.PATCH("/profile", userController.UpdateProfile)
+			users.DELETE("/profile", userController.DeleteAccount)
+			users.POST("/password/change", userController.ChangePassword)
+			users.PUT("/location", userController.UpdateLocation)
+			users.DELETE("/location", userController.ClearLocation)
+			users.GET("/places", userController.GetSavedPlaces)
+			users.POST("/places", userController.AddSavedPlace)
+			users.DELETE("/places/:placeId", userController.RemoveSavedPlace)
+			users.POST("/logout", userController.Logout)
+			users.POST("/logout-all", userController.LogoutAllDevices)
+			users.POST("/verify-email/resend", userController.ResendEmailVerification)
+			users.PATCH("/:id/role", middleware.RequirePermission(constants.PermissionUsersManage), userController.UpdateUserRole)
+			users.POST("/:id/unlock", middleware.RequirePermission(constants.PermissionUsersManage), userController.UnlockUser)
 		}
 	}
 }
@@ -1,27 +1,109 @@
 package user
 
 import (
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"resq/internal/infra/logger"
+	"resq/internal/infra/mailer"
+	"resq/internal/infra/sms"
 	"resq/pkg/constants"
 	"resq/pkg/dto"
 	"resq/pkg/models"
 	"resq/pkg/utils"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// RefreshTokenLifetime is how long a device stays signed in without being used
+	RefreshTokenLifetime      = 30 * 24 * time.Hour
+	EmailVerificationLifetime = 24 * time.Hour
+	PasswordResetLifetime     = time.Hour
+
+	PhoneOTPLifetime    = 5 * time.Minute
+	PhoneOTPDigits      = 6
+	PhoneOTPMaxAttempts = 5
+	// a number can ask for PhoneOTPMaxSends codes per PhoneOTPSendWindow, and for one code per PhoneOTPResendInterval
+	PhoneOTPMaxSends       = 3
+	PhoneOTPSendWindow     = 15 * time.Minute
+	PhoneOTPResendInterval = time.Minute
+
+	// failed logins past a threshold lock the account or address out, each further failure doubles the lockout
+	AccountLockoutThreshold = 5
+	IPLockoutThreshold      = 20
+	LoginFailureWindow      = 15 * time.Minute
+	LoginLockoutBase        = 30 * time.Second
+	LoginLockoutMax         = time.Hour
 )
 
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrInvalidUserToken    = errors.New("invalid or expired token")
+	ErrAlreadyVerified     = errors.New("email is already verified")
+	ErrInvalidPhoneOTP     = errors.New("invalid or expired code")
+	ErrTooManyOTPRequests  = errors.New("too many codes requested, try again later")
+	ErrTooManyOTPAttempts  = errors.New("too many attempts, request a new code")
+	ErrSMSUnavailable      = errors.New("unable to send text message")
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrLoginLocked         = errors.New("too many failed login attempts, try again later")
+	ErrEmailTaken          = errors.New("email is already in use")
+	ErrPhoneTaken          = errors.New("phone number is already in use")
+	ErrNoContactChannel    = errors.New("an account needs an email or a phone number")
+	ErrNoPassword          = errors.New("account has no password, sign in with a code instead")
+	ErrSavedPlaceNotFound  = errors.New("saved place not found")
+	ErrTooManySavedPlaces  = fmt.Errorf("you can save up to %d places", models.MaxSavedPlaces)
+)
+
+// LoginLockedError is returned while a login is locked out, it matches ErrLoginLocked
+type LoginLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *LoginLockedError) Error() string {
+	return ErrLoginLocked.Error()
+}
+
+func (e *LoginLockedError) Is(target error) bool {
+	return target == ErrLoginLocked
+}
+
 type UserService interface {
 	CreateUser(user *models.User) (*dto.UserDTO, error)
-	AuthorizeUser(login *dto.LoginRequestDTO) (string, error)
+	AuthorizeUser(login *dto.LoginRequestDTO, clientIP string) (*dto.TokenPairDTO, error)
+	UnlockUser(userId uint, actorId uint) error
+	UpdateProfile(userId uint, request *dto.UpdateProfileRequestDTO) (*dto.UserDTO, error)
+	ChangePassword(userId uint, request *dto.ChangePasswordRequestDTO) (*dto.TokenPairDTO, error)
+	DeleteAccount(userId uint, password string) error
+	UpdateLocation(userId uint, request *dto.UpdateLocationRequestDTO) error
+	ClearLocation(userId uint) error
+	GetSavedPlaces(userId uint) ([]dto.SavedPlaceDTO, error)
+	AddSavedPlace(userId uint, request *dto.SavedPlaceRequestDTO) (*dto.SavedPlaceDTO, error)
+	RemoveSavedPlace(userId uint, placeId uint) error
 	GetUserProfileInformation(userId uint) (*dto.UserDTO, error)
+	UpdateUserRole(userId uint, role string) (*dto.UserDTO, error)
+	RefreshSession(refreshToken string) (*dto.TokenPairDTO, error)
+	Logout(userId uint, jti string, accessExpiresAt time.Time, refreshToken string) error
+	LogoutAllDevices(userId uint) error
+	VerifyEmail(token string) error
+	ResendEmailVerification(userId uint) error
+	ForgotPassword(email string) error
+	ResetPassword(token string, password string) error
+	SendPhoneOTP(phone string) error
+	VerifyPhoneOTP(request *dto.VerifyPhoneOTPRequestDTO) (*dto.TokenPairDTO, error)
 }
 
 type userService struct {
 	repository UserRepository
+	mailer     mailer.Mailer
+	sms        sms.Provider
+	keys       utils.KeyResolver
 }
 
-func NewUserService(repo UserRepository) UserService {
-	return &userService{repository: repo}
+func NewUserService(repo UserRepository, m mailer.Mailer, smsProvider sms.Provider, keys utils.KeyResolver) UserService {
+	return &userService{repository: repo, mailer: m, sms: smsProvider, keys: keys}
 }
 
 func (u *userService) CreateUser(user *models.User) (*dto.UserDTO, error) {
@@ -38,6 +120,7 @@ func (u *userService) CreateUser(user *models.User) (*dto.UserDTO, error) {
 
 	user.Password = hashedPassword
 	user.Email = sanitizedEmail
+	user.Role = constants.RoleCitizen
 
 	result, err := u.repository.CreateUser(user)
 
@@ -46,31 +129,317 @@ func (u *userService) CreateUser(user *models.User) (*dto.UserDTO, error) {
 		return nil, errors.New(err.Error())
 	}
 
+	// the account exists either way, the user can ask for another email later
+	if err := u.sendEmailVerification(user); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to send verification email: %v", err))
+	}
+
 	return result, nil
 }
 
-func (u *userService) AuthorizeUser(login *dto.LoginRequestDTO) (string, error) {
+// AuthorizeUser checks the lockouts before the password, so a locked account or address never costs a hash
+func (u *userService) AuthorizeUser(login *dto.LoginRequestDTO, clientIP string) (*dto.TokenPairDTO, error) {
 	sanitizedEmail, err := utils.SanitizeEmail(login.Email)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	if err := u.checkLoginLocks(sanitizedEmail, clientIP); err != nil {
+		return nil, err
 	}
 
 	user, err := u.repository.FindUserByEmail(sanitizedEmail)
 	if err != nil {
-		return "", err
+		// an unknown email takes as long as a wrong password, response times must not tell which addresses have accounts
+		utils.VerifyPassword(login.Password, utils.DummyPasswordHash)
+		u.recordLoginFailure(sanitizedEmail, clientIP)
+		return nil, ErrInvalidCredentials
 	}
 
 	hasVerifiedPassword := utils.VerifyPassword(login.Password, user.Password)
 	if !hasVerifiedPassword {
-		return "", errors.New("invalid credentials")
+		u.recordLoginFailure(sanitizedEmail, clientIP)
+		return nil, ErrInvalidCredentials
 	}
 
-	token, err := utils.GenerateJWT(fmt.Sprint(user.ID), constants.JWTSecretKey)
+	u.upgradePasswordHash(user, login.Password)
+
+	// only the account counter is cleared, a valid login of its own must not reset an attacker's address
+	if err := u.repository.ClearLoginThrottles(models.LoginThrottleAccount, []string{sanitizedEmail}); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to clear login throttle: %v", err))
+	}
+
+	familyId, err := utils.GenerateRandomToken(16)
 	if err != nil {
-		return "", errors.New("authorization error")
+		return nil, errors.New("authorization error")
+	}
+
+	return u.issueTokenPair(user, familyId)
+}
+
+// upgradePasswordHash re-hashes a legacy or weaker hash while the plain password is at hand
+func (u *userService) upgradePasswordHash(user *models.User, password string) {
+	if !utils.PasswordNeedsRehash(user.Password) {
+		return
 	}
 
-	return token, nil
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to rehash password: %v", err))
+		return
+	}
+
+	if err := u.repository.UpdatePassword(user.ID, hashedPassword); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to store rehashed password: %v", err))
+		return
+	}
+	user.Password = hashedPassword
+}
+
+func (u *userService) checkLoginLocks(account string, clientIP string) error {
+	now := time.Now()
+	var retryAfter time.Duration
+
+	for scope, key := range map[models.LoginThrottleScope]string{
+		models.LoginThrottleAccount: account,
+		models.LoginThrottleIP:      clientIP,
+	} {
+		if key == "" {
+			continue
+		}
+		throttle, err := u.repository.FindLoginThrottle(scope, key)
+		if err != nil || !throttle.IsLocked(now) {
+			continue
+		}
+		if remaining := throttle.LockedUntil.Sub(now); remaining > retryAfter {
+			retryAfter = remaining
+		}
+	}
+
+	if retryAfter > 0 {
+		return &LoginLockedError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+func (u *userService) recordLoginFailure(account string, clientIP string) {
+	u.countLoginFailure(models.LoginThrottleAccount, account, AccountLockoutThreshold)
+	if clientIP != "" {
+		u.countLoginFailure(models.LoginThrottleIP, clientIP, IPLockoutThreshold)
+	}
+}
+
+func (u *userService) countLoginFailure(scope models.LoginThrottleScope, key string, threshold int) {
+	throttle, err := u.repository.RecordLoginFailure(scope, key, time.Now().Add(-LoginFailureWindow))
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to record login failure: %v", err))
+		return
+	}
+	if throttle.Failures < threshold {
+		return
+	}
+
+	lockout := loginLockoutDuration(throttle.Failures - threshold)
+	if err := u.repository.LockLogin(throttle.ID, time.Now().Add(lockout)); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to lock login: %v", err))
+		return
+	}
+
+	logger.GlobalLogger.Log(logger.INFO, "Login locked out after repeated failures", map[string]interface{}{
+		"scope":    scope,
+		"key":      key,
+		"failures": throttle.Failures,
+		"lockout":  lockout.String(),
+	})
+}
+
+func loginLockoutDuration(excessFailures int) time.Duration {
+	lockout := LoginLockoutBase
+	for i := 0; i < excessFailures && lockout < LoginLockoutMax; i++ {
+		lockout *= 2
+	}
+	if lockout > LoginLockoutMax {
+		lockout = LoginLockoutMax
+	}
+	return lockout
+}
+
+// UnlockUser clears the failed login counters of every identifier the account signs in with
+func (u *userService) UnlockUser(userId uint, actorId uint) error {
+	user, err := u.repository.FindUserById(userId)
+	if err != nil {
+		return err
+	}
+
+	var keys []string
+	for _, key := range []string{user.Email, user.Phone} {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+
+	if err := u.repository.ClearLoginThrottles(models.LoginThrottleAccount, keys); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to unlock user: %v", err))
+		return errors.New("unable to unlock user")
+	}
+
+	logger.GlobalLogger.Log(logger.INFO, "User login unlocked", map[string]interface{}{
+		"user_id":  userId,
+		"actor_id": actorId,
+	})
+	return nil
+}
+
+// issueTokenPair signs a new access token and stores the next refresh token of the family
+func (u *userService) issueTokenPair(user *models.User, familyId string) (*dto.TokenPairDTO, error) {
+	accessToken, refreshToken, err := u.newTokenPair(user, familyId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.repository.CreateRefreshToken(refreshToken.model); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to store refresh token: %v", err))
+		return nil, errors.New("authorization error")
+	}
+
+	return tokenPairDTO(accessToken, refreshToken.raw), nil
+}
+
+type issuedRefreshToken struct {
+	raw   string
+	model *models.RefreshToken
+}
+
+func (u *userService) newTokenPair(user *models.User, familyId string) (string, *issuedRefreshToken, error) {
+	accessToken, err := utils.GenerateJWT(fmt.Sprint(user.ID), string(user.Role), u.keys)
+	if err != nil {
+		return "", nil, errors.New("authorization error")
+	}
+
+	rawRefreshToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", nil, errors.New("authorization error")
+	}
+
+	return accessToken, &issuedRefreshToken{
+		raw: rawRefreshToken,
+		model: &models.RefreshToken{
+			UserID:    user.ID,
+			TokenHash: utils.HashToken(rawRefreshToken),
+			FamilyID:  familyId,
+			ExpiresAt: time.Now().Add(RefreshTokenLifetime),
+		},
+	}, nil
+}
+
+func tokenPairDTO(accessToken string, refreshToken string) *dto.TokenPairDTO {
+	return &dto.TokenPairDTO{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(utils.AccessTokenLifetime.Seconds()),
+	}
+}
+
+// RefreshSession trades a refresh token for a new pair. Presenting a token that was already
+// rotated means it leaked, so the whole family is revoked and that device has to sign in again.
+func (u *userService) RefreshSession(refreshToken string) (*dto.TokenPairDTO, error) {
+	current, err := u.repository.FindRefreshTokenByHash(utils.HashToken(refreshToken))
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if current.RevokedAt != nil {
+		u.revokeReusedFamily(current)
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if time.Now().After(current.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	user, err := u.repository.FindUserById(current.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	accessToken, next, err := u.newTokenPair(user, current.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.repository.RotateRefreshToken(current, next.model); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			u.revokeReusedFamily(current)
+			return nil, ErrInvalidRefreshToken
+		}
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to rotate refresh token: %v", err))
+		return nil, errors.New("authorization error")
+	}
+
+	return tokenPairDTO(accessToken, next.raw), nil
+}
+
+func (u *userService) revokeReusedFamily(token *models.RefreshToken) {
+	logger.GlobalLogger.Log(logger.INFO, "Refresh token reuse detected, revoking token family", map[string]interface{}{
+		"user_id":   token.UserID,
+		"family_id": token.FamilyID,
+	})
+
+	if err := u.repository.RevokeRefreshTokenFamily(token.FamilyID); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to revoke refresh token family: %v", err))
+	}
+}
+
+// Logout denies the access token in use and, when given, the refresh token of the same device
+func (u *userService) Logout(userId uint, jti string, accessExpiresAt time.Time, refreshToken string) error {
+	if err := u.repository.CreateRevokedToken(&models.RevokedToken{
+		JTI:       jti,
+		UserID:    userId,
+		ExpiresAt: accessExpiresAt,
+	}); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to revoke access token: %v", err))
+		return errors.New("unable to log out")
+	}
+
+	if refreshToken == "" {
+		return nil
+	}
+
+	token, err := u.repository.FindRefreshTokenByHash(utils.HashToken(refreshToken))
+	if err != nil || token.UserID != userId {
+		return nil
+	}
+
+	if err := u.repository.RevokeRefreshToken(token.ID); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to revoke refresh token: %v", err))
+		return errors.New("unable to log out")
+	}
+	return nil
+}
+
+// LogoutAllDevices revokes every refresh token and every access token issued until now
+func (u *userService) LogoutAllDevices(userId uint) error {
+	if err := u.repository.RevokeUserRefreshTokens(userId); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to revoke refresh tokens: %v", err))
+		return errors.New("unable to log out")
+	}
+
+	// token issue times have second precision, tokens from the current second stay valid
+	issuedBefore := time.Now().Truncate(time.Second)
+	if err := u.repository.CreateRevokedToken(&models.RevokedToken{
+		UserID:       userId,
+		IssuedBefore: &issuedBefore,
+		ExpiresAt:    issuedBefore.Add(utils.AccessTokenLifetime),
+	}); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to revoke access tokens: %v", err))
+		return errors.New("unable to log out")
+	}
+
+	logger.GlobalLogger.Log(logger.INFO, "User logged out of all devices", map[string]interface{}{
+		"user_id": userId,
+	})
+	return nil
 }
 
 func (u *userService) GetUserProfileInformation(userId uint) (*dto.UserDTO, error) {
@@ -82,3 +451,489 @@ func (u *userService) GetUserProfileInformation(userId uint) (*dto.UserDTO, erro
 
 	return result, nil
 }
+
+func (u *userService) UpdateUserRole(userId uint, role string) (*dto.UserDTO, error) {
+	newRole := constants.Role(role)
+	if !newRole.IsValid() {
+		return nil, errors.New("invalid role")
+	}
+
+	result, err := u.repository.UpdateUserRole(userId, newRole)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.GlobalLogger.Log(logger.INFO, "User role changed", map[string]interface{}{
+		"user_id": userId,
+		"role":    newRole,
+	})
+
+	return result, nil
+}
+
+// issueUserToken replaces any live token of the same purpose and returns the raw value to send out
+func (u *userService) issueUserToken(userId uint, purpose models.UserTokenPurpose, lifetime time.Duration) (string, error) {
+	if err := u.repository.InvalidateUserTokens(userId, purpose); err != nil {
+		return "", err
+	}
+
+	rawToken, err := utils.GenerateRandomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	if err := u.repository.CreateUserToken(&models.UserToken{
+		UserID:    userId,
+		Purpose:   purpose,
+		TokenHash: utils.HashToken(rawToken),
+		ExpiresAt: time.Now().Add(lifetime),
+	}); err != nil {
+		return "", err
+	}
+
+	return rawToken, nil
+}
+
+func (u *userService) sendEmailVerification(user *models.User) error {
+	token, err := u.issueUserToken(user.ID, models.UserTokenEmailVerification, EmailVerificationLifetime)
+	if err != nil {
+		return err
+	}
+	return u.mailer.Send(verificationEmail(user.Email, user.FirstName, token, EmailVerificationLifetime))
+}
+
+func (u *userService) VerifyEmail(token string) error {
+	userToken, err := u.repository.ConsumeUserToken(utils.HashToken(token), models.UserTokenEmailVerification)
+	if err != nil {
+		return ErrInvalidUserToken
+	}
+
+	if err := u.repository.MarkEmailVerified(userToken.UserID); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to verify email: %v", err))
+		return errors.New("unable to verify email")
+	}
+	return nil
+}
+
+func (u *userService) ResendEmailVerification(userId uint) error {
+	user, err := u.repository.FindUserById(userId)
+	if err != nil {
+		return err
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return ErrAlreadyVerified
+	}
+
+	if err := u.sendEmailVerification(user); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to send verification email: %v", err))
+		return errors.New("unable to send verification email")
+	}
+	return nil
+}
+
+// ForgotPassword never tells the caller whether the email belongs to an account
+func (u *userService) ForgotPassword(email string) error {
+	sanitizedEmail, err := utils.SanitizeEmail(email)
+	if err != nil {
+		return err
+	}
+
+	user, err := u.repository.FindUserByEmail(sanitizedEmail)
+	if err != nil {
+		return nil
+	}
+
+	token, err := u.issueUserToken(user.ID, models.UserTokenPasswordReset, PasswordResetLifetime)
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to issue password reset token: %v", err))
+		return nil
+	}
+
+	if err := u.mailer.Send(passwordResetEmail(user.Email, user.FirstName, token, PasswordResetLifetime)); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to send password reset email: %v", err))
+	}
+	return nil
+}
+
+// ResetPassword sets the new password and signs the user out everywhere, whoever triggered the reset may hold a session
+func (u *userService) ResetPassword(token string, password string) error {
+	hashedPassword, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	userToken, err := u.repository.ConsumeUserToken(utils.HashToken(token), models.UserTokenPasswordReset)
+	if err != nil {
+		return ErrInvalidUserToken
+	}
+
+	if err := u.repository.UpdatePassword(userToken.UserID, hashedPassword); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to reset password: %v", err))
+		return errors.New("unable to reset password")
+	}
+
+	// resetting through the emailed link proves the address as well
+	if err := u.repository.MarkEmailVerified(userToken.UserID); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to verify email: %v", err))
+	}
+
+	return u.LogoutAllDevices(userToken.UserID)
+}
+
+func phoneOTPHash(phone string, code string) string {
+	return utils.HashToken(phone + ":" + code)
+}
+
+// SendPhoneOTP texts a fresh code to the number, whether or not an account uses it yet
+func (u *userService) SendPhoneOTP(phone string) error {
+	normalizedPhone, err := utils.NormalizePhone(phone)
+	if err != nil {
+		return err
+	}
+
+	sent, err := u.repository.CountPhoneOTPsSince(normalizedPhone, time.Now().Add(-PhoneOTPSendWindow))
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to count phone otps: %v", err))
+		return ErrSMSUnavailable
+	}
+	if sent >= PhoneOTPMaxSends {
+		return ErrTooManyOTPRequests
+	}
+
+	if latest, err := u.repository.FindLatestPhoneOTP(normalizedPhone); err == nil && time.Since(latest.CreatedAt) < PhoneOTPResendInterval {
+		return ErrTooManyOTPRequests
+	}
+
+	code, err := utils.GenerateNumericCode(PhoneOTPDigits)
+	if err != nil {
+		return ErrSMSUnavailable
+	}
+
+	if err := u.repository.CreatePhoneOTP(&models.PhoneOTP{
+		Phone:     normalizedPhone,
+		CodeHash:  phoneOTPHash(normalizedPhone, code),
+		ExpiresAt: time.Now().Add(PhoneOTPLifetime),
+	}); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to store phone otp: %v", err))
+		return ErrSMSUnavailable
+	}
+
+	body := fmt.Sprintf("Your ResQ code is %s. It expires in %d minutes, do not share it with anyone.", code, int(PhoneOTPLifetime.Minutes()))
+	if err := u.sms.Send(normalizedPhone, body); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to send phone otp: %v", err))
+		return ErrSMSUnavailable
+	}
+	return nil
+}
+
+// VerifyPhoneOTP checks the code and signs in the owner of the number, creating a citizen account on first use
+func (u *userService) VerifyPhoneOTP(request *dto.VerifyPhoneOTPRequestDTO) (*dto.TokenPairDTO, error) {
+	normalizedPhone, err := utils.NormalizePhone(request.Phone)
+	if err != nil {
+		return nil, err
+	}
+
+	otp, err := u.repository.FindLatestPhoneOTP(normalizedPhone)
+	if err != nil || otp.ConsumedAt != nil || time.Now().After(otp.ExpiresAt) {
+		return nil, ErrInvalidPhoneOTP
+	}
+
+	// the guess is counted before it is checked so parallel requests cannot get past the limit
+	allowed, err := u.repository.RecordPhoneOTPAttempt(otp.ID, PhoneOTPMaxAttempts)
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to record phone otp attempt: %v", err))
+		return nil, errors.New("authorization error")
+	}
+	if !allowed {
+		return nil, ErrTooManyOTPAttempts
+	}
+
+	if subtle.ConstantTimeCompare([]byte(phoneOTPHash(normalizedPhone, request.Code)), []byte(otp.CodeHash)) != 1 {
+		return nil, ErrInvalidPhoneOTP
+	}
+
+	if err := u.repository.ConsumePhoneOTP(otp.ID); err != nil {
+		return nil, ErrInvalidPhoneOTP
+	}
+
+	user, err := u.findOrCreatePhoneUser(normalizedPhone, request)
+	if err != nil {
+		return nil, err
+	}
+
+	familyId, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, errors.New("authorization error")
+	}
+
+	return u.issueTokenPair(user, familyId)
+}
+
+func (u *userService) findOrCreatePhoneUser(phone string, request *dto.VerifyPhoneOTPRequestDTO) (*models.User, error) {
+	user, err := u.repository.FindUserByPhone(phone)
+	if err == nil {
+		if user.PhoneVerifiedAt == nil {
+			if err := u.repository.MarkPhoneVerified(user.ID); err != nil {
+				logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to verify phone: %v", err))
+			}
+		}
+		return user, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to find user by phone: %v", err))
+		return nil, errors.New("authorization error")
+	}
+
+	verifiedAt := time.Now()
+	user = &models.User{
+		Phone:           phone,
+		FirstName:       request.FirstName,
+		LastName:        request.LastName,
+		Role:            constants.RoleCitizen,
+		PhoneVerifiedAt: &verifiedAt,
+	}
+	if _, err := u.repository.CreateUser(user); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to create user: %v", err))
+		return nil, errors.New("authorization error")
+	}
+
+	logger.GlobalLogger.Log(logger.INFO, "User registered with phone number", map[string]interface{}{
+		"user_id": user.ID,
+	})
+	return user, nil
+}
+
+// UpdateProfile applies the given fields, a changed email or phone has to be verified again
+func (u *userService) UpdateProfile(userId uint, request *dto.UpdateProfileRequestDTO) (*dto.UserDTO, error) {
+	user, err := u.repository.FindUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]interface{}{}
+
+	for column, value := range map[string]*string{"first_name": request.FirstName, "last_name": request.LastName} {
+		if value == nil {
+			continue
+		}
+		name := strings.TrimSpace(*value)
+		if name == "" {
+			return nil, fmt.Errorf("%s cannot be empty", strings.ReplaceAll(column, "_", " "))
+		}
+		changes[column] = name
+	}
+
+	email, phone := user.Email, user.Phone
+
+	if request.Email != nil {
+		if email, err = u.profileEmail(user, *request.Email); err != nil {
+			return nil, err
+		}
+		if email != user.Email {
+			changes["email"] = email
+			changes["email_verified_at"] = nil
+		}
+	}
+
+	if request.Phone != nil {
+		if phone, err = u.profilePhone(user, *request.Phone); err != nil {
+			return nil, err
+		}
+		if phone != user.Phone {
+			changes["phone"] = phone
+			changes["phone_verified_at"] = nil
+		}
+	}
+
+	if email == "" && phone == "" {
+		return nil, ErrNoContactChannel
+	}
+
+	updated, err := u.repository.UpdateUserProfile(userId, changes)
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to update profile: %v", err))
+		return nil, errors.New("unable to update profile")
+	}
+
+	if _, changed := changes["email"]; changed && updated.Email != "" {
+		if err := u.sendEmailVerification(updated); err != nil {
+			logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to send verification email: %v", err))
+		}
+	}
+
+	return updated.ToDTO(), nil
+}
+
+func (u *userService) profileEmail(user *models.User, email string) (string, error) {
+	if strings.TrimSpace(email) == "" {
+		return "", nil
+	}
+
+	sanitizedEmail, err := utils.SanitizeEmail(strings.TrimSpace(email))
+	if err != nil {
+		return "", err
+	}
+
+	if existing, err := u.repository.FindUserByEmail(sanitizedEmail); err == nil && existing.ID != user.ID {
+		return "", ErrEmailTaken
+	}
+	return sanitizedEmail, nil
+}
+
+func (u *userService) profilePhone(user *models.User, phone string) (string, error) {
+	if strings.TrimSpace(phone) == "" {
+		return "", nil
+	}
+
+	normalizedPhone, err := utils.NormalizePhone(phone)
+	if err != nil {
+		return "", err
+	}
+
+	if existing, err := u.repository.FindUserByPhone(normalizedPhone); err == nil && existing.ID != user.ID {
+		return "", ErrPhoneTaken
+	}
+	return normalizedPhone, nil
+}
+
+// ChangePassword re-checks the current password, signs every other device out and returns a fresh pair for this one
+func (u *userService) ChangePassword(userId uint, request *dto.ChangePasswordRequestDTO) (*dto.TokenPairDTO, error) {
+	user, err := u.repository.FindUserById(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Password == "" {
+		return nil, ErrNoPassword
+	}
+
+	if !utils.VerifyPassword(request.CurrentPassword, user.Password) {
+		return nil, ErrInvalidCredentials
+	}
+
+	hashedPassword, err := utils.HashPassword(request.NewPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := u.repository.UpdatePassword(userId, hashedPassword); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to change password: %v", err))
+		return nil, errors.New("unable to change password")
+	}
+
+	if err := u.LogoutAllDevices(userId); err != nil {
+		return nil, err
+	}
+
+	// tokens issued in the same second as the revocation stay valid, so the new pair is not caught by it
+	familyId, err := utils.GenerateRandomToken(16)
+	if err != nil {
+		return nil, errors.New("authorization error")
+	}
+	return u.issueTokenPair(user, familyId)
+}
+
+// DeleteAccount asks for the password of accounts that have one, the reports the user filed stay but lose their reporter
+func (u *userService) DeleteAccount(userId uint, password string) error {
+	user, err := u.repository.FindUserById(userId)
+	if err != nil {
+		return err
+	}
+
+	if user.Password != "" && !utils.VerifyPassword(password, user.Password) {
+		return ErrInvalidCredentials
+	}
+
+	if err := u.repository.DeleteUser(userId); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to delete account: %v", err))
+		return errors.New("unable to delete account")
+	}
+
+	if err := u.LogoutAllDevices(userId); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to revoke tokens of deleted account: %v", err))
+	}
+
+	logger.GlobalLogger.Log(logger.INFO, "User deleted their account", map[string]interface{}{
+		"user_id": userId,
+	})
+	return nil
+}
+
+// UpdateLocation keeps the user's last known location, a fix from the future is taken as now
+func (u *userService) UpdateLocation(userId uint, request *dto.UpdateLocationRequestDTO) error {
+	now := time.Now()
+	recordedAt := now
+	if request.RecordedAt != nil && request.RecordedAt.Before(now) {
+		recordedAt = *request.RecordedAt
+	}
+
+	err := u.repository.SaveUserLocation(&models.UserLocation{
+		UserID:         userId,
+		Latitude:       *request.Latitude,
+		Longitude:      *request.Longitude,
+		AccuracyRadius: request.AccuracyRadius,
+		RecordedAt:     recordedAt,
+	})
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to save user location: %v", err))
+		return errors.New("unable to save location")
+	}
+	return nil
+}
+
+// ClearLocation forgets where the user was, they drop out of area check-ins until they share it again
+func (u *userService) ClearLocation(userId uint) error {
+	if err := u.repository.DeleteUserLocation(userId); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to delete user location: %v", err))
+		return errors.New("unable to clear location")
+	}
+	return nil
+}
+
+func (u *userService) GetSavedPlaces(userId uint) ([]dto.SavedPlaceDTO, error) {
+	places, err := u.repository.FindSavedPlaces(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]dto.SavedPlaceDTO, len(places))
+	for i := range places {
+		result[i] = *places[i].ToDTO()
+	}
+	return result, nil
+}
+
+// AddSavedPlace keeps alerts coming for a place, like home, wherever the user is
+func (u *userService) AddSavedPlace(userId uint, request *dto.SavedPlaceRequestDTO) (*dto.SavedPlaceDTO, error) {
+	count, err := u.repository.CountSavedPlaces(userId)
+	if err != nil {
+		return nil, err
+	}
+	if count >= models.MaxSavedPlaces {
+		return nil, ErrTooManySavedPlaces
+	}
+
+	place := &models.SavedPlace{
+		UserID:    userId,
+		Name:      request.Name,
+		Latitude:  *request.Latitude,
+		Longitude: *request.Longitude,
+	}
+	if err := u.repository.CreateSavedPlace(place); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to create saved place: %v", err))
+		return nil, errors.New("unable to save place")
+	}
+	return place.ToDTO(), nil
+}
+
+func (u *userService) RemoveSavedPlace(userId uint, placeId uint) error {
+	if err := u.repository.DeleteSavedPlace(userId, placeId); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSavedPlaceNotFound
+		}
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to delete saved place: %v", err))
+		return errors.New("unable to remove place")
+	}
+	return nil
+}
@@ -1 +1,78 @@
 package infra
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// DeadLetterSuffix names the topic messages go to once a consumer group gave up on them
+const DeadLetterSuffix = ".dead"
+
+// Header keys set on dead letters
+const (
+	HeaderDeadLetterGroup = "dead-letter-group"
+	HeaderDeadLetterError = "dead-letter-error"
+	HeaderOriginalID      = "original-id"
+)
+
+// Handler processes one message. Delivery is at least once, so handlers have to cope with seeing a message again,
+// an error makes the bus retry the message later.
+type Handler func(ctx context.Context, message Message) error
+
+// RetryPolicy is how often and how patiently a failing message is retried before it becomes a dead letter
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    5,
+	InitialBackoff: time.Second,
+	MaxBackoff:     5 * time.Minute,
+}
+
+// Backoff is how long to wait after the given failed attempt, doubling from InitialBackoff up to MaxBackoff
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := float64(p.InitialBackoff) * math.Pow(2, float64(attempt-1))
+	if backoff > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+	return time.Duration(backoff)
+}
+
+// Consumer delivers the messages of a topic to subscribed consumer groups. Every group gets every message,
+// handlers subscribed under the same group share its messages.
+type Consumer interface {
+	// Subscribe registers handler for topic under group, a subscription made after Start begins right away
+	Subscribe(topic string, group string, handler Handler) error
+	// Start delivers messages until ctx is done
+	Start(ctx context.Context)
+	// Wait blocks until every handler returned after the context given to Start is done
+	Wait()
+}
+
+// DeadLetterTopic is where the messages of topic go once a consumer group gave up on them
+func DeadLetterTopic(topic string) string {
+	return topic + DeadLetterSuffix
+}
+
+// deadLetter copies message onto its dead letter topic, noting which group failed it and why
+func deadLetter(message Message, group string, err error) Message {
+	headers := map[string]string{}
+	for key, value := range message.Headers {
+		headers[key] = value
+	}
+	headers[HeaderDeadLetterGroup] = group
+	headers[HeaderDeadLetterError] = err.Error()
+	headers[HeaderOriginalID] = message.ID
+
+	return Message{
+		Topic:     DeadLetterTopic(message.Topic),
+		Key:       message.Key,
+		Payload:   message.Payload,
+		Headers:   headers,
+		CreatedAt: time.Now(),
+	}
+}
@@ -0,0 +1,166 @@
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidCron = errors.New("invalid cron expression")
+
+// cronDescriptors are the shorthands accepted in place of the five fields
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Cron is a parsed five field cron expression: minute, hour, day of month, month and day of week.
+// Times are matched in UTC.
+type Cron struct {
+	minute, hour, dom, month, dow uint64
+	// like cron, a restricted day of month and day of week match when either does
+	domAny, dowAny bool
+}
+
+type cronField struct {
+	min, max int
+}
+
+var (
+	minuteField = cronField{0, 59}
+	hourField   = cronField{0, 23}
+	domField    = cronField{1, 31}
+	monthField  = cronField{1, 12}
+	// 7 is sunday as well as 0
+	dowField = cronField{0, 7}
+)
+
+// ParseCron reads an expression like "*/15 * * * *", "0 3 * * 1-5" or "@daily"
+func ParseCron(spec string) (*Cron, error) {
+	spec = strings.TrimSpace(spec)
+	if expanded, ok := cronDescriptors[spec]; ok {
+		spec = expanded
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: %q needs 5 fields", ErrInvalidCron, spec)
+	}
+
+	cron := &Cron{domAny: fields[2] == "*", dowAny: fields[4] == "*"}
+	targets := []*uint64{&cron.minute, &cron.hour, &cron.dom, &cron.month, &cron.dow}
+	for i, field := range []cronField{minuteField, hourField, domField, monthField, dowField} {
+		bits, err := field.parse(fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q %v", ErrInvalidCron, spec, err)
+		}
+		*targets[i] = bits
+	}
+
+	if cron.dow&(1<<7) != 0 {
+		cron.dow |= 1
+	}
+	return cron, nil
+}
+
+// parse turns a comma separated list of values, ranges and steps into a bit per matching value
+func (f cronField) parse(expression string) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(expression, ",") {
+		rangePart, step := part, 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[i+1:])
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			rangePart = part[:i]
+		}
+
+		low, high := f.min, f.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if low, err = f.value(bounds[0]); err != nil {
+				return 0, err
+			}
+			if high, err = f.value(bounds[1]); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf("range %q runs backwards", rangePart)
+			}
+		default:
+			value, err := f.value(rangePart)
+			if err != nil {
+				return 0, err
+			}
+			low = value
+			// "5/10" means every 10th value from 5 on, a plain "5" just 5
+			if step == 1 {
+				high = value
+			}
+		}
+
+		for value := low; value <= high; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+func (f cronField) value(text string) (int, error) {
+	value, err := strconv.Atoi(text)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a number", text)
+	}
+	if value < f.min || value > f.max {
+		return 0, fmt.Errorf("%d is outside %d-%d", value, f.min, f.max)
+	}
+	return value, nil
+}
+
+// Next is the first matching minute after t, or the zero time when nothing matches within five years
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, time.UTC)
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (c *Cron) dayMatches(t time.Time) bool {
+	domMatch := c.dom&(1<<uint(t.Day())) != 0
+	dowMatch := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
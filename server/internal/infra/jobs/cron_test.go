@@ -0,0 +1,143 @@
+package jobs
+
+import (
+	"errors"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		spec  string
+		valid bool
+	}{
+		{"* * * * *", true},
+		{"*/15 * * * *", true},
+		{"0 3 * * 1-5", true},
+		{"0,30 8-18/2 1,15 * *", true},
+		{"0 0 * * 7", true},
+		{"@daily", true},
+		{" @hourly ", true},
+		{"", false},
+		{"* * * *", false},
+		{"* * * * * *", false},
+		{"60 * * * *", false},
+		{"* 24 * * *", false},
+		{"* * 0 * *", false},
+		{"* * * 13 *", false},
+		{"* * * * 8", false},
+		{"5-1 * * * *", false},
+		{"*/0 * * * *", false},
+		{"a * * * *", false},
+		{"@sometimes", false},
+	}
+
+	for _, test := range tests {
+		_, err := ParseCron(test.spec)
+		if test.valid && err != nil {
+			t.Errorf("ParseCron(%q) failed: %v", test.spec, err)
+		}
+		if !test.valid && !errors.Is(err, ErrInvalidCron) {
+			t.Errorf("ParseCron(%q) = %v, want ErrInvalidCron", test.spec, err)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour, minute int) time.Time {
+		return time.Date(year, month, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"step", "*/15 * * * *", utc(2024, 1, 1, 10, 7).Add(30 * time.Second), utc(2024, 1, 1, 10, 15)},
+		{"strictly after", "*/15 * * * *", utc(2024, 1, 1, 10, 15), utc(2024, 1, 1, 10, 30)},
+		{"step from value", "5/10 * * * *", utc(2024, 1, 1, 10, 6), utc(2024, 1, 1, 10, 15)},
+		{"hour rolls over", "0 * * * *", utc(2024, 1, 1, 23, 30), utc(2024, 1, 2, 0, 0)},
+		{"weekdays skip the weekend", "0 3 * * 1-5", utc(2024, 1, 5, 4, 0), utc(2024, 1, 8, 3, 0)},
+		{"leap day", "@daily", utc(2024, 2, 28, 23, 59), utc(2024, 2, 29, 0, 0)},
+		{"year rolls over", "@yearly", utc(2024, 12, 31, 23, 59), utc(2025, 1, 1, 0, 0)},
+		{"short months are skipped", "0 0 31 * *", utc(2024, 4, 1, 0, 0), utc(2024, 5, 31, 0, 0)},
+		{"next leap year", "0 0 29 2 *", utc(2025, 1, 1, 0, 0), utc(2028, 2, 29, 0, 0)},
+		{"day of month alone", "0 0 10 * *", utc(2024, 9, 7, 12, 0), utc(2024, 9, 10, 0, 0)},
+		{"day of week alone", "0 0 * * 5", utc(2024, 9, 7, 12, 0), utc(2024, 9, 13, 0, 0)},
+		{"7 is sunday", "0 0 * * 7", utc(2024, 9, 7, 12, 0), utc(2024, 9, 8, 0, 0)},
+		// with both restricted either one matching is enough, the 10th comes before the next friday
+		{"day of month or day of week", "0 0 10 * 5", utc(2024, 9, 7, 12, 0), utc(2024, 9, 10, 0, 0)},
+		{"day of week or day of month", "0 0 10 * 5", utc(2024, 9, 10, 12, 0), utc(2024, 9, 13, 0, 0)},
+		{"never", "0 0 30 2 *", utc(2024, 1, 1, 0, 0), time.Time{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cron, err := ParseCron(test.spec)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) failed: %v", test.spec, err)
+			}
+			if got := cron.Next(test.from); !got.Equal(test.want) {
+				t.Errorf("Next(%v) = %v, want %v", test.from, got, test.want)
+			}
+		})
+	}
+}
+
+// schedules run in UTC, so a local clock jumping for daylight saving time neither skips nor repeats a run
+func TestCronNextAcrossDST(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("unable to load time zone: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want []time.Time
+	}{
+		{
+			// 02:30 local does not exist on 2024-03-10, 07:30 UTC is 03:30 EDT
+			name: "spring forward",
+			spec: "30 7 * * *",
+			from: time.Date(2024, 3, 10, 1, 0, 0, 0, newYork),
+			want: []time.Time{
+				time.Date(2024, 3, 10, 7, 30, 0, 0, time.UTC),
+				time.Date(2024, 3, 11, 7, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			// 01:30 local happens twice on 2024-11-03, both are runs an hour apart
+			name: "fall back",
+			spec: "30 * * * *",
+			from: time.Date(2024, 11, 3, 0, 0, 0, 0, newYork),
+			want: []time.Time{
+				time.Date(2024, 11, 3, 4, 30, 0, 0, time.UTC),
+				time.Date(2024, 11, 3, 5, 30, 0, 0, time.UTC),
+				time.Date(2024, 11, 3, 6, 30, 0, 0, time.UTC),
+				time.Date(2024, 11, 3, 7, 30, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cron, err := ParseCron(test.spec)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) failed: %v", test.spec, err)
+			}
+
+			from := test.from
+			for _, want := range test.want {
+				got := cron.Next(from)
+				if !got.Equal(want) {
+					t.Fatalf("Next(%v) = %v, want %v", from, got, want)
+				}
+				from = got
+			}
+		})
+	}
+}
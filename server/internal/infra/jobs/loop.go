@@ -0,0 +1,65 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"resq/internal/infra/logger"
+	"time"
+)
+
+// loop is recurring work that runs more often than a schedule can express
+type loop struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+// Every calls run every interval until the context given to Start is done, the first call comes right away.
+// Unlike scheduled jobs a loop runs on every instance, work that must not overlap takes a lock of its own
+// the way the outbox relay does. A loop added after Start begins right away.
+func (r *Runner) Every(name string, interval time.Duration, run func(ctx context.Context) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l := loop{name: name, interval: interval, run: run}
+	r.loops = append(r.loops, l)
+	if r.ctx != nil {
+		r.launchLoop(l)
+	}
+}
+
+func (r *Runner) launchLoop(l loop) {
+	r.running.Add(1)
+	go func(ctx context.Context) {
+		defer r.running.Done()
+
+		ticker := time.NewTicker(l.interval)
+		defer ticker.Stop()
+
+		for {
+			runLoop(ctx, l)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}(r.ctx)
+}
+
+// runLoop keeps a failing or panicking loop from stopping with it
+func runLoop(ctx context.Context, l loop) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("loop panicked: %v", recovered), map[string]interface{}{
+				"loop": l.name,
+			})
+		}
+	}()
+
+	if err := l.run(ctx); err != nil && ctx.Err() == nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("loop failed: %v", err), map[string]interface{}{
+			"loop": l.name,
+		})
+	}
+}
@@ -0,0 +1,369 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"resq/internal/infra"
+	"resq/internal/infra/logger"
+	jobModels "resq/pkg/models/job"
+	"resq/pkg/utils"
+	"strconv"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// pollInterval is how long an idle worker waits before looking for due jobs again
+	pollInterval = time.Second
+	// leaseGrace is how much longer than its timeout a running job stays with its instance
+	leaseGrace = time.Minute
+	// Retention is how long finished jobs are kept, dead ones stay for DeadRetention
+	Retention     = 7 * 24 * time.Hour
+	DeadRetention = 30 * 24 * time.Hour
+)
+
+// claimJobsSQL leases the next due jobs of a kind, running jobs whose lease ran out are taken over
+const claimJobsSQL = `UPDATE jobs SET status = ?, locked_by = ?, locked_until = ?, attempts = attempts + 1, updated_at = ?
+WHERE id IN (
+	SELECT id FROM jobs
+	WHERE kind = ? AND run_at <= ? AND (status = ? OR (status = ? AND locked_until < ?))
+	ORDER BY run_at, id
+	LIMIT ?
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING id`
+
+// Job is what a handler gets to work on
+type Job struct {
+	ID      uint
+	Kind    string
+	Payload json.RawMessage
+	// Attempt counts runs of this job, the first one is 1
+	Attempt int
+	RunAt   time.Time
+}
+
+// Decode unmarshals the payload into target
+func (j *Job) Decode(target interface{}) error {
+	if err := json.Unmarshal(j.Payload, target); err != nil {
+		return fmt.Errorf("unable to decode %s job %d: %w", j.Kind, j.ID, err)
+	}
+	return nil
+}
+
+// Handler does the work of one job, an error makes the runner retry it later
+type Handler func(ctx context.Context, job Job) error
+
+// Options tune how the jobs of one kind run
+type Options struct {
+	// Concurrency is how many jobs of the kind one instance runs at the same time
+	Concurrency int
+	// Timeout cancels the context of a job that runs longer
+	Timeout time.Duration
+	Retry   infra.RetryPolicy
+}
+
+var DefaultOptions = Options{
+	Concurrency: 1,
+	Timeout:     5 * time.Minute,
+	Retry:       infra.DefaultRetryPolicy,
+}
+
+// Runner runs persisted background jobs. Jobs survive restarts and are shared between server instances,
+// each kind runs with its own concurrency limit and retry policy.
+type Runner struct {
+	db       *gorm.DB
+	instance string
+	mu       sync.Mutex
+	workers  map[string]*worker
+	schedule map[string]*scheduledJob
+	loops    []loop
+	ctx      context.Context
+	running  sync.WaitGroup
+}
+
+type worker struct {
+	kind    string
+	handler Handler
+	options Options
+	slots   chan struct{}
+	wake    chan struct{}
+}
+
+var GlobalRunner *Runner
+
+func NewRunner(db *gorm.DB) *Runner {
+	hostname, _ := os.Hostname()
+	return &Runner{
+		db:       db,
+		instance: hostname + ":" + strconv.Itoa(os.Getpid()),
+		workers:  map[string]*worker{},
+		schedule: map[string]*scheduledJob{},
+	}
+}
+
+// Register sets the handler for jobs of kind, a kind registered after Start begins right away
+func (r *Runner) Register(kind string, handler Handler, options Options) {
+	if options.Concurrency < 1 {
+		options.Concurrency = DefaultOptions.Concurrency
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = DefaultOptions.Timeout
+	}
+	if options.Retry.MaxAttempts < 1 {
+		options.Retry = DefaultOptions.Retry
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	w := &worker{
+		kind:    kind,
+		handler: handler,
+		options: options,
+		slots:   make(chan struct{}, options.Concurrency),
+		wake:    make(chan struct{}, 1),
+	}
+	r.workers[kind] = w
+	if r.ctx != nil {
+		r.launch(w)
+	}
+}
+
+// Enqueue queues a job of kind to run at runAt, the zero time runs it as soon as possible
+func (r *Runner) Enqueue(ctx context.Context, kind string, payload interface{}, runAt time.Time) error {
+	if err := EnqueueTx(r.db.WithContext(ctx), kind, payload, runAt); err != nil {
+		return err
+	}
+	if runAt.IsZero() || !runAt.After(time.Now()) {
+		r.nudge(kind)
+	}
+	return nil
+}
+
+// EnqueueTx queues a job inside the caller's transaction, it only runs if the transaction commits
+func EnqueueTx(tx *gorm.DB, kind string, payload interface{}, runAt time.Time) error {
+	job, err := newJob(kind, payload, runAt)
+	if err != nil {
+		return err
+	}
+	if err := tx.Create(job).Error; err != nil {
+		return fmt.Errorf("unable to queue %s job %w", kind, err)
+	}
+	return nil
+}
+
+func newJob(kind string, payload interface{}, runAt time.Time) (*jobModels.Job, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode %s job %w", kind, err)
+	}
+	if runAt.IsZero() {
+		runAt = time.Now()
+	}
+	return &jobModels.Job{Kind: kind, Payload: data, Status: jobModels.JobQueued, RunAt: runAt}, nil
+}
+
+// nudge lets a local worker pick a new job up without waiting for its next poll
+func (r *Runner) nudge(kind string) {
+	r.mu.Lock()
+	w := r.workers[kind]
+	r.mu.Unlock()
+
+	if w != nil {
+		w.nudge()
+	}
+}
+
+func (w *worker) nudge() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start runs the registered kinds, schedules and loops until ctx is done, calling it twice has no effect
+func (r *Runner) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.ctx != nil {
+		return
+	}
+	r.ctx = ctx
+	for _, w := range r.workers {
+		r.launch(w)
+	}
+	for _, l := range r.loops {
+		r.launchLoop(l)
+	}
+
+	r.running.Add(1)
+	go func() {
+		defer r.running.Done()
+		r.runSchedules(ctx)
+	}()
+}
+
+// Wait blocks until every running job and loop returned after the context given to Start is done
+func (r *Runner) Wait() {
+	r.running.Wait()
+}
+
+func (r *Runner) launch(w *worker) {
+	r.running.Add(1)
+	go func(ctx context.Context) {
+		defer r.running.Done()
+
+		var jobs sync.WaitGroup
+		defer jobs.Wait()
+
+		for {
+			claimed, err := r.claim(ctx, w)
+			if err != nil && ctx.Err() == nil {
+				logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to claim jobs: %v", err), map[string]interface{}{
+					"kind": w.kind,
+				})
+			}
+
+			for i := range claimed {
+				w.slots <- struct{}{}
+				jobs.Add(1)
+				go func(job *jobModels.Job) {
+					defer jobs.Done()
+					r.run(ctx, w, job)
+					<-w.slots
+					w.nudge()
+				}(&claimed[i])
+			}
+
+			// more may be due, the next claim waits for a free slot
+			if len(claimed) > 0 {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-w.wake:
+			case <-time.After(pollInterval):
+			}
+		}
+	}(r.ctx)
+}
+
+// claim leases as many due jobs as the worker has free slots
+func (r *Runner) claim(ctx context.Context, w *worker) ([]jobModels.Job, error) {
+	free := cap(w.slots) - len(w.slots)
+	if free == 0 || ctx.Err() != nil {
+		return nil, nil
+	}
+
+	now := time.Now()
+	var ids []uint
+	if err := r.db.WithContext(ctx).Raw(claimJobsSQL,
+		jobModels.JobRunning, r.instance, now.Add(w.options.Timeout+leaseGrace), now,
+		w.kind, now, jobModels.JobQueued, jobModels.JobRunning, now, free).
+		Scan(&ids).Error; err != nil {
+		return nil, fmt.Errorf("unable to claim jobs %w", err)
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var claimed []jobModels.Job
+	if err := r.db.WithContext(ctx).Where("id IN ?", ids).Order("run_at asc, id asc").Find(&claimed).Error; err != nil {
+		return nil, fmt.Errorf("unable to load jobs %w", err)
+	}
+	return claimed, nil
+}
+
+func (r *Runner) run(ctx context.Context, w *worker, job *jobModels.Job) {
+	jobCtx, cancel := context.WithTimeout(ctx, w.options.Timeout)
+	runErr := handle(jobCtx, w.handler, Job{
+		ID:      job.ID,
+		Kind:    job.Kind,
+		Payload: job.Payload,
+		Attempt: job.Attempts,
+		RunAt:   job.RunAt,
+	})
+	cancel()
+
+	// only the instance holding the lease records the outcome, a job taken over by another one is theirs now
+	query := r.db.Model(&jobModels.Job{}).Where("id = ? AND locked_by = ? AND attempts = ?", job.ID, r.instance, job.Attempts)
+	now := time.Now()
+
+	var result error
+	switch {
+	case runErr == nil:
+		result = query.Updates(map[string]interface{}{
+			"status":       jobModels.JobDone,
+			"finished_at":  now,
+			"locked_until": nil,
+			"last_error":   "",
+		}).Error
+	case ctx.Err() != nil:
+		// shutting down, hand the job back without counting the interrupted attempt
+		result = query.Updates(map[string]interface{}{
+			"status":       jobModels.JobQueued,
+			"attempts":     gorm.Expr("attempts - 1"),
+			"locked_until": nil,
+		}).Error
+	case job.Attempts >= w.options.Retry.MaxAttempts:
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("job gave up: %v", runErr), map[string]interface{}{
+			"kind":     job.Kind,
+			"job_id":   job.ID,
+			"attempts": job.Attempts,
+		})
+		result = query.Updates(map[string]interface{}{
+			"status":       jobModels.JobDead,
+			"finished_at":  now,
+			"locked_until": nil,
+			"last_error":   utils.Truncate(runErr.Error(), utils.MaxErrorLength),
+		}).Error
+	default:
+		result = query.Updates(map[string]interface{}{
+			"status":       jobModels.JobQueued,
+			"run_at":       now.Add(w.options.Retry.Backoff(job.Attempts)),
+			"locked_until": nil,
+			"last_error":   utils.Truncate(runErr.Error(), utils.MaxErrorLength),
+		}).Error
+	}
+
+	if result != nil {
+		// the lease runs out and the job runs again
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to record job outcome: %v", result), map[string]interface{}{
+			"job_id": job.ID,
+		})
+	}
+}
+
+// handle turns a panicking handler into a failed attempt
+func handle(ctx context.Context, handler Handler, job Job) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("job panicked: %v", recovered)
+		}
+	}()
+	return handler(ctx, job)
+}
+
+// Prune drops finished jobs past Retention and dead ones past DeadRetention
+func (r *Runner) Prune(ctx context.Context) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("status = ? AND finished_at < ?", jobModels.JobDone, now.Add(-Retention)).
+			Delete(&jobModels.Job{}).Error; err != nil {
+			return fmt.Errorf("unable to prune jobs %w", err)
+		}
+		if err := tx.Where("status = ? AND finished_at < ?", jobModels.JobDead, now.Add(-DeadRetention)).
+			Delete(&jobModels.Job{}).Error; err != nil {
+			return fmt.Errorf("unable to prune dead jobs %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,183 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"resq/internal/infra/logger"
+	jobModels "resq/pkg/models/job"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// scheduleInterval is how often the leader looks for schedules that fell due
+	scheduleInterval = 5 * time.Second
+	// leaderLease is how long the leader keeps its lease without renewing it, another instance takes over after that
+	leaderLease = 30 * time.Second
+	// leaderLeaseName is the lease the instance queueing scheduled runs holds
+	leaderLeaseName = "job-schedules"
+)
+
+// acquireLeaseSQL takes the lease when it is free or expired, or renews it for its current holder
+const acquireLeaseSQL = `INSERT INTO job_leases (name, holder, expires_at) VALUES (?, ?, ?)
+ON CONFLICT (name) DO UPDATE SET holder = EXCLUDED.holder, expires_at = EXCLUDED.expires_at
+WHERE job_leases.holder = EXCLUDED.holder OR job_leases.expires_at < ?`
+
+type scheduledJob struct {
+	name    string
+	spec    string
+	cron    *Cron
+	kind    string
+	payload interface{}
+}
+
+// Schedule queues a job of kind with payload whenever spec falls due. Only the instance holding the leader lease
+// queues scheduled runs, and each run is queued once. Runs missed while no instance was up are made up by a single run.
+func (r *Runner) Schedule(name string, spec string, kind string, payload interface{}) error {
+	cron, err := ParseCron(spec)
+	if err != nil {
+		return err
+	}
+	if cron.Next(time.Now()).IsZero() {
+		return fmt.Errorf("%w: %q never falls due", ErrInvalidCron, spec)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.schedule[name] = &scheduledJob{name: name, spec: spec, cron: cron, kind: kind, payload: payload}
+	return nil
+}
+
+// Cron registers run as its own kind and schedules it under the same name, for recurring work without a payload
+func (r *Runner) Cron(name string, spec string, run func(ctx context.Context) error) error {
+	if err := r.Schedule(name, spec, name, nil); err != nil {
+		return err
+	}
+	r.Register(name, func(ctx context.Context, job Job) error {
+		return run(ctx)
+	}, DefaultOptions)
+	return nil
+}
+
+// runSchedules competes for the leader lease and, while holding it, queues the runs that fell due
+func (r *Runner) runSchedules(ctx context.Context) {
+	ticker := time.NewTicker(scheduleInterval)
+	defer ticker.Stop()
+
+	leading := false
+	for {
+		wasLeading := leading
+		var err error
+		leading, err = r.acquireLease(ctx)
+		if err != nil && ctx.Err() == nil {
+			logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to take the job schedule lease: %v", err))
+		}
+		if leading && !wasLeading {
+			logger.GlobalLogger.Log(logger.INFO, "Leading job schedules", map[string]interface{}{
+				"instance": r.instance,
+			})
+		}
+
+		if leading {
+			if err := r.queueDueRuns(ctx); err != nil && ctx.Err() == nil {
+				logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to queue scheduled jobs: %v", err))
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if leading {
+				r.releaseLease()
+			}
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) acquireLease(ctx context.Context) (bool, error) {
+	now := time.Now()
+	result := r.db.WithContext(ctx).Exec(acquireLeaseSQL, leaderLeaseName, r.instance, now.Add(leaderLease), now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// releaseLease lets another instance lead right away instead of after the lease runs out
+func (r *Runner) releaseLease() {
+	if err := r.db.Where("name = ? AND holder = ?", leaderLeaseName, r.instance).Delete(&jobModels.JobLease{}).Error; err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to release the job schedule lease: %v", err))
+	}
+}
+
+// queueDueRuns queues one job for every schedule that fell due and moves it to its next run
+func (r *Runner) queueDueRuns(ctx context.Context) error {
+	r.mu.Lock()
+	schedules := make([]*scheduledJob, 0, len(r.schedule))
+	for _, s := range r.schedule {
+		schedules = append(schedules, s)
+	}
+	r.mu.Unlock()
+
+	for _, s := range schedules {
+		if err := r.queueDueRun(ctx, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Runner) queueDueRun(ctx context.Context, s *scheduledJob) error {
+	now := time.Now()
+	queued := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var stored jobModels.JobSchedule
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("name = ?", s.name).Limit(1).Find(&stored)
+		if result.Error != nil {
+			return fmt.Errorf("unable to find job schedule %w", result.Error)
+		}
+
+		// a new schedule, or one whose spec changed, starts counting from now
+		if result.RowsAffected == 0 || stored.Spec != s.spec {
+			return tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&jobModels.JobSchedule{
+				Name:      s.name,
+				Spec:      s.spec,
+				NextRunAt: s.cron.Next(now),
+				LastRunAt: stored.LastRunAt,
+				UpdatedAt: now,
+			}).Error
+		}
+		if stored.NextRunAt.After(now) {
+			return nil
+		}
+
+		job, err := newJob(s.kind, s.payload, stored.NextRunAt)
+		if err != nil {
+			return err
+		}
+		uniqueKey := fmt.Sprintf("%s@%d", s.name, stored.NextRunAt.Unix())
+		job.UniqueKey = &uniqueKey
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(job).Error; err != nil {
+			return fmt.Errorf("unable to queue %s job %w", s.kind, err)
+		}
+
+		runAt := stored.NextRunAt
+		if err := tx.Model(&stored).Updates(map[string]interface{}{
+			"next_run_at": s.cron.Next(now),
+			"last_run_at": runAt,
+			"updated_at":  now,
+		}).Error; err != nil {
+			return fmt.Errorf("unable to update job schedule %w", err)
+		}
+		queued = true
+		return nil
+	})
+	if queued && err == nil {
+		r.nudge(s.kind)
+	}
+	return err
+}
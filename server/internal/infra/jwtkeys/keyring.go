@@ -0,0 +1,201 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"resq/pkg/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	activeFile = "active"
+	keyExt     = ".pem"
+
+	// reloadInterval is how often the ring looks for keys added or activated by the rotation command
+	reloadInterval = time.Minute
+	// missReloadInterval limits reloads triggered by tokens naming a key the ring does not know
+	missReloadInterval = 10 * time.Second
+)
+
+var ErrNoActiveKey = errors.New("no active jwt signing key, run `go run ./cmd/jwtkeys rotate`")
+
+// GlobalKeyRing signs and verifies access tokens once config loads it
+var GlobalKeyRing *KeyRing
+
+// KeyRing holds every key found in a directory of PKCS#8 PEM files named <kid>.pem,
+// the file named active holds the kid new tokens are signed with
+type KeyRing struct {
+	dir string
+
+	mu         sync.RWMutex
+	keys       map[string]*utils.SigningKey
+	activeKid  string
+	loadedAt   time.Time
+	lastMissAt time.Time
+}
+
+func LoadKeyRing(dir string) (*KeyRing, error) {
+	ring := &KeyRing{dir: dir}
+	if err := ring.Reload(); err != nil {
+		return nil, err
+	}
+	if _, err := ring.ActiveKey(); err != nil {
+		return nil, err
+	}
+	return ring, nil
+}
+
+// Reload reads the directory again, the ring is left untouched when it holds no usable active key
+func (r *KeyRing) Reload() error {
+	keys, activeKid, err := readKeys(r.dir)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.loadedAt = time.Now()
+	if err != nil {
+		return err
+	}
+	r.keys = keys
+	r.activeKid = activeKid
+	return nil
+}
+
+func (r *KeyRing) reloadIfStale(force bool) {
+	r.mu.RLock()
+	stale := time.Since(r.loadedAt) > reloadInterval
+	canRetryMiss := time.Since(r.lastMissAt) > missReloadInterval
+	r.mu.RUnlock()
+
+	if force && canRetryMiss {
+		r.mu.Lock()
+		r.lastMissAt = time.Now()
+		r.mu.Unlock()
+		stale = true
+	}
+
+	if stale {
+		// a failed reload keeps the keys already loaded
+		_ = r.Reload()
+	}
+}
+
+func (r *KeyRing) ActiveKey() (*utils.SigningKey, error) {
+	r.reloadIfStale(false)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[r.activeKid]
+	if !ok {
+		return nil, ErrNoActiveKey
+	}
+	return key, nil
+}
+
+func (r *KeyRing) Key(kid string) (*utils.SigningKey, bool) {
+	r.reloadIfStale(false)
+
+	r.mu.RLock()
+	key, ok := r.keys[kid]
+	r.mu.RUnlock()
+	if ok {
+		return key, true
+	}
+
+	// another instance may already sign with a key this one has not loaded yet
+	r.reloadIfStale(true)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	key, ok = r.keys[kid]
+	return key, ok
+}
+
+// Keys returns every key of the ring, oldest first
+func (r *KeyRing) Keys() []*utils.SigningKey {
+	r.reloadIfStale(false)
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := make([]*utils.SigningKey, 0, len(r.keys))
+	for _, key := range r.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+func readKeys(dir string) (map[string]*utils.SigningKey, string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, "", fmt.Errorf("unable to read jwt key directory %s: %w", dir, err)
+	}
+
+	keys := map[string]*utils.SigningKey{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), keyExt) {
+			continue
+		}
+
+		kid := strings.TrimSuffix(entry.Name(), keyExt)
+		key, err := readKey(filepath.Join(dir, entry.Name()), kid)
+		if err != nil {
+			return nil, "", err
+		}
+		keys[kid] = key
+	}
+
+	active, err := os.ReadFile(filepath.Join(dir, activeFile))
+	if err != nil {
+		return nil, "", ErrNoActiveKey
+	}
+
+	activeKid := strings.TrimSpace(string(active))
+	if _, ok := keys[activeKid]; !ok {
+		return nil, "", fmt.Errorf("active jwt key %q has no key file: %w", activeKid, ErrNoActiveKey)
+	}
+	return keys, activeKid, nil
+}
+
+func readKey(path string, kid string) (*utils.SigningKey, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read jwt key %s: %w", kid, err)
+	}
+
+	block, _ := pem.Decode(content)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, fmt.Errorf("jwt key %s is not a PKCS#8 PEM private key", kid)
+	}
+
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse jwt key %s: %w", kid, err)
+	}
+
+	switch private := parsed.(type) {
+	case ed25519.PrivateKey:
+		return &utils.SigningKey{ID: kid, Method: jwt.SigningMethodEdDSA, PrivateKey: private}, nil
+	case *rsa.PrivateKey:
+		if private.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("jwt key %s is an RSA key shorter than 2048 bits", kid)
+		}
+		return &utils.SigningKey{ID: kid, Method: jwt.SigningMethodRS256, PrivateKey: private}, nil
+	default:
+		return nil, fmt.Errorf("jwt key %s has an unsupported type %T", kid, parsed)
+	}
+}
@@ -0,0 +1,142 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"resq/pkg/utils"
+)
+
+const (
+	AlgorithmEdDSA = "EdDSA"
+	AlgorithmRS256 = "RS256"
+
+	rsaKeyBits = 3072
+)
+
+// kidTimeFormat puts the creation time first so kids sort oldest first
+const kidTimeFormat = "20060102T150405Z"
+
+// GenerateKey writes a new key to dir and returns its kid, the key is not used for signing until activated
+func GenerateKey(dir string, algorithm string) (string, error) {
+	var private crypto.Signer
+	switch algorithm {
+	case AlgorithmEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return "", err
+		}
+		private = key
+	case AlgorithmRS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return "", err
+		}
+		private = key
+	default:
+		return "", fmt.Errorf("unsupported jwt algorithm %q, use %s or %s", algorithm, AlgorithmEdDSA, AlgorithmRS256)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return "", err
+	}
+
+	suffix, err := utils.GenerateRandomToken(4)
+	if err != nil {
+		return "", err
+	}
+	kid := time.Now().UTC().Format(kidTimeFormat) + "-" + suffix
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", fmt.Errorf("unable to create jwt key directory: %w", err)
+	}
+
+	content := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := writeFileAtomic(filepath.Join(dir, kid+keyExt), content); err != nil {
+		return "", err
+	}
+	return kid, nil
+}
+
+// Activate makes kid the key new tokens are signed with, running servers pick it up within a minute
+func Activate(dir string, kid string) error {
+	if _, err := readKey(filepath.Join(dir, kid+keyExt), kid); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, activeFile), []byte(kid+"\n"))
+}
+
+// ActiveKid returns the kid in the active file and when it was activated
+func ActiveKid(dir string) (string, time.Time, error) {
+	path := filepath.Join(dir, activeFile)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", time.Time{}, ErrNoActiveKey
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", time.Time{}, ErrNoActiveKey
+	}
+	return strings.TrimSpace(string(content)), info.ModTime(), nil
+}
+
+// Prune deletes keys older than the active one once the active key has been signing for longer than grace,
+// by then every token signed with an older key has expired. Keys waiting to be activated are kept.
+func Prune(dir string, grace time.Duration) ([]string, error) {
+	activeKid, activatedAt, err := ActiveKid(dir)
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(activatedAt) < grace {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, entry := range entries {
+		kid, ok := strings.CutSuffix(entry.Name(), keyExt)
+		if !ok || entry.IsDir() || kid >= activeKid {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			return removed, err
+		}
+		removed = append(removed, kid)
+	}
+	return removed, nil
+}
+
+func writeFileAtomic(path string, content []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+
+	if _, err := temp.Write(content); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Chmod(0o600); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
@@ -0,0 +1,28 @@
+package mailer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// ConsoleMailer prints messages instead of sending them, it is the development default
+type ConsoleMailer struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func NewConsoleMailer() *ConsoleMailer {
+	return &ConsoleMailer{out: os.Stdout}
+}
+
+func (c *ConsoleMailer) Send(message Message) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, err := fmt.Fprintf(c.out, "----- mail %s -----\nTo: %s\nSubject: %s\n\n%s\n-----\n",
+		time.Now().Format(time.RFC3339), message.To, message.Subject, message.Body)
+	return err
+}
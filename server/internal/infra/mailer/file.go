@@ -0,0 +1,37 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// FileMailer appends every message to a file so local flows can be followed without a mail server
+type FileMailer struct {
+	mu       sync.Mutex
+	filename string
+}
+
+func NewFileMailer(filename string) (*FileMailer, error) {
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("unable to create mail directory: %w", err)
+	}
+	return &FileMailer{filename: filename}, nil
+}
+
+func (f *FileMailer) Send(message Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open mail file: %w", err)
+	}
+	defer file.Close()
+
+	_, err = fmt.Fprintf(file, "----- mail %s -----\nTo: %s\nSubject: %s\n\n%s\n-----\n",
+		time.Now().Format(time.RFC3339), message.To, message.Subject, message.Body)
+	return err
+}
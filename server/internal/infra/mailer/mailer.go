@@ -0,0 +1,13 @@
+package mailer
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(message Message) error
+}
+
+var GlobalMailer Mailer = NewConsoleMailer()
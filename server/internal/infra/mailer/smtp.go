@@ -0,0 +1,58 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+type SMTPMailer struct {
+	config SMTPConfig
+}
+
+func NewSMTPMailer(config SMTPConfig) (*SMTPMailer, error) {
+	if config.Host == "" || config.From == "" {
+		return nil, fmt.Errorf("smtp mailer needs a host and a from address")
+	}
+	if config.Port == "" {
+		config.Port = "587"
+	}
+	return &SMTPMailer{config: config}, nil
+}
+
+func (s *SMTPMailer) Send(message Message) error {
+	var auth smtp.Auth
+	if s.config.Username != "" {
+		auth = smtp.PlainAuth("", s.config.Username, s.config.Password, s.config.Host)
+	}
+
+	// header values come from our own templates, newlines are still stripped so nothing can inject headers
+	headers := strings.Join([]string{
+		"From: " + stripNewlines(s.config.From),
+		"To: " + stripNewlines(message.To),
+		"Subject: " + stripNewlines(message.Subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+	}, "\r\n")
+
+	body := headers + "\r\n\r\n" + message.Body
+	address := net.JoinHostPort(s.config.Host, s.config.Port)
+
+	if err := smtp.SendMail(address, auth, s.config.From, []string{message.To}, []byte(body)); err != nil {
+		return fmt.Errorf("unable to send mail: %w", err)
+	}
+	return nil
+}
+
+func stripNewlines(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
@@ -0,0 +1,204 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"resq/internal/infra/logger"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MemoryBus delivers messages inside this process. Nothing survives a restart, messages still queued
+// or waiting for a retry are lost, so it suits development and single instance setups.
+// Each handler of a group works through its own queue, messages with the same key always land on the same one.
+type MemoryBus struct {
+	retry   RetryPolicy
+	mu      sync.Mutex
+	groups  map[string]map[string]*memoryGroup
+	ctx     context.Context
+	running sync.WaitGroup
+	lastID  atomic.Uint64
+}
+
+type memoryGroup struct {
+	workers []*memoryWorker
+	next    int
+}
+
+type memoryWorker struct {
+	topic   string
+	group   string
+	handler Handler
+	mu      sync.Mutex
+	queue   []Message
+	wake    chan struct{}
+}
+
+func NewMemoryBus(retry RetryPolicy) *MemoryBus {
+	return &MemoryBus{retry: retry, groups: map[string]map[string]*memoryGroup{}}
+}
+
+func (b *MemoryBus) Publish(ctx context.Context, messages ...Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, message := range messages {
+		message.ID = strconv.FormatUint(b.lastID.Add(1), 10)
+		if message.CreatedAt.IsZero() {
+			message.CreatedAt = time.Now()
+		}
+
+		for _, group := range b.groups[message.Topic] {
+			group.pick(message.Key).enqueue(message)
+		}
+	}
+	return nil
+}
+
+// pick keeps messages of one key on one worker and spreads keyless ones round robin
+func (g *memoryGroup) pick(key string) *memoryWorker {
+	if key == "" {
+		g.next = (g.next + 1) % len(g.workers)
+		return g.workers[g.next]
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(key))
+	return g.workers[hash.Sum32()%uint32(len(g.workers))]
+}
+
+func (w *memoryWorker) enqueue(message Message) {
+	w.mu.Lock()
+	w.queue = append(w.queue, message)
+	w.mu.Unlock()
+
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (w *memoryWorker) dequeue() (Message, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.queue) == 0 {
+		return Message{}, false
+	}
+	message := w.queue[0]
+	w.queue = w.queue[1:]
+	return message, true
+}
+
+func (b *MemoryBus) Subscribe(topic string, group string, handler Handler) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.groups[topic] == nil {
+		b.groups[topic] = map[string]*memoryGroup{}
+	}
+	if b.groups[topic][group] == nil {
+		b.groups[topic][group] = &memoryGroup{}
+	}
+
+	worker := &memoryWorker{topic: topic, group: group, handler: handler, wake: make(chan struct{}, 1)}
+	b.groups[topic][group].workers = append(b.groups[topic][group].workers, worker)
+	if b.ctx != nil {
+		b.launch(worker)
+	}
+	return nil
+}
+
+// Start runs every subscribed handler until ctx is done, calling it twice has no effect
+func (b *MemoryBus) Start(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.ctx != nil {
+		return
+	}
+	b.ctx = ctx
+	for _, groups := range b.groups {
+		for _, group := range groups {
+			for _, worker := range group.workers {
+				b.launch(worker)
+			}
+		}
+	}
+}
+
+func (b *MemoryBus) Wait() {
+	b.running.Wait()
+}
+
+func (b *MemoryBus) launch(worker *memoryWorker) {
+	b.running.Add(1)
+	go func(ctx context.Context) {
+		defer b.running.Done()
+
+		for {
+			message, ok := worker.dequeue()
+			if !ok {
+				select {
+				case <-ctx.Done():
+					return
+				case <-worker.wake:
+					continue
+				}
+			}
+			if !b.deliver(ctx, worker, message) {
+				return
+			}
+		}
+	}(b.ctx)
+}
+
+// deliver retries the message with backoff until it is handled or becomes a dead letter,
+// false means the bus is shutting down
+func (b *MemoryBus) deliver(ctx context.Context, worker *memoryWorker, message Message) bool {
+	for attempt := 1; ; attempt++ {
+		message.Attempt = attempt
+		err := handle(ctx, worker.handler, message)
+		if err == nil {
+			return true
+		}
+
+		if attempt >= b.retry.MaxAttempts {
+			b.bury(message, worker.group, err)
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(b.retry.Backoff(attempt)):
+		}
+	}
+}
+
+// bury moves a message its group gave up on to the dead letter topic, dead letters that fail again are only logged
+func (b *MemoryBus) bury(message Message, group string, err error) {
+	logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("event handler gave up: %v", err), map[string]interface{}{
+		"topic":      message.Topic,
+		"group":      group,
+		"message_id": message.ID,
+		"attempts":   message.Attempt,
+	})
+	if strings.HasSuffix(message.Topic, DeadLetterSuffix) {
+		return
+	}
+	b.Publish(context.Background(), deadLetter(message, group, err))
+}
+
+// handle turns a panicking handler into a failed attempt
+func handle(ctx context.Context, handler Handler, message Message) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			err = fmt.Errorf("handler panicked: %v", recovered)
+		}
+	}()
+	return handler(ctx, message)
+}
@@ -0,0 +1,275 @@
+package infra
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"resq/internal/infra/logger"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	// a logger without a file drops what it is given
+	logger.GlobalLogger = &logger.Logger{}
+	os.Exit(m.Run())
+}
+
+var testRetry = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 20 * time.Millisecond,
+	MaxBackoff:     30 * time.Millisecond,
+}
+
+// startBus runs a memory bus until the test ends, subscribe runs before it starts
+func startBus(t *testing.T, retry RetryPolicy, subscribe func(bus *MemoryBus)) *MemoryBus {
+	t.Helper()
+	bus := NewMemoryBus(retry)
+	subscribe(bus)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	bus.Start(ctx)
+	t.Cleanup(func() {
+		cancel()
+		bus.Wait()
+	})
+	return bus
+}
+
+func receive(t *testing.T, messages <-chan Message) Message {
+	t.Helper()
+	select {
+	case message := <-messages:
+		return message
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message arrived")
+		return Message{}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+
+	for i, backoff := range want {
+		if got := policy.Backoff(i + 1); got != backoff {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, backoff)
+		}
+	}
+}
+
+func TestMemoryBusKeepsKeysInOrder(t *testing.T) {
+	const perKey = 50
+	keys := []string{"report:1", "report:2", "report:3", "report:4", "report:5"}
+
+	var mu sync.Mutex
+	seen := map[string][]int{}
+	var handled sync.WaitGroup
+	handled.Add(perKey * len(keys))
+
+	handler := func(ctx context.Context, message Message) error {
+		var sequence int
+		if err := message.Decode(&sequence); err != nil {
+			return err
+		}
+		// uneven handling times would reorder a key spread over several workers
+		time.Sleep(time.Duration(sequence%3) * time.Millisecond)
+
+		mu.Lock()
+		seen[message.Key] = append(seen[message.Key], sequence)
+		mu.Unlock()
+		handled.Done()
+		return nil
+	}
+
+	bus := startBus(t, testRetry, func(bus *MemoryBus) {
+		for i := 0; i < 4; i++ {
+			bus.Subscribe("report.updated", "feed", handler)
+		}
+	})
+
+	for sequence := 0; sequence < perKey; sequence++ {
+		for _, key := range keys {
+			payload, _ := json.Marshal(sequence)
+			if err := bus.Publish(context.Background(), Message{Topic: "report.updated", Key: key, Payload: payload}); err != nil {
+				t.Fatalf("Publish failed: %v", err)
+			}
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		handled.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("not every message was handled")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, key := range keys {
+		if len(seen[key]) != perKey {
+			t.Fatalf("%s: handled %d messages, want %d", key, len(seen[key]), perKey)
+		}
+		for i, sequence := range seen[key] {
+			if sequence != i {
+				t.Fatalf("%s: handled %v, want them in publishing order", key, seen[key])
+			}
+		}
+	}
+}
+
+func TestMemoryBusRetriesWithBackoff(t *testing.T) {
+	type attempt struct {
+		number int
+		at     time.Time
+	}
+	attempts := make(chan attempt, 10)
+
+	bus := startBus(t, testRetry, func(bus *MemoryBus) {
+		bus.Subscribe("report.created", "notify", func(ctx context.Context, message Message) error {
+			attempts <- attempt{number: message.Attempt, at: time.Now()}
+			if message.Attempt < 3 {
+				return errors.New("mail server unavailable")
+			}
+			return nil
+		})
+	})
+
+	if err := bus.Publish(context.Background(), Message{Topic: "report.created", Key: "report:1"}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	var got []attempt
+	for len(got) < 3 {
+		select {
+		case a := <-attempts:
+			got = append(got, a)
+		case <-time.After(2 * time.Second):
+			t.Fatalf("got %d attempts, want 3", len(got))
+		}
+	}
+
+	for i, a := range got {
+		if a.number != i+1 {
+			t.Errorf("attempt %d carried Attempt = %d", i+1, a.number)
+		}
+	}
+	for i := 1; i < len(got); i++ {
+		if gap, backoff := got[i].at.Sub(got[i-1].at), testRetry.Backoff(i); gap < backoff {
+			t.Errorf("attempt %d came %v after the one before, want at least %v", i+1, gap, backoff)
+		}
+	}
+
+	// a handled message is not delivered again
+	select {
+	case a := <-attempts:
+		t.Errorf("unexpected attempt %d after the message was handled", a.number)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestMemoryBusDeadLettersAfterMaxAttempts(t *testing.T) {
+	var mu sync.Mutex
+	attempts := 0
+	deadLetters := make(chan Message, 10)
+
+	bus := startBus(t, testRetry, func(bus *MemoryBus) {
+		bus.Subscribe("report.created", "notify", func(ctx context.Context, message Message) error {
+			mu.Lock()
+			attempts++
+			mu.Unlock()
+			return fmt.Errorf("attempt %d failed", message.Attempt)
+		})
+		bus.Subscribe(DeadLetterTopic("report.created"), "inspect", func(ctx context.Context, message Message) error {
+			deadLetters <- message
+			return nil
+		})
+	})
+
+	original := Message{
+		Topic:   "report.created",
+		Key:     "report:7",
+		Payload: json.RawMessage(`{"report_id":7}`),
+		Headers: map[string]string{HeaderEventID: "42"},
+	}
+	if err := bus.Publish(context.Background(), original); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	dead := receive(t, deadLetters)
+	mu.Lock()
+	if attempts != testRetry.MaxAttempts {
+		t.Errorf("handler ran %d times, want %d", attempts, testRetry.MaxAttempts)
+	}
+	mu.Unlock()
+
+	if dead.Topic != "report.created.dead" || dead.Key != original.Key || string(dead.Payload) != string(original.Payload) {
+		t.Errorf("dead letter = %s %s %s, want the original message on report.created.dead", dead.Topic, dead.Key, dead.Payload)
+	}
+	wantHeaders := map[string]string{
+		HeaderEventID:         "42",
+		HeaderDeadLetterGroup: "notify",
+		HeaderDeadLetterError: "attempt 3 failed",
+		HeaderOriginalID:      "1",
+	}
+	for key, value := range wantHeaders {
+		if dead.Headers[key] != value {
+			t.Errorf("dead letter header %s = %q, want %q", key, dead.Headers[key], value)
+		}
+	}
+}
+
+func TestMemoryBusDoesNotRepublishDeadLetters(t *testing.T) {
+	var mu sync.Mutex
+	deadAttempts := 0
+	failed := make(chan struct{})
+	republished := make(chan Message, 10)
+
+	bus := startBus(t, testRetry, func(bus *MemoryBus) {
+		bus.Subscribe("report.created", "notify", func(ctx context.Context, message Message) error {
+			return errors.New("always fails")
+		})
+		bus.Subscribe(DeadLetterTopic("report.created"), "replay", func(ctx context.Context, message Message) error {
+			mu.Lock()
+			defer mu.Unlock()
+			deadAttempts++
+			if deadAttempts == testRetry.MaxAttempts {
+				close(failed)
+			}
+			return errors.New("replay fails too")
+		})
+		bus.Subscribe(DeadLetterTopic(DeadLetterTopic("report.created")), "inspect", func(ctx context.Context, message Message) error {
+			republished <- message
+			return nil
+		})
+	})
+
+	if err := bus.Publish(context.Background(), Message{Topic: "report.created", Key: "report:7"}); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	select {
+	case <-failed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("the dead letter handler did not use up its attempts")
+	}
+
+	select {
+	case message := <-republished:
+		t.Errorf("a failed dead letter was published again to %s", message.Topic)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if deadAttempts != testRetry.MaxAttempts {
+		t.Errorf("dead letter handler ran %d times, want %d", deadAttempts, testRetry.MaxAttempts)
+	}
+}
@@ -3,14 +3,23 @@ package middleware
 import (
 	"log"
 	"net/http"
+	"resq/internal/infra/jwtkeys"
 	"resq/pkg/constants"
+	"resq/pkg/utils"
 	"strings"
 	"time"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 
+// TokenRevocationChecker answers whether an access token was revoked before it expired
+type TokenRevocationChecker interface {
+	IsTokenRevoked(jti string, userId string, issuedAt time.Time) bool
+}
+
+// RevocationChecker is consulted on every authenticated request once it is set
+var RevocationChecker TokenRevocationChecker
+
 func AuthMiddleware() gin.HandlerFunc {
 	return func (ctx *gin.Context) {
 		tokenString := ctx.GetHeader("Authorization")
@@ -20,23 +29,15 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 		tokenString = strings.TrimPrefix(tokenString, "Bearer ")
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return constants.JWTSecretKey, nil
-		})
-
-		if err != nil {
-			log.Printf("JWT Parse error: %v", err)
-			ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "invalid token"})
+		if jwtkeys.GlobalKeyRing == nil {
+			ctx.JSON(http.StatusServiceUnavailable, gin.H{constants.RequestError: "authentication is unavailable"})
 			ctx.Abort()
 			return
 		}
+		claims, err := utils.ValidateJWT(tokenString, jwtkeys.GlobalKeyRing)
 
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok || !token.Valid {
-			log.Printf("Invalid token claims or token validation failed")
+		if err != nil {
+			log.Printf("JWT Parse error: %v", err)
 			ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "invalid token"})
 			ctx.Abort()
 			return
@@ -45,7 +46,7 @@ func AuthMiddleware() gin.HandlerFunc {
 
 		expTime, err := claims.GetExpirationTime()
 
-		if err != nil {
+		if err != nil || expTime == nil {
 			log.Printf("Error getting expiration time: %v", err)
 			ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "invalid token"})
 			ctx.Abort()
@@ -59,8 +60,28 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		issuedAt, err := claims.GetIssuedAt()
+
+		if err != nil || issuedAt == nil || claims.ID == "" {
+			log.Printf("Token is missing its id or issue time")
+			ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "invalid token"})
+			ctx.Abort()
+			return
+		}
+
+		if RevocationChecker != nil && RevocationChecker.IsTokenRevoked(claims.ID, claims.UserID, issuedAt.Time) {
+			log.Printf("Token %s has been revoked", claims.ID)
+			ctx.JSON(http.StatusUnauthorized, gin.H{constants.RequestError: "invalid token"})
+			ctx.Abort()
+			return
+		}
+
 		// Add claims to context
-		ctx.Set("user_id", claims["user_id"])
+		ctx.Set("user_id", claims.UserID)
+		ctx.Set("jti", claims.ID)
+		ctx.Set("token_expires_at", expTime.Time)
+		ctx.Set("role", claims.Role)
+		ctx.Set("permissions", claims.Permissions)
 		ctx.Next()
 	}
 }
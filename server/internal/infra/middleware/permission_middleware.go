@@ -0,0 +1,21 @@
+package middleware
+
+import (
+	"net/http"
+	"resq/pkg/constants"
+	"resq/pkg/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequirePermission only lets requests through whose token carries the permission, it runs after AuthMiddleware
+func RequirePermission(permission constants.Permission) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !utils.HasPermission(ctx, permission) {
+			ctx.JSON(http.StatusForbidden, gin.H{constants.RequestError: "forbidden"})
+			ctx.Abort()
+			return
+		}
+		ctx.Next()
+	}
+}
@@ -0,0 +1,387 @@
+package notifier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"resq/internal/infra"
+	"resq/internal/infra/jobs"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/mailer"
+	"resq/internal/infra/push"
+	"resq/internal/infra/sms"
+	"resq/pkg/dto"
+	"resq/pkg/models"
+	notificationModels "resq/pkg/models/notification"
+	"resq/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// DeliveryJob is the job kind that sends one notification delivery
+	DeliveryJob = "notification-delivery"
+	// ReceiptSchedule is when the push provider is asked what became of sent push notifications
+	ReceiptSchedule = "*/15 * * * *"
+	// receiptDelay and receiptMaxAge bound when Expo has a receipt, it is ready about 15 minutes after sending and kept for a day
+	receiptDelay  = 15 * time.Minute
+	receiptMaxAge = 24 * time.Hour
+)
+
+var (
+	ErrRecipientNotFound = errors.New("recipient not found")
+	ErrNoAddress         = errors.New("notification needs a user, an email address or a phone number")
+)
+
+// DeliveryOptions retries a failing channel for about half an hour before the delivery is marked failed
+var DeliveryOptions = jobs.Options{
+	Concurrency: 10,
+	Timeout:     30 * time.Second,
+	Retry: infra.RetryPolicy{
+		MaxAttempts:    6,
+		InitialBackoff: 30 * time.Second,
+		MaxBackoff:     10 * time.Minute,
+	},
+}
+
+// Request is a notification for one user, Data fills the template
+type Request struct {
+	UserID uint
+	// Email or Phone reach someone without an account, they are only used when UserID is zero
+	Email    string
+	Phone    string
+	Template *Template
+	Data     interface{}
+	// Link is handed to the app along with push notifications and kept with the notification
+	Link map[string]string
+	// Critical notifications ignore quiet hours
+	Critical bool
+}
+
+// NotificationService stores a notification for a user and delivers it on every channel they registered and allow:
+// their push devices, their verified phone number and their verified email address
+type NotificationService interface {
+	Notify(ctx context.Context, request Request) (*dto.NotificationDTO, error)
+	// NotifyTx notifies inside the caller's transaction, nothing is sent unless it commits
+	NotifyTx(tx *gorm.DB, request Request) (*dto.NotificationDTO, error)
+	// Deliver is the DeliveryJob handler
+	Deliver(ctx context.Context, job jobs.Job) error
+	// CheckReceipts is the scheduled job that looks up the receipts of sent push notifications
+	CheckReceipts(ctx context.Context) error
+}
+
+type notificationService struct {
+	db     *gorm.DB
+	mailer mailer.Mailer
+	sms    sms.Provider
+	push   push.Provider
+}
+
+var GlobalNotifier NotificationService
+
+func NewNotificationService(db *gorm.DB, m mailer.Mailer, smsProvider sms.Provider, pushProvider push.Provider) NotificationService {
+	return &notificationService{db: db, mailer: m, sms: smsProvider, push: pushProvider}
+}
+
+type deliveryPayload struct {
+	DeliveryID uint `json:"delivery_id"`
+}
+
+func (n *notificationService) Notify(ctx context.Context, request Request) (*dto.NotificationDTO, error) {
+	var result *dto.NotificationDTO
+	err := n.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		result, err = n.NotifyTx(tx, request)
+		return err
+	})
+	return result, err
+}
+
+func (n *notificationService) NotifyTx(tx *gorm.DB, request Request) (*dto.NotificationDTO, error) {
+	title, body, err := request.Template.Render(request.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.UserID == 0 {
+		return n.notifyAddress(tx, request, title, body)
+	}
+
+	var user models.User
+	if err := tx.Where("id = ?", request.UserID).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRecipientNotFound
+		}
+		return nil, fmt.Errorf("unable to find recipient %w", err)
+	}
+
+	preference := notificationModels.DefaultPreference(user.ID)
+	if err := tx.Where("user_id = ?", user.ID).Limit(1).Find(preference).Error; err != nil {
+		return nil, fmt.Errorf("unable to find notification preferences %w", err)
+	}
+
+	var devices []notificationModels.Device
+	if err := tx.Where("user_id = ? AND disabled_at IS NULL", user.ID).Find(&devices).Error; err != nil {
+		return nil, fmt.Errorf("unable to find devices %w", err)
+	}
+
+	priority := notificationModels.NotificationNormal
+	if request.Critical {
+		priority = notificationModels.NotificationCritical
+	}
+
+	// outside critical notifications nothing goes out during quiet hours, it waits for them to end
+	scheduledFor := time.Now()
+	if !request.Critical {
+		if until, quiet := preference.QuietUntil(scheduledFor); quiet {
+			scheduledFor = until
+		}
+	}
+
+	notification := &notificationModels.Notification{
+		UserID:     &user.ID,
+		Template:   request.Template.Name,
+		Title:      title,
+		Body:       body,
+		Data:       request.Link,
+		Priority:   priority,
+		Deliveries: deliveries(&user, preference, devices, scheduledFor),
+	}
+	return n.create(tx, notification, scheduledFor)
+}
+
+// notifyAddress reaches someone without an account, there are no preferences or quiet hours to honour
+func (n *notificationService) notifyAddress(tx *gorm.DB, request Request, title string, body string) (*dto.NotificationDTO, error) {
+	if request.Email == "" && request.Phone == "" {
+		return nil, ErrNoAddress
+	}
+
+	priority := notificationModels.NotificationNormal
+	if request.Critical {
+		priority = notificationModels.NotificationCritical
+	}
+
+	now := time.Now()
+	notification := &notificationModels.Notification{
+		Template: request.Template.Name,
+		Title:    title,
+		Body:     body,
+		Data:     request.Link,
+		Priority: priority,
+	}
+	if request.Phone != "" {
+		notification.Deliveries = append(notification.Deliveries, pendingDelivery(notificationModels.NotificationSMS, request.Phone, nil, now))
+	}
+	if request.Email != "" {
+		notification.Deliveries = append(notification.Deliveries, pendingDelivery(notificationModels.NotificationEmail, request.Email, nil, now))
+	}
+	return n.create(tx, notification, now)
+}
+
+// create stores the notification with its deliveries and queues a job for each of them
+func (n *notificationService) create(tx *gorm.DB, notification *notificationModels.Notification, scheduledFor time.Time) (*dto.NotificationDTO, error) {
+	if err := tx.Create(notification).Error; err != nil {
+		return nil, fmt.Errorf("unable to create notification %w", err)
+	}
+
+	for _, delivery := range notification.Deliveries {
+		if err := jobs.EnqueueTx(tx, DeliveryJob, &deliveryPayload{DeliveryID: delivery.ID}, scheduledFor); err != nil {
+			return nil, err
+		}
+	}
+	return notification.ToDTO(), nil
+}
+
+func pendingDelivery(channel notificationModels.NotificationChannel, address string, deviceId *uint, scheduledFor time.Time) notificationModels.NotificationDelivery {
+	return notificationModels.NotificationDelivery{
+		Channel:      channel,
+		Address:      address,
+		DeviceID:     deviceId,
+		Status:       notificationModels.NotificationDeliveryPending,
+		ScheduledFor: scheduledFor,
+	}
+}
+
+// deliveries picks the channels the user registered and allows, unverified contact details are left out
+func deliveries(user *models.User, preference *notificationModels.NotificationPreference, devices []notificationModels.Device, scheduledFor time.Time) []notificationModels.NotificationDelivery {
+	var result []notificationModels.NotificationDelivery
+	add := func(channel notificationModels.NotificationChannel, address string, deviceId *uint) {
+		result = append(result, pendingDelivery(channel, address, deviceId, scheduledFor))
+	}
+
+	if preference.Allows(notificationModels.NotificationPush) {
+		for i := range devices {
+			add(notificationModels.NotificationPush, devices[i].Token, &devices[i].ID)
+		}
+	}
+	if preference.Allows(notificationModels.NotificationSMS) && user.Phone != "" && user.PhoneVerifiedAt != nil {
+		add(notificationModels.NotificationSMS, user.Phone, nil)
+	}
+	if preference.Allows(notificationModels.NotificationEmail) && user.Email != "" && user.EmailVerifiedAt != nil {
+		add(notificationModels.NotificationEmail, user.Email, nil)
+	}
+	return result
+}
+
+func (n *notificationService) Deliver(ctx context.Context, job jobs.Job) error {
+	var payload deliveryPayload
+	if err := job.Decode(&payload); err != nil {
+		return err
+	}
+
+	var delivery notificationModels.NotificationDelivery
+	result := n.db.WithContext(ctx).Preload("Notification").Where("id = ?", payload.DeliveryID).Limit(1).Find(&delivery)
+	if result.Error != nil {
+		return fmt.Errorf("unable to find notification delivery %w", result.Error)
+	}
+	// gone with its user, or already handled by an earlier run of this job
+	if result.RowsAffected == 0 || delivery.Status != notificationModels.NotificationDeliveryPending {
+		return nil
+	}
+
+	receipt, sendErr := n.send(&delivery)
+
+	updates := map[string]interface{}{
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": "",
+	}
+	switch {
+	case sendErr == nil:
+		updates["status"] = notificationModels.NotificationDeliverySent
+		updates["receipt"] = receipt
+		updates["sent_at"] = time.Now()
+	case errors.Is(sendErr, push.ErrInvalidToken):
+		updates["status"] = notificationModels.NotificationDeliveryFailed
+		updates["last_error"] = sendErr.Error()
+	case job.Attempt >= DeliveryOptions.Retry.MaxAttempts:
+		updates["status"] = notificationModels.NotificationDeliveryFailed
+		updates["last_error"] = utils.Truncate(sendErr.Error(), utils.MaxErrorLength)
+	default:
+		updates["last_error"] = utils.Truncate(sendErr.Error(), utils.MaxErrorLength)
+	}
+
+	if err := n.db.Model(&delivery).Updates(updates).Error; err != nil {
+		return fmt.Errorf("unable to record notification delivery %w", err)
+	}
+
+	// a token the provider refused for good is never tried again
+	if errors.Is(sendErr, push.ErrInvalidToken) {
+		return n.disableDevice(n.db, delivery.DeviceID)
+	}
+	return sendErr
+}
+
+func (n *notificationService) disableDevice(db *gorm.DB, deviceId *uint) error {
+	if deviceId == nil {
+		return nil
+	}
+	if err := db.Model(&notificationModels.Device{}).
+		Where("id = ? AND disabled_at IS NULL", *deviceId).
+		Update("disabled_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("unable to disable device %w", err)
+	}
+	return nil
+}
+
+// CheckReceipts marks the push deliveries the platform did not deliver as failed and disables the devices whose
+// token stopped working after the message was accepted. A delivery without a receipt yet is looked at again next run.
+func (n *notificationService) CheckReceipts(ctx context.Context) error {
+	now := time.Now()
+	var afterId uint
+	disabled := 0
+	for ctx.Err() == nil {
+		var deliveries []notificationModels.NotificationDelivery
+		if err := n.db.WithContext(ctx).
+			Where("id > ? AND channel = ? AND status = ? AND receipt <> '' AND receipt_checked_at IS NULL", afterId, notificationModels.NotificationPush, notificationModels.NotificationDeliverySent).
+			Where("sent_at BETWEEN ? AND ?", now.Add(-receiptMaxAge), now.Add(-receiptDelay)).
+			Order("id asc").
+			Limit(push.MaxReceiptIDs).
+			Find(&deliveries).Error; err != nil {
+			return fmt.Errorf("unable to find push deliveries %w", err)
+		}
+		if len(deliveries) == 0 {
+			break
+		}
+		afterId = deliveries[len(deliveries)-1].ID
+
+		ids := make([]string, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].Receipt
+		}
+		receipts, err := n.push.Receipts(ids)
+		if err != nil {
+			return fmt.Errorf("unable to fetch push receipts: %w", err)
+		}
+
+		for i := range deliveries {
+			delivery := &deliveries[i]
+			receiptErr, ok := receipts[delivery.Receipt]
+			if !ok {
+				continue
+			}
+
+			updates := map[string]interface{}{"receipt_checked_at": now}
+			if receiptErr != nil {
+				updates["status"] = notificationModels.NotificationDeliveryFailed
+				updates["last_error"] = utils.Truncate(receiptErr.Error(), utils.MaxErrorLength)
+			}
+			err := n.db.Transaction(func(tx *gorm.DB) error {
+				if err := tx.Model(delivery).Updates(updates).Error; err != nil {
+					return fmt.Errorf("unable to record push receipt %w", err)
+				}
+				if errors.Is(receiptErr, push.ErrInvalidToken) {
+					return n.disableDevice(tx, delivery.DeviceID)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if errors.Is(receiptErr, push.ErrInvalidToken) {
+				disabled++
+			}
+		}
+
+		if len(deliveries) < push.MaxReceiptIDs {
+			break
+		}
+	}
+
+	if disabled > 0 {
+		logger.GlobalLogger.Log(logger.INFO, "Devices disabled after push receipts", map[string]interface{}{
+			"devices": disabled,
+		})
+	}
+	return ctx.Err()
+}
+
+// send hands the delivery to its channel's provider and returns the provider's receipt, where there is one
+func (n *notificationService) send(delivery *notificationModels.NotificationDelivery) (string, error) {
+	notification := &delivery.Notification
+	switch delivery.Channel {
+	case notificationModels.NotificationPush:
+		var platform string
+		if delivery.DeviceID != nil {
+			var device notificationModels.Device
+			if err := n.db.Select("platform").Where("id = ?", *delivery.DeviceID).Limit(1).Find(&device).Error; err != nil {
+				return "", fmt.Errorf("unable to find device %w", err)
+			}
+			platform = string(device.Platform)
+		}
+		return n.push.Send(push.Message{
+			Token:    delivery.Address,
+			Platform: platform,
+			Title:    notification.Title,
+			Body:     notification.Body,
+			Data:     notification.Data,
+			Critical: notification.Priority == notificationModels.NotificationCritical,
+		})
+	case notificationModels.NotificationSMS:
+		return "", n.sms.Send(delivery.Address, notification.Title+"\n"+notification.Body)
+	case notificationModels.NotificationEmail:
+		return "", n.mailer.Send(mailer.Message{To: delivery.Address, Subject: notification.Title, Body: notification.Body})
+	default:
+		return "", fmt.Errorf("unknown notification channel %q", delivery.Channel)
+	}
+}
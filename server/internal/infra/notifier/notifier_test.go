@@ -0,0 +1,233 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"resq/internal/infra/jobs"
+	"resq/internal/infra/logger"
+	"resq/internal/infra/push"
+	"resq/internal/infra/sms"
+	notificationModels "resq/pkg/models/notification"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormLogger "gorm.io/gorm/logger"
+)
+
+// newTestDB opens a throwaway SQLite database with the tables deliveries touch
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	// a logger without a file drops what it is given
+	logger.GlobalLogger = &logger.Logger{}
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "notifier.db")), &gorm.Config{
+		Logger: gormLogger.Default.LogMode(gormLogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	if err := db.AutoMigrate(&notificationModels.Device{}, &notificationModels.Notification{}, &notificationModels.NotificationDelivery{}); err != nil {
+		t.Fatalf("unable to migrate: %v", err)
+	}
+	return db
+}
+
+// pushDelivery stores a push notification to a new device, delivery tweaks its one delivery before it is saved
+func pushDelivery(t *testing.T, db *gorm.DB, token string, delivery notificationModels.NotificationDelivery) (*notificationModels.Device, *notificationModels.NotificationDelivery) {
+	t.Helper()
+	now := time.Now()
+	device := &notificationModels.Device{UserID: 1, Token: token, Platform: notificationModels.DeviceIOS, LastSeenAt: now}
+	if err := db.Create(device).Error; err != nil {
+		t.Fatalf("unable to create device: %v", err)
+	}
+
+	delivery.Channel = notificationModels.NotificationPush
+	delivery.Address = token
+	delivery.DeviceID = &device.ID
+	delivery.ScheduledFor = now
+	notification := &notificationModels.Notification{
+		Template:   "test",
+		Title:      "Flood warning",
+		Body:       "Move to higher ground",
+		Priority:   notificationModels.NotificationNormal,
+		Deliveries: []notificationModels.NotificationDelivery{delivery},
+	}
+	if err := db.Create(notification).Error; err != nil {
+		t.Fatalf("unable to create notification: %v", err)
+	}
+	return device, &notification.Deliveries[0]
+}
+
+func deliveryJob(t *testing.T, delivery *notificationModels.NotificationDelivery) jobs.Job {
+	t.Helper()
+	payload, err := json.Marshal(deliveryPayload{DeliveryID: delivery.ID})
+	if err != nil {
+		t.Fatalf("unable to encode payload: %v", err)
+	}
+	return jobs.Job{ID: 1, Kind: DeliveryJob, Payload: payload, Attempt: 1, RunAt: time.Now()}
+}
+
+func reload(t *testing.T, db *gorm.DB, delivery *notificationModels.NotificationDelivery, device *notificationModels.Device) {
+	t.Helper()
+	if err := db.First(delivery, delivery.ID).Error; err != nil {
+		t.Fatalf("unable to reload delivery: %v", err)
+	}
+	if err := db.First(device, device.ID).Error; err != nil {
+		t.Fatalf("unable to reload device: %v", err)
+	}
+}
+
+func TestDeliverSendsPush(t *testing.T) {
+	db := newTestDB(t)
+	provider := push.NewFakeProvider()
+	service := NewNotificationService(db, nil, nil, provider)
+
+	device, delivery := pushDelivery(t, db, "ExponentPushToken[working]", notificationModels.NotificationDelivery{
+		Status: notificationModels.NotificationDeliveryPending,
+	})
+
+	if err := service.Deliver(context.Background(), deliveryJob(t, delivery)); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+
+	reload(t, db, delivery, device)
+	sent, ok := provider.LastMessage("ExponentPushToken[working]")
+	if !ok || sent.Title != "Flood warning" || sent.Platform != "ios" {
+		t.Errorf("provider got %+v, want the notification sent to the iOS device", sent)
+	}
+	if delivery.Status != notificationModels.NotificationDeliverySent || delivery.Receipt != sent.ID || delivery.SentAt == nil {
+		t.Errorf("delivery = %s with receipt %q, want sent with receipt %q", delivery.Status, delivery.Receipt, sent.ID)
+	}
+	if device.DisabledAt != nil {
+		t.Error("a working device was disabled")
+	}
+}
+
+func TestDeliverSendsSMS(t *testing.T) {
+	db := newTestDB(t)
+	provider := sms.NewFakeProvider()
+	service := NewNotificationService(db, nil, provider, push.NewFakeProvider())
+
+	notification := &notificationModels.Notification{
+		Template: "test",
+		Title:    "Flood warning",
+		Body:     "Move to higher ground",
+		Priority: notificationModels.NotificationNormal,
+		Deliveries: []notificationModels.NotificationDelivery{
+			pendingDelivery(notificationModels.NotificationSMS, "+254700000001", nil, time.Now()),
+		},
+	}
+	if err := db.Create(notification).Error; err != nil {
+		t.Fatalf("unable to create notification: %v", err)
+	}
+	delivery := &notification.Deliveries[0]
+
+	if err := service.Deliver(context.Background(), deliveryJob(t, delivery)); err != nil {
+		t.Fatalf("Deliver failed: %v", err)
+	}
+	// a second run of the same job finds the delivery handled
+	if err := service.Deliver(context.Background(), deliveryJob(t, delivery)); err != nil {
+		t.Fatalf("repeated Deliver failed: %v", err)
+	}
+
+	if err := db.First(delivery, delivery.ID).Error; err != nil {
+		t.Fatalf("unable to reload delivery: %v", err)
+	}
+	if delivery.Status != notificationModels.NotificationDeliverySent {
+		t.Errorf("delivery = %s, want sent", delivery.Status)
+	}
+	if messages := provider.Messages(); len(messages) != 1 || messages[0].Body != "Flood warning\nMove to higher ground" {
+		t.Errorf("provider got %+v, want the one text", messages)
+	}
+}
+
+func TestDeliverDisablesDeviceOnInvalidToken(t *testing.T) {
+	db := newTestDB(t)
+	provider := push.NewFakeProvider()
+	provider.Invalidate("ExponentPushToken[uninstalled]")
+	service := NewNotificationService(db, nil, nil, provider)
+
+	device, delivery := pushDelivery(t, db, "ExponentPushToken[uninstalled]", notificationModels.NotificationDelivery{
+		Status: notificationModels.NotificationDeliveryPending,
+	})
+
+	// a token that will never work again is not worth a retry
+	if err := service.Deliver(context.Background(), deliveryJob(t, delivery)); err != nil {
+		t.Fatalf("Deliver = %v, want nil so the job is not retried", err)
+	}
+
+	reload(t, db, delivery, device)
+	if delivery.Status != notificationModels.NotificationDeliveryFailed || delivery.LastError != push.ErrInvalidToken.Error() {
+		t.Errorf("delivery = %s with error %q, want failed with %q", delivery.Status, delivery.LastError, push.ErrInvalidToken)
+	}
+	if delivery.Attempts != 1 {
+		t.Errorf("delivery attempts = %d, want 1", delivery.Attempts)
+	}
+	if device.DisabledAt == nil {
+		t.Error("the device was not disabled")
+	}
+	if len(provider.Messages()) != 0 {
+		t.Errorf("provider accepted %d messages, want none", len(provider.Messages()))
+	}
+}
+
+func TestCheckReceipts(t *testing.T) {
+	db := newTestDB(t)
+	provider := push.NewFakeProvider()
+	service := NewNotificationService(db, nil, nil, provider)
+
+	deliveredID, _ := provider.Send(push.Message{Token: "ExponentPushToken[kept]"})
+	goneID, _ := provider.Send(push.Message{Token: "ExponentPushToken[removed]"})
+	recentID, _ := provider.Send(push.Message{Token: "ExponentPushToken[recent]"})
+	// the app was removed after Expo accepted the message
+	provider.Invalidate("ExponentPushToken[removed]")
+	provider.Invalidate("ExponentPushToken[recent]")
+
+	sentAt := time.Now().Add(-receiptDelay - time.Minute)
+	justSent := time.Now().Add(-time.Minute)
+	sent := func(receipt string, at time.Time) notificationModels.NotificationDelivery {
+		return notificationModels.NotificationDelivery{Status: notificationModels.NotificationDeliverySent, Receipt: receipt, SentAt: &at}
+	}
+	keptDevice, delivered := pushDelivery(t, db, "ExponentPushToken[kept]", sent(deliveredID, sentAt))
+	removedDevice, gone := pushDelivery(t, db, "ExponentPushToken[removed]", sent(goneID, sentAt))
+	recentDevice, recent := pushDelivery(t, db, "ExponentPushToken[recent]", sent(recentID, justSent))
+
+	if err := service.CheckReceipts(context.Background()); err != nil {
+		t.Fatalf("CheckReceipts failed: %v", err)
+	}
+
+	reload(t, db, delivered, keptDevice)
+	if delivered.Status != notificationModels.NotificationDeliverySent || delivered.ReceiptCheckedAt == nil {
+		t.Errorf("delivered push = %s, checked %v, want sent and checked", delivered.Status, delivered.ReceiptCheckedAt)
+	}
+	if keptDevice.DisabledAt != nil {
+		t.Error("the device of a delivered push was disabled")
+	}
+
+	reload(t, db, gone, removedDevice)
+	if gone.Status != notificationModels.NotificationDeliveryFailed || gone.LastError != push.ErrInvalidToken.Error() || gone.ReceiptCheckedAt == nil {
+		t.Errorf("undelivered push = %s with error %q, want failed with %q", gone.Status, gone.LastError, push.ErrInvalidToken)
+	}
+	if removedDevice.DisabledAt == nil {
+		t.Error("the device whose token stopped working was not disabled")
+	}
+
+	// Expo has no receipt yet for a push sent moments ago, it waits for a later run
+	reload(t, db, recent, recentDevice)
+	if recent.Status != notificationModels.NotificationDeliverySent || recent.ReceiptCheckedAt != nil || recentDevice.DisabledAt != nil {
+		t.Errorf("recent push = %s, checked %v, want it left alone", recent.Status, recent.ReceiptCheckedAt)
+	}
+
+	// checked receipts are not looked up again
+	provider.Invalidate("ExponentPushToken[kept]")
+	if err := service.CheckReceipts(context.Background()); err != nil {
+		t.Fatalf("second CheckReceipts failed: %v", err)
+	}
+	reload(t, db, delivered, keptDevice)
+	if delivered.Status != notificationModels.NotificationDeliverySent || keptDevice.DisabledAt != nil {
+		t.Error("a checked receipt was looked up again")
+	}
+}
@@ -0,0 +1,34 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Template is a notification's title and body as text/template sources, rendered with the data of each request
+type Template struct {
+	Name  string
+	title *template.Template
+	body  *template.Template
+}
+
+// NewTemplate parses title and body, a broken template is a programming error and panics at start up
+func NewTemplate(name string, title string, body string) *Template {
+	return &Template{
+		Name:  name,
+		title: template.Must(template.New(name + ".title").Option("missingkey=error").Parse(title)),
+		body:  template.Must(template.New(name + ".body").Option("missingkey=error").Parse(body)),
+	}
+}
+
+func (t *Template) Render(data interface{}) (string, string, error) {
+	var title, body strings.Builder
+	if err := t.title.Execute(&title, data); err != nil {
+		return "", "", fmt.Errorf("unable to render %s title: %w", t.Name, err)
+	}
+	if err := t.body.Execute(&body, data); err != nil {
+		return "", "", fmt.Errorf("unable to render %s body: %w", t.Name, err)
+	}
+	return strings.TrimSpace(title.String()), strings.TrimSpace(body.String()), nil
+}
@@ -0,0 +1,186 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"resq/internal/infra/logger"
+	outboxModels "resq/pkg/models/outbox"
+	"resq/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// HeaderEventID carries the outbox ID of an event, it stays the same when the relay publishes an event twice
+	HeaderEventID = "event-id"
+	// HeaderAggregateType names the kind of aggregate the message key identifies
+	HeaderAggregateType = "aggregate-type"
+
+	// outboxBatchSize is how many events the relay publishes per transaction
+	outboxBatchSize = 100
+	// outboxLockID is the advisory lock that keeps a single relay draining the outbox, so events leave it in order
+	outboxLockID = 72_417_001
+	// OutboxRetention is how long published events are kept for inspection
+	OutboxRetention = 7 * 24 * time.Hour
+	// ProcessedEventRetention is how long consumers remember handled events, well beyond any redelivery
+	ProcessedEventRetention = 30 * 24 * time.Hour
+)
+
+// OutboxRelay moves committed outbox events onto the event bus in the order they were recorded.
+// Each event is keyed by its aggregate, so consumers see the events of one aggregate in order.
+type OutboxRelay struct {
+	db       *gorm.DB
+	producer Producer
+}
+
+func NewOutboxRelay(db *gorm.DB, producer Producer) *OutboxRelay {
+	return &OutboxRelay{db: db, producer: producer}
+}
+
+// Drain publishes pending events until the outbox is empty. While another instance drains it this returns right away.
+func (r *OutboxRelay) Drain(ctx context.Context) error {
+	for ctx.Err() == nil {
+		relayed, err := r.relay(ctx)
+		if err != nil {
+			return err
+		}
+		if relayed < outboxBatchSize {
+			return nil
+		}
+	}
+	return nil
+}
+
+// relay publishes one batch while holding the relay lock and marks it published in the same transaction.
+// A bus that can join the transaction gets the batch exactly once, any other may see it again when the commit fails.
+func (r *OutboxRelay) relay(ctx context.Context) (int, error) {
+	var relayed int
+	var failed *outboxModels.OutboxEvent
+	var publishErr error
+
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var locked bool
+		if err := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", outboxLockID).Scan(&locked).Error; err != nil {
+			return fmt.Errorf("unable to take the outbox lock %w", err)
+		}
+		if !locked {
+			return nil
+		}
+
+		var events []outboxModels.OutboxEvent
+		if err := tx.Where("published_at IS NULL").Order("id asc").Limit(outboxBatchSize).Find(&events).Error; err != nil {
+			return fmt.Errorf("unable to find outbox events %w", err)
+		}
+		if len(events) == 0 {
+			return nil
+		}
+
+		messages := make([]Message, len(events))
+		ids := make([]uint, len(events))
+		for i, event := range events {
+			messages[i] = outboxMessage(&event)
+			ids[i] = event.ID
+		}
+
+		if txProducer, ok := r.producer.(TxProducer); ok {
+			publishErr = txProducer.PublishTx(tx, messages...)
+		} else {
+			publishErr = r.producer.Publish(ctx, messages...)
+		}
+		if publishErr != nil {
+			// nothing after the first event may go out before it, the whole batch waits for the next run
+			failed = &events[0]
+			return publishErr
+		}
+
+		if err := tx.Model(&outboxModels.OutboxEvent{}).Where("id IN ?", ids).
+			Update("published_at", time.Now()).Error; err != nil {
+			return fmt.Errorf("unable to mark outbox events published %w", err)
+		}
+		relayed = len(events)
+		return nil
+	})
+
+	if failed != nil {
+		r.recordFailure(failed, publishErr)
+		return 0, fmt.Errorf("unable to publish outbox event %d %w", failed.ID, publishErr)
+	}
+	return relayed, err
+}
+
+// recordFailure keeps the reason the head of the outbox is stuck on the event itself
+func (r *OutboxRelay) recordFailure(event *outboxModels.OutboxEvent, err error) {
+	result := r.db.Model(event).Updates(map[string]interface{}{
+		"attempts":   gorm.Expr("attempts + 1"),
+		"last_error": utils.Truncate(err.Error(), utils.MaxErrorLength),
+	})
+	if result.Error != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to record outbox failure: %v", result.Error), map[string]interface{}{
+			"event_id": event.ID,
+		})
+	}
+}
+
+func outboxMessage(event *outboxModels.OutboxEvent) Message {
+	return Message{
+		Topic:   event.Topic,
+		Key:     event.AggregateID,
+		Payload: event.Payload,
+		Headers: map[string]string{
+			HeaderEventID:       fmt.Sprintf("%d", event.ID),
+			HeaderAggregateType: event.AggregateType,
+		},
+		CreatedAt: event.CreatedAt,
+	}
+}
+
+// Prune drops published events older than OutboxRetention and processed event records older than ProcessedEventRetention
+func (r *OutboxRelay) Prune(ctx context.Context) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("published_at < ?", now.Add(-OutboxRetention)).
+			Delete(&outboxModels.OutboxEvent{}).Error; err != nil {
+			return fmt.Errorf("unable to prune outbox events %w", err)
+		}
+		if err := tx.Where("processed_at < ?", now.Add(-ProcessedEventRetention)).
+			Delete(&outboxModels.ProcessedEvent{}).Error; err != nil {
+			return fmt.Errorf("unable to prune processed events %w", err)
+		}
+		return nil
+	})
+}
+
+// TxHandler handles a message inside the transaction that records it as processed
+type TxHandler func(ctx context.Context, tx *gorm.DB, message Message) error
+
+// Idempotent makes handler run once per event for group even though the bus delivers at least once.
+// Database changes made through tx commit together with the bookkeeping, a failing handler leaves no trace
+// and the message is retried. Side effects outside the database, like sending an email, are not covered.
+func Idempotent(db *gorm.DB, group string, handler TxHandler) Handler {
+	return func(ctx context.Context, message Message) error {
+		return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&outboxModels.ProcessedEvent{
+				ConsumerGroup: group,
+				EventID:       EventID(message),
+				ProcessedAt:   time.Now(),
+			})
+			if result.Error != nil {
+				return fmt.Errorf("unable to record processed event %w", result.Error)
+			}
+			if result.RowsAffected == 0 {
+				return nil
+			}
+			return handler(ctx, tx, message)
+		})
+	}
+}
+
+// EventID identifies the event a message carries across redeliveries and repeated relays
+func EventID(message Message) string {
+	if id, ok := message.Headers[HeaderEventID]; ok {
+		return id
+	}
+	return message.Topic + "/" + message.ID
+}
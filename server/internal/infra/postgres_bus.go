@@ -0,0 +1,309 @@
+package infra
+
+import (
+	"context"
+	"fmt"
+	"resq/internal/infra/logger"
+	busModels "resq/pkg/models/bus"
+	"resq/pkg/utils"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// postgresPollInterval is how long an idle handler waits before looking for new deliveries
+	postgresPollInterval = time.Second
+	// postgresBatchSize is how many deliveries a handler claims at once
+	postgresBatchSize = 20
+	// PostgresLease is how long a claimed delivery stays with its handler, it is handed out again when the handler takes longer
+	PostgresLease = 5 * time.Minute
+	// PostgresRetention is how long handled messages are kept for inspection
+	PostgresRetention = 7 * 24 * time.Hour
+)
+
+// claimDeliveriesSQL leases the next pending deliveries of a group. A delivery waits while an older one
+// with the same key is still pending, so each key is handled in publishing order.
+const claimDeliveriesSQL = `UPDATE bus_deliveries SET locked_until = ?, attempts = attempts + 1, updated_at = ?
+WHERE id IN (
+	SELECT d.id FROM bus_deliveries d
+	WHERE d.consumer_group = ? AND d.topic = ? AND d.status = ? AND d.available_at <= ?
+		AND (d.locked_until IS NULL OR d.locked_until < ?)
+		AND (d.key = '' OR NOT EXISTS (
+			SELECT 1 FROM bus_deliveries e
+			WHERE e.consumer_group = d.consumer_group AND e.topic = d.topic AND e.key = d.key
+				AND e.status = ? AND e.id < d.id
+		))
+	ORDER BY d.id
+	LIMIT ?
+	FOR UPDATE SKIP LOCKED
+)
+RETURNING id`
+
+// PostgresBus keeps messages and their progress per consumer group in Postgres, so several server instances
+// share the work and nothing is lost on a restart. A consumer group receives the messages published
+// after it first subscribed.
+type PostgresBus struct {
+	db      *gorm.DB
+	retry   RetryPolicy
+	mu      sync.Mutex
+	workers []postgresWorker
+	ctx     context.Context
+	running sync.WaitGroup
+}
+
+type postgresWorker struct {
+	topic   string
+	group   string
+	handler Handler
+}
+
+func NewPostgresBus(db *gorm.DB, retry RetryPolicy) *PostgresBus {
+	return &PostgresBus{db: db, retry: retry}
+}
+
+func (b *PostgresBus) Publish(ctx context.Context, messages ...Message) error {
+	return b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return PublishTx(tx, messages...)
+	})
+}
+
+func (b *PostgresBus) PublishTx(tx *gorm.DB, messages ...Message) error {
+	return PublishTx(tx, messages...)
+}
+
+// PublishTx stores messages inside the caller's transaction, they are delivered only if it commits
+func PublishTx(tx *gorm.DB, messages ...Message) error {
+	for _, message := range messages {
+		stored := &busModels.BusMessage{
+			Topic:     message.Topic,
+			Key:       message.Key,
+			Payload:   message.Payload,
+			Headers:   message.Headers,
+			CreatedAt: message.CreatedAt,
+		}
+		if stored.CreatedAt.IsZero() {
+			stored.CreatedAt = time.Now()
+		}
+		if err := tx.Create(stored).Error; err != nil {
+			return fmt.Errorf("unable to store %s message %w", message.Topic, err)
+		}
+
+		now := time.Now()
+		if err := tx.Exec("INSERT INTO bus_deliveries (message_id, topic, consumer_group, key, status, attempts, available_at, created_at, updated_at) "+
+			"SELECT ?, topic, consumer_group, ?, ?, 0, ?, ?, ? FROM bus_subscriptions WHERE topic = ?",
+			stored.ID, stored.Key, busModels.BusDeliveryPending, now, now, now, stored.Topic).Error; err != nil {
+			return fmt.Errorf("unable to queue %s message %w", message.Topic, err)
+		}
+	}
+	return nil
+}
+
+func (b *PostgresBus) Subscribe(topic string, group string, handler Handler) error {
+	subscription := &busModels.BusSubscription{Topic: topic, ConsumerGroup: group, CreatedAt: time.Now()}
+	if err := b.db.Clauses(clause.OnConflict{DoNothing: true}).Create(subscription).Error; err != nil {
+		return fmt.Errorf("unable to subscribe %s to %s %w", group, topic, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	worker := postgresWorker{topic: topic, group: group, handler: handler}
+	b.workers = append(b.workers, worker)
+	if b.ctx != nil {
+		b.launch(worker)
+	}
+	return nil
+}
+
+// Start runs every subscribed handler until ctx is done, calling it twice has no effect
+func (b *PostgresBus) Start(ctx context.Context) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.ctx != nil {
+		return
+	}
+	b.ctx = ctx
+	for _, worker := range b.workers {
+		b.launch(worker)
+	}
+}
+
+func (b *PostgresBus) Wait() {
+	b.running.Wait()
+}
+
+func (b *PostgresBus) launch(worker postgresWorker) {
+	b.running.Add(1)
+	go func(ctx context.Context) {
+		defer b.running.Done()
+
+		for {
+			claimed, err := b.work(ctx, worker)
+			if err != nil && ctx.Err() == nil {
+				logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to work through event bus deliveries: %v", err), map[string]interface{}{
+					"topic": worker.topic,
+					"group": worker.group,
+				})
+			}
+			// a full batch means more may be waiting
+			if claimed == postgresBatchSize {
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(postgresPollInterval):
+			}
+		}
+	}(b.ctx)
+}
+
+// work claims a batch of deliveries and hands them to the worker's handler one by one
+func (b *PostgresBus) work(ctx context.Context, worker postgresWorker) (int, error) {
+	now := time.Now()
+	var ids []uint
+	if err := b.db.WithContext(ctx).Raw(claimDeliveriesSQL,
+		now.Add(PostgresLease), now,
+		worker.group, worker.topic, busModels.BusDeliveryPending, now, now,
+		busModels.BusDeliveryPending, postgresBatchSize).
+		Scan(&ids).Error; err != nil {
+		return 0, fmt.Errorf("unable to claim deliveries %w", err)
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	var deliveries []busModels.BusDelivery
+	if err := b.db.WithContext(ctx).Preload("Message").Where("id IN ?", ids).Order("id asc").Find(&deliveries).Error; err != nil {
+		return len(ids), fmt.Errorf("unable to load deliveries %w", err)
+	}
+
+	for i := range deliveries {
+		if ctx.Err() != nil {
+			// the lease runs out and another instance picks the rest up
+			return len(ids), nil
+		}
+		// the batch shares one lease, each delivery renews it so a slow handler earlier on does not
+		// leave the ones after it running past a lease another instance may already have taken over
+		lockedUntil, renewed, err := b.renew(ctx, &deliveries[i])
+		if err != nil {
+			return len(ids), err
+		}
+		if !renewed {
+			continue
+		}
+		b.deliver(ctx, worker, &deliveries[i], lockedUntil)
+	}
+	return len(ids), nil
+}
+
+// renew extends the lease on a claimed delivery, it fails when the lease ran out and another handler claimed it since
+func (b *PostgresBus) renew(ctx context.Context, delivery *busModels.BusDelivery) (time.Time, bool, error) {
+	now := time.Now()
+	lockedUntil := now.Add(PostgresLease)
+	result := b.owned(b.db.WithContext(ctx), delivery).Updates(map[string]interface{}{
+		"locked_until": lockedUntil,
+		"updated_at":   now,
+	})
+	if result.Error != nil {
+		return time.Time{}, false, fmt.Errorf("unable to renew delivery lease %w", result.Error)
+	}
+	return lockedUntil, result.RowsAffected == 1, nil
+}
+
+// owned scopes an update to the delivery as long as it was not claimed again since, each claim counts an attempt
+func (b *PostgresBus) owned(db *gorm.DB, delivery *busModels.BusDelivery) *gorm.DB {
+	return db.Model(&busModels.BusDelivery{}).
+		Where("id = ? AND attempts = ? AND status = ?", delivery.ID, delivery.Attempts, busModels.BusDeliveryPending)
+}
+
+// deliver runs the handler until the lease runs out at lockedUntil and records the outcome if the lease is still held
+func (b *PostgresBus) deliver(ctx context.Context, worker postgresWorker, delivery *busModels.BusDelivery, lockedUntil time.Time) {
+	message := Message{
+		ID:        strconv.FormatUint(uint64(delivery.MessageID), 10),
+		Topic:     delivery.Message.Topic,
+		Key:       delivery.Message.Key,
+		Payload:   delivery.Message.Payload,
+		Headers:   delivery.Message.Headers,
+		CreatedAt: delivery.Message.CreatedAt,
+		Attempt:   delivery.Attempts,
+	}
+
+	handlerCtx, cancel := context.WithDeadline(ctx, lockedUntil)
+	handlerErr := handle(handlerCtx, worker.handler, message)
+	cancel()
+
+	var result error
+	switch {
+	case handlerErr == nil:
+		result = b.owned(b.db, delivery).Updates(map[string]interface{}{
+			"status":       busModels.BusDeliveryDone,
+			"done_at":      time.Now(),
+			"locked_until": nil,
+			"last_error":   "",
+		}).Error
+	case delivery.Attempts >= b.retry.MaxAttempts:
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("event handler gave up: %v", handlerErr), map[string]interface{}{
+			"topic":      message.Topic,
+			"group":      worker.group,
+			"message_id": message.ID,
+			"attempts":   delivery.Attempts,
+		})
+		result = b.db.Transaction(func(tx *gorm.DB) error {
+			updated := b.owned(tx, delivery).Updates(map[string]interface{}{
+				"status":       busModels.BusDeliveryDead,
+				"done_at":      time.Now(),
+				"locked_until": nil,
+				"last_error":   utils.Truncate(handlerErr.Error(), utils.MaxErrorLength),
+			})
+			if updated.Error != nil {
+				return updated.Error
+			}
+			// another handler claimed the delivery again and owns its outcome now
+			if updated.RowsAffected == 0 {
+				return nil
+			}
+			// dead letters that fail again stay dead without another copy
+			if strings.HasSuffix(message.Topic, DeadLetterSuffix) {
+				return nil
+			}
+			return PublishTx(tx, deadLetter(message, worker.group, handlerErr))
+		})
+	default:
+		result = b.owned(b.db, delivery).Updates(map[string]interface{}{
+			"available_at": time.Now().Add(b.retry.Backoff(delivery.Attempts)),
+			"locked_until": nil,
+			"last_error":   utils.Truncate(handlerErr.Error(), utils.MaxErrorLength),
+		}).Error
+	}
+
+	if result != nil {
+		// the lease runs out and the delivery is handed out again
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to record event delivery: %v", result), map[string]interface{}{
+			"delivery_id": delivery.ID,
+		})
+	}
+}
+
+// Prune drops handled deliveries and messages older than PostgresRetention, dead ones are kept until someone looks at them
+func (b *PostgresBus) Prune(ctx context.Context) error {
+	cutoff := time.Now().Add(-PostgresRetention)
+	return b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("status = ? AND done_at < ?", busModels.BusDeliveryDone, cutoff).
+			Delete(&busModels.BusDelivery{}).Error; err != nil {
+			return fmt.Errorf("unable to prune bus deliveries %w", err)
+		}
+		if err := tx.Where("created_at < ? AND NOT EXISTS (SELECT 1 FROM bus_deliveries WHERE bus_deliveries.message_id = bus_messages.id)", cutoff).
+			Delete(&busModels.BusMessage{}).Error; err != nil {
+			return fmt.Errorf("unable to prune bus messages %w", err)
+		}
+		return nil
+	})
+}
@@ -1 +1,69 @@
 package infra
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Message is one event on a topic. Messages with the same Key are handled in the order they were published,
+// so the ID of the aggregate an event is about makes a good key.
+type Message struct {
+	ID        string
+	Topic     string
+	Key       string
+	Payload   json.RawMessage
+	Headers   map[string]string
+	CreatedAt time.Time
+	// Attempt counts deliveries to the current consumer group, the first one is 1
+	Attempt int
+}
+
+// Decode unmarshals the payload into target
+func (m *Message) Decode(target interface{}) error {
+	if err := json.Unmarshal(m.Payload, target); err != nil {
+		return fmt.Errorf("unable to decode %s message %s: %w", m.Topic, m.ID, err)
+	}
+	return nil
+}
+
+// NewMessage encodes payload as JSON into a message for topic
+func NewMessage(topic string, key string, payload interface{}) (Message, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Message{}, fmt.Errorf("unable to encode %s message: %w", topic, err)
+	}
+	return Message{Topic: topic, Key: key, Payload: data, CreatedAt: time.Now()}, nil
+}
+
+// Producer publishes messages for every consumer group subscribed to their topic
+type Producer interface {
+	Publish(ctx context.Context, messages ...Message) error
+}
+
+// TxProducer publishes inside the caller's transaction, the messages go out only if it commits
+type TxProducer interface {
+	Producer
+	PublishTx(tx *gorm.DB, messages ...Message) error
+}
+
+// Bus is both ends of the event bus, the server runs one
+type Bus interface {
+	Producer
+	Consumer
+}
+
+var GlobalBus Bus
+
+// Emit encodes payload and publishes it, a failure is returned for the caller to log,
+// the change the event describes has already happened
+func Emit(producer Producer, topic string, key string, payload interface{}) error {
+	message, err := NewMessage(topic, key, payload)
+	if err != nil {
+		return err
+	}
+	return producer.Publish(context.Background(), message)
+}
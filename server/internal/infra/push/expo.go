@@ -0,0 +1,162 @@
+package push
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	expoEndpoint         = "https://exp.host/--/api/v2/push/send"
+	expoReceiptsEndpoint = "https://exp.host/--/api/v2/push/getReceipts"
+	// MaxReceiptIDs is how many receipts Expo looks up in one request
+	MaxReceiptIDs = 1000
+)
+
+type ExpoConfig struct {
+	// AccessToken is only needed when enhanced push security is turned on for the project
+	AccessToken string
+}
+
+// ExpoProvider sends through the Expo push service, which forwards to APNs and FCM
+type ExpoProvider struct {
+	config ExpoConfig
+	client *http.Client
+}
+
+func NewExpoProvider(config ExpoConfig) *ExpoProvider {
+	return &ExpoProvider{config: config, client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+type expoMessage struct {
+	To        string            `json:"to"`
+	Title     string            `json:"title"`
+	Body      string            `json:"body"`
+	Data      map[string]string `json:"data,omitempty"`
+	Sound     string            `json:"sound,omitempty"`
+	Priority  string            `json:"priority"`
+	ChannelID string            `json:"channelId,omitempty"`
+}
+
+type expoResponse struct {
+	Data   expoTicket `json:"data"`
+	Errors []struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// expoTicket is Expo's answer for one message, an ok ticket carries the ID delivery receipts are fetched by
+type expoTicket struct {
+	Status  string `json:"status"`
+	ID      string `json:"id"`
+	Message string `json:"message"`
+	Details struct {
+		Error string `json:"error"`
+	} `json:"details"`
+}
+
+// expoReceiptsResponse maps ticket IDs to their receipts, a receipt has the shape of a ticket without an ID
+type expoReceiptsResponse struct {
+	Data   map[string]expoTicket `json:"data"`
+	Errors []struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (e *ExpoProvider) Send(message Message) (string, error) {
+	payload := expoMessage{
+		To:       message.Token,
+		Title:    message.Title,
+		Body:     message.Body,
+		Data:     message.Data,
+		Sound:    "default",
+		Priority: "default",
+	}
+	if message.Critical {
+		payload.Priority = "high"
+		payload.ChannelID = "critical"
+	}
+
+	var result expoResponse
+	if err := e.post(expoEndpoint, payload, &result); err != nil {
+		return "", err
+	}
+	if len(result.Errors) > 0 {
+		return "", fmt.Errorf("expo rejected the message: %s %s", result.Errors[0].Code, result.Errors[0].Message)
+	}
+
+	ticket := result.Data
+	if ticket.Status != "ok" {
+		if ticket.Details.Error == "DeviceNotRegistered" {
+			return "", ErrInvalidToken
+		}
+		return "", fmt.Errorf("expo refused the message: %s %s", ticket.Details.Error, ticket.Message)
+	}
+	return ticket.ID, nil
+}
+
+// Receipts fetches the receipts of up to MaxReceiptIDs tickets, Expo keeps them for a day after sending
+func (e *ExpoProvider) Receipts(ids []string) (map[string]error, error) {
+	if len(ids) > MaxReceiptIDs {
+		return nil, fmt.Errorf("expo looks up at most %d receipts at once, got %d", MaxReceiptIDs, len(ids))
+	}
+
+	var result expoReceiptsResponse
+	if err := e.post(expoReceiptsEndpoint, map[string][]string{"ids": ids}, &result); err != nil {
+		return nil, err
+	}
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("expo rejected the receipt request: %s %s", result.Errors[0].Code, result.Errors[0].Message)
+	}
+
+	receipts := make(map[string]error, len(result.Data))
+	for id, receipt := range result.Data {
+		switch {
+		case receipt.Status == "ok":
+			receipts[id] = nil
+		case receipt.Details.Error == "DeviceNotRegistered":
+			receipts[id] = ErrInvalidToken
+		default:
+			receipts[id] = fmt.Errorf("expo could not deliver the message: %s %s", receipt.Details.Error, receipt.Message)
+		}
+	}
+	return receipts, nil
+}
+
+// post sends payload to an Expo endpoint and decodes the answer into result
+func (e *ExpoProvider) post(endpoint string, payload interface{}, result interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("Accept", "application/json")
+	if e.config.AccessToken != "" {
+		request.Header.Set("Authorization", "Bearer "+e.config.AccessToken)
+	}
+
+	response, err := e.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("unable to reach expo: %w", err)
+	}
+	defer response.Body.Close()
+
+	raw, _ := io.ReadAll(io.LimitReader(response.Body, 1024*1024))
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("expo rejected the request with status %d: %s", response.StatusCode, strings.TrimSpace(string(raw)))
+	}
+	if err := json.Unmarshal(raw, result); err != nil {
+		return fmt.Errorf("unable to read expo response: %w", err)
+	}
+	return nil
+}
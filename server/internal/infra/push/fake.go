@@ -0,0 +1,97 @@
+package push
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type SentMessage struct {
+	ID string
+	Message
+	SentAt time.Time
+}
+
+// FakeProvider keeps every notification in memory and echoes it to the console, nothing leaves the machine.
+// Tokens marked invalid are refused with ErrInvalidToken.
+type FakeProvider struct {
+	mu       sync.Mutex
+	messages []SentMessage
+	invalid  map[string]bool
+	out      io.Writer
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{invalid: map[string]bool{}, out: os.Stdout}
+}
+
+func (f *FakeProvider) Send(message Message) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.invalid[message.Token] {
+		return "", ErrInvalidToken
+	}
+
+	sent := SentMessage{ID: "fake-" + strconv.Itoa(len(f.messages)+1), Message: message, SentAt: time.Now()}
+	f.messages = append(f.messages, sent)
+
+	if f.out != nil {
+		fmt.Fprintf(f.out, "----- push %s -----\nTo: %s (%s)\n%s\n\n%s\n-----\n", sent.SentAt.Format(time.RFC3339), message.Token, message.Platform, message.Title, message.Body)
+	}
+	return sent.ID, nil
+}
+
+// Receipts reports every sent message as delivered, unless its token was invalidated since
+func (f *FakeProvider) Receipts(ids []string) (map[string]error, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	wanted := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	receipts := map[string]error{}
+	for _, sent := range f.messages {
+		if !wanted[sent.ID] {
+			continue
+		}
+		receipts[sent.ID] = nil
+		if f.invalid[sent.Token] {
+			receipts[sent.ID] = ErrInvalidToken
+		}
+	}
+	return receipts, nil
+}
+
+// Invalidate makes later sends to token fail the way an uninstalled app does
+func (f *FakeProvider) Invalidate(token string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.invalid[token] = true
+}
+
+func (f *FakeProvider) Messages() []SentMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]SentMessage{}, f.messages...)
+}
+
+// LastMessage returns the newest notification sent to a token
+func (f *FakeProvider) LastMessage(token string) (SentMessage, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := len(f.messages) - 1; i >= 0; i-- {
+		if f.messages[i].Token == token {
+			return f.messages[i], true
+		}
+	}
+	return SentMessage{}, false
+}
@@ -0,0 +1,26 @@
+package push
+
+import "errors"
+
+// ErrInvalidToken means the device token will never work again, the app was removed or the token rotated
+var ErrInvalidToken = errors.New("push token is no longer valid")
+
+type Message struct {
+	Token    string
+	Platform string
+	Title    string
+	Body     string
+	Data     map[string]string
+	// Critical asks the device to sound even when it is muted, where the platform allows it
+	Critical bool
+}
+
+// Provider delivers a push notification to one device and returns the provider's ID of the message
+type Provider interface {
+	Send(message Message) (string, error)
+	// Receipts looks up what became of sent messages by their IDs. A message missing from the result has no receipt yet,
+	// nil means it reached the platform and ErrInvalidToken that the device token stopped working on the way.
+	Receipts(ids []string) (map[string]error, error)
+}
+
+var GlobalProvider Provider = NewFakeProvider()
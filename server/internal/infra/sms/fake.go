@@ -0,0 +1,59 @@
+package sms
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+type SentMessage struct {
+	To     string
+	Body   string
+	SentAt time.Time
+}
+
+// FakeProvider keeps every message in memory and echoes it to the console, nothing leaves the machine
+type FakeProvider struct {
+	mu       sync.Mutex
+	messages []SentMessage
+	out      io.Writer
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{out: os.Stdout}
+}
+
+func (f *FakeProvider) Send(to string, body string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	message := SentMessage{To: to, Body: body, SentAt: time.Now()}
+	f.messages = append(f.messages, message)
+
+	if f.out != nil {
+		fmt.Fprintf(f.out, "----- sms %s -----\nTo: %s\n\n%s\n-----\n", message.SentAt.Format(time.RFC3339), to, body)
+	}
+	return nil
+}
+
+func (f *FakeProvider) Messages() []SentMessage {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]SentMessage{}, f.messages...)
+}
+
+// LastMessage returns the newest message sent to a number
+func (f *FakeProvider) LastMessage(to string) (SentMessage, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for i := len(f.messages) - 1; i >= 0; i-- {
+		if f.messages[i].To == to {
+			return f.messages[i], true
+		}
+	}
+	return SentMessage{}, false
+}
@@ -0,0 +1,8 @@
+package sms
+
+// Provider delivers a text message to an E.164 phone number
+type Provider interface {
+	Send(to string, body string) error
+}
+
+var GlobalProvider Provider = NewFakeProvider()
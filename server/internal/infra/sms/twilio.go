@@ -0,0 +1,56 @@
+package sms
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+type TwilioConfig struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+}
+
+type TwilioProvider struct {
+	config TwilioConfig
+	client *http.Client
+}
+
+func NewTwilioProvider(config TwilioConfig) (*TwilioProvider, error) {
+	if config.AccountSID == "" || config.AuthToken == "" || config.From == "" {
+		return nil, fmt.Errorf("twilio provider needs an account sid, auth token and from number")
+	}
+	return &TwilioProvider{config: config, client: &http.Client{Timeout: 15 * time.Second}}, nil
+}
+
+func (t *TwilioProvider) Send(to string, body string) error {
+	endpoint := fmt.Sprintf("https://api.twilio.com/2010-04-01/Accounts/%s/Messages.json", url.PathEscape(t.config.AccountSID))
+
+	form := url.Values{}
+	form.Set("From", t.config.From)
+	form.Set("To", to)
+	form.Set("Body", body)
+
+	request, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth(t.config.AccountSID, t.config.AuthToken)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	response, err := t.client.Do(request)
+	if err != nil {
+		return fmt.Errorf("unable to reach twilio: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+		return fmt.Errorf("twilio rejected the message with status %d: %s", response.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+type LocalStorage struct {
+	root string
+}
+
+func NewLocalStorage(root string) (*LocalStorage, error) {
+	if err := os.MkdirAll(root, 0750); err != nil {
+		return nil, fmt.Errorf("unable to create storage directory: %w", err)
+	}
+	return &LocalStorage{root: root}, nil
+}
+
+func (l *LocalStorage) path(key string) (string, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(l.root, filepath.FromSlash(cleaned)), nil
+}
+
+// Save writes to a temporary file first so readers never see a half written object
+func (l *LocalStorage) Save(key string, content io.Reader) (int64, error) {
+	objectPath, err := l.path(key)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(objectPath), 0750); err != nil {
+		return 0, fmt.Errorf("unable to create object directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(objectPath), ".upload-*")
+	if err != nil {
+		return 0, fmt.Errorf("unable to create object: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, content)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("unable to write object: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), objectPath); err != nil {
+		return 0, fmt.Errorf("unable to store object: %w", err)
+	}
+	return written, nil
+}
+
+func (l *LocalStorage) Open(key string) (io.ReadCloser, error) {
+	objectPath, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(objectPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrObjectNotFound
+		}
+		return nil, fmt.Errorf("unable to open object: %w", err)
+	}
+	return file, nil
+}
+
+func (l *LocalStorage) Delete(key string) error {
+	objectPath, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(objectPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to delete object: %w", err)
+	}
+	return nil
+}
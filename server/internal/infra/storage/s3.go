@@ -0,0 +1,286 @@
+package storage
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3Service         = "s3"
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3EmptyPayload    = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+type S3Config struct {
+	Endpoint  string // e.g. https://s3.eu-west-1.amazonaws.com or http://localhost:9000 for MinIO
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// PathStyle addresses objects as endpoint/bucket/key, which is what MinIO and most stand-ins expect
+	PathStyle bool
+}
+
+// S3Storage talks to any S3 compatible API using signature version 4
+type S3Storage struct {
+	config   S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+func NewS3Storage(config S3Config) (*S3Storage, error) {
+	if config.Bucket == "" || config.AccessKey == "" || config.SecretKey == "" {
+		return nil, errors.New("s3 storage needs a bucket, access key and secret key")
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	endpoint, err := url.Parse(config.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("invalid s3 endpoint %q", config.Endpoint)
+	}
+
+	return &S3Storage{
+		config:   config,
+		endpoint: endpoint,
+		client:   &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *S3Storage) objectURL(key string) (*url.URL, error) {
+	cleaned, err := CleanKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	objectURL := *s.endpoint
+	if s.config.PathStyle {
+		objectURL.Path = "/" + s.config.Bucket + "/" + cleaned
+	} else {
+		objectURL.Host = s.config.Bucket + "." + s.endpoint.Host
+		objectURL.Path = "/" + cleaned
+	}
+	return &objectURL, nil
+}
+
+// Save needs the object size up front, readers that cannot seek are spooled to a temporary file first
+func (s *S3Storage) Save(key string, content io.Reader) (int64, error) {
+	body, size, cleanup, err := sizedBody(content)
+	if err != nil {
+		return 0, err
+	}
+	defer cleanup()
+
+	objectURL, err := s.objectURL(key)
+	if err != nil {
+		return 0, err
+	}
+
+	request, err := http.NewRequest(http.MethodPut, objectURL.String(), body)
+	if err != nil {
+		return 0, err
+	}
+	request.ContentLength = size
+
+	response, err := s.do(request, s3UnsignedPayload)
+	if err != nil {
+		return 0, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return 0, s3Error("put", response)
+	}
+	return size, nil
+}
+
+func (s *S3Storage) Open(key string) (io.ReadCloser, error) {
+	objectURL, err := s.objectURL(key)
+	if err != nil {
+		return nil, err
+	}
+
+	request, err := http.NewRequest(http.MethodGet, objectURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.do(request, s3EmptyPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode == http.StatusNotFound {
+		response.Body.Close()
+		return nil, ErrObjectNotFound
+	}
+	if response.StatusCode != http.StatusOK {
+		defer response.Body.Close()
+		return nil, s3Error("get", response)
+	}
+	return response.Body, nil
+}
+
+func (s *S3Storage) Delete(key string) error {
+	objectURL, err := s.objectURL(key)
+	if err != nil {
+		return err
+	}
+
+	request, err := http.NewRequest(http.MethodDelete, objectURL.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := s.do(request, s3EmptyPayload)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusNoContent && response.StatusCode != http.StatusOK && response.StatusCode != http.StatusNotFound {
+		return s3Error("delete", response)
+	}
+	return nil
+}
+
+func (s *S3Storage) do(request *http.Request, payloadHash string) (*http.Response, error) {
+	s.sign(request, payloadHash, time.Now().UTC())
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach s3: %w", err)
+	}
+	return response, nil
+}
+
+// sign adds an AWS signature version 4 Authorization header to the request
+func (s *S3Storage) sign(request *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	request.Header.Set("Host", request.URL.Host)
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := []string{"host", "x-amz-content-sha256", "x-amz-date"}
+	var canonicalHeaders strings.Builder
+	for _, header := range signedHeaders {
+		value := request.Header.Get(header)
+		if header == "host" {
+			value = request.URL.Host
+		}
+		canonicalHeaders.WriteString(header + ":" + strings.TrimSpace(value) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		s3EscapePath(request.URL.Path),
+		canonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		strings.Join(signedHeaders, ";"),
+		payloadHash,
+	}, "\n")
+
+	scope := strings.Join([]string{date, s.config.Region, s3Service, "aws4_request"}, "/")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{s3Algorithm, amzDate, scope, hex.EncodeToString(requestHash[:])}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+s.config.SecretKey), date)
+	signingKey = hmacSHA256(signingKey, s.config.Region)
+	signingKey = hmacSHA256(signingKey, s3Service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.config.AccessKey, scope, strings.Join(signedHeaders, ";"), signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// s3Escape encodes everything but the unreserved characters, which is what S3 expects of path segments and query strings
+func s3Escape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+// s3EscapePath encodes every segment the way S3 expects, leaving the separators alone
+func s3EscapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(values url.Values) string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		for _, value := range values[key] {
+			// unlike in the path a slash in the query is encoded too
+			pairs = append(pairs, s3Escape(key)+"="+s3Escape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func sizedBody(content io.Reader) (io.Reader, int64, func(), error) {
+	if seeker, ok := content.(io.ReadSeeker); ok {
+		current, err := seeker.Seek(0, io.SeekCurrent)
+		if err == nil {
+			end, err := seeker.Seek(0, io.SeekEnd)
+			if err == nil {
+				if _, err := seeker.Seek(current, io.SeekStart); err == nil {
+					return seeker, end - current, func() {}, nil
+				}
+			}
+		}
+	}
+
+	spool, err := os.CreateTemp("", "resq-s3-*")
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("unable to spool object: %w", err)
+	}
+	cleanup := func() {
+		spool.Close()
+		os.Remove(spool.Name())
+	}
+
+	size, err := io.Copy(spool, content)
+	if err == nil {
+		_, err = spool.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		cleanup()
+		return nil, 0, nil, fmt.Errorf("unable to spool object: %w", err)
+	}
+	return spool, size, cleanup, nil
+}
+
+func s3Error(operation string, response *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+	return fmt.Errorf("s3 %s failed with status %d: %s", operation, response.StatusCode, strings.TrimSpace(string(message)))
+}
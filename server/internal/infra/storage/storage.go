@@ -0,0 +1,33 @@
+package storage
+
+import (
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+var ErrObjectNotFound = errors.New("object not found")
+
+// Storage keeps uploaded blobs, keys are slash separated paths such as reports/12/<sha256>.jpg
+type Storage interface {
+	Save(key string, content io.Reader) (int64, error)
+	Open(key string) (io.ReadCloser, error)
+	Delete(key string) error
+}
+
+var GlobalStorage Storage
+
+// UploadStagingDir is where partially received uploads are kept until they are complete
+var UploadStagingDir = "uploads-staging"
+
+// CleanKey rejects keys that could escape the storage root
+func CleanKey(key string) (string, error) {
+	cleaned := path.Clean("/" + strings.TrimSpace(key))
+	cleaned = strings.TrimPrefix(cleaned, "/")
+
+	if cleaned == "" || cleaned == "." || strings.Contains(key, "..") {
+		return "", errors.New("invalid storage key")
+	}
+	return cleaned, nil
+}
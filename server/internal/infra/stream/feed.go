@@ -0,0 +1,17 @@
+package stream
+
+import (
+	feedModels "resq/pkg/models/feed"
+	"strconv"
+)
+
+// PublishFeed pushes stored feed events to their recipients who are connected right now
+func PublishFeed(hub *Hub, events []feedModels.FeedEvent) {
+	for i := range events {
+		hub.Publish(feedModels.Topic(events[i].UserID), Event{
+			ID:   strconv.FormatUint(uint64(events[i].ID), 10),
+			Name: string(events[i].Kind),
+			Data: events[i].ToDTO(),
+		})
+	}
+}
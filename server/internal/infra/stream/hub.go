@@ -0,0 +1,128 @@
+package stream
+
+import (
+	"sync"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind before it is dropped
+const subscriberBuffer = 64
+
+// Event is one message on a topic, ID lets a reconnecting client say where it left off
+type Event struct {
+	ID   string
+	Name string
+	Data interface{}
+}
+
+// Broadcaster carries the events published on this instance to the hubs of the other instances
+type Broadcaster interface {
+	// Broadcast must not block, like Publish
+	Broadcast(topic string, event Event)
+}
+
+// Hub fans events out to the subscribers of a topic inside this process, and through its Broadcaster,
+// when it has one, to the subscribers connected to the other instances
+type Hub struct {
+	mu          sync.RWMutex
+	topics      map[string]map[chan Event]struct{}
+	broadcaster Broadcaster
+}
+
+var GlobalHub = NewHub()
+
+func NewHub() *Hub {
+	return &Hub{topics: map[string]map[chan Event]struct{}{}}
+}
+
+// Subscribe returns the events published to topic from now on and a function that stops them.
+// The channel is closed when the subscriber falls too far behind, it should reconnect and catch up from storage.
+func (h *Hub) Subscribe(topic string) (<-chan Event, func()) {
+	events := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.topics[topic] == nil {
+		h.topics[topic] = map[chan Event]struct{}{}
+	}
+	h.topics[topic][events] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() { h.remove(topic, events) })
+	}
+}
+
+func (h *Hub) remove(topic string, events chan Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	subscribers := h.topics[topic]
+	if _, ok := subscribers[events]; !ok {
+		return
+	}
+	delete(subscribers, events)
+	close(events)
+	if len(subscribers) == 0 {
+		delete(h.topics, topic)
+	}
+}
+
+// SetBroadcaster makes Publish hand every event to broadcaster as well
+func (h *Hub) SetBroadcaster(broadcaster Broadcaster) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.broadcaster = broadcaster
+}
+
+// Publish never blocks, subscribers whose buffer is full are dropped
+func (h *Hub) Publish(topic string, event Event) {
+	h.deliver(topic, event)
+
+	h.mu.RLock()
+	broadcaster := h.broadcaster
+	h.mu.RUnlock()
+	if broadcaster != nil {
+		broadcaster.Broadcast(topic, event)
+	}
+}
+
+// deliver hands the event to the subscribers connected to this instance
+func (h *Hub) deliver(topic string, event Event) {
+	h.mu.RLock()
+	var lagging []chan Event
+	for events := range h.topics[topic] {
+		select {
+		case events <- event:
+		default:
+			lagging = append(lagging, events)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, events := range lagging {
+		h.remove(topic, events)
+	}
+}
+
+// drop closes the subscribers of topic, of every topic when it is empty, they reconnect and catch up from storage
+func (h *Hub) drop(topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for name, subscribers := range h.topics {
+		if topic != "" && name != topic {
+			continue
+		}
+		for events := range subscribers {
+			close(events)
+		}
+		delete(h.topics, name)
+	}
+}
+
+// Subscribers reports how many subscribers a topic has
+func (h *Hub) Subscribers(topic string) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.topics[topic])
+}
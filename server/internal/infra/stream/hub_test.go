@@ -0,0 +1,156 @@
+package stream
+
+import (
+	"encoding/json"
+	"os"
+	"resq/internal/infra/logger"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	// a logger without a file drops what it is given
+	logger.GlobalLogger = &logger.Logger{}
+	os.Exit(m.Run())
+}
+
+type recordingBroadcaster struct {
+	topics []string
+	events []Event
+}
+
+func (b *recordingBroadcaster) Broadcast(topic string, event Event) {
+	b.topics = append(b.topics, topic)
+	b.events = append(b.events, event)
+}
+
+// newTestFanout returns a fanout that is never connected, what it would send piles up in its buffer
+func newTestFanout(t *testing.T, hub *Hub) *PostgresFanout {
+	t.Helper()
+	fanout, err := NewPostgresFanout(nil, hub)
+	if err != nil {
+		t.Fatalf("NewPostgresFanout failed: %v", err)
+	}
+	return fanout
+}
+
+func sent(t *testing.T, fanout *PostgresFanout) notice {
+	t.Helper()
+	select {
+	case payload := <-fanout.outgoing:
+		var n notice
+		if err := json.Unmarshal([]byte(payload), &n); err != nil {
+			t.Fatalf("unable to decode %q: %v", payload, err)
+		}
+		return n
+	default:
+		t.Fatal("nothing was queued")
+		return notice{}
+	}
+}
+
+func TestPublishBroadcasts(t *testing.T) {
+	hub := NewHub()
+	broadcaster := &recordingBroadcaster{}
+	hub.SetBroadcaster(broadcaster)
+	events, stop := hub.Subscribe("feed:1")
+	defer stop()
+
+	hub.Publish("feed:1", Event{ID: "7", Name: "report.updated"})
+
+	if got := <-events; got.ID != "7" {
+		t.Errorf("local subscriber got %+v, want event 7", got)
+	}
+	if len(broadcaster.events) != 1 || broadcaster.topics[0] != "feed:1" || broadcaster.events[0].ID != "7" {
+		t.Errorf("broadcaster got %v %+v, want event 7 on feed:1", broadcaster.topics, broadcaster.events)
+	}
+}
+
+func TestFanoutDeliversEventsOfOtherInstances(t *testing.T) {
+	publishing, receiving := NewHub(), NewHub()
+	sender, receiver := newTestFanout(t, publishing), newTestFanout(t, receiving)
+	publishing.SetBroadcaster(sender)
+	events, stop := receiving.Subscribe("feed:1")
+	defer stop()
+
+	publishing.Publish("feed:1", Event{ID: "7", Name: "report.updated", Data: map[string]string{"status": "resolved"}})
+	payload := <-sender.outgoing
+
+	// an instance hears its own notifications too
+	sender.receive(payload)
+	receiver.receive(payload)
+
+	got := <-events
+	if got.ID != "7" || got.Name != "report.updated" {
+		t.Errorf("subscriber got %+v, want event 7", got)
+	}
+	if data, _ := json.Marshal(got.Data); string(data) != `{"status":"resolved"}` {
+		t.Errorf("subscriber got data %s, want the published data", data)
+	}
+	if publishing.Subscribers("feed:1") != 0 {
+		t.Error("the publishing hub gained subscribers")
+	}
+}
+
+func TestFanoutIgnoresItsOwnEvents(t *testing.T) {
+	hub := NewHub()
+	fanout := newTestFanout(t, hub)
+	events, stop := hub.Subscribe("feed:1")
+	defer stop()
+
+	fanout.Broadcast("feed:1", Event{ID: "7"})
+	fanout.receive(<-fanout.outgoing)
+
+	select {
+	case event := <-events:
+		t.Errorf("subscriber got its instance's own event %+v again", event)
+	default:
+	}
+}
+
+func TestFanoutResyncClosesSubscribers(t *testing.T) {
+	hub := NewHub()
+	fanout := newTestFanout(t, hub)
+	resynced, stopResynced := hub.Subscribe("feed:1")
+	defer stopResynced()
+	other, stopOther := hub.Subscribe("feed:2")
+	defer stopOther()
+
+	payload, _ := json.Marshal(notice{Origin: "elsewhere", Topic: "feed:1", Resync: true})
+	fanout.receive(string(payload))
+
+	if _, ok := <-resynced; ok {
+		t.Error("the subscriber of the resynced topic is still open")
+	}
+	if hub.Subscribers("feed:2") != 1 {
+		t.Error("a subscriber of another topic was closed")
+	}
+
+	payload, _ = json.Marshal(notice{Origin: "elsewhere", Resync: true})
+	fanout.receive(string(payload))
+	if _, ok := <-other; ok {
+		t.Error("a resync of every topic left a subscriber open")
+	}
+}
+
+func TestFanoutResyncsLargeEvents(t *testing.T) {
+	fanout := newTestFanout(t, NewHub())
+
+	fanout.Broadcast("feed:1", Event{ID: "7", Data: strings.Repeat("x", maxNotifyPayload)})
+
+	if n := sent(t, fanout); !n.Resync || n.Topic != "feed:1" || n.Data != nil {
+		t.Errorf("queued %+v, want a resync of feed:1", n)
+	}
+}
+
+func TestFanoutOverflowAsksForResync(t *testing.T) {
+	fanout := newTestFanout(t, NewHub())
+
+	for i := 0; i <= outgoingBuffer; i++ {
+		fanout.Broadcast("feed:1", Event{ID: "7"})
+	}
+
+	if !fanout.lost.Load() {
+		t.Error("an event that did not fit the buffer was not marked lost")
+	}
+}
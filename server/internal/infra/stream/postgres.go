@@ -0,0 +1,190 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"resq/internal/infra/logger"
+	"resq/pkg/utils"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"gorm.io/gorm"
+)
+
+const (
+	// notifyChannel is the Postgres channel the instances share stream events on
+	notifyChannel = "stream_events"
+	// maxNotifyPayload keeps a notification under the 8000 bytes Postgres accepts
+	maxNotifyPayload = 7900
+	// outgoingBuffer is how many events may wait to be sent before the other instances are told to resync
+	outgoingBuffer = 1024
+)
+
+// notice is one event on its way between instances
+type notice struct {
+	Origin string          `json:"origin"`
+	Topic  string          `json:"topic,omitempty"`
+	ID     string          `json:"id,omitempty"`
+	Name   string          `json:"name,omitempty"`
+	Data   json.RawMessage `json:"data,omitempty"`
+	// Resync closes the subscribers of Topic, of every topic when it is empty, they catch up from storage
+	Resync bool `json:"resync,omitempty"`
+}
+
+// PostgresFanout carries the events published on one instance to the subscribers connected to the others
+// over Postgres notifications. Notifications are not stored, an instance that may have missed some closes
+// its subscribers so they reconnect and catch up from storage the way a lagging subscriber does.
+type PostgresFanout struct {
+	db       *gorm.DB
+	hub      *Hub
+	origin   string
+	outgoing chan string
+	// lost is set when an event could not be sent, the other instances are told to resync before the next one
+	lost atomic.Bool
+}
+
+func NewPostgresFanout(db *gorm.DB, hub *Hub) (*PostgresFanout, error) {
+	origin, err := utils.GenerateRandomToken(8)
+	if err != nil {
+		return nil, fmt.Errorf("unable to name stream fanout %w", err)
+	}
+	return &PostgresFanout{
+		db:       db,
+		hub:      hub,
+		origin:   origin,
+		outgoing: make(chan string, outgoingBuffer),
+	}, nil
+}
+
+// Broadcast queues the event for Send, it never blocks
+func (f *PostgresFanout) Broadcast(topic string, event Event) {
+	payload, err := f.encode(topic, event)
+	if err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to encode stream event: %v", err), map[string]interface{}{
+			"topic": topic,
+		})
+		payload, _ = json.Marshal(notice{Origin: f.origin, Topic: topic, Resync: true})
+	}
+
+	select {
+	case f.outgoing <- string(payload):
+	default:
+		f.lost.Store(true)
+	}
+}
+
+// encode turns an event too large for a notification into a resync of its topic
+func (f *PostgresFanout) encode(topic string, event Event) ([]byte, error) {
+	data, err := json.Marshal(event.Data)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(notice{Origin: f.origin, Topic: topic, ID: event.ID, Name: event.Name, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > maxNotifyPayload {
+		return json.Marshal(notice{Origin: f.origin, Topic: topic, Resync: true})
+	}
+	return payload, nil
+}
+
+// Send notifies the other instances of the queued events until ctx is done
+func (f *PostgresFanout) Send(ctx context.Context) error {
+	for {
+		if f.lost.Swap(false) {
+			resync, _ := json.Marshal(notice{Origin: f.origin, Resync: true})
+			if err := f.notify(ctx, string(resync)); err != nil {
+				f.lost.Store(true)
+				return err
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case payload := <-f.outgoing:
+			if err := f.notify(ctx, payload); err != nil {
+				f.lost.Store(true)
+				return err
+			}
+		}
+	}
+}
+
+func (f *PostgresFanout) notify(ctx context.Context, payload string) error {
+	if err := f.db.WithContext(ctx).Exec("SELECT pg_notify(?, ?)", notifyChannel, payload).Error; err != nil {
+		return fmt.Errorf("unable to send stream event %w", err)
+	}
+	return nil
+}
+
+// Listen delivers the events the other instances publish to the subscribers of this one until ctx is done.
+// It holds a connection of its own for as long as it listens.
+func (f *PostgresFanout) Listen(ctx context.Context) error {
+	sqlDB, err := f.db.DB()
+	if err != nil {
+		return fmt.Errorf("unable to get database %w", err)
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to get connection %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		pgConn, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("stream fanout needs a pgx connection, got %T", driverConn)
+		}
+		return f.listen(ctx, pgConn.Conn())
+	})
+}
+
+func (f *PostgresFanout) listen(ctx context.Context, conn *pgx.Conn) error {
+	if _, err := conn.Exec(ctx, "LISTEN "+notifyChannel); err != nil {
+		return fmt.Errorf("unable to listen for stream events %w", err)
+	}
+	defer func() {
+		// the connection goes back to the pool
+		if !conn.IsClosed() {
+			unlistenCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			conn.Exec(unlistenCtx, "UNLISTEN *")
+		}
+	}()
+
+	// whatever was published while this instance was not listening is gone
+	f.hub.drop("")
+
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("unable to receive stream events %w", err)
+		}
+		f.receive(notification.Payload)
+	}
+}
+
+func (f *PostgresFanout) receive(payload string) {
+	var n notice
+	if err := json.Unmarshal([]byte(payload), &n); err != nil {
+		logger.GlobalLogger.Log(logger.ERROR, fmt.Sprintf("unable to decode stream event: %v", err))
+		return
+	}
+	// this instance delivered its own events when they were published
+	if n.Origin == f.origin {
+		return
+	}
+	if n.Resync {
+		f.hub.drop(n.Topic)
+		return
+	}
+	f.hub.deliver(n.Topic, Event{ID: n.ID, Name: n.Name, Data: n.Data})
+}
@@ -2,4 +2,29 @@ package constants
 
 import "os"
 
-var JWTSecretKey = []byte(os.Getenv("JWT_SECRET"))
+// MediaSigningKey signs media download URLs, it falls back to JWT_SECRET, which tokens no longer use, when no dedicated key is set.
+// It is read on every call so values loaded from .env after start up are picked up.
+func MediaSigningKey() []byte {
+	if key := os.Getenv("MEDIA_SIGNING_KEY"); key != "" {
+		return []byte(key)
+	}
+	return []byte(os.Getenv("JWT_SECRET"))
+}
+
+// AppBaseURL is where links in emails point, e.g. https://app.resq.example
+func AppBaseURL() string {
+	return os.Getenv("APP_BASE_URL")
+}
+
+// CAPSender identifies ResQ as the sender of the CAP messages it exports, e.g. alerts@resq.example
+func CAPSender() string {
+	if sender := os.Getenv("CAP_SENDER"); sender != "" {
+		return sender
+	}
+	return "resq"
+}
+
+// APIBaseURL is where this API is reached from outside, e.g. https://api.resq.example, feeds link to it
+func APIBaseURL() string {
+	return os.Getenv("API_BASE_URL")
+}
@@ -0,0 +1,14 @@
+package constants
+
+// Topics of the domain events published on the event bus, payloads are the matching dto event types
+const (
+	TopicReportCreated       = "report.created"
+	TopicReportStatusChanged = "report.status_changed"
+	TopicUserRegistered      = "user.registered"
+)
+
+// Aggregates the domain events are about, the events of one aggregate are published in the order they happened
+const (
+	AggregateReport = "report"
+	AggregateUser   = "user"
+)
@@ -0,0 +1,54 @@
+package constants
+
+type Role string
+
+const (
+	RoleCitizen    Role = "citizen"
+	RoleResponder  Role = "responder"
+	RoleDispatcher Role = "dispatcher"
+	RoleAdmin      Role = "admin"
+)
+
+type Permission string
+
+const (
+	PermissionReportsReadAny      Permission = "reports:read_any"
+	PermissionReportsUpdateStatus Permission = "reports:update_status"
+	PermissionCategoriesManage    Permission = "categories:manage"
+	PermissionUsersManage         Permission = "users:manage"
+	// PermissionCheckInsArea starts check-ins for everyone in an area, anyone may check in on their own contacts
+	PermissionCheckInsArea  Permission = "checkins:area"
+	PermissionAlertsPublish Permission = "alerts:publish"
+)
+
+// RolePermissions is what each role may do on top of what every signed in user can do
+var RolePermissions = map[Role][]Permission{
+	RoleCitizen: {},
+	RoleResponder: {
+		PermissionReportsReadAny,
+		PermissionReportsUpdateStatus,
+	},
+	RoleDispatcher: {
+		PermissionReportsReadAny,
+		PermissionReportsUpdateStatus,
+		PermissionCheckInsArea,
+		PermissionAlertsPublish,
+	},
+	RoleAdmin: {
+		PermissionReportsReadAny,
+		PermissionReportsUpdateStatus,
+		PermissionCategoriesManage,
+		PermissionUsersManage,
+		PermissionCheckInsArea,
+		PermissionAlertsPublish,
+	},
+}
+
+func (r Role) IsValid() bool {
+	_, exists := RolePermissions[r]
+	return exists
+}
+
+func (r Role) Permissions() []Permission {
+	return RolePermissions[r]
+}
@@ -0,0 +1,90 @@
+package dto
+
+import "time"
+
+type AlertPointDTO struct {
+	Latitude  float64 `json:"latitude" binding:"min=-90,max=90"`
+	Longitude float64 `json:"longitude" binding:"min=-180,max=180"`
+}
+
+// AlertAreaRequestDTO is either a circle, given by its centre and radius, or a polygon of at least three points
+type AlertAreaRequestDTO struct {
+	Type        string          `json:"type" binding:"required,oneof=circle polygon"`
+	Description string          `json:"description" binding:"max=255"`
+	Latitude    *float64        `json:"latitude" binding:"omitempty,min=-90,max=90"`
+	Longitude   *float64        `json:"longitude" binding:"omitempty,min=-180,max=180"`
+	RadiusKm    *float64        `json:"radius_km" binding:"omitempty,gt=0,max=500"`
+	Polygon     []AlertPointDTO `json:"polygon" binding:"omitempty,min=3,max=500,dive"`
+}
+
+type PublishAlertRequestDTO struct {
+	Severity    string              `json:"severity" binding:"required,oneof=minor moderate severe extreme"`
+	Headline    string              `json:"headline" binding:"required,max=160"`
+	Message     string              `json:"message" binding:"required,max=2000"`
+	Instruction string              `json:"instruction" binding:"max=1000"`
+	Area        AlertAreaRequestDTO `json:"area" binding:"required"`
+	// EffectiveAt defaults to now, ExpiresAt has to be after it
+	EffectiveAt *time.Time `json:"effective_at"`
+	ExpiresAt   time.Time  `json:"expires_at" binding:"required"`
+}
+
+type ActiveAlertsQueryDTO struct {
+	Latitude  *float64 `form:"lat" binding:"required,min=-90,max=90"`
+	Longitude *float64 `form:"lng" binding:"required,min=-180,max=180"`
+}
+
+type AlertAreaDTO struct {
+	Type        string          `json:"type"`
+	Description string          `json:"description,omitempty"`
+	Latitude    *float64        `json:"latitude,omitempty"`
+	Longitude   *float64        `json:"longitude,omitempty"`
+	RadiusKm    *float64        `json:"radius_km,omitempty"`
+	Polygon     []AlertPointDTO `json:"polygon,omitempty"`
+}
+
+type AlertDTO struct {
+	ID          uint         `json:"id"`
+	Severity    string       `json:"severity"`
+	Headline    string       `json:"headline"`
+	Message     string       `json:"message"`
+	Instruction string       `json:"instruction,omitempty"`
+	Area        AlertAreaDTO `json:"area"`
+	PublishedBy string       `json:"published_by,omitempty"`
+	// Source is the sender of the CAP message the alert was imported from
+	Source      string     `json:"source,omitempty"`
+	Active      bool       `json:"active"`
+	EffectiveAt time.Time  `json:"effective_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	// Delivery is the viewer's own delivery of the alert, when it was sent to them
+	Delivery *AlertDeliveryDTO `json:"delivery,omitempty"`
+}
+
+type AlertDeliveryDTO struct {
+	ID           uint       `json:"id"`
+	AlertID      uint       `json:"alert_id"`
+	UserID       uint       `json:"user_id"`
+	MatchedBy    string     `json:"matched_by"`
+	SavedPlaceID *uint      `json:"saved_place_id,omitempty"`
+	Channel      string     `json:"channel,omitempty"`
+	Status       string     `json:"status"`
+	Error        string     `json:"error,omitempty"`
+	SentAt       *time.Time `json:"sent_at,omitempty"`
+	ReadAt       *time.Time `json:"read_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type AlertDeliverySummaryDTO struct {
+	Total   int64 `json:"total"`
+	Pending int64 `json:"pending"`
+	Sent    int64 `json:"sent"`
+	Failed  int64 `json:"failed"`
+	Skipped int64 `json:"skipped"`
+	Read    int64 `json:"read"`
+}
+
+type AlertDeliveriesDTO struct {
+	Summary    AlertDeliverySummaryDTO `json:"summary"`
+	Deliveries []AlertDeliveryDTO      `json:"deliveries"`
+}
@@ -0,0 +1,19 @@
+package dto
+
+import "resq/pkg/constants"
+
+// AuthenticatedUserDTO is who is making the request, as read from the bearer token
+type AuthenticatedUserDTO struct {
+	ID          uint
+	Role        string
+	Permissions []string
+}
+
+func (a *AuthenticatedUserDTO) Can(permission constants.Permission) bool {
+	for _, granted := range a.Permissions {
+		if granted == string(permission) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package dto
+
+import "time"
+
+// StartCheckInRequestDTO asks either an area, given by its centre and radius, or the requester's contacts
+type StartCheckInRequestDTO struct {
+	Scope     string   `json:"scope" binding:"required,oneof=area contacts"`
+	Title     string   `json:"title" binding:"required,max=120"`
+	Message   string   `json:"message" binding:"max=1000"`
+	Latitude  *float64 `json:"latitude" binding:"omitempty,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" binding:"omitempty,min=-180,max=180"`
+	RadiusKm  *float64 `json:"radius_km" binding:"omitempty,gt=0,max=100"`
+	// DurationMinutes is how long answers are taken, a day when left out
+	DurationMinutes int `json:"duration_minutes" binding:"omitempty,min=5,max=10080"`
+}
+
+// RespondCheckInRequestDTO can carry where the recipient is, which a "need help" report is filed at
+type RespondCheckInRequestDTO struct {
+	Answer    string   `json:"answer" binding:"required,oneof=safe need_help"`
+	Note      string   `json:"note" binding:"max=500"`
+	Latitude  *float64 `json:"latitude" binding:"required_with=Longitude,omitempty,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" binding:"required_with=Latitude,omitempty,min=-180,max=180"`
+}
+
+type CheckInAreaDTO struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+	RadiusKm  float64 `json:"radius_km"`
+}
+
+type CheckInLocationDTO struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type CheckInTallyDTO struct {
+	Total      int64 `json:"total"`
+	Safe       int64 `json:"safe"`
+	NeedHelp   int64 `json:"need_help"`
+	NoResponse int64 `json:"no_response"`
+}
+
+type CheckInDTO struct {
+	ID            uint             `json:"id"`
+	CreatedByID   uint             `json:"created_by_id"`
+	CreatedByName string           `json:"created_by_name,omitempty"`
+	Scope         string           `json:"scope"`
+	Title         string           `json:"title"`
+	Message       string           `json:"message,omitempty"`
+	Area          *CheckInAreaDTO  `json:"area,omitempty"`
+	Open          bool             `json:"open"`
+	ClosesAt      time.Time        `json:"closes_at"`
+	ClosedAt      *time.Time       `json:"closed_at,omitempty"`
+	CreatedAt     time.Time        `json:"created_at"`
+	Tally         *CheckInTallyDTO `json:"tally,omitempty"`
+	// MyAnswer is the viewer's own answer when they were asked
+	MyAnswer *CheckInRecipientDTO `json:"my_answer,omitempty"`
+}
+
+type CheckInRecipientDTO struct {
+	UserID      uint                `json:"user_id"`
+	FirstName   string              `json:"first_name,omitempty"`
+	LastName    string              `json:"last_name,omitempty"`
+	Answer      string              `json:"answer"`
+	Note        string              `json:"note,omitempty"`
+	Location    *CheckInLocationDTO `json:"location,omitempty"`
+	ReportID    *uint               `json:"report_id,omitempty"`
+	RespondedAt *time.Time          `json:"responded_at,omitempty"`
+}
@@ -0,0 +1,41 @@
+package dto
+
+import "time"
+
+// InviteContactRequestDTO addresses the invitee by exactly one of email or phone
+type InviteContactRequestDTO struct {
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+type UpdateContactRequestDTO struct {
+	NotifyOnSOS *bool `json:"notify_on_sos" binding:"required"`
+}
+
+type ContactUserDTO struct {
+	ID        uint   `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email,omitempty"`
+	Phone     string `json:"phone,omitempty"`
+}
+
+type ContactDTO struct {
+	ID     uint   `json:"id"`
+	Status string `json:"status"`
+	// Direction is outgoing when the viewer sent the invitation
+	Direction   string          `json:"direction"`
+	User        *ContactUserDTO `json:"user,omitempty"`
+	InviteEmail string          `json:"invite_email,omitempty"`
+	InvitePhone string          `json:"invite_phone,omitempty"`
+	// NotifyOnSOS is whether the viewer's SOS alerts this contact, NotifiesMeOnSOS the other way round
+	NotifyOnSOS     bool       `json:"notify_on_sos"`
+	NotifiesMeOnSOS bool       `json:"notifies_me_on_sos"`
+	CreatedAt       time.Time  `json:"created_at"`
+	RespondedAt     *time.Time `json:"responded_at,omitempty"`
+}
+
+type ContactInvitationsDTO struct {
+	Incoming []ContactDTO `json:"incoming"`
+	Outgoing []ContactDTO `json:"outgoing"`
+}
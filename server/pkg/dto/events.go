@@ -0,0 +1,35 @@
+package dto
+
+import "time"
+
+// ReportCreatedEvent is published on report.created. ReporterID is set for anonymous reports too,
+// consumers must not show it to anyone but the reporter.
+type ReportCreatedEvent struct {
+	ReportID    uint      `json:"report_id"`
+	ReporterID  *uint     `json:"reporter_id,omitempty"`
+	IsAnonymous bool      `json:"is_anonymous"`
+	CategoryID  uint      `json:"category_id"`
+	Priority    string    `json:"priority"`
+	Latitude    float64   `json:"latitude"`
+	Longitude   float64   `json:"longitude"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ReportStatusChangedEvent is published on report.status_changed
+type ReportStatusChangedEvent struct {
+	ReportID   uint      `json:"report_id"`
+	ReporterID *uint     `json:"reporter_id,omitempty"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ActorID    uint      `json:"actor_id"`
+	Reason     string    `json:"reason,omitempty"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
+
+// UserRegisteredEvent is published on user.registered
+type UserRegisteredEvent struct {
+	UserID       uint      `json:"user_id"`
+	Email        string    `json:"email"`
+	Role         string    `json:"role"`
+	RegisteredAt time.Time `json:"registered_at"`
+}
@@ -0,0 +1,26 @@
+package dto
+
+import "time"
+
+type FeedEventDTO struct {
+	ID        uint        `json:"id"`
+	Kind      string      `json:"kind"`
+	ReportID  *uint       `json:"report_id,omitempty"`
+	AlertID   *uint       `json:"alert_id,omitempty"`
+	Data      interface{} `json:"data"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+type FeedEventsQueryDTO struct {
+	After uint `form:"after"`
+	Limit int  `form:"limit" binding:"omitempty,min=1,max=500"`
+}
+
+// ReportStatusEventDTO is a report status transition as followers see it
+type ReportStatusEventDTO struct {
+	ReportID   uint      `json:"report_id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	Reason     string    `json:"reason,omitempty"`
+	ChangedAt  time.Time `json:"changed_at"`
+}
@@ -0,0 +1,67 @@
+package dto
+
+import "time"
+
+type NotificationDTO struct {
+	ID        uint              `json:"id"`
+	Template  string            `json:"template"`
+	Title     string            `json:"title"`
+	Body      string            `json:"body"`
+	Data      map[string]string `json:"data,omitempty"`
+	Priority  string            `json:"priority"`
+	Read      bool              `json:"read"`
+	ReadAt    *time.Time        `json:"read_at,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+type NotificationsQueryDTO struct {
+	// Before is the ID of the oldest notification the client has, the page holds older ones
+	Before     uint `form:"before"`
+	Limit      int  `form:"limit" binding:"omitempty,min=1,max=100"`
+	UnreadOnly bool `form:"unread_only"`
+}
+
+type NotificationPreferenceDTO struct {
+	Push            bool   `json:"push"`
+	SMS             bool   `json:"sms"`
+	Email           bool   `json:"email"`
+	QuietHoursStart string `json:"quiet_hours_start,omitempty"`
+	QuietHoursEnd   string `json:"quiet_hours_end,omitempty"`
+	TimeZone        string `json:"time_zone"`
+}
+
+// NotificationPreferenceRequestDTO replaces a user's preferences, quiet hours are "HH:MM" and come as a pair,
+// both empty turns them off
+type NotificationPreferenceRequestDTO struct {
+	Push            *bool  `json:"push" binding:"required"`
+	SMS             *bool  `json:"sms" binding:"required"`
+	Email           *bool  `json:"email" binding:"required"`
+	QuietHoursStart string `json:"quiet_hours_start" binding:"omitempty,len=5"`
+	QuietHoursEnd   string `json:"quiet_hours_end" binding:"omitempty,len=5"`
+	TimeZone        string `json:"time_zone" binding:"omitempty,max=64"`
+}
+
+// DeviceDTO leaves the push token out, only the app that registered it needs it
+type DeviceDTO struct {
+	ID         uint      `json:"id"`
+	Platform   string    `json:"platform"`
+	AppVersion string    `json:"app_version,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	Active     bool      `json:"active"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// RegisterDeviceRequestDTO registers a push token, registering a known token again hands it to the caller
+type RegisterDeviceRequestDTO struct {
+	Token      string `json:"token" binding:"required,max=255"`
+	Platform   string `json:"platform" binding:"required,oneof=ios android web"`
+	AppVersion string `json:"app_version" binding:"omitempty,max=30"`
+	Name       string `json:"name" binding:"omitempty,max=100"`
+}
+
+// RefreshDeviceRequestDTO is sent when the app starts or its token rotated, empty fields stay as they are
+type RefreshDeviceRequestDTO struct {
+	Token      string `json:"token" binding:"omitempty,max=255"`
+	AppVersion string `json:"app_version" binding:"omitempty,max=30"`
+}
@@ -0,0 +1,136 @@
+package dto
+
+import "time"
+
+type ReportCategoryDTO struct {
+	ID          uint   `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+type ReportCategoryRequestDTO struct {
+	Title       string `json:"title" binding:"required,max=100"`
+	Description string `json:"description" binding:"max=500"`
+}
+
+type ReportLocationDTO struct {
+	Latitude       float64  `json:"latitude"`
+	Longitude      float64  `json:"longitude"`
+	AccuracyRadius float64  `json:"accuracy_radius"`
+	Altitude       *float64 `json:"altitude,omitempty"`
+	Address        *string  `json:"address,omitempty"`
+	Geohash        string   `json:"geohash"`
+}
+
+type ReportDTO struct {
+	ID          uint               `json:"id"`
+	Title       string             `json:"title"`
+	Summary     string             `json:"summary"`
+	IsAnonymous bool               `json:"is_anonymous"`
+	Status      string             `json:"status"`
+	Priority    string             `json:"priority"`
+	ReporterID  *uint              `json:"reporter_id,omitempty"`
+	Category    *ReportCategoryDTO `json:"category"`
+	Location    *ReportLocationDTO `json:"location"`
+	DistanceKm  *float64           `json:"distance_km,omitempty"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+type ReportStatusDTO struct {
+	ID        uint      `json:"id"`
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ReportStatusHistoryDTO struct {
+	ID         uint      `json:"id"`
+	FromStatus string    `json:"from_status"`
+	ToStatus   string    `json:"to_status"`
+	ActorID    uint      `json:"actor_id"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type UpdateReportStatusRequestDTO struct {
+	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason"`
+}
+
+type CreateReportLocationDTO struct {
+	Latitude       *float64 `json:"latitude" binding:"required,min=-90,max=90"`
+	Longitude      *float64 `json:"longitude" binding:"required,min=-180,max=180"`
+	AccuracyRadius float64  `json:"accuracy_radius" binding:"min=0"`
+	Altitude       *float64 `json:"altitude"`
+	Address        *string  `json:"address" binding:"omitempty,max=255"`
+}
+
+type CreateReportRequestDTO struct {
+	CategoryID  uint                    `json:"category_id" binding:"required"`
+	Summary     string                  `json:"summary" binding:"required"`
+	IsAnonymous bool                    `json:"is_anonymous"`
+	Location    CreateReportLocationDTO `json:"location"`
+}
+
+type NearbyReportsQueryDTO struct {
+	Latitude  *float64 `form:"lat" binding:"required,min=-90,max=90"`
+	Longitude *float64 `form:"lng" binding:"required,min=-180,max=180"`
+	RadiusKm  float64  `form:"radius_km" binding:"required,gt=0,max=100"`
+	Limit     int      `form:"limit" binding:"omitempty,min=1,max=500"`
+}
+
+// BoundingBoxReportsQueryDTO describes the visible map area, min_lng greater than max_lng wraps around the antimeridian
+type BoundingBoxReportsQueryDTO struct {
+	MinLatitude  *float64 `form:"min_lat" binding:"required,min=-90,max=90"`
+	MinLongitude *float64 `form:"min_lng" binding:"required,min=-180,max=180"`
+	MaxLatitude  *float64 `form:"max_lat" binding:"required,min=-90,max=90"`
+	MaxLongitude *float64 `form:"max_lng" binding:"required,min=-180,max=180"`
+	Limit        int      `form:"limit" binding:"omitempty,min=1,max=500"`
+}
+
+type ReportFileDTO struct {
+	ID           uint      `json:"id"`
+	ReportID     uint      `json:"report_id"`
+	FileType     string    `json:"file_type"`
+	MimeType     string    `json:"mime_type"`
+	FileSize     int64     `json:"file_size"`
+	Sha256       string    `json:"sha256"`
+	OriginalName string    `json:"original_name"`
+	DownloadURL  string    `json:"download_url,omitempty"`
+	URLExpiresAt time.Time `json:"url_expires_at,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+type ReportUploadDTO struct {
+	UploadID     string         `json:"upload_id"`
+	ReportID     uint           `json:"report_id"`
+	FileName     string         `json:"file_name"`
+	TotalSize    int64          `json:"total_size"`
+	ReceivedSize int64          `json:"received_size"`
+	Status       string         `json:"status"`
+	ExpiresAt    time.Time      `json:"expires_at"`
+	File         *ReportFileDTO `json:"file,omitempty"`
+}
+
+type CreateReportUploadRequestDTO struct {
+	FileName  string `json:"file_name" binding:"required,max=255"`
+	TotalSize int64  `json:"total_size" binding:"required,gt=0"`
+}
+
+type ReportCommentRequestDTO struct {
+	Body string `json:"body" binding:"required,max=2000"`
+}
+
+type ReportCommentDTO struct {
+	ID         uint      `json:"id"`
+	ReportID   uint      `json:"report_id"`
+	AuthorID   uint      `json:"author_id"`
+	AuthorName string    `json:"author_name,omitempty"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type ReportFollowDTO struct {
+	ReportID  uint `json:"report_id"`
+	Following bool `json:"following"`
+}
@@ -0,0 +1,68 @@
+package dto
+
+import "time"
+
+type StartSOSRequestDTO struct {
+	Latitude       *float64 `json:"latitude" binding:"required,min=-90,max=90"`
+	Longitude      *float64 `json:"longitude" binding:"required,min=-180,max=180"`
+	AccuracyRadius float64  `json:"accuracy_radius" binding:"min=0"`
+	// Pin cancels the session later, 4 to 8 digits
+	Pin     string `json:"pin" binding:"required,numeric,min=4,max=8"`
+	Message string `json:"message" binding:"max=500"`
+}
+
+type SOSPingRequestDTO struct {
+	Latitude       *float64   `json:"latitude" binding:"required,min=-90,max=90"`
+	Longitude      *float64   `json:"longitude" binding:"required,min=-180,max=180"`
+	AccuracyRadius float64    `json:"accuracy_radius" binding:"min=0"`
+	Altitude       *float64   `json:"altitude"`
+	Speed          *float64   `json:"speed" binding:"omitempty,min=0"`
+	Heading        *float64   `json:"heading" binding:"omitempty,min=0,max=360"`
+	BatteryLevel   *int       `json:"battery_level" binding:"omitempty,min=0,max=100"`
+	RecordedAt     *time.Time `json:"recorded_at"`
+}
+
+// SOSPingsRequestDTO lets a phone that was offline send the fixes it buffered in one go
+type SOSPingsRequestDTO struct {
+	Pings []SOSPingRequestDTO `json:"pings" binding:"required,min=1,max=100,dive"`
+}
+
+type CancelSOSRequestDTO struct {
+	Pin string `json:"pin" binding:"required"`
+}
+
+type ResolveSOSRequestDTO struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+type SOSLocationDTO struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type SOSSessionDTO struct {
+	ID           uint            `json:"id"`
+	UserID       uint            `json:"user_id"`
+	FirstName    string          `json:"first_name,omitempty"`
+	LastName     string          `json:"last_name,omitempty"`
+	ReportID     uint            `json:"report_id"`
+	Status       string          `json:"status"`
+	Message      string          `json:"message,omitempty"`
+	LastLocation *SOSLocationDTO `json:"last_location,omitempty"`
+	LastPingAt   *time.Time      `json:"last_ping_at,omitempty"`
+	StartedAt    time.Time       `json:"started_at"`
+	EndedAt      *time.Time      `json:"ended_at,omitempty"`
+	EndReason    string          `json:"end_reason,omitempty"`
+}
+
+type SOSPingDTO struct {
+	ID             uint      `json:"id"`
+	Latitude       float64   `json:"latitude"`
+	Longitude      float64   `json:"longitude"`
+	AccuracyRadius float64   `json:"accuracy_radius"`
+	Altitude       *float64  `json:"altitude,omitempty"`
+	Speed          *float64  `json:"speed,omitempty"`
+	Heading        *float64  `json:"heading,omitempty"`
+	BatteryLevel   *int      `json:"battery_level,omitempty"`
+	RecordedAt     time.Time `json:"recorded_at"`
+}
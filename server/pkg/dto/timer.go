@@ -0,0 +1,32 @@
+package dto
+
+import "time"
+
+// ArmSafetyTimerRequestDTO sets the deadline either as a time or as minutes from now
+type ArmSafetyTimerRequestDTO struct {
+	DueAt           *time.Time `json:"due_at"`
+	DurationMinutes int        `json:"duration_minutes" binding:"omitempty,min=1"`
+	Note            string     `json:"note" binding:"max=500"`
+	Latitude        *float64   `json:"latitude" binding:"required_with=Longitude,omitempty,min=-90,max=90"`
+	Longitude       *float64   `json:"longitude" binding:"required_with=Latitude,omitempty,min=-180,max=180"`
+}
+
+// ExtendSafetyTimerRequestDTO moves the deadline to DueAt or pushes it back by DurationMinutes
+type ExtendSafetyTimerRequestDTO struct {
+	DueAt           *time.Time `json:"due_at"`
+	DurationMinutes int        `json:"duration_minutes" binding:"omitempty,min=1"`
+	Latitude        *float64   `json:"latitude" binding:"required_with=Longitude,omitempty,min=-90,max=90"`
+	Longitude       *float64   `json:"longitude" binding:"required_with=Latitude,omitempty,min=-180,max=180"`
+}
+
+type SafetyTimerDTO struct {
+	ID               uint       `json:"id"`
+	Status           string     `json:"status"`
+	DueAt            time.Time  `json:"due_at"`
+	SecondsRemaining *int64     `json:"seconds_remaining,omitempty"`
+	Note             string     `json:"note,omitempty"`
+	Extensions       int        `json:"extensions"`
+	CreatedAt        time.Time  `json:"created_at"`
+	EscalatedAt      *time.Time `json:"escalated_at,omitempty"`
+	DisarmedAt       *time.Time `json:"disarmed_at,omitempty"`
+}
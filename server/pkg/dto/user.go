@@ -1,14 +1,106 @@
 package dto
 
+import "time"
+
 
 type UserDTO struct {
 	ID uint
 	Email string
 	FirstName string
 	LastName string
+	Role string
+	EmailVerified bool
+	Phone string
+	PhoneVerified bool
+	HasPassword bool
+	CreatedAt time.Time
 }
 
 type LoginRequestDTO struct {
 	Email    string `json:"email" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
+
+type UpdateUserRoleRequestDTO struct {
+	Role string `json:"role" binding:"required"`
+}
+
+type TokenPairDTO struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+type RefreshTokenRequestDTO struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+type LogoutRequestDTO struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type VerifyEmailRequestDTO struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPasswordRequestDTO struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type ResetPasswordRequestDTO struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+type SendPhoneOTPRequestDTO struct {
+	Phone string `json:"phone" binding:"required"`
+}
+
+// VerifyPhoneOTPRequestDTO signs in the owner of the number, the names are only used when the number has no account yet
+type VerifyPhoneOTPRequestDTO struct {
+	Phone     string `json:"phone" binding:"required"`
+	Code      string `json:"code" binding:"required,len=6,numeric"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+}
+
+// UpdateProfileRequestDTO only changes the fields that are present, an empty email or phone removes it
+type UpdateProfileRequestDTO struct {
+	FirstName *string `json:"first_name"`
+	LastName  *string `json:"last_name"`
+	Email     *string `json:"email"`
+	Phone     *string `json:"phone"`
+}
+
+type ChangePasswordRequestDTO struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// DeleteAccountRequestDTO needs the password of accounts that have one
+type DeleteAccountRequestDTO struct {
+	Password string `json:"password"`
+}
+
+// UpdateLocationRequestDTO shares where the phone is, check-ins and area alerts use the latest one
+type UpdateLocationRequestDTO struct {
+	Latitude       *float64   `json:"latitude" binding:"required,min=-90,max=90"`
+	Longitude      *float64   `json:"longitude" binding:"required,min=-180,max=180"`
+	AccuracyRadius float64    `json:"accuracy_radius" binding:"min=0"`
+	RecordedAt     *time.Time `json:"recorded_at"`
+}
+
+type SavedPlaceRequestDTO struct {
+	Name      string   `json:"name" binding:"required,max=60"`
+	Latitude  *float64 `json:"latitude" binding:"required,min=-90,max=90"`
+	Longitude *float64 `json:"longitude" binding:"required,min=-180,max=180"`
+}
+
+type SavedPlaceDTO struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	CreatedAt time.Time `json:"created_at"`
+}
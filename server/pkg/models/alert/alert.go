@@ -0,0 +1,163 @@
+package models
+
+import (
+	"resq/pkg/dto"
+	"resq/pkg/models"
+	"resq/pkg/utils"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AlertSeverity string
+
+const (
+	AlertMinor    AlertSeverity = "minor"
+	AlertModerate AlertSeverity = "moderate"
+	AlertSevere   AlertSeverity = "severe"
+	AlertExtreme  AlertSeverity = "extreme"
+)
+
+func (s AlertSeverity) IsValid() bool {
+	switch s {
+	case AlertMinor, AlertModerate, AlertSevere, AlertExtreme:
+		return true
+	}
+	return false
+}
+
+// IsCritical reports whether alerts of the severity reach people during their quiet hours too
+func (s AlertSeverity) IsCritical() bool {
+	return s == AlertSevere || s == AlertExtreme
+}
+
+type AlertAreaType string
+
+const (
+	AlertAreaCircle  AlertAreaType = "circle"
+	AlertAreaPolygon AlertAreaType = "polygon"
+)
+
+// Alert is a broadcast from an authority to everyone inside its area until it expires or is cancelled.
+// The bounding box columns let the database narrow candidates before the exact shape is checked.
+type Alert struct {
+	gorm.Model
+	PublishedByID uint          `gorm:"not null;index"`
+	PublishedBy   models.User   `gorm:"foreignKey:PublishedByID"`
+	Severity      AlertSeverity `gorm:"type:varchar(20);not null;index"`
+	Headline      string        `gorm:"not null"`
+	Message       string        `gorm:"not null"`
+	Instruction   string
+	AreaType      AlertAreaType `gorm:"type:varchar(20);not null"`
+	// AreaDescription names the area in words, CAP requires one
+	AreaDescription string
+	// Latitude, Longitude and RadiusKm describe a circle area
+	Latitude  *float64
+	Longitude *float64
+	RadiusKm  *float64
+	// Polygon is the outline of a polygon area
+	Polygon       []utils.GeoPoint `gorm:"type:jsonb;serializer:json"`
+	MinLatitude   float64          `gorm:"not null;index:idx_alerts_bounds,priority:1"`
+	MaxLatitude   float64          `gorm:"not null;index:idx_alerts_bounds,priority:2"`
+	MinLongitude  float64          `gorm:"not null;index:idx_alerts_bounds,priority:3"`
+	MaxLongitude  float64          `gorm:"not null;index:idx_alerts_bounds,priority:4"`
+	EffectiveAt   time.Time        `gorm:"not null"`
+	ExpiresAt     time.Time        `gorm:"not null;index"`
+	CancelledAt   *time.Time
+	CancelledByID *uint
+	// DispatchedAt is set once the audience was worked out and the deliveries recorded
+	DispatchedAt *time.Time `gorm:"index"`
+	// CAPSender and CAPIdentifier name the CAP message an imported alert came from, both are empty for alerts published here.
+	// A message with several areas is imported as one alert per area, so the pair is not unique.
+	CAPSender     string `gorm:"index:idx_alerts_cap,priority:1"`
+	CAPIdentifier string `gorm:"index:idx_alerts_cap,priority:2"`
+	// CAP details of imported alerts, exported again as they came
+	Event     string
+	Category  string
+	Urgency   string
+	Certainty string
+}
+
+// BoundingBox is the stored box around the area
+func (a *Alert) BoundingBox() utils.BoundingBox {
+	return utils.BoundingBox{
+		MinLatitude:  a.MinLatitude,
+		MinLongitude: a.MinLongitude,
+		MaxLatitude:  a.MaxLatitude,
+		MaxLongitude: a.MaxLongitude,
+	}
+}
+
+// SetArea stores a circle or polygon area together with the box around it
+func (a *Alert) SetArea(areaType AlertAreaType, lat, lng, radiusKm *float64, polygon []utils.GeoPoint) {
+	a.AreaType = areaType
+	var box utils.BoundingBox
+	if areaType == AlertAreaCircle {
+		a.Latitude, a.Longitude, a.RadiusKm = lat, lng, radiusKm
+		a.Polygon = nil
+		box = utils.RadiusBoundingBox(*lat, *lng, *radiusKm)
+	} else {
+		a.Latitude, a.Longitude, a.RadiusKm = nil, nil, nil
+		a.Polygon = polygon
+		box = utils.PolygonBoundingBox(polygon)
+	}
+	a.MinLatitude, a.MaxLatitude = box.MinLatitude, box.MaxLatitude
+	a.MinLongitude, a.MaxLongitude = box.MinLongitude, box.MaxLongitude
+}
+
+// Covers tells whether the point is inside the alert's area
+func (a *Alert) Covers(lat, lng float64) bool {
+	if !a.BoundingBox().Contains(lat, lng) {
+		return false
+	}
+	if a.AreaType == AlertAreaCircle {
+		return a.Latitude != nil && a.Longitude != nil && a.RadiusKm != nil &&
+			utils.HaversineKm(*a.Latitude, *a.Longitude, lat, lng) <= *a.RadiusKm
+	}
+	return utils.PolygonContains(a.Polygon, lat, lng)
+}
+
+// IsActive reports whether the alert is in force at now
+func (a *Alert) IsActive(now time.Time) bool {
+	return a.CancelledAt == nil && !now.Before(a.EffectiveAt) && now.Before(a.ExpiresAt)
+}
+
+func (a *Alert) ToDTO() *dto.AlertDTO {
+	alert := &dto.AlertDTO{
+		ID:          a.ID,
+		Severity:    string(a.Severity),
+		Headline:    a.Headline,
+		Message:     a.Message,
+		Instruction: a.Instruction,
+		Area: dto.AlertAreaDTO{
+			Type:        string(a.AreaType),
+			Description: a.AreaDescription,
+			Latitude:    a.Latitude,
+			Longitude:   a.Longitude,
+			RadiusKm:    a.RadiusKm,
+			Polygon:     polygonToDTO(a.Polygon),
+		},
+		Source:      a.CAPSender,
+		Active:      a.IsActive(time.Now()),
+		EffectiveAt: a.EffectiveAt,
+		ExpiresAt:   a.ExpiresAt,
+		CancelledAt: a.CancelledAt,
+		CreatedAt:   a.CreatedAt,
+	}
+
+	if a.PublishedBy.ID != 0 {
+		alert.PublishedBy = a.PublishedBy.FirstName + " " + a.PublishedBy.LastName
+	}
+	return alert
+}
+
+func polygonToDTO(polygon []utils.GeoPoint) []dto.AlertPointDTO {
+	if len(polygon) == 0 {
+		return nil
+	}
+	points := make([]dto.AlertPointDTO, len(polygon))
+	for i, point := range polygon {
+		points[i] = dto.AlertPointDTO{Latitude: point.Latitude, Longitude: point.Longitude}
+	}
+	return points
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"resq/pkg/dto"
+	"resq/pkg/models"
+	"time"
+)
+
+type AlertMatch string
+
+const (
+	// AlertMatchLocation means the user's last known location was inside the area
+	AlertMatchLocation AlertMatch = "location"
+	// AlertMatchSavedPlace means one of the user's saved places was inside the area
+	AlertMatchSavedPlace AlertMatch = "saved_place"
+)
+
+type AlertDeliveryStatus string
+
+const (
+	AlertDeliveryPending AlertDeliveryStatus = "pending"
+	AlertDeliverySent    AlertDeliveryStatus = "sent"
+	AlertDeliveryFailed  AlertDeliveryStatus = "failed"
+	// AlertDeliverySkipped is a recipient whose account was gone by the time the alert went out
+	AlertDeliverySkipped AlertDeliveryStatus = "skipped"
+)
+
+// AlertDelivery records that an alert was meant for a user, why, and how getting it to them went
+type AlertDelivery struct {
+	ID           uint        `gorm:"primarykey"`
+	AlertID      uint        `gorm:"not null;uniqueIndex:idx_alert_deliveries_user,priority:1"`
+	Alert        Alert       `gorm:"foreignKey:AlertID"`
+	UserID       uint        `gorm:"not null;uniqueIndex:idx_alert_deliveries_user,priority:2;index"`
+	User         models.User `gorm:"foreignKey:UserID"`
+	MatchedBy    AlertMatch  `gorm:"type:varchar(20);not null"`
+	SavedPlaceID *uint
+	Channel      string              `gorm:"type:varchar(20);not null;default:''"`
+	Status       AlertDeliveryStatus `gorm:"type:varchar(20);not null;default:'pending';index"`
+	Error        string
+	SentAt       *time.Time
+	ReadAt       *time.Time
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+func (d *AlertDelivery) ToDTO() *dto.AlertDeliveryDTO {
+	return &dto.AlertDeliveryDTO{
+		ID:           d.ID,
+		AlertID:      d.AlertID,
+		UserID:       d.UserID,
+		MatchedBy:    string(d.MatchedBy),
+		SavedPlaceID: d.SavedPlaceID,
+		Channel:      d.Channel,
+		Status:       string(d.Status),
+		Error:        d.Error,
+		SentAt:       d.SentAt,
+		ReadAt:       d.ReadAt,
+		CreatedAt:    d.CreatedAt,
+	}
+}
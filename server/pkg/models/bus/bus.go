@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type BusDeliveryStatus string
+
+const (
+	BusDeliveryPending BusDeliveryStatus = "pending"
+	BusDeliveryDone    BusDeliveryStatus = "done"
+	BusDeliveryDead    BusDeliveryStatus = "dead"
+)
+
+// BusMessage is a message published on the Postgres event bus
+type BusMessage struct {
+	ID        uint              `gorm:"primaryKey"`
+	Topic     string            `gorm:"not null;index"`
+	Key       string            `gorm:"not null;default:''"`
+	Payload   json.RawMessage   `gorm:"type:jsonb;serializer:json;not null"`
+	Headers   map[string]string `gorm:"type:jsonb;serializer:json"`
+	CreatedAt time.Time         `gorm:"not null;index"`
+}
+
+// BusSubscription records that a consumer group reads a topic, messages published from then on are delivered to it
+type BusSubscription struct {
+	Topic         string    `gorm:"primaryKey"`
+	ConsumerGroup string    `gorm:"primaryKey"`
+	CreatedAt     time.Time `gorm:"not null"`
+}
+
+// BusDelivery is one message on its way to one consumer group.
+// LockedUntil is the lease of the instance handling it, a delivery whose lease ran out is handed out again.
+type BusDelivery struct {
+	ID            uint              `gorm:"primaryKey"`
+	MessageID     uint              `gorm:"not null;uniqueIndex:idx_bus_deliveries_message,priority:1"`
+	Message       BusMessage        `gorm:"foreignKey:MessageID"`
+	Topic         string            `gorm:"not null;index:idx_bus_deliveries_claim,priority:2"`
+	ConsumerGroup string            `gorm:"not null;uniqueIndex:idx_bus_deliveries_message,priority:2;index:idx_bus_deliveries_claim,priority:1"`
+	Key           string            `gorm:"not null;default:''"`
+	Status        BusDeliveryStatus `gorm:"type:varchar(20);not null;default:'pending';index:idx_bus_deliveries_claim,priority:3"`
+	Attempts      int               `gorm:"not null;default:0"`
+	AvailableAt   time.Time         `gorm:"not null;index:idx_bus_deliveries_claim,priority:4"`
+	LockedUntil   *time.Time
+	LastError     string
+	DoneAt        *time.Time `gorm:"index"`
+	CreatedAt     time.Time  `gorm:"not null"`
+	UpdatedAt     time.Time  `gorm:"not null"`
+}
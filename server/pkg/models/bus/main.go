@@ -0,0 +1,7 @@
+package models
+
+var Models = []interface{}{
+	&BusMessage{},
+	&BusSubscription{},
+	&BusDelivery{},
+}
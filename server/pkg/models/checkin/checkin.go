@@ -0,0 +1,67 @@
+package models
+
+import (
+	"resq/pkg/dto"
+	"resq/pkg/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type CheckInScope string
+
+const (
+	// CheckInArea asks everyone whose last known location is inside a circle
+	CheckInArea CheckInScope = "area"
+	// CheckInContacts asks the requester's accepted contacts
+	CheckInContacts CheckInScope = "contacts"
+)
+
+func (s CheckInScope) IsValid() bool {
+	return s == CheckInArea || s == CheckInContacts
+}
+
+// CheckIn is an "are you safe?" campaign, it takes answers until it is closed or ClosesAt passes
+type CheckIn struct {
+	gorm.Model
+	CreatedByID uint         `gorm:"not null;index"`
+	CreatedBy   models.User  `gorm:"foreignKey:CreatedByID"`
+	Scope       CheckInScope `gorm:"type:varchar(20);not null"`
+	Title       string       `gorm:"not null"`
+	Message     string
+	// the circle an area check-in covers, unset for contact check-ins
+	Latitude  *float64
+	Longitude *float64
+	RadiusKm  *float64
+	ClosesAt  time.Time `gorm:"not null;index"`
+	ClosedAt  *time.Time
+}
+
+// IsOpen reports whether recipients may still answer at now
+func (c *CheckIn) IsOpen(now time.Time) bool {
+	return c.ClosedAt == nil && now.Before(c.ClosesAt)
+}
+
+func (c *CheckIn) ToDTO(tally *dto.CheckInTallyDTO) *dto.CheckInDTO {
+	checkIn := &dto.CheckInDTO{
+		ID:          c.ID,
+		CreatedByID: c.CreatedByID,
+		Scope:       string(c.Scope),
+		Title:       c.Title,
+		Message:     c.Message,
+		Open:        c.IsOpen(time.Now()),
+		ClosesAt:    c.ClosesAt,
+		ClosedAt:    c.ClosedAt,
+		CreatedAt:   c.CreatedAt,
+		Tally:       tally,
+	}
+
+	if c.CreatedBy.ID != 0 {
+		checkIn.CreatedByName = c.CreatedBy.FirstName + " " + c.CreatedBy.LastName
+	}
+
+	if c.Scope == CheckInArea && c.Latitude != nil && c.Longitude != nil && c.RadiusKm != nil {
+		checkIn.Area = &dto.CheckInAreaDTO{Latitude: *c.Latitude, Longitude: *c.Longitude, RadiusKm: *c.RadiusKm}
+	}
+	return checkIn
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"resq/pkg/dto"
+	"resq/pkg/models"
+	"time"
+)
+
+type CheckInAnswer string
+
+const (
+	CheckInNoResponse CheckInAnswer = "no_response"
+	CheckInSafe       CheckInAnswer = "safe"
+	CheckInNeedHelp   CheckInAnswer = "need_help"
+)
+
+func (a CheckInAnswer) IsValid() bool {
+	return a == CheckInNoResponse || a == CheckInSafe || a == CheckInNeedHelp
+}
+
+// CheckInRecipient is one person asked by a check-in and their latest answer,
+// a "need help" answer opens a report that stays linked here
+type CheckInRecipient struct {
+	ID          uint          `gorm:"primarykey"`
+	CheckInID   uint          `gorm:"not null;uniqueIndex:idx_check_in_recipients_user,priority:1"`
+	UserID      uint          `gorm:"not null;uniqueIndex:idx_check_in_recipients_user,priority:2;index"`
+	User        models.User   `gorm:"foreignKey:UserID"`
+	Answer      CheckInAnswer `gorm:"type:varchar(20);not null;default:'no_response';index"`
+	Note        string
+	Latitude    *float64
+	Longitude   *float64
+	ReportID    *uint
+	AskedAt     *time.Time
+	RespondedAt *time.Time
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+func (r *CheckInRecipient) ToDTO() *dto.CheckInRecipientDTO {
+	recipient := &dto.CheckInRecipientDTO{
+		UserID:      r.UserID,
+		Answer:      string(r.Answer),
+		Note:        r.Note,
+		ReportID:    r.ReportID,
+		RespondedAt: r.RespondedAt,
+	}
+
+	if r.User.ID != 0 {
+		recipient.FirstName = r.User.FirstName
+		recipient.LastName = r.User.LastName
+	}
+
+	if r.Latitude != nil && r.Longitude != nil {
+		recipient.Location = &dto.CheckInLocationDTO{Latitude: *r.Latitude, Longitude: *r.Longitude}
+	}
+	return recipient
+}
@@ -0,0 +1,6 @@
+package models
+
+var Models = []interface{}{
+	&CheckIn{},
+	&CheckInRecipient{},
+}
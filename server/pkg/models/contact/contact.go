@@ -0,0 +1,115 @@
+package models
+
+import (
+	"resq/pkg/dto"
+	"resq/pkg/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ContactStatus string
+
+const (
+	ContactPending  ContactStatus = "pending"
+	ContactAccepted ContactStatus = "accepted"
+	ContactDeclined ContactStatus = "declined"
+)
+
+// Contact is an invitation from one user to another that becomes a mutual contact once accepted.
+// The invitee is addressed by email or phone, AddresseeID is filled in as soon as a verified account owns it.
+type Contact struct {
+	gorm.Model
+	RequesterID uint          `gorm:"not null;index"`
+	Requester   models.User   `gorm:"foreignKey:RequesterID"`
+	AddresseeID *uint         `gorm:"index"`
+	Addressee   *models.User  `gorm:"foreignKey:AddresseeID"`
+	InviteEmail string        `gorm:"not null;default:'';index"`
+	InvitePhone string        `gorm:"type:varchar(16);not null;default:'';index"`
+	Status      ContactStatus `gorm:"type:varchar(20);not null;default:'pending';index"`
+	// each side picks whether their own SOS alerts the other one
+	RequesterNotifyOnSOS bool `gorm:"not null;default:true"`
+	AddresseeNotifyOnSOS bool `gorm:"not null;default:true"`
+	RespondedAt          *time.Time
+}
+
+// otherSideOf selects the other party of the accepted contacts userId is on either side of
+func otherSideOf(db *gorm.DB, userId uint) *gorm.DB {
+	return db.Model(&Contact{}).
+		Select("CASE WHEN requester_id = ? THEN addressee_id ELSE requester_id END", userId).
+		Where("status = ?", ContactAccepted)
+}
+
+// AcceptedContactIDs selects the other side of every accepted contact of userId
+func AcceptedContactIDs(db *gorm.DB, userId uint) *gorm.DB {
+	return otherSideOf(db, userId).Where("requester_id = ? OR addressee_id = ?", userId, userId)
+}
+
+// SOSRecipientIDs selects the users an SOS of ownerId alerts, accepted contacts the owner left notifications on for
+func SOSRecipientIDs(db *gorm.DB, ownerId uint) *gorm.DB {
+	return otherSideOf(db, ownerId).
+		Where("(requester_id = ? AND requester_notify_on_sos) OR (addressee_id = ? AND addressee_notify_on_sos)", ownerId, ownerId)
+}
+
+// SOSOwnerIDs selects the users whose SOS alerts recipientId
+func SOSOwnerIDs(db *gorm.DB, recipientId uint) *gorm.DB {
+	return otherSideOf(db, recipientId).
+		Where("(addressee_id = ? AND requester_notify_on_sos) OR (requester_id = ? AND addressee_notify_on_sos)", recipientId, recipientId)
+}
+
+func (c *Contact) IsRequester(userId uint) bool {
+	return c.RequesterID == userId
+}
+
+func (c *Contact) IsAddressee(userId uint) bool {
+	return c.AddresseeID != nil && *c.AddresseeID == userId
+}
+
+// NotifyOnSOS is the choice userId made for their side of the contact
+func (c *Contact) NotifyOnSOS(userId uint) bool {
+	if c.IsRequester(userId) {
+		return c.RequesterNotifyOnSOS
+	}
+	return c.AddresseeNotifyOnSOS
+}
+
+// ToDTO describes the contact from viewerId's side, the other party's details are only shared once both agreed
+func (c *Contact) ToDTO(viewerId uint) *dto.ContactDTO {
+	contact := &dto.ContactDTO{
+		ID:          c.ID,
+		Status:      string(c.Status),
+		NotifyOnSOS: c.NotifyOnSOS(viewerId),
+		CreatedAt:   c.CreatedAt,
+		RespondedAt: c.RespondedAt,
+	}
+
+	var other *models.User
+	if c.IsRequester(viewerId) {
+		contact.Direction = "outgoing"
+		contact.NotifiesMeOnSOS = c.AddresseeNotifyOnSOS
+		contact.InviteEmail = c.InviteEmail
+		contact.InvitePhone = c.InvitePhone
+		other = c.Addressee
+	} else {
+		contact.Direction = "incoming"
+		contact.NotifiesMeOnSOS = c.RequesterNotifyOnSOS
+		other = &c.Requester
+	}
+
+	if other != nil && other.ID != 0 {
+		contact.User = &dto.ContactUserDTO{
+			ID:        other.ID,
+			FirstName: other.FirstName,
+			LastName:  other.LastName,
+		}
+		if c.Status == ContactAccepted {
+			contact.User.Email = other.Email
+			contact.User.Phone = other.Phone
+		}
+	}
+
+	if c.Status != ContactAccepted {
+		contact.NotifiesMeOnSOS = false
+	}
+	return contact
+}
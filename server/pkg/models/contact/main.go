@@ -0,0 +1,5 @@
+package models
+
+var Models = []interface{}{
+	&Contact{},
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"fmt"
+	"resq/pkg/dto"
+	"time"
+)
+
+type FeedEventKind string
+
+const (
+	FeedReportStatus  FeedEventKind = "report_status"
+	FeedReportComment FeedEventKind = "report_comment"
+	FeedAlert         FeedEventKind = "alert"
+)
+
+// FeedEvent is one entry of a user's status updates feed. Events are stored per recipient
+// so a reconnecting client can pick up after the last ID it saw.
+type FeedEvent struct {
+	ID       uint          `gorm:"primaryKey;index:idx_feed_events_user,priority:2"`
+	UserID   uint          `gorm:"not null;index:idx_feed_events_user,priority:1"`
+	Kind     FeedEventKind `gorm:"type:varchar(30);not null"`
+	ReportID *uint
+	AlertID  *uint
+	// Payload is the DTO of what happened, as the client receives it
+	Payload   interface{} `gorm:"type:jsonb;serializer:json"`
+	CreatedAt time.Time   `gorm:"not null;index"`
+}
+
+// Topic is where a user's feed events are published live
+func Topic(userId uint) string {
+	return fmt.Sprintf("feed:%d", userId)
+}
+
+// NewFeedEvents builds one event per recipient
+func NewFeedEvents(userIds []uint, kind FeedEventKind, reportId, alertId *uint, payload interface{}) []FeedEvent {
+	events := make([]FeedEvent, len(userIds))
+	for i, userId := range userIds {
+		events[i] = FeedEvent{UserID: userId, Kind: kind, ReportID: reportId, AlertID: alertId, Payload: payload}
+	}
+	return events
+}
+
+func (e *FeedEvent) ToDTO() *dto.FeedEventDTO {
+	return &dto.FeedEventDTO{
+		ID:        e.ID,
+		Kind:      string(e.Kind),
+		ReportID:  e.ReportID,
+		AlertID:   e.AlertID,
+		Data:      e.Payload,
+		CreatedAt: e.CreatedAt,
+	}
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type JobStatus string
+
+const (
+	JobQueued  JobStatus = "queued"
+	JobRunning JobStatus = "running"
+	JobDone    JobStatus = "done"
+	// JobDead is a job that failed every attempt, it is kept for someone to look at
+	JobDead JobStatus = "dead"
+)
+
+// Job is one run of background work. A running job whose lease ran out belongs to an instance
+// that went away, it is picked up again.
+type Job struct {
+	ID      uint            `gorm:"primaryKey"`
+	Kind    string          `gorm:"not null;index:idx_jobs_claim,priority:1"`
+	Payload json.RawMessage `gorm:"type:jsonb;serializer:json"`
+	Status  JobStatus       `gorm:"type:varchar(20);not null;default:'queued';index:idx_jobs_claim,priority:2"`
+	RunAt   time.Time       `gorm:"not null;index:idx_jobs_claim,priority:3"`
+	// UniqueKey keeps a job from being queued twice, scheduled runs use it so each run is queued once
+	UniqueKey   *string `gorm:"uniqueIndex"`
+	Attempts    int     `gorm:"not null;default:0"`
+	LockedBy    string
+	LockedUntil *time.Time
+	LastError   string
+	FinishedAt  *time.Time `gorm:"index"`
+	CreatedAt   time.Time  `gorm:"not null"`
+	UpdatedAt   time.Time  `gorm:"not null"`
+}
+
+// JobSchedule remembers when a recurring job is next due, so a restart neither skips nor repeats a run
+type JobSchedule struct {
+	Name      string    `gorm:"primaryKey"`
+	Spec      string    `gorm:"not null"`
+	NextRunAt time.Time `gorm:"not null"`
+	LastRunAt *time.Time
+	UpdatedAt time.Time `gorm:"not null"`
+}
+
+// JobLease is held by the one instance doing work that must not run twice, like queueing scheduled runs
+type JobLease struct {
+	Name      string    `gorm:"primaryKey"`
+	Holder    string    `gorm:"not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+}
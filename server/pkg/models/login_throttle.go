@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type LoginThrottleScope string
+
+const (
+	LoginThrottleAccount LoginThrottleScope = "account"
+	LoginThrottleIP      LoginThrottleScope = "ip"
+)
+
+// LoginThrottle counts recent failed logins for an account identifier or a client address
+type LoginThrottle struct {
+	gorm.Model
+	Scope         LoginThrottleScope `gorm:"type:varchar(10);not null;uniqueIndex:idx_login_throttles_scope_key"`
+	Key           string             `gorm:"type:varchar(255);not null;uniqueIndex:idx_login_throttles_scope_key"`
+	Failures      int                `gorm:"not null;default:0"`
+	LastFailureAt time.Time          `gorm:"not null"`
+	LockedUntil   *time.Time
+}
+
+// IsLocked reports whether logins are refused at the given time
+func (l *LoginThrottle) IsLocked(now time.Time) bool {
+	return l.LockedUntil != nil && now.Before(*l.LockedUntil)
+}
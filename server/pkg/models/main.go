@@ -3,4 +3,11 @@ package models
 
 var Models = []interface{}{
 	&User{},
+	&RefreshToken{},
+	&RevokedToken{},
+	&UserToken{},
+	&PhoneOTP{},
+	&LoginThrottle{},
+	&UserLocation{},
+	&SavedPlace{},
 }
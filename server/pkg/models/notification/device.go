@@ -0,0 +1,42 @@
+package models
+
+import (
+	"resq/pkg/dto"
+	"time"
+)
+
+type DevicePlatform string
+
+const (
+	DeviceIOS     DevicePlatform = "ios"
+	DeviceAndroid DevicePlatform = "android"
+	DeviceWeb     DevicePlatform = "web"
+)
+
+// Device is an app installation that receives push notifications.
+// DisabledAt is set once the push provider reports the token as no longer valid.
+type Device struct {
+	ID         uint           `gorm:"primaryKey"`
+	UserID     uint           `gorm:"not null;index"`
+	Token      string         `gorm:"not null;uniqueIndex"`
+	Platform   DevicePlatform `gorm:"type:varchar(10);not null"`
+	AppVersion string         `gorm:"type:varchar(30);not null;default:''"`
+	Name       string         `gorm:"type:varchar(100);not null;default:''"`
+	DisabledAt *time.Time     `gorm:"index"`
+	// LastSeenAt moves whenever the app registers or refreshes its token
+	LastSeenAt time.Time `gorm:"not null;index"`
+	CreatedAt  time.Time `gorm:"not null"`
+	UpdatedAt  time.Time `gorm:"not null"`
+}
+
+func (d *Device) ToDTO() *dto.DeviceDTO {
+	return &dto.DeviceDTO{
+		ID:         d.ID,
+		Platform:   string(d.Platform),
+		AppVersion: d.AppVersion,
+		Name:       d.Name,
+		Active:     d.DisabledAt == nil,
+		LastSeenAt: d.LastSeenAt,
+		CreatedAt:  d.CreatedAt,
+	}
+}
@@ -0,0 +1,8 @@
+package models
+
+var Models = []interface{}{
+	&Notification{},
+	&NotificationDelivery{},
+	&NotificationPreference{},
+	&Device{},
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"resq/pkg/dto"
+	"time"
+)
+
+type NotificationPriority string
+
+const (
+	NotificationNormal NotificationPriority = "normal"
+	// NotificationCritical is delivered during quiet hours too
+	NotificationCritical NotificationPriority = "critical"
+)
+
+// Notification is one message to a user, rendered once and sent on every channel they allow
+type Notification struct {
+	ID uint `gorm:"primaryKey"`
+	// UserID is empty for someone without an account who was reached at an address
+	UserID   *uint                `gorm:"index:idx_notifications_user,priority:1"`
+	Template string               `gorm:"type:varchar(50);not null"`
+	Title    string               `gorm:"not null"`
+	Body     string               `gorm:"type:text;not null"`
+	Data     map[string]string    `gorm:"type:jsonb;serializer:json"`
+	Priority NotificationPriority `gorm:"type:varchar(20);not null;default:'normal'"`
+	ReadAt   *time.Time
+	// Deliveries are the sends on each channel
+	Deliveries []NotificationDelivery `gorm:"foreignKey:NotificationID"`
+	CreatedAt  time.Time              `gorm:"not null;index:idx_notifications_user,priority:2"`
+}
+
+func (n *Notification) ToDTO() *dto.NotificationDTO {
+	return &dto.NotificationDTO{
+		ID:        n.ID,
+		Template:  n.Template,
+		Title:     n.Title,
+		Body:      n.Body,
+		Data:      n.Data,
+		Priority:  string(n.Priority),
+		Read:      n.ReadAt != nil,
+		ReadAt:    n.ReadAt,
+		CreatedAt: n.CreatedAt,
+	}
+}
@@ -0,0 +1,41 @@
+package models
+
+import "time"
+
+type NotificationChannel string
+
+const (
+	NotificationPush  NotificationChannel = "push"
+	NotificationSMS   NotificationChannel = "sms"
+	NotificationEmail NotificationChannel = "email"
+)
+
+type NotificationDeliveryStatus string
+
+const (
+	NotificationDeliveryPending NotificationDeliveryStatus = "pending"
+	NotificationDeliverySent    NotificationDeliveryStatus = "sent"
+	NotificationDeliveryFailed  NotificationDeliveryStatus = "failed"
+)
+
+// NotificationDelivery is a notification on its way over one channel to one address.
+// ScheduledFor is later than the notification when it waits for the user's quiet hours to end.
+type NotificationDelivery struct {
+	ID             uint                       `gorm:"primaryKey"`
+	NotificationID uint                       `gorm:"not null;index"`
+	Notification   Notification               `gorm:"foreignKey:NotificationID"`
+	Channel        NotificationChannel        `gorm:"type:varchar(10);not null"`
+	Address        string                     `gorm:"not null"`
+	DeviceID       *uint                      `gorm:"index"`
+	Status         NotificationDeliveryStatus `gorm:"type:varchar(20);not null;default:'pending';index"`
+	Attempts       int                        `gorm:"not null;default:0"`
+	// Receipt is the provider's ID of the sent message, what delivery reports refer to
+	Receipt   string
+	LastError string
+	// ReceiptCheckedAt is set once the provider's delivery report for Receipt was looked at
+	ReceiptCheckedAt *time.Time
+	ScheduledFor     time.Time  `gorm:"not null"`
+	SentAt           *time.Time `gorm:"index"`
+	CreatedAt        time.Time  `gorm:"not null"`
+	UpdatedAt        time.Time  `gorm:"not null"`
+}
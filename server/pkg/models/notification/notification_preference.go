@@ -0,0 +1,86 @@
+package models
+
+import (
+	"fmt"
+	"resq/pkg/dto"
+	"time"
+)
+
+// NotificationPreference is which channels a user gets notifications on and when to hold them back.
+// Quiet hours are minutes after midnight in TimeZone, a window may wrap past midnight.
+type NotificationPreference struct {
+	UserID          uint `gorm:"primaryKey"`
+	Push            bool `gorm:"not null;default:true"`
+	SMS             bool `gorm:"not null;default:true"`
+	Email           bool `gorm:"not null;default:true"`
+	QuietHoursStart *int
+	QuietHoursEnd   *int
+	TimeZone        string    `gorm:"type:varchar(64);not null;default:'UTC'"`
+	UpdatedAt       time.Time `gorm:"not null"`
+}
+
+// DefaultPreference is what a user who never changed their preferences gets
+func DefaultPreference(userId uint) *NotificationPreference {
+	return &NotificationPreference{UserID: userId, Push: true, SMS: true, Email: true, TimeZone: "UTC"}
+}
+
+// Allows reports whether the user wants notifications on channel
+func (p *NotificationPreference) Allows(channel NotificationChannel) bool {
+	switch channel {
+	case NotificationPush:
+		return p.Push
+	case NotificationSMS:
+		return p.SMS
+	case NotificationEmail:
+		return p.Email
+	default:
+		return false
+	}
+}
+
+// QuietUntil is when the quiet hours around now end, false when now is outside them
+func (p *NotificationPreference) QuietUntil(now time.Time) (time.Time, bool) {
+	if p.QuietHoursStart == nil || p.QuietHoursEnd == nil || *p.QuietHoursStart == *p.QuietHoursEnd {
+		return time.Time{}, false
+	}
+
+	location, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		location = time.UTC
+	}
+	local := now.In(location)
+	minute := local.Hour()*60 + local.Minute()
+	start, end := *p.QuietHoursStart, *p.QuietHoursEnd
+
+	quiet := minute >= start && minute < end
+	if start > end {
+		quiet = minute >= start || minute < end
+	}
+	if !quiet {
+		return time.Time{}, false
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, location)
+	if !until.After(local) {
+		until = until.AddDate(0, 0, 1)
+	}
+	return until, true
+}
+
+func (p *NotificationPreference) ToDTO() *dto.NotificationPreferenceDTO {
+	return &dto.NotificationPreferenceDTO{
+		Push:            p.Push,
+		SMS:             p.SMS,
+		Email:           p.Email,
+		QuietHoursStart: formatClock(p.QuietHoursStart),
+		QuietHoursEnd:   formatClock(p.QuietHoursEnd),
+		TimeZone:        p.TimeZone,
+	}
+}
+
+func formatClock(minutes *int) string {
+	if minutes == nil {
+		return ""
+	}
+	return fmt.Sprintf("%02d:%02d", *minutes/60, *minutes%60)
+}
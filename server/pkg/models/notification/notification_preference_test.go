@@ -0,0 +1,100 @@
+package models
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func clock(hour, minute int) *int {
+	minutes := hour*60 + minute
+	return &minutes
+}
+
+func TestQuietUntil(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatalf("unable to load time zone: %v", err)
+	}
+	nairobi, err := time.LoadLocation("Africa/Nairobi")
+	if err != nil {
+		t.Fatalf("unable to load time zone: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		start    *int
+		end      *int
+		timeZone string
+		now      time.Time
+		until    time.Time
+		quiet    bool
+	}{
+		{
+			name: "no quiet hours", timeZone: "UTC",
+			now: time.Date(2026, 3, 10, 23, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "empty window", start: clock(22, 0), end: clock(22, 0), timeZone: "UTC",
+			now: time.Date(2026, 3, 10, 22, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "inside a daytime window", start: clock(13, 0), end: clock(15, 30), timeZone: "UTC",
+			now:   time.Date(2026, 3, 10, 14, 0, 0, 0, time.UTC),
+			until: time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC), quiet: true,
+		},
+		{
+			name: "end of a daytime window", start: clock(13, 0), end: clock(15, 30), timeZone: "UTC",
+			now: time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "before midnight in a window past midnight", start: clock(22, 0), end: clock(7, 0), timeZone: "UTC",
+			now:   time.Date(2026, 3, 10, 23, 15, 0, 0, time.UTC),
+			until: time.Date(2026, 3, 11, 7, 0, 0, 0, time.UTC), quiet: true,
+		},
+		{
+			name: "after midnight in a window past midnight", start: clock(22, 0), end: clock(7, 0), timeZone: "UTC",
+			now:   time.Date(2026, 3, 11, 2, 0, 0, 0, time.UTC),
+			until: time.Date(2026, 3, 11, 7, 0, 0, 0, time.UTC), quiet: true,
+		},
+		{
+			name: "outside a window past midnight", start: clock(22, 0), end: clock(7, 0), timeZone: "UTC",
+			now: time.Date(2026, 3, 11, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "local time decides", start: clock(22, 0), end: clock(7, 0), timeZone: "Africa/Nairobi",
+			// 20:30 UTC is 23:30 in Nairobi
+			now:   time.Date(2026, 3, 10, 20, 30, 0, 0, time.UTC),
+			until: time.Date(2026, 3, 11, 7, 0, 0, 0, nairobi), quiet: true,
+		},
+		{
+			name: "quiet in UTC but not locally", start: clock(22, 0), end: clock(7, 0), timeZone: "Africa/Nairobi",
+			// 05:00 UTC is 08:00 in Nairobi
+			now: time.Date(2026, 3, 11, 5, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "night the clocks go forward", start: clock(22, 0), end: clock(7, 0), timeZone: "Europe/Berlin",
+			// 23:00 UTC on 28 March is midnight in Berlin, summer time starts at 02:00 that night
+			now:   time.Date(2026, 3, 28, 23, 0, 0, 0, time.UTC),
+			until: time.Date(2026, 3, 29, 7, 0, 0, 0, berlin), quiet: true,
+		},
+		{
+			name: "unknown time zone falls back to UTC", start: clock(22, 0), end: clock(7, 0), timeZone: "Mars/Olympus_Mons",
+			now:   time.Date(2026, 3, 10, 23, 0, 0, 0, time.UTC),
+			until: time.Date(2026, 3, 11, 7, 0, 0, 0, time.UTC), quiet: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			preference := &NotificationPreference{QuietHoursStart: test.start, QuietHoursEnd: test.end, TimeZone: test.timeZone}
+			until, quiet := preference.QuietUntil(test.now)
+			if quiet != test.quiet {
+				t.Fatalf("QuietUntil(%v) quiet = %v, want %v", test.now, quiet, test.quiet)
+			}
+			if !until.Equal(test.until) {
+				t.Errorf("QuietUntil(%v) = %v, want %v", test.now, until, test.until)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OutboxEvent is a domain event written in the same transaction as the change it describes.
+// The relay hands it to the event bus afterwards, so an event exists exactly when its change was committed.
+type OutboxEvent struct {
+	ID            uint            `gorm:"primaryKey;index:idx_outbox_events_pending,where:published_at IS NULL"`
+	AggregateType string          `gorm:"type:varchar(30);not null"`
+	AggregateID   string          `gorm:"not null"`
+	Topic         string          `gorm:"not null"`
+	Payload       json.RawMessage `gorm:"type:jsonb;serializer:json;not null"`
+	CreatedAt     time.Time       `gorm:"not null"`
+	PublishedAt   *time.Time      `gorm:"index"`
+	// Attempts and LastError are kept while the bus refuses the event
+	Attempts  int `gorm:"not null;default:0"`
+	LastError string
+}
+
+// ProcessedEvent records that a consumer group handled an event, so a redelivered copy is skipped
+type ProcessedEvent struct {
+	ConsumerGroup string    `gorm:"primaryKey"`
+	EventID       string    `gorm:"primaryKey"`
+	ProcessedAt   time.Time `gorm:"not null;index"`
+}
+
+// Record adds an event about the aggregate to the outbox inside tx, it is published once tx commits
+func Record(tx *gorm.DB, topic string, aggregateType string, aggregateId uint, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("unable to encode %s event %w", topic, err)
+	}
+
+	event := &OutboxEvent{
+		AggregateType: aggregateType,
+		AggregateID:   strconv.FormatUint(uint64(aggregateId), 10),
+		Topic:         topic,
+		Payload:       data,
+	}
+	if err := tx.Create(event).Error; err != nil {
+		return fmt.Errorf("unable to record %s event %w", topic, err)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PhoneOTP is a one time passcode texted to a phone number, only its hash is stored
+type PhoneOTP struct {
+	gorm.Model
+	Phone      string    `gorm:"type:varchar(16);not null;index"`
+	CodeHash   string    `gorm:"type:char(64);not null"`
+	ExpiresAt  time.Time `gorm:"not null"`
+	Attempts   int       `gorm:"not null;default:0"`
+	ConsumedAt *time.Time
+}
@@ -0,0 +1,13 @@
+package models
+
+
+var Models = []interface{}{
+	&ReportCategory{},
+	&ReportLocation{},
+	&ReportFile{},
+	&Report{},
+	&ReportStatusHistory{},
+	&ReportUpload{},
+	&ReportFollower{},
+	&ReportComment{},
+}
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"resq/pkg/dto"
 	"resq/pkg/models"
+
 	"gorm.io/gorm"
 )
 
@@ -12,14 +14,63 @@ type Report struct {
 	gorm.Model
 	Title       string         `gorm:"null" json:"title"` //would be processed by AI agent later during analysis
 	Summary     string         `gorm:"null" json:"summary"`
-	Category    []ReportCategory `gorm:"foreignKey:CategoryID" json:"categories"`
+	Category    ReportCategory `gorm:"foreignKey:CategoryID" json:"category"`
 	IsAnonymous bool          `json:"is_anonymous"`
 	CategoryID  uint          `json:"category_id"`
-	ReporterID  uint          `json:"reporter_id"`
-	Status      string        `gorm:"type:enum('pending','in_progress','resolved','rejected');default:'pending'" json:"status"`
+	// ReporterID is cleared when the reporter deletes their account
+	ReporterID  *uint         `gorm:"index" json:"reporter_id"`
+	Status      ReportStatus  `gorm:"type:varchar(20);not null;default:'pending';index" json:"status"`
+	Priority    ReportPriority `gorm:"type:varchar(20);not null;default:'normal';index" json:"priority"`
 	Reporter    models.User   `gorm:"foreignKey:ReporterID" json:"reporter"`
 	Location    ReportLocation `gorm:"foreignKey:LocationID" json:"location"`
 	LocationID  uint          `json:"location_id"`
 	Files       []ReportFile  `gorm:"foreignKey:ReportID" json:"files"`
 	ValidityLevel int         `gorm:"check:validity_level >= 0 AND validity_level <= 5" json:"validity_level"`
 }
+
+func (r *Report) ToDTO() *dto.ReportDTO {
+	report := &dto.ReportDTO{
+		ID:          r.ID,
+		Title:       r.Title,
+		Summary:     r.Summary,
+		IsAnonymous: r.IsAnonymous,
+		Status:      string(r.Status),
+		Priority:    string(r.Priority),
+		Category:    r.Category.ToDTO(),
+		Location:    r.Location.ToDTO(),
+		CreatedAt:   r.CreatedAt,
+		UpdatedAt:   r.UpdatedAt,
+	}
+
+	// anonymous reports never leak who filed them
+	if !r.IsAnonymous && r.ReporterID != nil {
+		reporterId := *r.ReporterID
+		report.ReporterID = &reporterId
+	}
+
+	return report
+}
+
+// IsFiledBy reports whether userId filed the report and still owns it
+func (r *Report) IsFiledBy(userId uint) bool {
+	return r.ReporterID != nil && *r.ReporterID == userId
+}
+
+// IsOnPublicMap reports whether anyone may find the report on the map, the category has to be loaded
+func (r *Report) IsOnPublicMap() bool {
+	return r.Status != ReportStatusRejected && !r.Category.IsPrivate()
+}
+
+// CreatedEvent is the report.created payload, the location has to be loaded
+func (r *Report) CreatedEvent() *dto.ReportCreatedEvent {
+	return &dto.ReportCreatedEvent{
+		ReportID:    r.ID,
+		ReporterID:  r.ReporterID,
+		IsAnonymous: r.IsAnonymous,
+		CategoryID:  r.CategoryID,
+		Priority:    string(r.Priority),
+		Latitude:    r.Location.Latitude,
+		Longitude:   r.Location.Longitude,
+		CreatedAt:   r.CreatedAt,
+	}
+}
@@ -1,9 +1,41 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"resq/pkg/dto"
+
+	"gorm.io/gorm"
+)
+
+// SOSCategoryKey marks the category SOS reports are filed under, it is created on first use when missing
+const SOSCategoryKey = "sos"
+
+// NeedHelpCategoryKey marks the category of reports opened by "need help" check-in answers
+const NeedHelpCategoryKey = "need_help"
+
+// IsPrivate reports whether reports filed under the category stay off the public map
+func (c *ReportCategory) IsPrivate() bool {
+	return c.Private
+}
+
+// IsSystem reports whether the server files reports under the category itself, those cannot be renamed or deleted
+func (c *ReportCategory) IsSystem() bool {
+	return c.SystemKey != ""
+}
 
 type ReportCategory struct {
 	gorm.Model
 	Title string
 	Description string 
+	// SystemKey finds the categories the server files reports under, whatever they are titled
+	SystemKey string `gorm:"not null;default:'';uniqueIndex:idx_report_categories_system_key,where:system_key <> '' AND deleted_at IS NULL"`
+	// Private keeps the category's reports to their reporter and staff, never the public map
+	Private bool `gorm:"not null;default:false"`
+}
+
+func (c *ReportCategory) ToDTO() *dto.ReportCategoryDTO {
+	return &dto.ReportCategoryDTO{
+		ID:          c.ID,
+		Title:       c.Title,
+		Description: c.Description,
+	}
 }
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"resq/pkg/dto"
+	"resq/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+// ReportComment is a note a responder leaves on a report, the reporter and followers read it
+type ReportComment struct {
+	gorm.Model
+	ReportID uint        `gorm:"not null;index"`
+	AuthorID uint        `gorm:"not null"`
+	Author   models.User `gorm:"foreignKey:AuthorID"`
+	Body     string      `gorm:"not null"`
+}
+
+func (c *ReportComment) ToDTO() *dto.ReportCommentDTO {
+	comment := &dto.ReportCommentDTO{
+		ID:        c.ID,
+		ReportID:  c.ReportID,
+		AuthorID:  c.AuthorID,
+		Body:      c.Body,
+		CreatedAt: c.CreatedAt,
+	}
+	if c.Author.ID != 0 {
+		comment.AuthorName = c.Author.FirstName + " " + c.Author.LastName
+	}
+	return comment
+}
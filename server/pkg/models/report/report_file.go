@@ -1,11 +1,50 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"resq/pkg/dto"
+
+	"gorm.io/gorm"
+)
+
+type ReportFileType string
+
+const (
+	ReportFileTypeImage ReportFileType = "image"
+	ReportFileTypeVideo ReportFileType = "video"
+	ReportFileTypeAudio ReportFileType = "audio"
+)
+
+// ReportFileSizeLimits caps each kind of evidence in bytes
+var ReportFileSizeLimits = map[ReportFileType]int64{
+	ReportFileTypeImage: 15 << 20,
+	ReportFileTypeVideo: 250 << 20,
+	ReportFileTypeAudio: 30 << 20,
+}
+
+// MaxReportFileSize is the largest upload any file type allows
+const MaxReportFileSize int64 = 250 << 20
 
 type ReportFile struct {
 	gorm.Model
-	FileType string
-	// FileSize
-	// MediaUrl
+	ReportID     uint           `gorm:"not null;index"`
+	UploaderID   uint           `gorm:"not null"`
+	FileType     ReportFileType `gorm:"type:varchar(10);not null"`
+	MimeType     string         `gorm:"not null"`
+	FileSize     int64          `gorm:"not null"`
+	Sha256       string         `gorm:"type:char(64);not null;index"`
+	StorageKey   string         `gorm:"not null"`
+	OriginalName string
+}
 
+func (f *ReportFile) ToDTO() *dto.ReportFileDTO {
+	return &dto.ReportFileDTO{
+		ID:           f.ID,
+		ReportID:     f.ReportID,
+		FileType:     string(f.FileType),
+		MimeType:     f.MimeType,
+		FileSize:     f.FileSize,
+		Sha256:       f.Sha256,
+		OriginalName: f.OriginalName,
+		CreatedAt:    f.CreatedAt,
+	}
 }
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"fmt"
+	feedModels "resq/pkg/models/feed"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ReportFollower is a user who asked to be told when a report they did not file changes
+type ReportFollower struct {
+	ID        uint      `gorm:"primaryKey"`
+	ReportID  uint      `gorm:"not null;uniqueIndex:idx_report_followers_user,priority:1"`
+	UserID    uint      `gorm:"not null;uniqueIndex:idx_report_followers_user,priority:2;index"`
+	CreatedAt time.Time `gorm:"not null"`
+}
+
+// AudienceOf returns who hears about changes to a report, its reporter and its followers
+func AudienceOf(db *gorm.DB, reportId uint) ([]uint, error) {
+	var userIds []uint
+	result := db.Raw("SELECT reporter_id FROM reports WHERE id = ? AND reporter_id IS NOT NULL "+
+		"UNION SELECT user_id FROM report_followers WHERE report_id = ?", reportId, reportId).
+		Scan(&userIds)
+	return userIds, result.Error
+}
+
+// CreateFeedEvents stores an event about the report in the feed of its audience, inside the caller's transaction
+func CreateFeedEvents(tx *gorm.DB, reportId uint, kind feedModels.FeedEventKind, payload interface{}) ([]feedModels.FeedEvent, error) {
+	userIds, err := AudienceOf(tx, reportId)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find report followers %w", err)
+	}
+	if len(userIds) == 0 {
+		return nil, nil
+	}
+
+	events := feedModels.NewFeedEvents(userIds, kind, &reportId, nil, payload)
+	if err := tx.Create(&events).Error; err != nil {
+		return nil, fmt.Errorf("unable to record feed events %w", err)
+	}
+	return events, nil
+}
@@ -1,8 +1,35 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"resq/pkg/dto"
+	"resq/pkg/utils"
+
+	"gorm.io/gorm"
+)
 
 type ReportLocation struct {
 	gorm.Model
-	
+	Latitude       float64  `gorm:"not null;index:idx_report_locations_coordinates,priority:1" json:"latitude"`
+	Longitude      float64  `gorm:"not null;index:idx_report_locations_coordinates,priority:2" json:"longitude"`
+	AccuracyRadius float64  `gorm:"not null;default:0" json:"accuracy_radius"` // metres
+	Altitude       *float64 `json:"altitude"`                                  // metres above sea level
+	Address        *string  `json:"address"`
+	Geohash        string   `gorm:"type:varchar(12);not null;index" json:"geohash"`
+}
+
+// BeforeSave keeps the geohash in step with the coordinates
+func (l *ReportLocation) BeforeSave(tx *gorm.DB) error {
+	l.Geohash = utils.EncodeGeohash(l.Latitude, l.Longitude, utils.GeohashPrecision)
+	return nil
+}
+
+func (l *ReportLocation) ToDTO() *dto.ReportLocationDTO {
+	return &dto.ReportLocationDTO{
+		Latitude:       l.Latitude,
+		Longitude:      l.Longitude,
+		AccuracyRadius: l.AccuracyRadius,
+		Altitude:       l.Altitude,
+		Address:        l.Address,
+		Geohash:        l.Geohash,
+	}
 }
@@ -0,0 +1,13 @@
+package models
+
+type ReportPriority string
+
+const (
+	ReportPriorityNormal ReportPriority = "normal"
+	// ReportPriorityHigh is given to reports raised from the SOS button
+	ReportPriorityHigh ReportPriority = "high"
+)
+
+func (p ReportPriority) IsValid() bool {
+	return p == ReportPriorityNormal || p == ReportPriorityHigh
+}
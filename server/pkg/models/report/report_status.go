@@ -0,0 +1,105 @@
+package models
+
+import (
+	"resq/pkg/dto"
+	"resq/pkg/models"
+
+	"gorm.io/gorm"
+)
+
+type ReportStatus string
+
+const (
+	ReportStatusPending    ReportStatus = "pending"
+	ReportStatusInProgress ReportStatus = "in_progress"
+	ReportStatusResolved   ReportStatus = "resolved"
+	ReportStatusRejected   ReportStatus = "rejected"
+)
+
+// reportStatusTransitions is the report lifecycle, resolved and rejected reports go back to pending when reopened
+var reportStatusTransitions = map[ReportStatus][]ReportStatus{
+	ReportStatusPending:    {ReportStatusInProgress, ReportStatusRejected},
+	ReportStatusInProgress: {ReportStatusResolved, ReportStatusRejected},
+	ReportStatusResolved:   {ReportStatusPending},
+	ReportStatusRejected:   {ReportStatusPending},
+}
+
+func (s ReportStatus) IsValid() bool {
+	_, exists := reportStatusTransitions[s]
+	return exists
+}
+
+func (s ReportStatus) CanTransitionTo(next ReportStatus) bool {
+	for _, allowed := range reportStatusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// PathTo is the shortest way through the lifecycle from s to next without reopening the report on the way,
+// it is empty when s already is next and nil when next cannot be reached
+func (s ReportStatus) PathTo(next ReportStatus) []ReportStatus {
+	if s == next {
+		return []ReportStatus{}
+	}
+
+	previous := map[ReportStatus]ReportStatus{s: s}
+	queue := []ReportStatus{s}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, candidate := range reportStatusTransitions[current] {
+			if _, seen := previous[candidate]; seen || candidate == ReportStatusPending {
+				continue
+			}
+			previous[candidate] = current
+			if candidate != next {
+				queue = append(queue, candidate)
+				continue
+			}
+
+			var path []ReportStatus
+			for step := next; step != s; step = previous[step] {
+				path = append([]ReportStatus{step}, path...)
+			}
+			return path
+		}
+	}
+	return nil
+}
+
+type ReportStatusHistory struct {
+	gorm.Model
+	ReportID   uint         `gorm:"not null;index" json:"report_id"`
+	FromStatus ReportStatus `gorm:"type:varchar(20);not null" json:"from_status"`
+	ToStatus   ReportStatus `gorm:"type:varchar(20);not null" json:"to_status"`
+	ActorID    uint         `gorm:"not null" json:"actor_id"`
+	Actor      models.User  `gorm:"foreignKey:ActorID" json:"actor"`
+	Reason     string       `json:"reason"`
+}
+
+func (h *ReportStatusHistory) ToDTO() *dto.ReportStatusHistoryDTO {
+	return &dto.ReportStatusHistoryDTO{
+		ID:         h.ID,
+		FromStatus: string(h.FromStatus),
+		ToStatus:   string(h.ToStatus),
+		ActorID:    h.ActorID,
+		Reason:     h.Reason,
+		CreatedAt:  h.CreatedAt,
+	}
+}
+
+// ChangedEvent is the report.status_changed payload
+func (h *ReportStatusHistory) ChangedEvent(reporterId *uint) *dto.ReportStatusChangedEvent {
+	return &dto.ReportStatusChangedEvent{
+		ReportID:   h.ReportID,
+		ReporterID: reporterId,
+		FromStatus: string(h.FromStatus),
+		ToStatus:   string(h.ToStatus),
+		ActorID:    h.ActorID,
+		Reason:     h.Reason,
+		ChangedAt:  h.CreatedAt,
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"resq/pkg/dto"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type ReportUploadStatus string
+
+const (
+	ReportUploadStatusUploading ReportUploadStatus = "uploading"
+	ReportUploadStatusCompleted ReportUploadStatus = "completed"
+	ReportUploadStatusFailed    ReportUploadStatus = "failed"
+)
+
+// ReportUpload tracks a resumable chunked upload, chunks are appended to a staging file until ReceivedSize reaches TotalSize
+type ReportUpload struct {
+	gorm.Model
+	UploadID     string `gorm:"type:varchar(64);not null;uniqueIndex"`
+	ReportID     uint   `gorm:"not null;index"`
+	UploaderID   uint   `gorm:"not null"`
+	FileName     string
+	TotalSize    int64              `gorm:"not null"`
+	ReceivedSize int64              `gorm:"not null;default:0"`
+	Status       ReportUploadStatus `gorm:"type:varchar(20);not null;default:'uploading'"`
+	ReportFileID *uint
+	ReportFile   *ReportFile `gorm:"foreignKey:ReportFileID"`
+	ExpiresAt    time.Time   `gorm:"not null;index"`
+}
+
+func (u *ReportUpload) ToDTO() *dto.ReportUploadDTO {
+	upload := &dto.ReportUploadDTO{
+		UploadID:     u.UploadID,
+		ReportID:     u.ReportID,
+		FileName:     u.FileName,
+		TotalSize:    u.TotalSize,
+		ReceivedSize: u.ReceivedSize,
+		Status:       string(u.Status),
+		ExpiresAt:    u.ExpiresAt,
+	}
+
+	if u.ReportFile != nil {
+		upload.File = u.ReportFile.ToDTO()
+	}
+	return upload
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"resq/pkg/dto"
+
+	"gorm.io/gorm"
+)
+
+// MaxSavedPlaces is how many places, like home or work, a user may keep alerts coming for
+const MaxSavedPlaces = 10
+
+// SavedPlace is a place a user wants alerts for wherever their phone is
+type SavedPlace struct {
+	gorm.Model
+	UserID    uint    `gorm:"not null;index"`
+	Name      string  `gorm:"not null"`
+	Latitude  float64 `gorm:"not null;index:idx_saved_places_coordinates,priority:1"`
+	Longitude float64 `gorm:"not null;index:idx_saved_places_coordinates,priority:2"`
+}
+
+func (p *SavedPlace) ToDTO() *dto.SavedPlaceDTO {
+	return &dto.SavedPlaceDTO{
+		ID:        p.ID,
+		Name:      p.Name,
+		Latitude:  p.Latitude,
+		Longitude: p.Longitude,
+		CreatedAt: p.CreatedAt,
+	}
+}
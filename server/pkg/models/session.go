@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is stored hashed, every refresh revokes the presented token and issues the next one in the same family
+type RefreshToken struct {
+	gorm.Model
+	UserID    uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"type:char(64);not null;uniqueIndex"`
+	FamilyID  string    `gorm:"type:varchar(64);not null;index"`
+	ExpiresAt time.Time `gorm:"not null"`
+	RevokedAt *time.Time
+}
+
+// RevokedToken denies access tokens before they expire, either one token by JTI
+// or every token a user was issued before IssuedBefore
+type RevokedToken struct {
+	gorm.Model
+	JTI          string `gorm:"type:varchar(64);index"`
+	UserID       uint   `gorm:"not null;index"`
+	IssuedBefore *time.Time
+	ExpiresAt    time.Time `gorm:"not null;index"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"resq/pkg/dto"
+	"time"
+)
+
+// SOSPing is one location fix streamed by the phone, pings are kept for the incident record
+type SOSPing struct {
+	ID             uint    `gorm:"primarykey"`
+	SessionID      uint    `gorm:"not null;index"`
+	Latitude       float64 `gorm:"not null"`
+	Longitude      float64 `gorm:"not null"`
+	AccuracyRadius float64 `gorm:"not null;default:0"`
+	Altitude       *float64
+	Speed          *float64
+	Heading        *float64
+	BatteryLevel   *int
+	RecordedAt     time.Time `gorm:"not null"`
+	CreatedAt      time.Time
+}
+
+func (p *SOSPing) ToDTO() *dto.SOSPingDTO {
+	return &dto.SOSPingDTO{
+		ID:             p.ID,
+		Latitude:       p.Latitude,
+		Longitude:      p.Longitude,
+		AccuracyRadius: p.AccuracyRadius,
+		Altitude:       p.Altitude,
+		Speed:          p.Speed,
+		Heading:        p.Heading,
+		BatteryLevel:   p.BatteryLevel,
+		RecordedAt:     p.RecordedAt,
+	}
+}
@@ -0,0 +1,69 @@
+package models
+
+import (
+	"resq/pkg/dto"
+	"resq/pkg/models"
+	reportModels "resq/pkg/models/report"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SOSStatus string
+
+const (
+	SOSActive    SOSStatus = "active"
+	SOSCancelled SOSStatus = "cancelled"
+	SOSResolved  SOSStatus = "resolved"
+)
+
+// SOSMaxCancelAttempts wrong PINs end the owner's ability to cancel, only a responder can close the session then
+const SOSMaxCancelAttempts = 5
+
+// SOSSession is the live part of an SOS, the report it opened is the incident record
+type SOSSession struct {
+	gorm.Model
+	// a user has at most one active session, pressing SOS again returns it
+	UserID         uint                `gorm:"not null;index;uniqueIndex:idx_sos_sessions_active_user,where:status = 'active' AND deleted_at IS NULL"`
+	User           models.User         `gorm:"foreignKey:UserID"`
+	ReportID       uint                `gorm:"not null;index"`
+	Report         reportModels.Report `gorm:"foreignKey:ReportID"`
+	Status         SOSStatus           `gorm:"type:varchar(20);not null;default:'active';index"`
+	Message        string
+	PinHash        string `gorm:"not null" json:"-"`
+	CancelAttempts int    `gorm:"not null;default:0"`
+	LastLatitude   float64
+	LastLongitude  float64
+	LastPingAt     *time.Time
+	EndedAt        *time.Time
+	EndedByID      *uint
+	EndReason      string
+}
+
+func (s *SOSSession) IsActive() bool {
+	return s.Status == SOSActive
+}
+
+func (s *SOSSession) ToDTO() *dto.SOSSessionDTO {
+	session := &dto.SOSSessionDTO{
+		ID:         s.ID,
+		UserID:     s.UserID,
+		ReportID:   s.ReportID,
+		Status:     string(s.Status),
+		Message:    s.Message,
+		LastPingAt: s.LastPingAt,
+		StartedAt:  s.CreatedAt,
+		EndedAt:    s.EndedAt,
+		EndReason:  s.EndReason,
+	}
+
+	if s.User.ID != 0 {
+		session.FirstName = s.User.FirstName
+		session.LastName = s.User.LastName
+	}
+
+	if s.LastPingAt != nil {
+		session.LastLocation = &dto.SOSLocationDTO{Latitude: s.LastLatitude, Longitude: s.LastLongitude}
+	}
+	return session
+}
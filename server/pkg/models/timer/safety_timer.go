@@ -0,0 +1,63 @@
+package models
+
+import (
+	"resq/pkg/dto"
+	"resq/pkg/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type SafetyTimerStatus string
+
+const (
+	SafetyTimerArmed     SafetyTimerStatus = "armed"
+	SafetyTimerDisarmed  SafetyTimerStatus = "disarmed"
+	SafetyTimerEscalated SafetyTimerStatus = "escalated"
+)
+
+// SafetyTimer is a "check in by DueAt or alert my contacts" switch.
+// It lives in the database so a restart neither loses it nor lets it lapse unnoticed.
+type SafetyTimer struct {
+	gorm.Model
+	// a user has at most one armed timer
+	UserID uint              `gorm:"not null;index;uniqueIndex:idx_safety_timers_armed_user,where:status = 'armed' AND deleted_at IS NULL"`
+	User   models.User       `gorm:"foreignKey:UserID"`
+	Status SafetyTimerStatus `gorm:"type:varchar(20);not null;default:'armed';index:idx_safety_timers_due,priority:1"`
+	DueAt  time.Time         `gorm:"not null;index:idx_safety_timers_due,priority:2"`
+	// Note tells contacts what the user was doing, e.g. the route they were walking
+	Note           string
+	Extensions     int `gorm:"not null;default:0"`
+	ReminderSentAt *time.Time
+	EscalatedAt    *time.Time
+	// the last known location when the timer lapsed, as sent to the contacts
+	EscalatedLatitude  *float64
+	EscalatedLongitude *float64
+	DisarmedAt         *time.Time
+}
+
+func (t *SafetyTimer) IsArmed() bool {
+	return t.Status == SafetyTimerArmed
+}
+
+func (t *SafetyTimer) ToDTO() *dto.SafetyTimerDTO {
+	timer := &dto.SafetyTimerDTO{
+		ID:          t.ID,
+		Status:      string(t.Status),
+		DueAt:       t.DueAt,
+		Note:        t.Note,
+		Extensions:  t.Extensions,
+		CreatedAt:   t.CreatedAt,
+		EscalatedAt: t.EscalatedAt,
+		DisarmedAt:  t.DisarmedAt,
+	}
+
+	if t.IsArmed() {
+		remaining := int64(time.Until(t.DueAt).Seconds())
+		if remaining < 0 {
+			remaining = 0
+		}
+		timer.SecondsRemaining = &remaining
+	}
+	return timer
+}
@@ -1,7 +1,9 @@
 package models
 
 import (
+	"resq/pkg/constants"
 	"resq/pkg/dto"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -12,10 +14,22 @@ import (
 
 type User struct {
 	gorm.Model
-	Email     string `gorm:"not null;unique;index" json:"email" binding:"required"`
+	// Email and Phone are both optional in the table, an account needs at least one of them.
+	// They are only unique among live accounts so a deleted account does not block signing up again.
+	Email     string `gorm:"not null;default:'';uniqueIndex:idx_users_live_email,where:email <> '' AND deleted_at IS NULL" json:"email" binding:"required"`
+	Phone     string `gorm:"type:varchar(16);not null;default:'';uniqueIndex:idx_users_live_phone,where:phone <> '' AND deleted_at IS NULL" json:"-"`
 	FirstName string `gorm:"not null" json:"first_name" binding:"required"`
 	LastName  string `gorm:"not null" json:"last_name" binding:"required"`
 	Password  string `gorm:"not null" json:"password" binding:"required"`
+	// Role is never bound from a request body, new accounts are always citizens
+	Role      constants.Role `gorm:"type:varchar(20);not null;default:'citizen'" json:"-"`
+	EmailVerifiedAt *time.Time `json:"-"`
+	PhoneVerifiedAt *time.Time `json:"-"`
+}
+
+// IsVerified reports whether the user proved they own a contact channel
+func (u *User) IsVerified() bool {
+	return u.EmailVerifiedAt != nil || u.PhoneVerifiedAt != nil
 }
 
 func (u *User) ToDTO() *dto.UserDTO{
@@ -24,5 +38,21 @@ func (u *User) ToDTO() *dto.UserDTO{
 		Email:     u.Email,
 		FirstName: u.FirstName,
 		LastName:  u.LastName,
+		Role:      string(u.Role),
+		EmailVerified: u.EmailVerifiedAt != nil,
+		Phone:         u.Phone,
+		PhoneVerified: u.PhoneVerifiedAt != nil,
+		HasPassword:   u.Password != "",
+		CreatedAt:     u.CreatedAt,
+	}
+}
+
+// RegisteredEvent is the user.registered payload
+func (u *User) RegisteredEvent() *dto.UserRegisteredEvent {
+	return &dto.UserRegisteredEvent{
+		UserID:       u.ID,
+		Email:        u.Email,
+		Role:         string(u.Role),
+		RegisteredAt: u.CreatedAt,
 	}
 }
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+// UserLocation is the last place a user's phone reported, one row per user.
+// Check-ins and area alerts find people through it, so only the latest fix is kept.
+type UserLocation struct {
+	ID             uint      `gorm:"primarykey"`
+	UserID         uint      `gorm:"not null;uniqueIndex"`
+	Latitude       float64   `gorm:"not null;index:idx_user_locations_coordinates,priority:1"`
+	Longitude      float64   `gorm:"not null;index:idx_user_locations_coordinates,priority:2"`
+	AccuracyRadius float64   `gorm:"not null;default:0"`
+	RecordedAt     time.Time `gorm:"not null;index"`
+	UpdatedAt      time.Time
+}
+
+// UpsertUserLocation is the conflict clause for saving a UserLocation, an older fix never replaces a newer one
+var UpsertUserLocation = clause.OnConflict{
+	Columns:   []clause.Column{{Name: "user_id"}},
+	DoUpdates: clause.AssignmentColumns([]string{"latitude", "longitude", "accuracy_radius", "recorded_at", "updated_at"}),
+	Where: clause.Where{Exprs: []clause.Expression{
+		clause.Expr{SQL: "user_locations.recorded_at < excluded.recorded_at"},
+	}},
+}
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type UserTokenPurpose string
+
+const (
+	UserTokenEmailVerification UserTokenPurpose = "email_verification"
+	UserTokenPasswordReset     UserTokenPurpose = "password_reset"
+)
+
+// UserToken is a single use emailed token, only its hash is stored
+type UserToken struct {
+	gorm.Model
+	UserID    uint             `gorm:"not null;index"`
+	Purpose   UserTokenPurpose `gorm:"type:varchar(30);not null"`
+	TokenHash string           `gorm:"type:char(64);not null;uniqueIndex"`
+	ExpiresAt time.Time        `gorm:"not null;index"`
+	UsedAt    *time.Time
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"errors"
+	"resq/pkg/constants"
+	"resq/pkg/dto"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetAuthenticatedUserId reads the user id the auth middleware stored on the request
+func GetAuthenticatedUserId(ctx *gin.Context) (uint, error) {
+	userIDStr, exists := ctx.Get("user_id")
+	if !exists || userIDStr == nil {
+		return 0, errors.New("unauthorized")
+	}
+
+	userIdStr, ok := userIDStr.(string)
+	if !ok {
+		return 0, errors.New("invalid user id")
+	}
+
+	return ParseUserId(userIdStr)
+}
+
+// GetAuthenticatedUser bundles the id, role and permissions the auth middleware stored on the request
+func GetAuthenticatedUser(ctx *gin.Context) (*dto.AuthenticatedUserDTO, error) {
+	userId, err := GetAuthenticatedUserId(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.AuthenticatedUserDTO{
+		ID:          userId,
+		Role:        ctx.GetString("role"),
+		Permissions: ctx.GetStringSlice("permissions"),
+	}, nil
+}
+
+func HasPermission(ctx *gin.Context, permission constants.Permission) bool {
+	for _, granted := range ctx.GetStringSlice("permissions") {
+		if granted == string(permission) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package utils
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	EarthRadiusKm = 6371.0
+
+	// GeohashPrecision gives cells of roughly 5m x 5m
+	GeohashPrecision = 9
+
+	geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+)
+
+type BoundingBox struct {
+	MinLatitude  float64
+	MinLongitude float64
+	MaxLatitude  float64
+	MaxLongitude float64
+}
+
+func degreesToRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func radiansToDegrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+// HaversineKm returns the great-circle distance between two points in kilometres
+func HaversineKm(lat1, lng1, lat2, lng2 float64) float64 {
+	dLat := degreesToRadians(lat2 - lat1)
+	dLng := degreesToRadians(lng2 - lng1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(degreesToRadians(lat1))*math.Cos(degreesToRadians(lat2))*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * EarthRadiusKm * math.Asin(math.Min(1, math.Sqrt(a)))
+}
+
+// RadiusBoundingBox returns the smallest box containing every point within radiusKm of the centre.
+// When the box crosses the antimeridian MinLongitude is greater than MaxLongitude.
+func RadiusBoundingBox(lat, lng, radiusKm float64) BoundingBox {
+	angularRadius := radiusKm / EarthRadiusKm
+	minLat := lat - radiansToDegrees(angularRadius)
+	maxLat := lat + radiansToDegrees(angularRadius)
+
+	// the circle covers a pole, every longitude is in range
+	if minLat <= -90 || maxLat >= 90 {
+		return BoundingBox{
+			MinLatitude:  math.Max(minLat, -90),
+			MinLongitude: -180,
+			MaxLatitude:  math.Min(maxLat, 90),
+			MaxLongitude: 180,
+		}
+	}
+
+	deltaLng := radiansToDegrees(math.Asin(math.Sin(angularRadius) / math.Cos(degreesToRadians(lat))))
+
+	return BoundingBox{
+		MinLatitude:  minLat,
+		MinLongitude: normalizeLongitude(lng - deltaLng),
+		MaxLatitude:  maxLat,
+		MaxLongitude: normalizeLongitude(lng + deltaLng),
+	}
+}
+
+func normalizeLongitude(lng float64) float64 {
+	for lng < -180 {
+		lng += 360
+	}
+	for lng > 180 {
+		lng -= 360
+	}
+	return lng
+}
+
+// CrossesAntimeridian reports whether the box wraps around longitude 180
+func (b BoundingBox) CrossesAntimeridian() bool {
+	return b.MinLongitude > b.MaxLongitude
+}
+
+func (b BoundingBox) Contains(lat, lng float64) bool {
+	if lat < b.MinLatitude || lat > b.MaxLatitude {
+		return false
+	}
+	if b.CrossesAntimeridian() {
+		return lng >= b.MinLongitude || lng <= b.MaxLongitude
+	}
+	return lng >= b.MinLongitude && lng <= b.MaxLongitude
+}
+
+// GeoPoint is one vertex of a polygon
+type GeoPoint struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// PolygonBoundingBox returns the smallest box around the polygon, polygons are not expected to cross the antimeridian
+func PolygonBoundingBox(polygon []GeoPoint) BoundingBox {
+	box := BoundingBox{MinLatitude: 90, MinLongitude: 180, MaxLatitude: -90, MaxLongitude: -180}
+	for _, point := range polygon {
+		box.MinLatitude = math.Min(box.MinLatitude, point.Latitude)
+		box.MaxLatitude = math.Max(box.MaxLatitude, point.Latitude)
+		box.MinLongitude = math.Min(box.MinLongitude, point.Longitude)
+		box.MaxLongitude = math.Max(box.MaxLongitude, point.Longitude)
+	}
+	return box
+}
+
+// PolygonContains tells whether the point is inside the polygon using ray casting on plain coordinates,
+// which is close enough for the city sized areas alerts cover. The polygon is closed implicitly.
+func PolygonContains(polygon []GeoPoint, lat, lng float64) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Latitude > lat) != (b.Latitude > lat) &&
+			lng < (b.Longitude-a.Longitude)*(lat-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+func ValidateCoordinates(lat, lng float64) bool {
+	return lat >= -90 && lat <= 90 && lng >= -180 && lng <= 180
+}
+
+// EncodeGeohash encodes a point as a base32 geohash of the given length
+func EncodeGeohash(lat, lng float64, precision int) string {
+	var hash strings.Builder
+
+	minLat, maxLat := -90.0, 90.0
+	minLng, maxLng := -180.0, 180.0
+	bit, ch := 0, 0
+	evenBit := true
+
+	for hash.Len() < precision {
+		if evenBit {
+			mid := (minLng + maxLng) / 2
+			if lng >= mid {
+				ch = ch<<1 | 1
+				minLng = mid
+			} else {
+				ch <<= 1
+				maxLng = mid
+			}
+		} else {
+			mid := (minLat + maxLat) / 2
+			if lat >= mid {
+				ch = ch<<1 | 1
+				minLat = mid
+			} else {
+				ch <<= 1
+				maxLat = mid
+			}
+		}
+		evenBit = !evenBit
+
+		bit++
+		if bit == 5 {
+			hash.WriteByte(geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+
+	return hash.String()
+}
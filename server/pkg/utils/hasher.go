@@ -2,22 +2,62 @@ package utils
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"regexp"
 	"strings"
+
 	"golang.org/x/crypto/argon2"
 )
 
-const (
-	iterations   = 1         // Number of iterations
-	memory  = 64 * 1024 // 64MB memory
-	threads = 4         // Number of parallel threads
-	keyLen  = 32        // Length of the derived key
-	saltLen = 16        // Length of the salt
-)
+// Argon2Params are written into every hash, so they can be raised without breaking stored passwords
+type Argon2Params struct {
+	Memory      uint32 // in KiB
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params is what new hashes use, RFC 9106's recommendation for memory constrained hosts
+var DefaultArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// legacyArgon2Params were hard coded for the old "salt$hash" format
+var legacyArgon2Params = Argon2Params{
+	Memory:      64 * 1024,
+	Iterations:  1,
+	Parallelism: 4,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// DummyPasswordHash hashes a password nobody knows with DefaultArgon2Params, verifying against it when there is
+// no account costs as much as a wrong password does. It has to be replaced whenever the defaults change.
+const DummyPasswordHash = "$argon2id$v=19$m=65536,t=3,p=4$xHsuz7CZ40Vjt5W/O2jzGA$pjLjQ1a/bm4Z/YGGYeZNRhZHB+/qv6VVYCFoRr58c54"
+
+var errInvalidPasswordHash = errors.New("invalid password hash")
 
-func GenerateSalt() ([]byte, error) {
-	salt := make([]byte, saltLen)
+var pinPattern = regexp.MustCompile(`^[0-9]{4,8}$`)
+
+type passwordHash struct {
+	params Argon2Params
+	salt   []byte
+	key    []byte
+	legacy bool
+}
+
+func GenerateSalt(length uint32) ([]byte, error) {
+	salt := make([]byte, length)
 	_, err := rand.Read(salt)
 	if err != nil {
 		return nil, err
@@ -25,44 +65,113 @@ func GenerateSalt() ([]byte, error) {
 	return salt, nil
 }
 
+// HashPassword returns a PHC string, $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>
 func HashPassword(password string) (string, error) {
 	validatedPassword, err := ValidatePassword(password)
-
 	if err != nil {
 		return "", err
-	};
+	}
+	return hashSecret(validatedPassword)
+}
+
+// HashPIN hashes a short numeric PIN like a password, it is checked with VerifyPassword
+func HashPIN(pin string) (string, error) {
+	if !pinPattern.MatchString(pin) {
+		return "", errors.New("pin must be 4 to 8 digits")
+	}
+	return hashSecret(pin)
+}
 
-	salt, err := GenerateSalt()
+func hashSecret(secret string) (string, error) {
+	params := DefaultArgon2Params
+	salt, err := GenerateSalt(params.SaltLength)
 	if err != nil {
 		return "", err
 	}
 
-	hash := argon2.IDKey([]byte(validatedPassword), salt, iterations, memory, threads, keyLen)
+	key := argon2.IDKey([]byte(secret), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
 
-	hashedPassword := fmt.Sprintf("%s$%s", base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(hash))
-	return hashedPassword, nil
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key),
+	), nil
 }
 
+// VerifyPassword accepts PHC hashes and the legacy "salt$hash" format, keys are compared in constant time
 func VerifyPassword(password string, storedHash string) bool {
-	parts := strings.Split(storedHash, "$")
-
-	if len(parts) != 2 {
+	hash, err := parsePasswordHash(storedHash)
+	if err != nil {
 		return false
 	}
 
-	salt, err := base64.RawStdEncoding.DecodeString(parts[0])
+	key := argon2.IDKey([]byte(password), hash.salt, hash.params.Iterations, hash.params.Memory, hash.params.Parallelism, uint32(len(hash.key)))
+
+	return subtle.ConstantTimeCompare(key, hash.key) == 1
+}
 
+// PasswordNeedsRehash reports whether a stored hash is in the legacy format or weaker than DefaultArgon2Params
+func PasswordNeedsRehash(storedHash string) bool {
+	hash, err := parsePasswordHash(storedHash)
 	if err != nil {
-		return false
+		return true
+	}
+	if hash.legacy {
+		return true
 	}
 
-	storedKey, err := base64.RawStdEncoding.DecodeString(parts[1])
+	params := DefaultArgon2Params
+	return hash.params.Memory < params.Memory ||
+		hash.params.Iterations < params.Iterations ||
+		hash.params.Parallelism != params.Parallelism ||
+		uint32(len(hash.salt)) < params.SaltLength ||
+		uint32(len(hash.key)) < params.KeyLength
+}
 
+func parsePasswordHash(storedHash string) (*passwordHash, error) {
+	parts := strings.Split(storedHash, "$")
+
+	switch len(parts) {
+	case 2:
+		return decodePasswordHash(legacyArgon2Params, parts[0], parts[1], true)
+	case 6:
+		if parts[0] != "" || parts[1] != "argon2id" {
+			return nil, errInvalidPasswordHash
+		}
+
+		var version int
+		if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+			return nil, errInvalidPasswordHash
+		}
+
+		var params Argon2Params
+		if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+			return nil, errInvalidPasswordHash
+		}
+		if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+			return nil, errInvalidPasswordHash
+		}
+		return decodePasswordHash(params, parts[4], parts[5], false)
+	default:
+		return nil, errInvalidPasswordHash
+	}
+}
+
+func decodePasswordHash(params Argon2Params, encodedSalt string, encodedKey string, legacy bool) (*passwordHash, error) {
+	salt, err := base64.RawStdEncoding.DecodeString(encodedSalt)
 	if err != nil {
-		return false
+		return nil, errInvalidPasswordHash
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(encodedKey)
+	if err != nil || len(key) == 0 {
+		return nil, errInvalidPasswordHash
 	}
 
-	newHash := argon2.IDKey([]byte(password), salt, iterations, memory, threads, keyLen)
+	return &passwordHash{params: params, salt: salt, key: key, legacy: legacy}, nil
+}
 
-	return string(storedKey) == string(newHash)
+// HashToken fingerprints random tokens before they are stored, they carry enough entropy that a fast hash is fine
+func HashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
 }
@@ -0,0 +1,13 @@
+package utils
+
+import "testing"
+
+func TestDummyPasswordHashUsesDefaults(t *testing.T) {
+	// a dummy hash weaker than the real ones would make unknown emails answer faster again
+	if PasswordNeedsRehash(DummyPasswordHash) {
+		t.Fatal("DummyPasswordHash is not hashed with DefaultArgon2Params, generate a new one")
+	}
+	if VerifyPassword("", DummyPasswordHash) {
+		t.Error("DummyPasswordHash accepted an empty password")
+	}
+}
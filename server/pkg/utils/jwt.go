@@ -1,53 +1,105 @@
 package utils
 
 import (
+	"crypto"
+	"errors"
+	"resq/pkg/constants"
 	"time"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// AccessTokenLifetime is kept short, clients use a refresh token to get the next one
+const AccessTokenLifetime = 15 * time.Minute
+
+var ErrNoSigningKey = errors.New("no jwt signing key available")
 
 type Claims struct {
-	UserID string `json:"user_id"`
+	UserID      string   `json:"user_id"`
+	Role        string   `json:"role"`
+	Permissions []string `json:"permissions"`
 	jwt.RegisteredClaims
 }
 
-func GenerateJWT (userID string, secret []byte) (string, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+// SigningKey is one asymmetric key of a key ring, its ID goes into the kid header of every token it signs
+type SigningKey struct {
+	ID         string
+	Method     jwt.SigningMethod
+	PrivateKey crypto.Signer
+}
+
+func (k *SigningKey) PublicKey() crypto.PublicKey {
+	return k.PrivateKey.Public()
+}
+
+// KeyResolver hands out the key new tokens are signed with and finds the key a token names
+type KeyResolver interface {
+	ActiveKey() (*SigningKey, error)
+	Key(kid string) (*SigningKey, bool)
+}
+
+func GenerateJWT(userID string, role string, keys KeyResolver) (string, error) {
+	key, err := keys.ActiveKey()
+	if err != nil {
+		return "", err
+	}
+
 	currentTime := time.Now()
+	expirationTime := currentTime.Add(AccessTokenLifetime)
+
+	tokenId, err := GenerateRandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	permissions := []string{}
+	for _, permission := range constants.Role(role).Permissions() {
+		permissions = append(permissions, string(permission))
+	}
 
 	claims := &Claims{
-		UserID: userID,
+		UserID:      userID,
+		Role:        role,
+		Permissions: permissions,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        tokenId,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
-			IssuedAt: jwt.NewNumericDate(currentTime),
-			Issuer: "resq-server",
+			IssuedAt:  jwt.NewNumericDate(currentTime),
+			Issuer:    "resq-server",
 		},
-
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-
-	tokenString, err := token.SignedString(secret)
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.ID
 
-	if err != nil {
-		return "", err
-	}
-	return tokenString, nil
+	return token.SignedString(key.PrivateKey)
 }
 
-
-// ValidateJWT checks if the token is valid and extracts claims
-func ValidateJWT(tokenString string, secret string) (*Claims, error) {
+// ValidateJWT checks the token against the key named by its kid header and extracts claims,
+// a token signed with any other algorithm than its key's is rejected
+func ValidateJWT(tokenString string, keys KeyResolver) (*Claims, error) {
 	claims := &Claims{}
 
-	// Parse token and validate claims
 	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-		return secret, nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, jwt.ErrTokenUnverifiable
+		}
+
+		key, ok := keys.Key(kid)
+		if !ok || token.Method.Alg() != key.Method.Alg() {
+			return nil, jwt.ErrSignatureInvalid
+		}
+		return key.PublicKey(), nil
+	}, jwt.WithIssuer("resq-server"))
 
-	if err != nil || !token.Valid {
+	if err != nil {
 		return nil, err
 	}
 
+	if !token.Valid {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
 	return claims, nil
 }
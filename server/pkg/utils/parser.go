@@ -69,3 +69,12 @@ func ParseUserId (userId string) (uint, error) {
 
 	return userIDUint, nil
 }
+
+func ParseId (id string) (uint, error) {
+	idUint, err := strconv.ParseUint(id, 10, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	return uint(idUint), nil
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var e164Pattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// NormalizePhone strips the usual formatting characters and checks the result is an E.164 number
+func NormalizePhone(phone string) (string, error) {
+	normalized := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '(', ')', '.':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(phone))
+
+	if strings.HasPrefix(normalized, "00") {
+		normalized = "+" + normalized[2:]
+	}
+
+	if !e164Pattern.MatchString(normalized) {
+		return "", errors.New("phone number must be in E.164 format, e.g. +233201234567")
+	}
+	return normalized, nil
+}
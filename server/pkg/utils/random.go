@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+)
+
+// GenerateRandomToken returns n random bytes hex encoded
+func GenerateRandomToken(n int) (string, error) {
+	token := make([]byte, n)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(token), nil
+}
+
+// GenerateNumericCode returns a uniformly random code of the given number of digits, leading zeros included
+func GenerateNumericCode(digits int) (string, error) {
+	limit := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(digits)), nil)
+	n, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", digits, n), nil
+}
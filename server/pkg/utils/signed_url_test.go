@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var testKey = []byte("test-signing-key")
+
+// signed signs path and splits the result back into the values a request would carry
+func signed(t *testing.T, path string, ttl time.Duration) (string, string, string) {
+	t.Helper()
+	signedURL, _, err := SignURL(path, ttl, testKey)
+	if err != nil {
+		t.Fatalf("SignURL failed: %v", err)
+	}
+	parsed, err := url.Parse(signedURL)
+	if err != nil {
+		t.Fatalf("unable to parse %q: %v", signedURL, err)
+	}
+	return parsed.Path, parsed.Query().Get("expires"), parsed.Query().Get("signature")
+}
+
+func TestSignURL(t *testing.T) {
+	before := time.Now()
+	signedURL, expiresAt, err := SignURL("/reports/12/media/3", 15*time.Minute, testKey)
+	if err != nil {
+		t.Fatalf("SignURL failed: %v", err)
+	}
+
+	if expiresAt.Before(before.Add(15*time.Minute)) || expiresAt.After(time.Now().Add(15*time.Minute)) {
+		t.Errorf("expiresAt = %v, want about 15 minutes from now", expiresAt)
+	}
+	parsed, err := url.Parse(signedURL)
+	if err != nil {
+		t.Fatalf("unable to parse %q: %v", signedURL, err)
+	}
+	if parsed.Path != "/reports/12/media/3" {
+		t.Errorf("path = %q, want /reports/12/media/3", parsed.Path)
+	}
+	if got := parsed.Query().Get("expires"); got != strconv.FormatInt(expiresAt.Unix(), 10) {
+		t.Errorf("expires = %q, want %d", got, expiresAt.Unix())
+	}
+
+	if _, _, err := SignURL("/reports/12/media/3", time.Minute, nil); err == nil {
+		t.Error("SignURL without a key succeeded")
+	}
+}
+
+func TestVerifySignedURL(t *testing.T) {
+	path, expires, signature := signed(t, "/reports/12/media/3", time.Minute)
+	if err := VerifySignedURL(path, expires, signature, testKey); err != nil {
+		t.Fatalf("VerifySignedURL of a fresh URL failed: %v", err)
+	}
+
+	expiredPath, expiredExpires, expiredSignature := signed(t, "/reports/12/media/3", -time.Second)
+	later := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	tampered := []byte(signature)
+	tampered[0] ^= 1
+
+	tests := []struct {
+		name      string
+		path      string
+		expires   string
+		signature string
+		key       []byte
+	}{
+		{"expired", expiredPath, expiredExpires, expiredSignature, testKey},
+		{"other path", "/reports/12/media/4", expires, signature, testKey},
+		{"extended expiry", path, later, signature, testKey},
+		{"tampered signature", path, expires, string(tampered), testKey},
+		{"missing signature", path, expires, "", testKey},
+		{"malformed expiry", path, "soon", signature, testKey},
+		{"other key", path, expires, signature, []byte("another-key")},
+		{"no key", path, expires, signature, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := VerifySignedURL(test.path, test.expires, test.signature, test.key); !errors.Is(err, ErrInvalidSignature) {
+				t.Errorf("VerifySignedURL = %v, want ErrInvalidSignature", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package utils
+
+import "unicode/utf8"
+
+// Truncate cuts text down to at most length bytes without splitting a character
+func Truncate(text string, length int) string {
+	if len(text) <= length {
+		return text
+	}
+	for length > 0 && !utf8.RuneStart(text[length]) {
+		length--
+	}
+	return text[:length]
+}
+
+// MaxErrorLength is how much of an error message is kept in the last_error columns
+const MaxErrorLength = 1000